@@ -0,0 +1,90 @@
+// Package cache provides an optional key-value cache for hot database reads.
+//
+// The cache is strictly an optimization: callers treat every error as a miss and fall back
+// to the database, so an outage only costs performance.
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// ErrMiss is returned by Get when the key is not cached.
+var ErrMiss = errors.New("cache miss")
+
+// Cache stores opaque values with a time to live.
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+}
+
+// Options selects and configures a cache implementation.
+type Options struct {
+	// Driver is "none", "memory" or "redis". An empty driver means "none".
+	Driver string
+	// MaxEntries bounds the in-memory cache.
+	MaxEntries int
+	// RedisAddr, RedisPassword and RedisDB configure the Redis client.
+	RedisAddr     string
+	RedisPassword string
+	RedisDB       int
+}
+
+// New returns the cache selected by opts, wrapped so that its hit/miss ratio is counted.
+func New(opts Options) (Cache, error) {
+	var c Cache
+	switch opts.Driver {
+	case "", "none":
+		c = noop{}
+	case "memory":
+		c = NewMemory(opts.MaxEntries)
+	case "redis":
+		c = NewRedis(opts.RedisAddr, opts.RedisPassword, opts.RedisDB)
+	default:
+		return nil, fmt.Errorf("unknown cache driver %q", opts.Driver)
+	}
+	return instrumented{c}, nil
+}
+
+// Stats counts cache lookups since the process started.
+type Stats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+	Errors uint64 `json:"errors"`
+}
+
+var hits, misses, failures atomic.Uint64
+
+// GetStats returns the lookup counters of every cache created by New.
+func GetStats() Stats {
+	return Stats{Hits: hits.Load(), Misses: misses.Load(), Errors: failures.Load()}
+}
+
+// instrumented counts hits, misses and errors of the wrapped cache.
+type instrumented struct {
+	Cache
+}
+
+func (c instrumented) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := c.Cache.Get(ctx, key)
+	switch {
+	case err == nil:
+		hits.Add(1)
+	case errors.Is(err, ErrMiss):
+		misses.Add(1)
+	default:
+		failures.Add(1)
+	}
+	return value, err
+}
+
+// noop is used when caching is disabled. Every lookup misses.
+type noop struct{}
+
+func (noop) Get(context.Context, string) ([]byte, error)              { return nil, ErrMiss }
+func (noop) Set(context.Context, string, []byte, time.Duration) error { return nil }
+func (noop) Delete(context.Context, string) error                     { return nil }
@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory is a process-local cache. It suits single-instance deployments; with several
+// instances each keeps its own copy and only sees its own invalidations.
+type Memory struct {
+	mu         sync.Mutex
+	entries    map[string]memoryEntry
+	maxEntries int
+}
+
+type memoryEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+// NewMemory creates an in-memory cache holding at most maxEntries values.
+func NewMemory(maxEntries int) *Memory {
+	if maxEntries <= 0 {
+		maxEntries = 10000
+	}
+	return &Memory{entries: map[string]memoryEntry{}, maxEntries: maxEntries}
+}
+
+// Get returns the value stored under key, or ErrMiss.
+func (m *Memory) Get(_ context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.entries[key]
+	if !ok {
+		return nil, ErrMiss
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(m.entries, key)
+		return nil, ErrMiss
+	}
+	return entry.value, nil
+}
+
+// Set stores value under key for ttl.
+func (m *Memory) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.entries[key]; !exists && len(m.entries) >= m.maxEntries {
+		m.evict()
+	}
+	m.entries[key] = memoryEntry{value: value, expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+// Delete removes key.
+func (m *Memory) Delete(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.entries, key)
+	return nil
+}
+
+// evict makes room for one entry, dropping expired entries first and an arbitrary one
+// if none have expired. It must be called with mu held.
+func (m *Memory) evict() {
+	now := time.Now()
+	for key, entry := range m.entries {
+		if now.After(entry.expiresAt) {
+			delete(m.entries, key)
+		}
+	}
+	for key := range m.entries {
+		if len(m.entries) < m.maxEntries {
+			break
+		}
+		delete(m.entries, key)
+	}
+}
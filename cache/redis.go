@@ -0,0 +1,43 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis is a cache shared by every instance that points at the same server.
+type Redis struct {
+	client *redis.Client
+}
+
+// NewRedis creates a Redis-backed cache. The connection is established lazily, so an
+// unreachable server surfaces as errors from Get and Set rather than at startup.
+func NewRedis(addr, password string, db int) *Redis {
+	return &Redis{client: redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: password,
+		DB:       db,
+	})}
+}
+
+// Get returns the value stored under key, or ErrMiss.
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := r.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrMiss
+	}
+	return value, err
+}
+
+// Set stores value under key for ttl.
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return r.client.Set(ctx, key, value, ttl).Err()
+}
+
+// Delete removes key.
+func (r *Redis) Delete(ctx context.Context, key string) error {
+	return r.client.Del(ctx, key).Err()
+}
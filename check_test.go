@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/spf13/viper"
+	"go-share/config"
+	"go-share/selfcheck"
+)
+
+// captureStdout returns what fn prints to standard output.
+func captureStdout(t *testing.T, fn func()) []byte {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	fn()
+	w.Close()
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// With the database unreachable and no public ID key, go-share check prints a report naming
+// what failed and exits 1.
+func TestCheckUnreachableDatabase(t *testing.T) {
+	viper.Reset()
+	config.SetDefaults()
+	viper.Set("database.host", "127.0.0.1")
+	viper.Set("database.port", "1")
+	oldDB := config.DB
+	t.Cleanup(func() { config.DB = oldDB })
+
+	var code int
+	out := captureStdout(t, func() { code = check() })
+	if code != 1 {
+		t.Errorf("exit code %d, want 1", code)
+	}
+
+	var report selfcheck.Report
+	if err := json.Unmarshal(out, &report); err != nil {
+		t.Fatalf("output is not a JSON report: %s\n%s", err, out)
+	}
+	results := map[string]selfcheck.Result{}
+	for _, result := range report.Checks {
+		results[result.Name] = result
+	}
+	if report.Status != selfcheck.Fail {
+		t.Errorf("report status %q, want fail", report.Status)
+	}
+	for _, name := range []string{"database", "migrations", "clock", "public_id_key"} {
+		if result, ok := results[name]; !ok || result.Status != selfcheck.Fail || result.Message == "" {
+			t.Errorf("%s: got %+v, want a failure with a reason", name, result)
+		}
+	}
+	if _, ok := results["jwt_secret"]; !ok {
+		t.Error("the report has no jwt_secret check")
+	}
+}
@@ -3,8 +3,11 @@ package config
 import (
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/spf13/viper"
+	"go-share/cache"
+	"go-share/querystats"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
@@ -12,23 +15,93 @@ import (
 // DB is the global database connection.
 var DB *gorm.DB
 
+// Cache is the global cache for hot reads. It is a no-op cache unless cache.driver is set.
+var Cache cache.Cache
+
 // LoadConfig loads the application configuration from a YAML file.
 func LoadConfig() {
 	viper.SetConfigName("config")
 	viper.AddConfigPath(".")
 	viper.SetConfigType("yaml")
+	SetDefaults()
 
 	if err := viper.ReadInConfig(); err != nil {
 		log.Fatalf("Error reading config file: %s", err)
 	}
 }
 
+// SetDefaults registers the default value of every setting. LoadConfig calls it before
+// reading the file; tests, which run without one, call it directly.
+func SetDefaults() {
+	viper.SetDefault("server.shutdown_timeout", "10s")
+	viper.SetDefault("server.max_request_timeout", "5m")
+	viper.SetDefault("features.registration", true)
+	viper.SetDefault("features.social_login", true)
+	viper.SetDefault("api.legacy_field_names", false)
+	viper.SetDefault("api.numeric_ids", true)
+	viper.SetDefault("api.public_id_key", "")
+	viper.SetDefault("auth.cookie.enabled", false)
+	viper.SetDefault("auth.cookie.secure", true)
+	viper.SetDefault("admin.stats_cache_ttl", "1m")
+	viper.SetDefault("admin.impersonation_ttl", "15m")
+	viper.SetDefault("debug.pprof", "off")
+	viper.SetDefault("debug.pprof_address", "127.0.0.1:6060")
+	viper.SetDefault("files.lock_ttl", "5m")
+	viper.SetDefault("files.lock_max_ttl", "1h")
+	viper.SetDefault("files.download_token_ttl", "2m")
+	viper.SetDefault("files.batch_max_ids", 100)
+	viper.SetDefault("files.grant_max_ttl", "720h")
+	viper.SetDefault("idempotency.ttl", "24h")
+	viper.SetDefault("idempotency.lease", "1m")
+	viper.SetDefault("cleanup.interval", "1h")
+	viper.SetDefault("leader.retry_interval", "30s")
+	viper.SetDefault("jobs.workers", 4)
+	viper.SetDefault("jobs.poll_interval", "1s")
+	viper.SetDefault("jobs.lease", "5m")
+	viper.SetDefault("jobs.max_attempts", 5)
+	viper.SetDefault("jobs.base_backoff", "10s")
+	viper.SetDefault("jobs.max_backoff", "1h")
+	viper.SetDefault("jobs.retention", "168h")
+	viper.SetDefault("limits.max_files_per_user", 0)
+	viper.SetDefault("plans.default", "")
+	viper.SetDefault("upload.on_conflict", "error")
+	viper.SetDefault("confirm.bulk_delete", false)
+	viper.SetDefault("confirm.token_ttl", "5m")
+	viper.SetDefault("content_types.normalize_on_cleanup", false)
+	viper.SetDefault("content_types.normalize_batch_size", 1000)
+	viper.SetDefault("upload.grant_ttl", "5m")
+	viper.SetDefault("upload.grant_max_size", 100<<20)
+	viper.SetDefault("notifications.retention", "720h")
+	viper.SetDefault("maintenance.retry_after", "5m")
+	viper.SetDefault("api_usage.flush_interval", "1m")
+	viper.SetDefault("api_usage.retention", "2160h")
+	viper.SetDefault("database.replica_dsn", "")
+	viper.SetDefault("database.replica_max_lag", "5s")
+	viper.SetDefault("database.replica_check_interval", "10s")
+	viper.SetDefault("queries.slow_threshold", "200ms")
+	viper.SetDefault("queries.max_per_request", 50)
+	viper.SetDefault("queries.max_time_per_request", "1s")
+	viper.SetDefault("selfcheck.timeout", "10s")
+	viper.SetDefault("cache.driver", "none")
+	viper.SetDefault("cache.ttl", "1m")
+	viper.SetDefault("cache.timeout", "100ms")
+	viper.SetDefault("cache.max_entries", 10000)
+}
+
 // ConnectDB connects to the PostgreSQL database.
 func ConnectDB() {
+	if err := TryConnectDB(); err != nil {
+		log.Fatalf("Error connecting to database: %s", err)
+	}
+}
+
+// TryConnectDB is ConnectDB for callers that handle the error themselves, such as the
+// self-check, which reports an unreachable database instead of exiting.
+func TryConnectDB() error {
 	dbConfig := viper.GetStringMapString("database") // Use GetStringMapString for type safety
 
 	dsn := fmt.Sprintf(
-		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable TimeZone=UTC",
 		dbConfig["host"], 
 		dbConfig["port"],
 		dbConfig["user"], 
@@ -37,9 +110,45 @@ func ConnectDB() {
 	)
 
 	var err error
-	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	DB, err = openDB(dsn, true)
 	if err != nil {
-		log.Fatalf("Error connecting to database: %s", err)
+		DB, dbConnectErr = nil, err
+	}
+	return err
+}
+
+// openDB opens a connection with the settings shared by the primary and the replica. Unless
+// ping is set, an unreachable server is not an error until the connection is used.
+func openDB(dsn string, ping bool) (*gorm.DB, error) {
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		// Timestamps are stored in UTC with millisecond precision so every response
+		// formats them the same way.
+		NowFunc: func() time.Time { return time.Now().UTC().Truncate(time.Millisecond) },
+		// Lets models recognise unique violations via gorm.ErrDuplicatedKey.
+		TranslateError:       true,
+		DisableAutomaticPing: !ping,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Use(&querystats.Plugin{SlowQuery: viper.GetDuration("queries.slow_threshold")}); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// ConnectCache sets up the cache selected by cache.driver.
+func ConnectCache() {
+	var err error
+	Cache, err = cache.New(cache.Options{
+		Driver:        viper.GetString("cache.driver"),
+		MaxEntries:    viper.GetInt("cache.max_entries"),
+		RedisAddr:     viper.GetString("cache.redis.addr"),
+		RedisPassword: viper.GetString("cache.redis.password"),
+		RedisDB:       viper.GetInt("cache.redis.db"),
+	})
+	if err != nil {
+		log.Fatalf("Error configuring cache: %s", err)
 	}
 }
 
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+// Replica is the read replica connection, or nil unless database.replica_dsn is set.
+var Replica *gorm.DB
+
+// replicaUsable is false while the replica is unreachable or lags too far behind.
+var replicaUsable atomic.Bool
+
+// ReadDB returns the connection for reads that tolerate slightly stale data, such as
+// listings: the replica when it is configured and usable, the primary otherwise. Writes, and
+// reads that must see a write the caller just made, always use DB.
+func ReadDB() *gorm.DB {
+	if Replica != nil && replicaUsable.Load() {
+		return Replica
+	}
+	return DB
+}
+
+// ConnectReplica connects to database.replica_dsn if it is set and starts checking the
+// replica's health every database.replica_check_interval. A replica that can't be reached at
+// startup is not fatal; reads go to the primary until it recovers.
+func ConnectReplica() {
+	dsn := viper.GetString("database.replica_dsn")
+	if dsn == "" {
+		return
+	}
+
+	replica, err := openDB(dsn, false)
+	if err != nil {
+		log.Fatalf("Error configuring database replica: %s", err)
+	}
+
+	SetReplica(replica)
+	if !replicaUsable.Load() {
+		log.Printf("Database replica is not usable yet, reading from the primary")
+	}
+	go func() {
+		ticker := time.NewTicker(viper.GetDuration("database.replica_check_interval"))
+		defer ticker.Stop()
+		for range ticker.C {
+			checkReplica()
+		}
+	}()
+}
+
+// SetReplica makes db the read replica, or removes the replica if db is nil, and checks
+// whether it is usable. ConnectReplica calls it; tests use it to stand a second database in.
+func SetReplica(db *gorm.DB) {
+	Replica = db
+	replicaUsable.Store(false)
+	if db != nil {
+		checkReplica()
+	}
+}
+
+// checkReplica marks the replica usable if it answers and its replication lag is within
+// database.replica_max_lag. A max lag of zero disables the lag check. A replica that has
+// replayed everything it received counts as current even if the primary has been idle.
+func checkReplica() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Only Postgres reports replication lag; any other database just has to answer.
+	lagQuery := "SELECT 0"
+	if Replica.Dialector.Name() == "postgres" {
+		lagQuery = "SELECT CASE WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0 " +
+			"ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0) END"
+	}
+	var lagSeconds float64
+	err := Replica.WithContext(ctx).Raw(lagQuery).Scan(&lagSeconds).Error
+
+	usable := err == nil
+	maxLag := viper.GetDuration("database.replica_max_lag")
+	if usable && maxLag > 0 && time.Duration(lagSeconds*float64(time.Second)) > maxLag {
+		usable = false
+	}
+
+	if replicaUsable.Swap(usable) != usable {
+		switch {
+		case err != nil:
+			log.Printf("Database replica unavailable, reading from the primary: %s", err)
+		case !usable:
+			log.Printf("Database replica is %.1fs behind, reading from the primary", lagSeconds)
+		default:
+			log.Printf("Database replica is back in use")
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"testing"
+
+	"go-share/internal/testdb"
+	"gorm.io/gorm"
+)
+
+// withDatabases sets DB to primary and the replica to replica for the rest of the test.
+func withDatabases(t *testing.T, primary, replica *gorm.DB) {
+	t.Helper()
+	oldDB := DB
+	DB = primary
+	SetReplica(replica)
+	t.Cleanup(func() {
+		DB = oldDB
+		SetReplica(nil)
+	})
+}
+
+func TestReadDB(t *testing.T) {
+	primary, replica := testdb.Open(t), testdb.Open(t)
+
+	withDatabases(t, primary, nil)
+	if ReadDB() != primary {
+		t.Error("without a replica, reads don't go to the primary")
+	}
+
+	SetReplica(replica)
+	if ReadDB() != replica {
+		t.Error("reads don't go to a healthy replica")
+	}
+}
+
+// A replica that stops answering is dropped at the next check; a replacement is used at once.
+func TestReadDBFallsBackToPrimary(t *testing.T) {
+	primary, replica := testdb.Open(t), testdb.Open(t)
+	withDatabases(t, primary, replica)
+
+	sqlDB, err := replica.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.Close()
+	checkReplica()
+	if ReadDB() != primary {
+		t.Error("reads still go to a replica that is down")
+	}
+
+	SetReplica(testdb.Open(t))
+	if ReadDB() == primary {
+		t.Error("reads don't go to a replacement replica")
+	}
+}
+
+// The replication lag query runs on Postgres. The primary itself stands in for the replica: it
+// replays nothing, so it counts as current.
+func TestReplicaLagQuery(t *testing.T) {
+	primary, replica := testdb.Open(t), testdb.OpenPostgres(t)
+	withDatabases(t, primary, replica)
+	if ReadDB() != replica {
+		t.Error("reads don't go to a current Postgres replica")
+	}
+}
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	"go-share/selfcheck"
+)
+
+// requiredKeys must be set for the server to start.
+var requiredKeys = []string{"database.host", "database.port", "database.user", "database.name"}
+
+// dbConnectErr is why TryConnectDB failed, for the database check to report.
+var dbConnectErr error
+
+// maxClockSkew is how far the local clock may drift from the database's before checks fail.
+// Timestamps written by the database and by this process are compared against each other,
+// for example for lock expiry and suspension end dates.
+const maxClockSkew = 30 * time.Second
+
+func init() {
+	selfcheck.Register(selfcheck.Func("config", checkConfig))
+	selfcheck.Register(selfcheck.Func("database", checkDatabase))
+	selfcheck.Register(selfcheck.Func("clock", checkClock))
+	selfcheck.Register(selfcheck.Func("replica", checkReplicaHealth))
+	selfcheck.Register(selfcheck.Func("cache", checkCache))
+}
+
+// checkConfig fails if a required key is missing.
+func checkConfig(ctx context.Context) (selfcheck.Status, string) {
+	var missing []string
+	for _, key := range requiredKeys {
+		if viper.GetString(key) == "" {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		return selfcheck.Fail, "missing " + strings.Join(missing, ", ")
+	}
+	return selfcheck.Pass, ""
+}
+
+// checkDatabase fails if the primary database is unreachable.
+func checkDatabase(ctx context.Context) (selfcheck.Status, string) {
+	if DB == nil {
+		if dbConnectErr != nil {
+			return selfcheck.Fail, dbConnectErr.Error()
+		}
+		return selfcheck.Fail, "not connected"
+	}
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return selfcheck.Fail, err.Error()
+	}
+	if err := sqlDB.PingContext(ctx); err != nil {
+		return selfcheck.Fail, err.Error()
+	}
+	return selfcheck.Pass, ""
+}
+
+// checkClock compares the local clock with the database's. Any skew over a second is worth
+// a warning; skew over maxClockSkew fails.
+func checkClock(ctx context.Context) (selfcheck.Status, string) {
+	if DB == nil {
+		return selfcheck.Fail, "no database connection to compare with"
+	}
+
+	var dbNow time.Time
+	before := time.Now()
+	if err := DB.WithContext(ctx).Raw("SELECT now()").Scan(&dbNow).Error; err != nil {
+		return selfcheck.Fail, err.Error()
+	}
+	// Compare against the middle of the round trip so query latency doesn't count as skew.
+	local := before.Add(time.Since(before) / 2)
+
+	skew := dbNow.Sub(local)
+	if skew < 0 {
+		skew = -skew
+	}
+	message := fmt.Sprintf("local clock is %s from the database's", skew.Round(time.Millisecond))
+	switch {
+	case skew > maxClockSkew:
+		return selfcheck.Fail, message
+	case skew > time.Second:
+		return selfcheck.Warn, message
+	}
+	return selfcheck.Pass, ""
+}
+
+// checkReplicaHealth fails if a configured read replica is unreachable or lags too far behind.
+// Reads would fall back to the primary, so the server still works, but the replica is wasted.
+func checkReplicaHealth(ctx context.Context) (selfcheck.Status, string) {
+	if viper.GetString("database.replica_dsn") == "" {
+		return selfcheck.Pass, "not configured"
+	}
+	if Replica == nil {
+		return selfcheck.Fail, "not connected"
+	}
+	checkReplica()
+	if !replicaUsable.Load() {
+		return selfcheck.Fail, "unreachable or lagging more than database.replica_max_lag"
+	}
+	return selfcheck.Pass, ""
+}
+
+// checkCache writes, reads and deletes a key. A broken cache only costs performance, so
+// failures are warnings.
+func checkCache(ctx context.Context) (selfcheck.Status, string) {
+	if Cache == nil {
+		return selfcheck.Warn, "not configured"
+	}
+	switch viper.GetString("cache.driver") {
+	case "", "none":
+		return selfcheck.Pass, "disabled"
+	}
+
+	key := fmt.Sprintf("selfcheck:%d", time.Now().UnixNano())
+	if err := Cache.Set(ctx, key, []byte("ok"), time.Minute); err != nil {
+		return selfcheck.Warn, "write failed: " + err.Error()
+	}
+	if value, err := Cache.Get(ctx, key); err != nil || string(value) != "ok" {
+		return selfcheck.Warn, "read back failed"
+	}
+	if err := Cache.Delete(ctx, key); err != nil {
+		return selfcheck.Warn, "delete failed: " + err.Error()
+	}
+	return selfcheck.Pass, ""
+}
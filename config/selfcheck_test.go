@@ -0,0 +1,140 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"go-share/cache"
+	"go-share/internal/testdb"
+	"go-share/selfcheck"
+	"gorm.io/gorm"
+)
+
+// brokenCache fails every operation, like a Redis server that went away.
+type brokenCache struct{}
+
+func (brokenCache) Get(ctx context.Context, key string) ([]byte, error) {
+	return nil, errors.New("connection refused")
+}
+func (brokenCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return errors.New("connection refused")
+}
+func (brokenCache) Delete(ctx context.Context, key string) error {
+	return errors.New("connection refused")
+}
+
+// withSettings resets viper to the defaults, then applies settings, for the rest of the test.
+func withSettings(t *testing.T, settings map[string]interface{}) {
+	t.Helper()
+	viper.Reset()
+	SetDefaults()
+	for key, value := range settings {
+		viper.Set(key, value)
+	}
+	t.Cleanup(func() {
+		viper.Reset()
+		SetDefaults()
+	})
+}
+
+// closedDB returns a database whose connections are closed, like a server that went down.
+func closedDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db := testdb.Open(t)
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.Close()
+	return db
+}
+
+// withDB sets DB and dbConnectErr for the rest of the test.
+func withDB(t *testing.T, db *gorm.DB, connectErr error) {
+	t.Helper()
+	oldDB, oldErr := DB, dbConnectErr
+	DB, dbConnectErr = db, connectErr
+	t.Cleanup(func() { DB, dbConnectErr = oldDB, oldErr })
+}
+
+// checkResult is the expected outcome of a check; an empty message matches any.
+type checkResult struct {
+	status  selfcheck.Status
+	message string
+}
+
+func expectCheck(t *testing.T, name string, check func(context.Context) (selfcheck.Status, string), want checkResult) {
+	t.Helper()
+	status, message := check(context.Background())
+	if status != want.status || !strings.Contains(message, want.message) {
+		t.Errorf("%s: got %s %q, want %s %q", name, status, message, want.status, want.message)
+	}
+}
+
+func TestCheckConfig(t *testing.T) {
+	withSettings(t, map[string]interface{}{"database.host": "db", "database.port": "5432", "database.user": "share", "database.name": "share"})
+	expectCheck(t, "complete", checkConfig, checkResult{selfcheck.Pass, ""})
+
+	viper.Set("database.host", "")
+	viper.Set("database.name", "")
+	expectCheck(t, "missing keys", checkConfig, checkResult{selfcheck.Fail, "missing database.host, database.name"})
+}
+
+func TestCheckDatabase(t *testing.T) {
+	withDB(t, nil, errors.New("dial tcp: connection refused"))
+	expectCheck(t, "never connected", checkDatabase, checkResult{selfcheck.Fail, "connection refused"})
+
+	withDB(t, closedDB(t), nil)
+	expectCheck(t, "connection lost", checkDatabase, checkResult{selfcheck.Fail, "closed"})
+
+	withDB(t, testdb.Open(t), nil)
+	expectCheck(t, "reachable", checkDatabase, checkResult{selfcheck.Pass, ""})
+}
+
+// The clock check needs the database's clock; without one it can only fail.
+func TestCheckClockWithoutDatabase(t *testing.T) {
+	withDB(t, nil, nil)
+	expectCheck(t, "no database", checkClock, checkResult{selfcheck.Fail, "no database connection"})
+
+	withDB(t, closedDB(t), nil)
+	expectCheck(t, "connection lost", checkClock, checkResult{selfcheck.Fail, ""})
+}
+
+func TestCheckReplicaHealth(t *testing.T) {
+	withSettings(t, nil)
+	expectCheck(t, "not configured", checkReplicaHealth, checkResult{selfcheck.Pass, "not configured"})
+
+	viper.Set("database.replica_dsn", "host=replica")
+	withDatabases(t, testdb.Open(t), nil)
+	expectCheck(t, "not connected", checkReplicaHealth, checkResult{selfcheck.Fail, "not connected"})
+
+	SetReplica(closedDB(t))
+	expectCheck(t, "unreachable", checkReplicaHealth, checkResult{selfcheck.Fail, "unreachable"})
+
+	SetReplica(testdb.Open(t))
+	expectCheck(t, "healthy", checkReplicaHealth, checkResult{selfcheck.Pass, ""})
+}
+
+// A broken cache only warns: requests fall back to the database.
+func TestCheckCache(t *testing.T) {
+	oldCache := Cache
+	t.Cleanup(func() { Cache = oldCache })
+	withSettings(t, map[string]interface{}{"cache.driver": "redis"})
+
+	Cache = nil
+	expectCheck(t, "not configured", checkCache, checkResult{selfcheck.Warn, "not configured"})
+
+	Cache = brokenCache{}
+	expectCheck(t, "unreachable", checkCache, checkResult{selfcheck.Warn, "write failed: connection refused"})
+
+	Cache = cache.NewMemory(10)
+	expectCheck(t, "working", checkCache, checkResult{selfcheck.Pass, ""})
+
+	viper.Set("cache.driver", "none")
+	Cache = brokenCache{}
+	expectCheck(t, "disabled", checkCache, checkResult{selfcheck.Pass, "disabled"})
+}
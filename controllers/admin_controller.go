@@ -0,0 +1,379 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/spf13/viper"
+	"go-share/cache"
+	"go-share/config"
+	"go-share/models"
+	"go-share/querystats"
+	"go-share/selfcheck"
+	"go-share/utils"
+)
+
+// statsCache keeps the last computed stats so dashboard polling doesn't hit the database on every request.
+var statsCache struct {
+	sync.Mutex
+	stats     *models.Stats
+	expiresAt time.Time
+}
+
+// RegisterAdminRoutes registers the admin-only API routes.
+func RegisterAdminRoutes(router *mux.Router) {
+	adminRouter := subrouter(router, "/admin")
+	UseMiddleware(adminRouter, utils.AuthMiddleware, AdminMiddleware, trackAPIUsage("admin"))
+
+	adminRouter.HandleFunc("/stats", GetStats).Methods("GET")
+	adminRouter.HandleFunc("/runtime", GetRuntime).Methods("GET")
+	adminRouter.HandleFunc("/files/{id}/hold", HoldFile).Methods("POST")
+	adminRouter.HandleFunc("/files/{id}/release", ReleaseFile).Methods("POST")
+	adminRouter.HandleFunc("/impersonate/{userID}", ImpersonateUser).Methods("POST")
+	adminRouter.HandleFunc("/users/{userID}/suspend", SuspendUser).Methods("POST")
+	adminRouter.HandleFunc("/users/{userID}/reinstate", ReinstateUser).Methods("POST")
+	adminRouter.HandleFunc("/audit-logs", GetAuditLogs).Methods("GET")
+	adminRouter.HandleFunc("/file-counts/recalculate", RecalculateFileCounts).Methods("POST")
+	adminRouter.HandleFunc("/content-types/normalize", NormalizeContentTypes).Methods("POST")
+	adminRouter.HandleFunc("/maintenance", SetMaintenance).Methods("POST")
+	adminRouter.HandleFunc("/features", GetFeatures).Methods("GET")
+	adminRouter.HandleFunc("/features", UpdateFeatures).Methods("PATCH")
+	adminRouter.HandleFunc("/jobs", GetJobs).Methods("GET")
+	adminRouter.HandleFunc("/jobs/{jobID}/retry", RetryJob).Methods("POST")
+	adminRouter.HandleFunc("/jobs/{jobID}/cancel", CancelJob).Methods("POST")
+	adminRouter.HandleFunc("/routes", getRoutes(router)).Methods("GET")
+	adminRouter.HandleFunc("/selfcheck", RunSelfCheck).Methods("POST")
+	registerPlanRoutes(adminRouter)
+}
+
+// AdminMiddleware rejects requests from users who are not administrators, as well as
+// any request made with an impersonation token. It must run after utils.AuthMiddleware.
+func AdminMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := utils.GetUserID(r)
+		if !ok {
+			utils.ErrorJsonResponse(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if _, impersonating := utils.GetImpersonatorID(r); impersonating {
+			utils.ErrorJsonResponse(w, "Admin routes are unavailable while impersonating", http.StatusForbidden)
+			return
+		}
+
+		var user models.User
+		if err := config.DB.First(&user, userID).Error; err != nil || !user.IsAdmin {
+			utils.ErrorJsonResponse(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// GetStats returns aggregate usage statistics, cached for admin.stats_cache_ttl.
+func GetStats(w http.ResponseWriter, r *http.Request) {
+	statsCache.Lock()
+	defer statsCache.Unlock()
+
+	if statsCache.stats == nil || time.Now().After(statsCache.expiresAt) {
+		stats, err := models.CollectStats(config.DB)
+		if err != nil {
+			utils.ErrorJsonResponse(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		statsCache.stats = stats
+		statsCache.expiresAt = time.Now().Add(viper.GetDuration("admin.stats_cache_ttl"))
+	}
+
+	utils.JsonResponse(w, http.StatusOK, statsCache.stats)
+}
+
+// RuntimeStats describes the state of the Go runtime, the database pool, the cache and query timings.
+type RuntimeStats struct {
+	Goroutines     int              `json:"goroutines"`
+	HeapAlloc      uint64           `json:"heap_alloc_bytes"`
+	HeapInuse      uint64           `json:"heap_inuse_bytes"`
+	HeapSys        uint64           `json:"heap_sys_bytes"`
+	HeapObjects    uint64           `json:"heap_objects"`
+	NumGC          uint32           `json:"num_gc"`
+	GCPauseTotalNs uint64           `json:"gc_pause_total_ns"`
+	GCPausesNs     []uint64         `json:"gc_recent_pauses_ns"`
+	DB             DBPoolStats      `json:"db"`
+	Cache          cache.Stats      `json:"cache"`
+	Queries        querystats.Stats `json:"queries"`
+}
+
+// DBPoolStats mirrors sql.DBStats with JSON-friendly field names.
+type DBPoolStats struct {
+	MaxOpenConnections int   `json:"max_open_connections"`
+	OpenConnections    int   `json:"open_connections"`
+	InUse              int   `json:"in_use"`
+	Idle               int   `json:"idle"`
+	WaitCount          int64 `json:"wait_count"`
+	WaitDurationNs     int64 `json:"wait_duration_ns"`
+}
+
+// GetRuntime returns goroutine, heap, GC, and database connection pool statistics.
+func GetRuntime(w http.ResponseWriter, r *http.Request) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	stats := RuntimeStats{
+		Goroutines:     runtime.NumGoroutine(),
+		HeapAlloc:      mem.HeapAlloc,
+		HeapInuse:      mem.HeapInuse,
+		HeapSys:        mem.HeapSys,
+		HeapObjects:    mem.HeapObjects,
+		NumGC:          mem.NumGC,
+		GCPauseTotalNs: mem.PauseTotalNs,
+	}
+
+	// PauseNs is a circular buffer; walk back from the most recent GC.
+	for i := uint32(0); i < mem.NumGC && i < 10; i++ {
+		stats.GCPausesNs = append(stats.GCPausesNs, mem.PauseNs[(mem.NumGC-1-i)%uint32(len(mem.PauseNs))])
+	}
+
+	sqlDB, err := config.DB.DB()
+	if err != nil {
+		utils.ErrorJsonResponse(w, "Error getting database stats", http.StatusInternalServerError)
+		return
+	}
+	dbStats := sqlDB.Stats()
+	stats.DB = DBPoolStats{
+		MaxOpenConnections: dbStats.MaxOpenConnections,
+		OpenConnections:    dbStats.OpenConnections,
+		InUse:              dbStats.InUse,
+		Idle:               dbStats.Idle,
+		WaitCount:          dbStats.WaitCount,
+		WaitDurationNs:     dbStats.WaitDuration.Nanoseconds(),
+	}
+	stats.Cache = cache.GetStats()
+	stats.Queries = querystats.GetStats()
+
+	utils.JsonResponse(w, http.StatusOK, stats)
+}
+
+// HoldFile places a file under legal hold, blocking every deletion path.
+func HoldFile(w http.ResponseWriter, r *http.Request) {
+	setLegalHold(w, r, true)
+}
+
+// ReleaseFile lifts a legal hold.
+func ReleaseFile(w http.ResponseWriter, r *http.Request) {
+	setLegalHold(w, r, false)
+}
+
+// setLegalHold handles both legal hold endpoints. A reason is required for the audit trail.
+func setLegalHold(w http.ResponseWriter, r *http.Request, hold bool) {
+	id, err := parseFileID(mux.Vars(r)["id"])
+	if err != nil {
+		utils.ErrorJsonResponse(w, "Invalid file ID", http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Reason == "" {
+		utils.ErrorJsonResponse(w, "A reason is required", http.StatusBadRequest)
+		return
+	}
+
+	var file models.File
+	if err := config.DB.First(&file, id).Error; err != nil {
+		utils.ErrorJsonResponse(w, "File not found", http.StatusNotFound)
+		return
+	}
+
+	adminID, _ := utils.GetUserID(r)
+	audit := auditEntry(r, adminID)
+	audit.Reason = body.Reason
+	if err := file.SetLegalHold(config.DB, hold, audit); err != nil {
+		utils.ErrorJsonResponse(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	invalidateCachedFile(file.ID)
+
+	utils.JsonResponse(w, http.StatusOK, file)
+}
+
+// ImpersonateUser issues a short-lived token that lets the calling admin act as another user.
+func ImpersonateUser(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseUint(mux.Vars(r)["userID"], 10, 64)
+	if err != nil {
+		utils.ErrorJsonResponse(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Reason == "" {
+		utils.ErrorJsonResponse(w, "A reason is required", http.StatusBadRequest)
+		return
+	}
+
+	var target models.User
+	if err := config.DB.First(&target, targetID).Error; err != nil {
+		utils.ErrorJsonResponse(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if target.IsAdmin {
+		utils.ErrorJsonResponse(w, "Admins cannot be impersonated", http.StatusForbidden)
+		return
+	}
+
+	adminID, _ := utils.GetUserID(r)
+	ttl := viper.GetDuration("admin.impersonation_ttl")
+	token, err := utils.GenerateImpersonationToken(target.ID, adminID, ttl)
+	if err != nil {
+		utils.ErrorJsonResponse(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	entry := auditEntry(r, adminID)
+	entry.Action, entry.TargetUserID, entry.Reason = "user.impersonate", &target.ID, body.Reason
+	if err := models.RecordAudit(config.DB, &entry); err != nil {
+		utils.ErrorJsonResponse(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.JsonResponse(w, http.StatusCreated, map[string]interface{}{
+		"token":      token,
+		"expires_at": time.Now().Add(ttl),
+	})
+}
+
+// SuspendUser suspends an account. The body carries a required reason and an optional
+// "until" timestamp for time-boxed suspensions.
+func SuspendUser(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseUint(mux.Vars(r)["userID"], 10, 64)
+	if err != nil {
+		utils.ErrorJsonResponse(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		Reason string     `json:"reason"`
+		Until  *time.Time `json:"until"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Reason == "" {
+		utils.ErrorJsonResponse(w, "A reason is required", http.StatusBadRequest)
+		return
+	}
+	if body.Until != nil && !body.Until.After(time.Now()) {
+		utils.ErrorJsonResponse(w, "until must be in the future", http.StatusBadRequest)
+		return
+	}
+
+	var target models.User
+	if err := config.DB.First(&target, targetID).Error; err != nil {
+		utils.ErrorJsonResponse(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	adminID, _ := utils.GetUserID(r)
+	audit := auditEntry(r, adminID)
+	audit.Reason = body.Reason
+	if err := target.Suspend(config.DB, body.Until, audit); err != nil {
+		utils.ErrorJsonResponse(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	invalidateOwnerState(target.ID)
+
+	utils.JsonResponse(w, http.StatusOK, target.Profile())
+}
+
+// ReinstateUser lifts a suspension. A reason is required for the audit trail.
+func ReinstateUser(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseUint(mux.Vars(r)["userID"], 10, 64)
+	if err != nil {
+		utils.ErrorJsonResponse(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Reason == "" {
+		utils.ErrorJsonResponse(w, "A reason is required", http.StatusBadRequest)
+		return
+	}
+
+	var target models.User
+	if err := config.DB.First(&target, targetID).Error; err != nil {
+		utils.ErrorJsonResponse(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	adminID, _ := utils.GetUserID(r)
+	audit := auditEntry(r, adminID)
+	audit.Reason = body.Reason
+	if err := target.Reinstate(config.DB, audit); err != nil {
+		utils.ErrorJsonResponse(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	invalidateOwnerState(target.ID)
+
+	utils.JsonResponse(w, http.StatusOK, target.Profile())
+}
+
+// GetAuditLogs lists audit log entries, newest first. ?action= filters by action.
+func GetAuditLogs(w http.ResponseWriter, r *http.Request) {
+	page, pageSize := parsePagination(r)
+	entries, total, err := models.ListAuditLogs(readDB(r), r.URL.Query().Get("action"), (page-1)*pageSize, pageSize)
+	if err != nil {
+		utils.ErrorJsonResponse(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.JsonResponse(w, http.StatusOK, map[string]interface{}{
+		"audit_logs": entries,
+		"page":       page,
+		"page_size":  pageSize,
+		"total":      total,
+	})
+}
+
+// NormalizeContentTypes checks the content types of up to ?limit= (default 1000) unverified
+// files, corrects the ones their extension contradicts, and reports the changes along with
+// how many files remain to be checked.
+func NormalizeContentTypes(w http.ResponseWriter, r *http.Request) {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit < 1 || limit > 10000 {
+		limit = 1000
+	}
+
+	report, err := models.NormalizeContentTypes(config.DB, limit)
+	if err != nil {
+		utils.ErrorJsonResponse(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, change := range report.Changed {
+		invalidateCachedFile(uint(change.FileID))
+	}
+
+	utils.JsonResponse(w, http.StatusOK, report)
+}
+
+// RunSelfCheck runs the deployment self-checks, the same as the check command, and returns
+// the report. The response is 200 whatever the outcome; the report's status says it.
+func RunSelfCheck(w http.ResponseWriter, r *http.Request) {
+	utils.JsonResponse(w, http.StatusOK, selfcheck.Run(r.Context(), viper.GetDuration("selfcheck.timeout")))
+}
+
+// RecalculateFileCounts rebuilds the per-user file counters used by limits.max_files_per_user.
+func RecalculateFileCounts(w http.ResponseWriter, r *http.Request) {
+	corrected, err := models.RecalculateFileCounts(config.DB)
+	if err != nil {
+		utils.ErrorJsonResponse(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.JsonResponse(w, http.StatusOK, map[string]int64{"corrected_users": corrected})
+}
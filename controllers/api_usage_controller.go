@@ -0,0 +1,169 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go-share/models"
+	"go-share/utils"
+	"gorm.io/gorm"
+)
+
+// apiUsageKey identifies one bucket of the in-memory usage counters.
+type apiUsageKey struct {
+	userID uint
+	day    time.Time
+	group  string
+}
+
+// apiUsage aggregates request counts in memory until the next flush, so counting a
+// request costs a map update rather than a database write.
+var apiUsage struct {
+	sync.Mutex
+	counts map[apiUsageKey]*models.APIUsage
+}
+
+// statusRecorder captures the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+// trackAPIUsage counts authenticated requests to a route group. It must run after
+// utils.AuthMiddleware so that the user is known.
+func trackAPIUsage(group string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			userID, ok := utils.GetUserID(r)
+			if !ok {
+				return
+			}
+			recordAPIUsage(userID, group, rec.status >= 400, time.Now())
+		})
+	}
+}
+
+// recordAPIUsage adds one request made at the given time to the counters of its UTC day.
+func recordAPIUsage(userID uint, group string, failed bool, at time.Time) {
+	key := apiUsageKey{userID: userID, day: at.UTC().Truncate(24 * time.Hour), group: group}
+	var errors int64
+	if failed {
+		errors = 1
+	}
+
+	apiUsage.Lock()
+	defer apiUsage.Unlock()
+	addAPIUsage(key, 1, errors)
+}
+
+// addAPIUsage adds to the counters of one bucket. It must be called with apiUsage locked.
+func addAPIUsage(key apiUsageKey, requests, errors int64) {
+	if apiUsage.counts == nil {
+		apiUsage.counts = map[apiUsageKey]*models.APIUsage{}
+	}
+	usage, ok := apiUsage.counts[key]
+	if !ok {
+		usage = &models.APIUsage{UserID: key.userID, Day: key.day, RouteGroup: key.group}
+		apiUsage.counts[key] = usage
+	}
+	usage.Requests += requests
+	usage.Errors += errors
+}
+
+// StartAPIUsageFlush writes the in-memory usage counters to the database every interval.
+func StartAPIUsageFlush(db *gorm.DB, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			FlushAPIUsage(db)
+		}
+	}()
+}
+
+// FlushAPIUsage writes the in-memory usage counters to the database. Counters that fail
+// to save are kept for the next flush.
+func FlushAPIUsage(db *gorm.DB) {
+	apiUsage.Lock()
+	pending := apiUsage.counts
+	apiUsage.counts = nil
+	apiUsage.Unlock()
+
+	if len(pending) == 0 {
+		return
+	}
+
+	rows := make([]models.APIUsage, 0, len(pending))
+	for _, usage := range pending {
+		rows = append(rows, *usage)
+	}
+	if err := models.AddAPIUsage(db, rows); err != nil {
+		log.Printf("Error flushing API usage: %s", err)
+
+		apiUsage.Lock()
+		defer apiUsage.Unlock()
+		for key, usage := range pending {
+			addAPIUsage(key, usage.Requests, usage.Errors)
+		}
+	}
+}
+
+// GetAPIUsage returns the current user's daily request counts between ?from= and ?to=
+// (YYYY-MM-DD, UTC, inclusive). The range defaults to the last 30 days.
+func GetAPIUsage(w http.ResponseWriter, r *http.Request) {
+	to := time.Now().UTC().Truncate(24 * time.Hour)
+	from := to.AddDate(0, 0, -29)
+
+	var err error
+	if value := r.URL.Query().Get("from"); value != "" {
+		if from, err = time.Parse("2006-01-02", value); err != nil {
+			utils.ErrorJsonResponse(w, "from must be a date in YYYY-MM-DD format", http.StatusBadRequest)
+			return
+		}
+	}
+	if value := r.URL.Query().Get("to"); value != "" {
+		if to, err = time.Parse("2006-01-02", value); err != nil {
+			utils.ErrorJsonResponse(w, "to must be a date in YYYY-MM-DD format", http.StatusBadRequest)
+			return
+		}
+	}
+	if to.Before(from) {
+		utils.ErrorJsonResponse(w, "to must not be before from", http.StatusBadRequest)
+		return
+	}
+
+	userID, _ := utils.GetUserID(r)
+	usage, err := models.ListAPIUsage(readDB(r), userID, from, to)
+	if err != nil {
+		utils.ErrorJsonResponse(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var requests, errors int64
+	for _, day := range usage {
+		requests += day.Requests
+		errors += day.Errors
+	}
+
+	utils.JsonResponse(w, http.StatusOK, map[string]interface{}{
+		"from":  from.Format("2006-01-02"),
+		"to":    to.Format("2006-01-02"),
+		"usage": usage,
+		"totals": map[string]int64{
+			"requests": requests,
+			"errors":   errors,
+		},
+	})
+}
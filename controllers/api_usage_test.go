@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"go-share/config"
+	"go-share/models"
+)
+
+// apiUsageResponse is the body of GET /users/me/api-usage.
+type apiUsageResponse struct {
+	From   string            `json:"from"`
+	To     string            `json:"to"`
+	Usage  []models.APIUsage `json:"usage"`
+	Totals map[string]int64  `json:"totals"`
+}
+
+// newUsageAPI returns a test API with no usage counted yet, not even from earlier tests.
+func newUsageAPI(t *testing.T) http.Handler {
+	t.Helper()
+	api := newTestAPI(t)
+	apiUsage.Lock()
+	apiUsage.counts = nil
+	apiUsage.Unlock()
+	return api
+}
+
+// usageOf flushes the counters and returns the usage token's holder sees for the query.
+func usageOf(t *testing.T, api http.Handler, token, query string) apiUsageResponse {
+	t.Helper()
+	FlushAPIUsage(config.DB)
+	w := serve(api, newRequest(t, "GET", "/users/me/api-usage"+query, token, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("api-usage%s: got %d %s", query, w.Code, w.Body)
+	}
+	var usage apiUsageResponse
+	decode(t, w, &usage)
+	return usage
+}
+
+// countsByGroup sums usage rows by route group.
+func countsByGroup(usage []models.APIUsage) map[string][2]int64 {
+	counts := map[string][2]int64{}
+	for _, row := range usage {
+		c := counts[row.RouteGroup]
+		counts[row.RouteGroup] = [2]int64{c[0] + row.Requests, c[1] + row.Errors}
+	}
+	return counts
+}
+
+// Each request is counted once, for the user who made it and the route group it went to.
+// Failed requests count as errors; anonymous ones aren't counted at all.
+func TestAPIUsageAttribution(t *testing.T) {
+	api := newUsageAPI(t)
+	ada, adaToken := createTestUser(t, "ada@example.com")
+	_, bobToken := createTestUser(t, "bob@example.com")
+	file := createTestFile(t, ada, "a.txt", 1)
+
+	expectStatus(t, api, newRequest(t, "GET", "/files", adaToken, nil), http.StatusOK)
+	expectStatus(t, api, newRequest(t, "GET", fileURL(file), adaToken, nil), http.StatusOK)
+	expectStatus(t, api, newRequest(t, "GET", "/users/me", adaToken, nil), http.StatusOK)
+	expectStatus(t, api, newRequest(t, "GET", fileURL(file), bobToken, nil), http.StatusNotFound)
+	expectStatus(t, api, newRequest(t, "GET", "/admin/stats", bobToken, nil), http.StatusForbidden)
+	expectStatus(t, api, newRequest(t, "GET", "/files", "", nil), http.StatusUnauthorized)
+
+	// A usage request is counted after it responds, so it is missing from its own results.
+	tests := []struct {
+		token  string
+		counts map[string][2]int64
+		totals map[string]int64
+	}{
+		{adaToken, map[string][2]int64{"files": {2, 0}, "users": {1, 0}}, map[string]int64{"requests": 3, "errors": 0}},
+		{bobToken, map[string][2]int64{"files": {1, 1}}, map[string]int64{"requests": 1, "errors": 1}},
+	}
+	for _, tt := range tests {
+		usage := usageOf(t, api, tt.token, "")
+		if got := countsByGroup(usage.Usage); !equalCounts(got, tt.counts) {
+			t.Errorf("usage by group = %v, want %v", got, tt.counts)
+		}
+		if usage.Totals["requests"] != tt.totals["requests"] || usage.Totals["errors"] != tt.totals["errors"] {
+			t.Errorf("totals = %v, want %v", usage.Totals, tt.totals)
+		}
+	}
+
+	// Bob's admin request was rejected before the admin group counted it.
+	var admin int64
+	config.DB.Model(&models.APIUsage{}).Where("route_group = ?", "admin").Count(&admin)
+	if admin != 0 {
+		t.Errorf("%d admin usage rows for a non-admin", admin)
+	}
+}
+
+func equalCounts(a, b map[string][2]int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for group, counts := range a {
+		if b[group] != counts {
+			return false
+		}
+	}
+	return true
+}
+
+// Requests are counted on their UTC day, whatever the server's time zone, and from and to
+// both include their whole day.
+func TestAPIUsageDayBoundary(t *testing.T) {
+	api := newUsageAPI(t)
+	user, token := createTestUser(t, "ada@example.com")
+
+	midnight := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	east := time.FixedZone("UTC+10", 10*60*60)
+	recordAPIUsage(user.ID, "files", false, midnight.Add(-time.Millisecond))
+	recordAPIUsage(user.ID, "files", false, midnight.Add(-time.Millisecond).In(east))
+	recordAPIUsage(user.ID, "files", false, midnight)
+	recordAPIUsage(user.ID, "files", true, midnight.Add(24*time.Hour-time.Millisecond).In(east))
+	recordAPIUsage(user.ID, "files", false, midnight.Add(24*time.Hour))
+
+	tests := []struct {
+		query string
+		days  map[string][2]int64
+	}{
+		{"?from=2026-02-28&to=2026-03-02", map[string][2]int64{"2026-02-28": {2, 0}, "2026-03-01": {2, 1}, "2026-03-02": {1, 0}}},
+		{"?from=2026-03-01&to=2026-03-01", map[string][2]int64{"2026-03-01": {2, 1}}},
+		{"?from=2026-02-01&to=2026-02-28", map[string][2]int64{"2026-02-28": {2, 0}}},
+		{"?from=2026-03-03&to=2026-03-31", map[string][2]int64{}},
+	}
+	for _, tt := range tests {
+		usage := usageOf(t, api, token, tt.query)
+		days := map[string][2]int64{}
+		for _, row := range usage.Usage {
+			days[row.Day.UTC().Format("2006-01-02")] = [2]int64{row.Requests, row.Errors}
+		}
+		if !equalCounts(days, tt.days) {
+			t.Errorf("%s: usage by day = %v, want %v", tt.query, days, tt.days)
+		}
+	}
+}
+
+func TestAPIUsageRange(t *testing.T) {
+	api := newUsageAPI(t)
+	_, token := createTestUser(t, "ada@example.com")
+
+	usage := usageOf(t, api, token, "")
+	to := time.Now().UTC().Format("2006-01-02")
+	from := time.Now().UTC().AddDate(0, 0, -29).Format("2006-01-02")
+	if usage.From != from || usage.To != to {
+		t.Errorf("default range = %s to %s, want %s to %s", usage.From, usage.To, from, to)
+	}
+
+	for _, query := range []string{"?from=yesterday", "?to=2026-13-01", "?from=2026-03-02&to=2026-03-01"} {
+		expectStatus(t, api, newRequest(t, "GET", "/users/me/api-usage"+query, token, nil), http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,18 @@
+package controllers
+
+import (
+	"net/http"
+
+	"go-share/models"
+	"go-share/utils"
+)
+
+// auditEntry starts an audit log entry for an action actorID takes through r. It records the
+// client IP and, for impersonation tokens, the admin acting as actorID; callers fill in the rest.
+func auditEntry(r *http.Request, actorID uint) models.AuditLog {
+	entry := models.AuditLog{ActorID: actorID, IP: utils.ClientIP(r)}
+	if impersonatorID, ok := utils.GetImpersonatorID(r); ok {
+		entry.ImpersonatorID = &impersonatorID
+	}
+	return entry
+}
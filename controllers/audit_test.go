@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"go-share/config"
+	"go-share/models"
+	"go-share/utils"
+)
+
+func TestAuditEntryRecordsImpersonator(t *testing.T) {
+	tests := []struct {
+		name          string
+		impersonating bool
+	}{
+		{"owner", false},
+		{"impersonating admin", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newTestAPI(t)
+			owner, token := createTestUser(t, "owner@example.com")
+			createTestUser(t, "grantee@example.com")
+			admin, _ := createTestAdmin(t, "admin@example.com")
+			file := createTestFile(t, owner, "a.txt", 1)
+			if tt.impersonating {
+				var err error
+				if token, err = utils.GenerateImpersonationToken(owner.ID, admin.ID, time.Minute); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			body := map[string]interface{}{"email": "grantee@example.com", "expires_at": time.Now().Add(time.Hour)}
+			w := serve(api, newRequest(t, "POST", "/files/"+utils.EncodePublicID(file.ID)+"/grants", token, body))
+			if w.Code != http.StatusCreated {
+				t.Fatalf("creating grant: got %d %s", w.Code, w.Body)
+			}
+
+			var entry models.AuditLog
+			if err := config.DB.Where("action = ?", "file.grant.create").First(&entry).Error; err != nil {
+				t.Fatal(err)
+			}
+			if entry.ActorID != owner.ID {
+				t.Errorf("actor = %d, want the owner %d", entry.ActorID, owner.ID)
+			}
+			switch {
+			case tt.impersonating && (entry.ImpersonatorID == nil || *entry.ImpersonatorID != admin.ID):
+				t.Errorf("impersonator = %v, want %d", entry.ImpersonatorID, admin.ID)
+			case !tt.impersonating && entry.ImpersonatorID != nil:
+				t.Errorf("impersonator = %d, want none", *entry.ImpersonatorID)
+			}
+		})
+	}
+}
+
+// Audit entries record the client behind a trusted proxy, and the peer otherwise.
+func TestAuditEntryRecordsClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		remoteAddr string
+		want       string
+	}{
+		{"through trusted proxy", "10.0.0.1:1234", "198.51.100.1"},
+		{"from untrusted peer", "203.0.113.9:1234", "203.0.113.9"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newTestAPI(t)
+			viper.Set("server.trusted_proxies", []string{"10.0.0.0/8"})
+			owner, token := createTestUser(t, "owner@example.com")
+			createTestUser(t, "grantee@example.com")
+			file := createTestFile(t, owner, "a.txt", 1)
+
+			body := map[string]interface{}{"email": "grantee@example.com", "expires_at": time.Now().Add(time.Hour)}
+			r := newRequest(t, "POST", "/files/"+utils.EncodePublicID(file.ID)+"/grants", token, body)
+			r.RemoteAddr = tt.remoteAddr
+			r.Header.Set("X-Forwarded-For", "198.51.100.1")
+			if w := serve(api, r); w.Code != http.StatusCreated {
+				t.Fatalf("creating grant: got %d %s", w.Code, w.Body)
+			}
+
+			var entry models.AuditLog
+			if err := config.DB.Where("action = ?", "file.grant.create").First(&entry).Error; err != nil {
+				t.Fatal(err)
+			}
+			if entry.IP != tt.want {
+				t.Errorf("ip = %q, want %q", entry.IP, tt.want)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/gorilla/mux"
@@ -12,8 +13,9 @@ import (
 
 // RegisterAuthRoutes registers the authentication routes with the provided router.
 func RegisterAuthRoutes(router *mux.Router) {
-	router.HandleFunc("/register", Register).Methods("POST")
+	router.Handle("/register", requireFeature(FeatureRegistration)(http.HandlerFunc(Register))).Methods("POST")
 	router.HandleFunc("/login", Login).Methods("POST")
+	router.HandleFunc("/logout", Logout).Methods("POST")
 }
 
 // Register handles user registration.
@@ -25,6 +27,14 @@ func Register(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := user.CreateUser(config.DB); err != nil {
+		if errors.Is(err, models.ErrEmailUnavailable) {
+			utils.ErrorCodeJsonResponse(w, "email_unavailable", err.Error(), http.StatusConflict)
+			return
+		}
+		if utils.IsValidationError(err) {
+			utils.ErrorCodeJsonResponse(w, "validation_failed", err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
 		utils.ErrorJsonResponse(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -47,10 +57,19 @@ func Login(w http.ResponseWriter, r *http.Request) {
 	}
 
 	foundUser, err := user.ValidateUserCredentials(config.DB) 
-	if err != nil {
+	if errors.Is(err, models.ErrInvalidCredentials) {
 		utils.ErrorJsonResponse(w, err.Error(), http.StatusUnauthorized) // Use StatusUnauthorized for auth errors
 		return
 	}
+	if err != nil {
+		utils.ErrorJsonResponse(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := foundUser.RecordLogin(config.DB); err != nil {
+		utils.ErrorJsonResponse(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	token, err := utils.GenerateToken(foundUser.ID)
 	if err != nil {
@@ -58,5 +77,23 @@ func Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// With ?cookie=true the token goes into an HttpOnly cookie instead of the response body.
+	if r.URL.Query().Get("cookie") == "true" && utils.CookieAuthEnabled() {
+		csrfToken, err := utils.SetSessionCookies(w, token, utils.TokenTTL)
+		if err != nil {
+			utils.ErrorJsonResponse(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		utils.JsonResponse(w, http.StatusOK, map[string]string{"csrf_token": csrfToken})
+		return
+	}
+
 	utils.JsonResponse(w, http.StatusOK, map[string]string{"token": token}) 
+}
+
+// Logout clears the session cookies set by a cookie-mode login.
+func Logout(w http.ResponseWriter, r *http.Request) {
+	utils.ClearSessionCookies(w)
+	w.WriteHeader(http.StatusNoContent)
 }
\ No newline at end of file
@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"net/http"
+	"sort"
+	"testing"
+	"time"
+
+	"go-share/config"
+	"go-share/models"
+)
+
+// login posts credentials to /login.
+func login(t *testing.T, api http.Handler, email, password string) (int, string) {
+	t.Helper()
+	w := serve(api, newRequest(t, "POST", "/login", "", map[string]string{"email": email, "password": password}))
+	return w.Code, w.Body.String()
+}
+
+// createProviderUser stores an account created through social login, without a password.
+func createProviderUser(t *testing.T, email string) {
+	t.Helper()
+	if _, err := models.FindOrCreateProviderUser(config.DB, email, "google", true); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoginFailuresLookAlike(t *testing.T) {
+	api := newTestAPI(t)
+	createTestUser(t, "known@example.com")
+	createProviderUser(t, "provider@example.com")
+
+	if code, body := login(t, api, "known@example.com", "correct horse"); code != http.StatusOK {
+		t.Fatalf("valid login: got %d %s", code, body)
+	}
+
+	wantCode, wantBody := login(t, api, "unknown@example.com", "correct horse")
+	if wantCode != http.StatusUnauthorized {
+		t.Fatalf("unknown email: got %d %s, want 401", wantCode, wantBody)
+	}
+	for _, attempt := range []struct{ name, email, password string }{
+		{"wrong password", "known@example.com", "wrong password"},
+		{"provider-only account", "provider@example.com", ""},
+		{"provider-only account with a password", "provider@example.com", "correct horse"},
+	} {
+		if code, body := login(t, api, attempt.email, attempt.password); code != wantCode || body != wantBody {
+			t.Errorf("%s: got %d %s, want the unknown email's %d %s", attempt.name, code, body, wantCode, wantBody)
+		}
+	}
+}
+
+// medianLoginTime returns the median duration of n failed logins as email.
+func medianLoginTime(t *testing.T, api http.Handler, email string, n int) time.Duration {
+	t.Helper()
+	durations := make([]time.Duration, n)
+	for i := range durations {
+		start := time.Now()
+		login(t, api, email, "wrong password")
+		durations[i] = time.Since(start)
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	return durations[n/2]
+}
+
+// Failed logins take a bcrypt comparison whether or not the account exists. The check is
+// coarse: without the dummy comparison an unknown email is answered orders of magnitude faster.
+func TestLoginTimingDoesNotRevealAccounts(t *testing.T) {
+	if testing.Short() {
+		t.Skip("timing test")
+	}
+	api := newTestAPI(t)
+	createTestUser(t, "known@example.com")
+	createProviderUser(t, "provider@example.com")
+
+	known := medianLoginTime(t, api, "known@example.com", 7)
+	for _, email := range []string{"unknown@example.com", "provider@example.com"} {
+		got := medianLoginTime(t, api, email, 7)
+		if got < known/3 || got > known*3 {
+			t.Errorf("median failed login as %s took %s, against %s for an existing account", email, got, known)
+		}
+	}
+}
+
+// Registering a taken email gets the same 409 whether the account has a password or was
+// created through social login.
+func TestRegisterTakenEmail(t *testing.T) {
+	api := newTestAPI(t)
+	createTestUser(t, "known@example.com")
+	createProviderUser(t, "provider@example.com")
+
+	var bodies []string
+	for _, email := range []string{"known@example.com", "provider@example.com"} {
+		w := serve(api, newRequest(t, "POST", "/register", "", map[string]string{"email": email, "password": "another password"}))
+		if w.Code != http.StatusConflict {
+			t.Fatalf("registering %s: got %d %s, want 409", email, w.Code, w.Body)
+		}
+		bodies = append(bodies, w.Body.String())
+	}
+	if bodies[0] != bodies[1] {
+		t.Errorf("responses differ: %s and %s", bodies[0], bodies[1])
+	}
+}
@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"go-share/config"
+	"go-share/models"
+	"go-share/utils"
+)
+
+func TestBulkDeleteWhileImpersonating(t *testing.T) {
+	tests := []struct {
+		name          string
+		impersonating bool
+		query         string
+		status        int
+		trashed       bool
+		purged        bool
+	}{
+		{"impersonator trashes", true, "", http.StatusOK, true, false},
+		{"impersonator purges", true, "?permanent=true", http.StatusForbidden, false, false},
+		{"owner purges", false, "?permanent=true", http.StatusOK, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newTestAPI(t)
+			owner, token := createTestUser(t, "owner@example.com")
+			admin, _ := createTestAdmin(t, "admin@example.com")
+			file := createTestFile(t, owner, "a.txt", 1)
+			if tt.impersonating {
+				var err error
+				if token, err = utils.GenerateImpersonationToken(owner.ID, admin.ID, time.Minute); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			body := map[string]interface{}{"ids": []string{utils.EncodePublicID(file.ID)}}
+			w := serve(api, newRequest(t, "POST", "/files/bulk-delete"+tt.query, token, body))
+			if w.Code != tt.status {
+				t.Fatalf("got %d %s, want %d", w.Code, w.Body, tt.status)
+			}
+
+			var remaining int64
+			config.DB.Model(&models.File{}).Where("id = ?", file.ID).Count(&remaining)
+			var stored int64
+			config.DB.Unscoped().Model(&models.File{}).Where("id = ?", file.ID).Count(&stored)
+			if trashed := remaining == 0; trashed != tt.trashed {
+				t.Errorf("file trashed = %v, want %v", trashed, tt.trashed)
+			}
+			if purged := stored == 0; purged != tt.purged {
+				t.Errorf("file purged = %v, want %v", purged, tt.purged)
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package controllers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"go-share/config"
+	"go-share/jobs"
+	"go-share/models"
+	"go-share/utils"
+)
+
+// registerCommentRoutes registers the comment routes on the authenticated files subrouter.
+func registerCommentRoutes(fileRouter *mux.Router) {
+	fileRouter.HandleFunc("/{id}/comments", CreateComment).Methods("POST")
+	fileRouter.HandleFunc("/{id}/comments", GetComments).Methods("GET")
+	fileRouter.HandleFunc("/{id}/comments/{cid}", UpdateComment).Methods("PATCH")
+	fileRouter.HandleFunc("/{id}/comments/{cid}", DeleteComment).Methods("DELETE")
+}
+
+// loadAccessibleFile fetches the file named by the {id} route variable and checks that the
+// caller may see it. It writes the error response and returns false on failure.
+func loadAccessibleFile(w http.ResponseWriter, r *http.Request, file *models.File) (uint, bool) {
+	id, err := parseFileID(mux.Vars(r)["id"])
+	if err != nil {
+		utils.ErrorJsonResponse(w, "Invalid file ID", http.StatusBadRequest)
+		return 0, false
+	}
+
+	userID, _ := utils.GetUserID(r)
+	if err := findVisibleFile(userID, id, file); err != nil {
+		utils.ErrorJsonResponse(w, "File not found", http.StatusNotFound)
+		return 0, false
+	}
+
+	return userID, true
+}
+
+// parsePagination reads the page and page_size query parameters and returns the offset and limit.
+func parsePagination(r *http.Request) (page, pageSize int) {
+	page, _ = strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ = strconv.Atoi(r.URL.Query().Get("page_size"))
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	return page, pageSize
+}
+
+// CreateComment adds a comment to a file.
+func CreateComment(w http.ResponseWriter, r *http.Request) {
+	var file models.File
+	userID, ok := loadAccessibleFile(w, r, &file)
+	if !ok {
+		return
+	}
+
+	var comment models.Comment
+	if err := json.NewDecoder(r.Body).Decode(&comment); err != nil {
+		utils.ErrorJsonResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	comment.FileID = file.ID
+	comment.AuthorID = userID
+	if err := comment.CreateComment(config.DB); err != nil {
+		writeCommentError(w, err)
+		return
+	}
+	comment.AuthorDisplayName = authorDisplayName(comment.AuthorID)
+
+	if file.UserID != userID {
+		payload := models.Metadata{
+			"file_id":    utils.EncodePublicID(file.ID),
+			"comment_id": strconv.FormatUint(uint64(comment.ID), 10),
+			"author_id":  strconv.FormatUint(uint64(userID), 10),
+		}
+		if err := jobs.EnqueueNotification(config.DB, file.UserID, models.NotificationCommentAdded, payload); err != nil {
+			log.Printf("Error notifying user %d about comment %d: %s", file.UserID, comment.ID, err)
+		}
+	}
+
+	utils.JsonResponse(w, http.StatusCreated, comment)
+}
+
+// GetComments lists a file's comments, newest first.
+func GetComments(w http.ResponseWriter, r *http.Request) {
+	var file models.File
+	if _, ok := loadAccessibleFile(w, r, &file); !ok {
+		return
+	}
+
+	page, pageSize := parsePagination(r)
+	comments, total, err := models.ListFileComments(readDB(r), file.ID, (page-1)*pageSize, pageSize)
+	if err != nil {
+		utils.ErrorJsonResponse(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.JsonResponse(w, http.StatusOK, map[string]interface{}{
+		"comments":  comments,
+		"page":      page,
+		"page_size": pageSize,
+		"total":     total,
+	})
+}
+
+// loadComment fetches the comment named by the {cid} route variable within file.
+func loadComment(w http.ResponseWriter, r *http.Request, file *models.File, comment *models.Comment) bool {
+	cid, err := strconv.ParseUint(mux.Vars(r)["cid"], 10, 64)
+	if err != nil {
+		utils.ErrorJsonResponse(w, "Invalid comment ID", http.StatusBadRequest)
+		return false
+	}
+
+	if err := config.DB.Where("file_id = ?", file.ID).First(comment, cid).Error; err != nil {
+		utils.ErrorJsonResponse(w, "Comment not found", http.StatusNotFound)
+		return false
+	}
+	return true
+}
+
+// UpdateComment edits a comment's body.
+func UpdateComment(w http.ResponseWriter, r *http.Request) {
+	var file models.File
+	userID, ok := loadAccessibleFile(w, r, &file)
+	if !ok {
+		return
+	}
+
+	var comment models.Comment
+	if !loadComment(w, r, &file, &comment) {
+		return
+	}
+
+	var body struct {
+		Body string `json:"body"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		utils.ErrorJsonResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if !comment.CanModify(&file, userID) {
+		utils.ErrorJsonResponse(w, "Unauthorized to update comment", http.StatusForbidden)
+		return
+	}
+	if err := comment.UpdateComment(config.DB, &file, userID, body.Body); err != nil {
+		writeCommentError(w, err)
+		return
+	}
+	comment.AuthorDisplayName = authorDisplayName(comment.AuthorID)
+
+	utils.JsonResponse(w, http.StatusOK, comment)
+}
+
+// DeleteComment removes a comment.
+func DeleteComment(w http.ResponseWriter, r *http.Request) {
+	var file models.File
+	userID, ok := loadAccessibleFile(w, r, &file)
+	if !ok {
+		return
+	}
+
+	var comment models.Comment
+	if !loadComment(w, r, &file, &comment) {
+		return
+	}
+
+	if !comment.CanModify(&file, userID) {
+		utils.ErrorJsonResponse(w, "Unauthorized to delete comment", http.StatusForbidden)
+		return
+	}
+	if err := comment.DeleteComment(config.DB, &file, userID); err != nil {
+		utils.ErrorJsonResponse(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.JsonResponse(w, http.StatusOK, comment)
+}
+
+// writeCommentError maps errors from saving a comment to a response: 400 for a body that fails
+// validation, 500 for anything else.
+func writeCommentError(w http.ResponseWriter, err error) {
+	if utils.IsValidationError(err) {
+		utils.ErrorJsonResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	utils.ErrorJsonResponse(w, err.Error(), http.StatusInternalServerError)
+}
+
+// authorDisplayName looks up the display name embedded in single-comment responses.
+func authorDisplayName(authorID uint) string {
+	var author models.User
+	if err := config.DB.Select("display_name").First(&author, authorID).Error; err != nil {
+		return ""
+	}
+	return author.DisplayName
+}
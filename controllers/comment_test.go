@@ -0,0 +1,184 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"go-share/config"
+	"go-share/models"
+	"go-share/utils"
+)
+
+// postComment adds a comment to file as the holder of token and returns it.
+func postComment(t *testing.T, api http.Handler, token string, file *models.File, body string) models.Comment {
+	t.Helper()
+	w := serve(api, newRequest(t, "POST", fileURL(file)+"/comments", token, map[string]string{"body": body}))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("adding comment: got %d %s", w.Code, w.Body)
+	}
+	var comment models.Comment
+	decode(t, w, &comment)
+	return comment
+}
+
+func TestCreateComment(t *testing.T) {
+	api := newTestAPI(t)
+	owner, token := createTestUser(t, "owner@example.com")
+	if err := owner.UpdateDisplayName(config.DB, "Ada"); err != nil {
+		t.Fatal(err)
+	}
+	file := createTestFile(t, owner, "a.txt", 1)
+
+	w := serve(api, newRequest(t, "POST", fileURL(file)+"/comments", token, map[string]string{"body": "  Looks\x07 good\n"}))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("got %d %s", w.Code, w.Body)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, `"file_id":"`+utils.EncodePublicID(file.ID)+`"`) {
+		t.Errorf("response %s does not carry the file's public ID", body)
+	}
+
+	var comment models.Comment
+	decode(t, w, &comment)
+	if comment.FileID != file.ID || comment.AuthorID != owner.ID || comment.Body != "Looks good" || comment.AuthorDisplayName != "Ada" {
+		t.Errorf("created %+v", comment)
+	}
+	var stored models.Comment
+	if err := config.DB.First(&stored, comment.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.FileID != file.ID {
+		t.Errorf("stored file_id = %d, want %d", stored.FileID, file.ID)
+	}
+}
+
+func TestCreateCommentErrors(t *testing.T) {
+	api := newTestAPI(t)
+	owner, token := createTestUser(t, "owner@example.com")
+	_, strangerToken := createTestUser(t, "stranger@example.com")
+	file := createTestFile(t, owner, "a.txt", 1)
+
+	tests := []struct {
+		name   string
+		token  string
+		body   string
+		status int
+	}{
+		{"empty", token, "", http.StatusBadRequest},
+		{"only control characters", token, " \x00\x07 ", http.StatusBadRequest},
+		{"too long", token, strings.Repeat("x", 4001), http.StatusBadRequest},
+		{"longest", token, strings.Repeat("x", 4000), http.StatusCreated},
+		{"file the caller can't see", strangerToken, "Hello", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(api, newRequest(t, "POST", fileURL(file)+"/comments", tt.token, map[string]string{"body": tt.body}))
+			if w.Code != tt.status {
+				t.Errorf("got %d %s, want %d", w.Code, w.Body, tt.status)
+			}
+		})
+	}
+
+	// A failure to store the comment is the server's fault, not the client's.
+	if err := config.DB.Migrator().DropTable(&models.Comment{}); err != nil {
+		t.Fatal(err)
+	}
+	w := serve(api, newRequest(t, "POST", fileURL(file)+"/comments", token, map[string]string{"body": "Hello"}))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("storage failure: got %d %s, want 500", w.Code, w.Body)
+	}
+}
+
+func TestGetCommentsPagination(t *testing.T) {
+	api := newTestAPI(t)
+	owner, token := createTestUser(t, "owner@example.com")
+	file := createTestFile(t, owner, "a.txt", 1)
+	other := createTestFile(t, owner, "b.txt", 1)
+	for i := 1; i <= 5; i++ {
+		postComment(t, api, token, file, fmt.Sprintf("Comment %d", i))
+	}
+	postComment(t, api, token, other, "Elsewhere")
+
+	var page struct {
+		Comments []models.Comment `json:"comments"`
+		Page     int              `json:"page"`
+		PageSize int              `json:"page_size"`
+		Total    int64            `json:"total"`
+	}
+	decode(t, serve(api, newRequest(t, "GET", fileURL(file)+"/comments?page=2&page_size=2", token, nil)), &page)
+	if page.Total != 5 || page.Page != 2 || page.PageSize != 2 || len(page.Comments) != 2 {
+		t.Fatalf("got page %d of size %d with %d of %d comments", page.Page, page.PageSize, len(page.Comments), page.Total)
+	}
+	if page.Comments[0].Body != "Comment 3" || page.Comments[1].Body != "Comment 2" {
+		t.Errorf("page 2 = %q, %q; want newest first", page.Comments[0].Body, page.Comments[1].Body)
+	}
+}
+
+// Comments can be edited and deleted by their author and by the file's owner, and by nobody
+// else who can see the file.
+func TestModifyCommentPermissions(t *testing.T) {
+	tests := []struct {
+		caller string
+		status int
+	}{
+		{"author", http.StatusOK},
+		{"owner", http.StatusOK},
+		{"other grantee", http.StatusForbidden},
+		{"stranger", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caller, func(t *testing.T) {
+			api := newTestAPI(t)
+			owner, ownerToken := createTestUser(t, "owner@example.com")
+			author, authorToken := createTestUser(t, "author@example.com")
+			grantee, granteeToken := createTestUser(t, "grantee@example.com")
+			_, strangerToken := createTestUser(t, "stranger@example.com")
+			file := createTestFile(t, owner, "a.txt", 1)
+			grantAccess(t, author, file, time.Now().Add(time.Hour))
+			grantAccess(t, grantee, file, time.Now().Add(time.Hour))
+			tokens := map[string]string{"author": authorToken, "owner": ownerToken, "other grantee": granteeToken, "stranger": strangerToken}
+
+			comment := postComment(t, api, authorToken, file, "First")
+			target := fmt.Sprintf("%s/comments/%d", fileURL(file), comment.ID)
+
+			w := serve(api, newRequest(t, "PATCH", target, tokens[tt.caller], map[string]string{"body": "Edited"}))
+			if w.Code != tt.status {
+				t.Errorf("edit: got %d %s, want %d", w.Code, w.Body, tt.status)
+			}
+			w = serve(api, newRequest(t, "DELETE", target, tokens[tt.caller], nil))
+			if w.Code != tt.status {
+				t.Errorf("delete: got %d %s, want %d", w.Code, w.Body, tt.status)
+			}
+
+			var remaining int64
+			config.DB.Model(&models.Comment{}).Where("id = ?", comment.ID).Count(&remaining)
+			if deleted := remaining == 0; deleted != (tt.status == http.StatusOK) {
+				t.Errorf("comment deleted = %v after a %d", deleted, tt.status)
+			}
+		})
+	}
+}
+
+func TestUpdateCommentErrors(t *testing.T) {
+	api := newTestAPI(t)
+	owner, token := createTestUser(t, "owner@example.com")
+	file := createTestFile(t, owner, "a.txt", 1)
+	other := createTestFile(t, owner, "b.txt", 1)
+	comment := postComment(t, api, token, file, "First")
+	target := fmt.Sprintf("%s/comments/%d", fileURL(file), comment.ID)
+
+	expectStatus(t, api, newRequest(t, "PATCH", target, token, map[string]string{"body": ""}), http.StatusBadRequest)
+	expectStatus(t, api, newRequest(t, "PATCH", target, token, map[string]string{"body": strings.Repeat("x", 4001)}), http.StatusBadRequest)
+	expectStatus(t, api, newRequest(t, "PATCH", fileURL(file)+"/comments/nope", token, map[string]string{"body": "Edited"}), http.StatusBadRequest)
+	// A comment is only found through the file it belongs to.
+	expectStatus(t, api, newRequest(t, "PATCH", fmt.Sprintf("%s/comments/%d", fileURL(other), comment.ID), token, map[string]string{"body": "Edited"}), http.StatusNotFound)
+
+	var stored models.Comment
+	config.DB.First(&stored, comment.ID)
+	if stored.Body != "First" {
+		t.Errorf("body = %q after rejected edits, want %q", stored.Body, "First")
+	}
+}
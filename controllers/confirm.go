@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/spf13/viper"
+	"go-share/utils"
+)
+
+// confirmHeader carries the token that confirms a destructive request.
+const confirmHeader = "X-Confirm-Token"
+
+// requireConfirmation guards a destructive operation while confirm.<operation> is enabled.
+// A request without X-Confirm-Token gets 428 with a summary of what would be destroyed and
+// a token bound to this exact request; repeating the request with the token returns true.
+// body is the raw request body, which is part of the binding. When confirmation is disabled
+// it returns true straight away.
+func requireConfirmation(w http.ResponseWriter, r *http.Request, operation string, userID uint, body []byte, summary interface{}) bool {
+	if !viper.GetBool("confirm." + operation) {
+		return true
+	}
+
+	requestHash := utils.ConfirmRequestHash(r, body)
+	if token := r.Header.Get(confirmHeader); token != "" {
+		if err := utils.VerifyConfirmToken(token, userID, requestHash); err != nil {
+			utils.ErrorCodeJsonResponse(w, "invalid_confirm_token", "The confirmation token is invalid, expired or was issued for a different request", http.StatusPreconditionFailed)
+			return false
+		}
+		return true
+	}
+
+	token, expiresAt, err := utils.GenerateConfirmToken(userID, requestHash, viper.GetDuration("confirm.token_ttl"))
+	if err != nil {
+		utils.ErrorJsonResponse(w, "Error creating confirmation token", http.StatusInternalServerError)
+		return false
+	}
+	utils.JsonResponse(w, http.StatusPreconditionRequired, map[string]interface{}{
+		"error":         "Repeat the request with the " + confirmHeader + " header to confirm it",
+		"code":          "confirmation_required",
+		"confirm_token": token,
+		"expires_at":    expiresAt,
+		"summary":       summary,
+	})
+	return false
+}
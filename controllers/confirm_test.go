@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/spf13/viper"
+	"go-share/config"
+	"go-share/models"
+	"go-share/utils"
+)
+
+func TestBulkDeleteConfirmation(t *testing.T) {
+	api := newTestAPI(t)
+	viper.Set("confirm.bulk_delete", true)
+	owner, token := createTestUser(t, "owner@example.com")
+	_, otherToken := createTestUser(t, "other@example.com")
+	a, b := createTestFile(t, owner, "a.txt", 3), createTestFile(t, owner, "b.txt", 4)
+	body := map[string]interface{}{"ids": []string{utils.EncodePublicID(a.ID), utils.EncodePublicID(b.ID)}}
+
+	w := serve(api, newRequest(t, "POST", "/files/bulk-delete", token, body))
+	if w.Code != http.StatusPreconditionRequired {
+		t.Fatalf("unconfirmed: got %d %s, want 428", w.Code, w.Body)
+	}
+	var prompt struct {
+		Code         string `json:"code"`
+		ConfirmToken string `json:"confirm_token"`
+		Summary      struct {
+			Files     int   `json:"files"`
+			Bytes     int64 `json:"bytes"`
+			Permanent bool  `json:"permanent"`
+		} `json:"summary"`
+	}
+	decode(t, w, &prompt)
+	if prompt.Code != "confirmation_required" || prompt.Summary.Files != 2 || prompt.Summary.Bytes != 7 || prompt.Summary.Permanent {
+		t.Errorf("prompt = %+v, want 2 files, 7 bytes, not permanent", prompt)
+	}
+
+	confirmed := func(target, token string, body interface{}) *http.Request {
+		r := newRequest(t, "POST", target, token, body)
+		r.Header.Set(confirmHeader, prompt.ConfirmToken)
+		return r
+	}
+	changed := map[string]interface{}{"ids": []string{utils.EncodePublicID(a.ID)}}
+	for name, r := range map[string]*http.Request{
+		"other body":  confirmed("/files/bulk-delete", token, changed),
+		"other query": confirmed("/files/bulk-delete?permanent=true", token, body),
+		"other user":  confirmed("/files/bulk-delete", otherToken, body),
+	} {
+		if w := serve(api, r); w.Code != http.StatusPreconditionFailed {
+			t.Errorf("%s: got %d %s, want 412", name, w.Code, w.Body)
+		}
+	}
+	var count int64
+	if config.DB.Model(&models.File{}).Where("user_id = ?", owner.ID).Count(&count); count != 2 {
+		t.Fatalf("%d files left before confirming, want 2", count)
+	}
+
+	if w := serve(api, confirmed("/files/bulk-delete", token, body)); w.Code != http.StatusOK {
+		t.Fatalf("confirmed: got %d %s", w.Code, w.Body)
+	}
+	if config.DB.Model(&models.File{}).Where("user_id = ?", owner.ID).Count(&count); count != 0 {
+		t.Errorf("%d files left after confirming, want 0", count)
+	}
+}
@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/gorilla/mux"
+	"github.com/spf13/viper"
+	"go-share/utils"
+)
+
+// RegisterDebugRoutes exposes the net/http/pprof handlers according to debug.pprof:
+//   - "off" (default): profiling is not exposed at all.
+//   - "admin": handlers are mounted under /debug/pprof/ on the main router behind admin auth.
+//   - "localhost": handlers are served by NewDebugServer on a separate listener at
+//     debug.pprof_address; nothing is added to the main router.
+func RegisterDebugRoutes(router *mux.Router) {
+	switch mode := viper.GetString("debug.pprof"); mode {
+	case "", "off", "localhost":
+		return
+	case "admin":
+		debugRouter := subrouter(router, "/debug/pprof")
+		UseMiddleware(debugRouter, utils.AuthMiddleware, AdminMiddleware)
+		mountPprof(debugRouter)
+	default:
+		log.Fatalf("Invalid debug.pprof mode: %q", mode)
+	}
+}
+
+// NewDebugServer returns the server for the pprof handlers when debug.pprof is "localhost",
+// and nil otherwise. It is started and shut down along with the API servers.
+func NewDebugServer() *http.Server {
+	if viper.GetString("debug.pprof") != "localhost" {
+		return nil
+	}
+	debugRouter := mux.NewRouter()
+	mountPprof(debugRouter.PathPrefix("/debug/pprof").Subrouter())
+	return &http.Server{Addr: viper.GetString("debug.pprof_address"), Handler: debugRouter}
+}
+
+// mountPprof registers the pprof handlers on a router rooted at /debug/pprof.
+func mountPprof(router *mux.Router) {
+	router.HandleFunc("/cmdline", pprof.Cmdline)
+	router.HandleFunc("/profile", pprof.Profile)
+	router.HandleFunc("/symbol", pprof.Symbol)
+	router.HandleFunc("/trace", pprof.Trace)
+	router.PathPrefix("/").HandlerFunc(pprof.Index) // Index also serves named profiles such as /goroutine
+}
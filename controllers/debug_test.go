@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// newDebugAPI is newTestAPI with debug.pprof set to mode, which applies when routes are
+// registered.
+func newDebugAPI(t *testing.T, mode string) http.Handler {
+	t.Helper()
+	newTestAPI(t)
+	viper.Set("debug.pprof", mode)
+	return MethodHandler(NewRouter())
+}
+
+func TestPprofAdminMode(t *testing.T) {
+	api := newDebugAPI(t, "admin")
+	_, adminToken := createTestAdmin(t, "admin@example.com")
+	_, userToken := createTestUser(t, "user@example.com")
+
+	const profile = "/debug/pprof/goroutine?debug=1"
+	expectStatus(t, api, newRequest(t, "GET", profile, "", nil), http.StatusUnauthorized)
+	expectStatus(t, api, newRequest(t, "GET", profile, userToken, nil), http.StatusForbidden)
+
+	w := serve(api, newRequest(t, "GET", profile, adminToken, nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "goroutine profile:") {
+		t.Errorf("admin: got %d %.200s, want a goroutine profile", w.Code, w.Body)
+	}
+}
+
+// Profiling is off unless configured, and the localhost mode keeps it off the main router.
+func TestPprofNotOnMainRouter(t *testing.T) {
+	for _, mode := range []string{"off", "localhost"} {
+		api := newDebugAPI(t, mode)
+		_, adminToken := createTestAdmin(t, "admin@example.com")
+		if w := serve(api, newRequest(t, "GET", "/debug/pprof/goroutine?debug=1", adminToken, nil)); w.Code != http.StatusNotFound {
+			t.Errorf("%s: admin got %d, want 404", mode, w.Code)
+		}
+	}
+}
+
+func TestNewDebugServer(t *testing.T) {
+	newDebugAPI(t, "off")
+	if server := NewDebugServer(); server != nil {
+		t.Errorf("debug.pprof off: got a server on %s", server.Addr)
+	}
+
+	viper.Set("debug.pprof", "localhost")
+	server := NewDebugServer()
+	if server == nil || server.Addr != "127.0.0.1:6060" {
+		t.Fatalf("debug.pprof localhost: got %+v, want a server on debug.pprof_address", server)
+	}
+	// The listener is bound to localhost, so it serves the profiles without credentials.
+	w := serve(server.Handler, newRequest(t, "GET", "/debug/pprof/goroutine?debug=1", "", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "goroutine profile:") {
+		t.Errorf("got %d %.200s, want a goroutine profile", w.Code, w.Body)
+	}
+}
+
+func TestGetRuntime(t *testing.T) {
+	api := newTestAPI(t)
+	_, adminToken := createTestAdmin(t, "admin@example.com")
+	_, userToken := createTestUser(t, "user@example.com")
+
+	expectStatus(t, api, newRequest(t, "GET", "/admin/runtime", userToken, nil), http.StatusForbidden)
+
+	w := serve(api, newRequest(t, "GET", "/admin/runtime", adminToken, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("admin: got %d %s", w.Code, w.Body)
+	}
+	var stats RuntimeStats
+	decode(t, w, &stats)
+	if stats.Goroutines == 0 || stats.HeapAlloc == 0 || stats.DB.OpenConnections == 0 {
+		t.Errorf("got %+v, want goroutines, heap and open database connections", stats)
+	}
+}
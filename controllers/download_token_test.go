@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"go-share/utils"
+)
+
+// issueDownloadToken asks the API for a download link to file and returns its path and query.
+func issueDownloadToken(t *testing.T, api http.Handler, token, fileID string) string {
+	t.Helper()
+
+	w := serve(api, newRequest(t, "POST", "/files/"+fileID+"/download-token", token, nil))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("creating download token: got %d %s", w.Code, w.Body)
+	}
+	var issued struct {
+		URL       string    `json:"url"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	decode(t, w, &issued)
+	if until := time.Until(issued.ExpiresAt); until <= 0 || until > viper.GetDuration("files.download_token_ttl") {
+		t.Errorf("token expires in %s, want within files.download_token_ttl", until)
+	}
+	link, err := url.Parse(issued.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return link.RequestURI()
+}
+
+func TestDownloadTokenLink(t *testing.T) {
+	api := newTestAPI(t)
+	owner, token := createTestUser(t, "owner@example.com")
+	file := createTestFile(t, owner, "a.txt", 1)
+	other := createTestFile(t, owner, "b.txt", 1)
+	link := issueDownloadToken(t, api, token, utils.EncodePublicID(file.ID))
+
+	// The link needs no Authorization header.
+	if w := serve(api, newRequest(t, "GET", link, "", nil)); w.Code != http.StatusOK {
+		t.Fatalf("GET %s: got %d %s", link, w.Code, w.Body)
+	}
+
+	// Replaying the token against another file fails even for the same owner.
+	query, _ := url.Parse(link)
+	replayed := "/files/" + utils.EncodePublicID(other.ID) + "?" + query.RawQuery
+	if w := serve(api, newRequest(t, "GET", replayed, "", nil)); w.Code != http.StatusUnauthorized {
+		t.Errorf("GET %s: got %d %s, want 401", replayed, w.Code, w.Body)
+	}
+}
+
+func TestDownloadTokenRejected(t *testing.T) {
+	api := newTestAPI(t)
+	owner, _ := createTestUser(t, "owner@example.com")
+	file := createTestFile(t, owner, "a.txt", 1)
+	valid, _ := utils.GenerateDownloadToken(file.ID, owner.ID, time.Minute)
+	expired, _ := utils.GenerateDownloadToken(file.ID, owner.ID, -time.Second)
+	encoded, signature, _ := strings.Cut(valid, ".")
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"expired", expired},
+		{"tampered", encoded + "." + strings.Repeat("A", len(signature))},
+		{"not a token", "invalid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := "/files/" + utils.EncodePublicID(file.ID) + "?token=" + url.QueryEscape(tt.token)
+			if w := serve(api, newRequest(t, "GET", target, "", nil)); w.Code != http.StatusUnauthorized {
+				t.Errorf("got %d %s, want 401", w.Code, w.Body)
+			}
+		})
+	}
+}
+
+// A token only carries the access its holder had: one issued to a user who can't see the
+// file does not open it.
+func TestDownloadTokenRequiresVisibility(t *testing.T) {
+	api := newTestAPI(t)
+	owner, _ := createTestUser(t, "owner@example.com")
+	stranger, strangerToken := createTestUser(t, "stranger@example.com")
+	file := createTestFile(t, owner, "a.txt", 1)
+
+	if w := serve(api, newRequest(t, "POST", "/files/"+utils.EncodePublicID(file.ID)+"/download-token", strangerToken, nil)); w.Code != http.StatusNotFound {
+		t.Errorf("stranger creating a token: got %d %s, want 404", w.Code, w.Body)
+	}
+
+	forged, _ := utils.GenerateDownloadToken(file.ID, stranger.ID, time.Minute)
+	if w := serve(api, newRequest(t, "GET", "/files/"+utils.EncodePublicID(file.ID)+"?token="+forged, "", nil)); w.Code != http.StatusNotFound {
+		t.Errorf("stranger's token: got %d %s, want 404", w.Code, w.Body)
+	}
+}
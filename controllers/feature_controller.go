@@ -0,0 +1,191 @@
+package controllers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/spf13/viper"
+	"go-share/config"
+	"go-share/internal/buildinfo"
+	"go-share/models"
+	"go-share/utils"
+)
+
+// Feature flags that can be switched off at runtime.
+const (
+	FeatureRegistration = "registration"
+	FeatureSocialLogin  = "social_login"
+)
+
+// knownFeatures lists every feature flag. Unknown names are rejected by the admin endpoint.
+var knownFeatures = []string{FeatureRegistration, FeatureSocialLogin}
+
+// featureSettingPrefix prefixes the settings rows that persist admin overrides.
+const featureSettingPrefix = "feature."
+
+// featureRefreshInterval bounds how stale the cached overrides may be on other instances.
+const featureRefreshInterval = 5 * time.Second
+
+// FeatureGate answers whether a feature is enabled. The value comes from an admin override
+// stored in the settings table if there is one, and from features.<name> in the config
+// otherwise. Overrides are cached so checks don't hit the database on every request.
+type FeatureGate struct {
+	mu        sync.Mutex
+	overrides map[string]bool
+	checkedAt time.Time
+}
+
+// Features is the feature gate used by the request handlers.
+var Features = &FeatureGate{}
+
+// Enabled reports whether the named feature is enabled.
+func (g *FeatureGate) Enabled(name string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.refresh()
+	if enabled, ok := g.overrides[name]; ok {
+		return enabled
+	}
+	return viper.GetBool("features." + name)
+}
+
+// All returns the state of every known feature.
+func (g *FeatureGate) All() map[string]bool {
+	states := make(map[string]bool, len(knownFeatures))
+	for _, name := range knownFeatures {
+		states[name] = g.Enabled(name)
+	}
+	return states
+}
+
+// Set stores an override for the named feature and applies it immediately.
+func (g *FeatureGate) Set(name string, enabled bool) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if err := models.SetSetting(config.DB, featureSettingPrefix+name, strconv.FormatBool(enabled)); err != nil {
+		return err
+	}
+	if g.overrides == nil {
+		g.overrides = map[string]bool{}
+	}
+	g.overrides[name] = enabled
+	publishFeature(name, enabled)
+	return nil
+}
+
+// refresh reloads the overrides once they are older than featureRefreshInterval.
+// It must be called with mu held.
+func (g *FeatureGate) refresh() {
+	if g.overrides != nil && time.Since(g.checkedAt) < featureRefreshInterval {
+		return
+	}
+
+	settings, err := models.ListSettings(config.DB, featureSettingPrefix)
+	if err != nil {
+		// Keep the last known overrides rather than failing every request.
+		log.Printf("Error loading feature flags: %s", err)
+		if g.overrides == nil {
+			g.overrides = map[string]bool{}
+		}
+		g.checkedAt = time.Now()
+		return
+	}
+
+	overrides := make(map[string]bool, len(settings))
+	for key, value := range settings {
+		if enabled, err := strconv.ParseBool(value); err == nil {
+			overrides[strings.TrimPrefix(key, featureSettingPrefix)] = enabled
+		}
+	}
+	g.overrides = overrides
+	g.checkedAt = time.Now()
+
+	for _, name := range knownFeatures {
+		enabled, ok := overrides[name]
+		if !ok {
+			enabled = viper.GetBool("features." + name)
+		}
+		publishFeature(name, enabled)
+	}
+}
+
+// publishFeature records the feature's state in the build info served by /version.
+func publishFeature(name string, enabled bool) {
+	state := "disabled"
+	if enabled {
+		state = "enabled"
+	}
+	buildinfo.SetFeature(name, state)
+}
+
+// requireFeature returns 404 with a feature_disabled code while the named feature is disabled.
+func requireFeature(name string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !Features.Enabled(name) {
+				utils.ErrorCodeJsonResponse(w, "feature_disabled", "This feature is disabled", http.StatusNotFound)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// GetFeatures returns the state of every feature flag.
+func GetFeatures(w http.ResponseWriter, r *http.Request) {
+	utils.JsonResponse(w, http.StatusOK, Features.All())
+}
+
+// UpdateFeatures overrides feature flags, e.g. {"registration": false}. Overrides are
+// persisted and take precedence over the config file.
+func UpdateFeatures(w http.ResponseWriter, r *http.Request) {
+	var body map[string]bool
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		utils.ErrorJsonResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	for name := range body {
+		if !isKnownFeature(name) {
+			utils.ErrorJsonResponse(w, "Unknown feature: "+name, http.StatusBadRequest)
+			return
+		}
+	}
+
+	adminID, _ := utils.GetUserID(r)
+	for name, enabled := range body {
+		if err := Features.Set(name, enabled); err != nil {
+			utils.ErrorJsonResponse(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		action := "feature." + name + ".disable"
+		if enabled {
+			action = "feature." + name + ".enable"
+		}
+		entry := auditEntry(r, adminID)
+		entry.Action = action
+		if err := models.RecordAudit(config.DB, &entry); err != nil {
+			log.Printf("Error recording feature audit entry: %s", err)
+		}
+	}
+
+	utils.JsonResponse(w, http.StatusOK, Features.All())
+}
+
+// isKnownFeature reports whether name is a feature flag.
+func isKnownFeature(name string) bool {
+	for _, known := range knownFeatures {
+		if known == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"go-share/config"
+	"go-share/models"
+)
+
+// registerRequest builds POST /register for a new account.
+func registerRequest(t *testing.T, email string) *http.Request {
+	t.Helper()
+	return newRequest(t, "POST", "/register", "", map[string]string{"email": email, "password": "correct horse"})
+}
+
+// versionFeatures returns the feature states reported by /version.
+func versionFeatures(t *testing.T, api http.Handler) map[string]string {
+	t.Helper()
+	var version struct {
+		Features map[string]string `json:"features"`
+	}
+	decode(t, serve(api, newRequest(t, "GET", "/version", "", nil)), &version)
+	return version.Features
+}
+
+// Switching a flag through the admin API applies to the very next request and is reported
+// by /version, and switching it back restores the endpoint.
+func TestFeatureToggleAtRuntime(t *testing.T) {
+	api := newTestAPI(t)
+	_, adminToken := createTestAdmin(t, "admin@example.com")
+	newStubProvider(t)
+
+	expectStatus(t, api, registerRequest(t, "first@example.com"), http.StatusCreated)
+	expectStatus(t, api, newRequest(t, "GET", "/auth/google/login", "", nil), http.StatusFound)
+
+	expectStatus(t, api, newRequest(t, "PATCH", "/admin/features", adminToken, map[string]bool{FeatureRegistration: false, FeatureSocialLogin: false}), http.StatusOK)
+	expectError(t, api, registerRequest(t, "second@example.com"), http.StatusNotFound, "feature_disabled")
+	expectError(t, api, newRequest(t, "GET", "/auth/google/login", "", nil), http.StatusNotFound, "feature_disabled")
+	if features := versionFeatures(t, api); features[FeatureRegistration] != "disabled" || features[FeatureSocialLogin] != "disabled" {
+		t.Errorf("/version features = %v, want both disabled", features)
+	}
+	// Signing in doesn't depend on either flag.
+	if code, body := login(t, api, "first@example.com", "correct horse"); code != http.StatusOK {
+		t.Errorf("login: got %d %s", code, body)
+	}
+
+	expectStatus(t, api, newRequest(t, "PATCH", "/admin/features", adminToken, map[string]bool{FeatureRegistration: true}), http.StatusOK)
+	expectStatus(t, api, registerRequest(t, "second@example.com"), http.StatusCreated)
+	expectError(t, api, newRequest(t, "GET", "/auth/google/login", "", nil), http.StatusNotFound, "feature_disabled")
+	if features := versionFeatures(t, api); features[FeatureRegistration] != "enabled" || features[FeatureSocialLogin] != "disabled" {
+		t.Errorf("/version features = %v", features)
+	}
+
+	var audits int64
+	config.DB.Model(&models.AuditLog{}).Where("action LIKE ?", "feature.%").Count(&audits)
+	if audits != 3 {
+		t.Errorf("%d feature audit entries, want 3", audits)
+	}
+}
+
+// An override beats the config file, and the config applies again for features without one.
+func TestFeatureOverridePrecedence(t *testing.T) {
+	api := newTestAPI(t)
+	_, adminToken := createTestAdmin(t, "admin@example.com")
+
+	viper.Set("features.registration", false)
+	expectError(t, api, registerRequest(t, "a@example.com"), http.StatusNotFound, "feature_disabled")
+
+	expectStatus(t, api, newRequest(t, "PATCH", "/admin/features", adminToken, map[string]bool{FeatureRegistration: true}), http.StatusOK)
+	expectStatus(t, api, registerRequest(t, "a@example.com"), http.StatusCreated)
+
+	var features map[string]bool
+	decode(t, serve(api, newRequest(t, "GET", "/admin/features", adminToken, nil)), &features)
+	if !features[FeatureRegistration] || !features[FeatureSocialLogin] {
+		t.Errorf("features = %v, want both enabled", features)
+	}
+}
+
+// Another instance picks up an override once its cache is older than featureRefreshInterval.
+func TestFeatureOverrideReachesOtherInstances(t *testing.T) {
+	newTestAPI(t)
+	other := &FeatureGate{}
+	if !other.Enabled(FeatureRegistration) {
+		t.Fatal("registration disabled before any override")
+	}
+
+	if err := Features.Set(FeatureRegistration, false); err != nil {
+		t.Fatal(err)
+	}
+	if !other.Enabled(FeatureRegistration) {
+		t.Error("the other instance refreshed before featureRefreshInterval")
+	}
+	other.mu.Lock()
+	other.checkedAt = time.Now().Add(-featureRefreshInterval)
+	other.mu.Unlock()
+	if other.Enabled(FeatureRegistration) {
+		t.Error("the other instance still has registration enabled after refreshing")
+	}
+}
+
+func TestUpdateFeaturesErrors(t *testing.T) {
+	api := newTestAPI(t)
+	_, adminToken := createTestAdmin(t, "admin@example.com")
+	_, token := createTestUser(t, "user@example.com")
+
+	expectStatus(t, api, newRequest(t, "PATCH", "/admin/features", token, map[string]bool{FeatureRegistration: false}), http.StatusForbidden)
+	expectStatus(t, api, newRequest(t, "PATCH", "/admin/features", adminToken, map[string]bool{"teleport": false, FeatureRegistration: false}), http.StatusBadRequest)
+	expectStatus(t, api, newRequest(t, "PATCH", "/admin/features", adminToken, map[string]string{FeatureRegistration: "off"}), http.StatusBadRequest)
+	expectStatus(t, api, registerRequest(t, "a@example.com"), http.StatusCreated)
+}
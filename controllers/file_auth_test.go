@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/spf13/viper"
+	"go-share/utils"
+)
+
+// authenticate sets the credentials of the named style on r.
+func authenticate(r *http.Request, style, token string) {
+	r.Header.Del("Authorization")
+	switch style {
+	case "bearer":
+		r.Header.Set("Authorization", "Bearer "+token)
+	case "raw header":
+		r.Header.Set("Authorization", token)
+	case "cookie":
+		r.AddCookie(&http.Cookie{Name: utils.SessionCookieName, Value: token})
+		r.AddCookie(&http.Cookie{Name: utils.CSRFCookieName, Value: "csrf"})
+		r.Header.Set(utils.CSRFHeaderName, "csrf")
+	}
+}
+
+// The file handlers must act for whoever AuthMiddleware authenticated, however the token
+// was presented.
+func TestFileHandlersAuthStyles(t *testing.T) {
+	for _, style := range []string{"bearer", "raw header", "cookie"} {
+		t.Run(style, func(t *testing.T) {
+			api := newTestAPI(t)
+			viper.Set("auth.cookie.enabled", true)
+			_, token := createTestUser(t, "owner@example.com")
+
+			r := newRequest(t, "POST", "/files", "", map[string]interface{}{"name": "a.txt", "path": "/a.txt", "size": 1})
+			authenticate(r, style, token)
+			w := serve(api, r)
+			if w.Code != http.StatusCreated {
+				t.Fatalf("create: got %d %s", w.Code, w.Body)
+			}
+			var created struct {
+				ID string `json:"id"`
+			}
+			decode(t, w, &created)
+
+			r = newRequest(t, "PUT", "/files/"+created.ID, "", map[string]interface{}{"name": "b.txt", "version": 1})
+			authenticate(r, style, token)
+			if w := serve(api, r); w.Code != http.StatusOK {
+				t.Fatalf("update: got %d %s", w.Code, w.Body)
+			}
+
+			r = newRequest(t, "DELETE", "/files/"+created.ID, "", nil)
+			authenticate(r, style, token)
+			if w := serve(api, r); w.Code != http.StatusOK {
+				t.Fatalf("delete: got %d %s", w.Code, w.Body)
+			}
+		})
+	}
+}
+
+func TestFileHandlersCookieRequiresCSRF(t *testing.T) {
+	api := newTestAPI(t)
+	viper.Set("auth.cookie.enabled", true)
+	owner, token := createTestUser(t, "owner@example.com")
+	file := createTestFile(t, owner, "a.txt", 1)
+
+	r := newRequest(t, "DELETE", "/files/"+utils.EncodePublicID(file.ID), "", nil)
+	r.AddCookie(&http.Cookie{Name: utils.SessionCookieName, Value: token})
+	if w := serve(api, r); w.Code != http.StatusForbidden {
+		t.Fatalf("delete without CSRF token: got %d %s", w.Code, w.Body)
+	}
+}
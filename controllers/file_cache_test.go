@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"go-share/cache"
+	"go-share/config"
+	"go-share/jobs"
+	"go-share/models"
+	"go-share/utils"
+)
+
+// primeFileCache downloads file with a download token, which caches it under file:<id>.
+func primeFileCache(t *testing.T, api http.Handler, file *models.File) {
+	t.Helper()
+
+	token, _ := utils.GenerateDownloadToken(file.ID, file.UserID, time.Minute)
+	w := serve(api, newRequest(t, "GET", "/files/"+utils.EncodePublicID(file.ID)+"?token="+token, "", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("downloading with a token: got %d %s", w.Code, w.Body)
+	}
+	if _, err := config.Cache.Get(context.Background(), fileCacheKey(uint64(file.ID))); err != nil {
+		t.Fatalf("file was not cached: %s", err)
+	}
+}
+
+// Every path that changes a file row must drop the cached copy, or token downloads keep
+// serving the old row until it expires.
+func TestFileChangesInvalidateCache(t *testing.T) {
+	tests := []struct {
+		name string
+		// setup runs before the cache is primed.
+		setup  func(t *testing.T, api http.Handler, file *models.File, token string)
+		change func(t *testing.T, api http.Handler, file *models.File, token, adminToken string)
+	}{
+		{"update", nil, func(t *testing.T, api http.Handler, file *models.File, token, _ string) {
+			expectStatus(t, api, newRequest(t, "PUT", fileURL(file), token, map[string]interface{}{"name": "b.txt", "version": file.Version}), http.StatusOK)
+		}},
+		{"delete", nil, func(t *testing.T, api http.Handler, file *models.File, token, _ string) {
+			expectStatus(t, api, newRequest(t, "DELETE", fileURL(file), token, nil), http.StatusOK)
+		}},
+		{"bulk delete", nil, func(t *testing.T, api http.Handler, file *models.File, token, _ string) {
+			body := map[string]interface{}{"ids": []string{utils.EncodePublicID(file.ID)}}
+			expectStatus(t, api, newRequest(t, "POST", "/files/bulk-delete", token, body), http.StatusOK)
+		}},
+		{"replace upload", nil, func(t *testing.T, api http.Handler, file *models.File, token, _ string) {
+			body := map[string]interface{}{"name": file.Name, "path": file.Path, "size": 2}
+			expectStatus(t, api, newRequest(t, "POST", "/files?on_conflict=replace", token, body), http.StatusOK)
+		}},
+		{"lock", nil, func(t *testing.T, api http.Handler, file *models.File, token, _ string) {
+			expectStatus(t, api, newRequest(t, "POST", fileURL(file)+"/lock", token, nil), http.StatusOK)
+		}},
+		{"unlock", func(t *testing.T, api http.Handler, file *models.File, token string) {
+			expectStatus(t, api, newRequest(t, "POST", fileURL(file)+"/lock", token, nil), http.StatusOK)
+		}, func(t *testing.T, api http.Handler, file *models.File, token, _ string) {
+			expectStatus(t, api, newRequest(t, "POST", fileURL(file)+"/unlock", token, nil), http.StatusOK)
+		}},
+		{"pin", nil, func(t *testing.T, api http.Handler, file *models.File, token, _ string) {
+			expectStatus(t, api, newRequest(t, "POST", fileURL(file)+"/pin", token, nil), http.StatusOK)
+		}},
+		{"unpin", func(t *testing.T, api http.Handler, file *models.File, token string) {
+			expectStatus(t, api, newRequest(t, "POST", fileURL(file)+"/pin", token, nil), http.StatusOK)
+		}, func(t *testing.T, api http.Handler, file *models.File, token, _ string) {
+			expectStatus(t, api, newRequest(t, "POST", fileURL(file)+"/unpin", token, nil), http.StatusOK)
+		}},
+		{"metadata", nil, func(t *testing.T, api http.Handler, file *models.File, token, _ string) {
+			expectStatus(t, api, newRequest(t, "PATCH", fileURL(file)+"/metadata", token, map[string]interface{}{"project": "x"}), http.StatusOK)
+		}},
+		{"legal hold", nil, func(t *testing.T, api http.Handler, file *models.File, _, adminToken string) {
+			body := map[string]interface{}{"reason": "litigation"}
+			expectStatus(t, api, newRequest(t, "POST", "/admin/files/"+utils.EncodePublicID(file.ID)+"/hold", adminToken, body), http.StatusOK)
+		}},
+		{"legal hold release", func(t *testing.T, _ http.Handler, file *models.File, _ string) {
+			if err := config.DB.Model(file).Update("legal_hold", true).Error; err != nil {
+				t.Fatal(err)
+			}
+		}, func(t *testing.T, api http.Handler, file *models.File, _, adminToken string) {
+			body := map[string]interface{}{"reason": "settled"}
+			expectStatus(t, api, newRequest(t, "POST", "/admin/files/"+utils.EncodePublicID(file.ID)+"/release", adminToken, body), http.StatusOK)
+		}},
+		{"content type normalization", func(t *testing.T, _ http.Handler, file *models.File, _ string) {
+			if err := config.DB.Model(file).Update("content_type", "application/octet-stream").Error; err != nil {
+				t.Fatal(err)
+			}
+		}, func(t *testing.T, api http.Handler, file *models.File, _, adminToken string) {
+			expectStatus(t, api, newRequest(t, "POST", "/admin/content-types/normalize", adminToken, nil), http.StatusOK)
+		}},
+		{"cleanup categorization", func(t *testing.T, _ http.Handler, file *models.File, _ string) {
+			if err := config.DB.Model(file).UpdateColumn("category", "").Error; err != nil {
+				t.Fatal(err)
+			}
+		}, func(t *testing.T, _ http.Handler, _ *models.File, _, _ string) {
+			jobs.RunCleanup(config.DB, InvalidateCachedFiles)
+		}},
+		{"original name backfill", func(t *testing.T, _ http.Handler, file *models.File, _ string) {
+			if err := config.DB.Model(file).UpdateColumn("original_name", "").Error; err != nil {
+				t.Fatal(err)
+			}
+		}, func(t *testing.T, _ http.Handler, file *models.File, _, _ string) {
+			backfilled, err := models.BackfillOriginalNames(config.DB)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(backfilled) != 1 || backfilled[0] != file.ID {
+				t.Fatalf("backfilled %v, want [%d]", backfilled, file.ID)
+			}
+			InvalidateCachedFiles(backfilled)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newTestAPI(t)
+			owner, token := createTestUser(t, "owner@example.com")
+			_, adminToken := createTestAdmin(t, "admin@example.com")
+			file := createTestFile(t, owner, "a.txt", 1)
+			if tt.setup != nil {
+				tt.setup(t, api, file, token)
+			}
+			primeFileCache(t, api, file)
+
+			tt.change(t, api, file, token, adminToken)
+
+			if _, err := config.Cache.Get(context.Background(), fileCacheKey(uint64(file.ID))); !errors.Is(err, cache.ErrMiss) {
+				t.Errorf("file is still cached after the change (err %v)", err)
+			}
+		})
+	}
+}
+
+// fileURL returns the API path of file.
+func fileURL(file *models.File) string {
+	return "/files/" + utils.EncodePublicID(file.ID)
+}
+
+// expectStatus sends r to api and fails the test unless it answers with status.
+func expectStatus(t *testing.T, api http.Handler, r *http.Request, status int) {
+	t.Helper()
+
+	if w := serve(api, r); w.Code != status {
+		t.Fatalf("%s %s: got %d %s, want %d", r.Method, r.URL, w.Code, w.Body, status)
+	}
+}
@@ -1,30 +1,197 @@
 package controllers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/spf13/viper"
+	"go-share/cache"
 	"go-share/config"
 	"go-share/models"
+	"go-share/repositories"
 	"go-share/utils"
 )
 
 // RegisterFileRoutes registers the file-related API routes.
 func RegisterFileRoutes(router *mux.Router) {
+	// Signed download tokens and upload grants authorize a single request without the
+	// Authorization header, so these routes are registered ahead of the authenticated subrouter.
+	registerDownloadTokenRoute(router)
+	registerUploadGrantRoute(router)
+
 	// Apply authentication middleware to all file-related routes
-	fileRouter := router.PathPrefix("/files").Subrouter()
-	fileRouter.Use(utils.AuthMiddleware)
+	fileRouter := subrouter(router, "/files")
+	UseMiddleware(fileRouter, utils.AuthMiddleware, trackAPIUsage("files"))
 
 	fileRouter.HandleFunc("", CreateFile).Methods("POST")
 	fileRouter.HandleFunc("", GetFiles).Methods("GET")
+	fileRouter.HandleFunc("/shared-with-me", GetSharedWithMe).Methods("GET")
+	fileRouter.HandleFunc("/batch-get", BatchGetFiles).Methods("POST")
+	fileRouter.HandleFunc("/bulk-delete", BulkDeleteFiles).Methods("POST")
+	fileRouter.HandleFunc("/upload-grants", CreateUploadGrant).Methods("POST")
 	fileRouter.HandleFunc("/{id}", GetFile).Methods("GET")
 	fileRouter.HandleFunc("/{id}", UpdateFile).Methods("PUT")
 	fileRouter.HandleFunc("/{id}", DeleteFile).Methods("DELETE")
+	fileRouter.HandleFunc("/{id}/lock", LockFile).Methods("POST")
+	fileRouter.HandleFunc("/{id}/unlock", UnlockFile).Methods("POST")
+	fileRouter.HandleFunc("/{id}/metadata", UpdateFileMetadata).Methods("PATCH")
+	fileRouter.HandleFunc("/{id}/pin", PinFile).Methods("POST")
+	fileRouter.HandleFunc("/{id}/unpin", UnpinFile).Methods("POST")
+	fileRouter.HandleFunc("/{id}/download-token", CreateDownloadToken).Methods("POST")
+
+	registerCommentRoutes(fileRouter)
+	registerGrantRoutes(fileRouter)
+}
+
+// registerDownloadTokenRoute registers GET /files/{id}?token=, which needs no other credentials.
+func registerDownloadTokenRoute(router *mux.Router) {
+	router.HandleFunc("/files/{id}", GetFileWithDownloadToken).Methods("GET").Queries("token", "{token}")
+}
+
+// writeFileError maps errors returned by the File model to HTTP responses.
+func writeFileError(w http.ResponseWriter, err error) {
+	status, code := fileErrorStatus(err)
+	if code == "" {
+		utils.ErrorJsonResponse(w, err.Error(), status)
+		return
+	}
+	utils.ErrorCodeJsonResponse(w, code, err.Error(), status)
+}
+
+// fileErrorStatus returns the HTTP status and error code for an error returned by the File model.
+// Unexpected errors map to 500 with no code.
+func fileErrorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, models.ErrVersionConflict):
+		return http.StatusConflict, "conflict"
+	case errors.Is(err, models.ErrFileLocked):
+		return http.StatusLocked, "locked"
+	case errors.Is(err, models.ErrNotFileOwner):
+		return http.StatusForbidden, "forbidden"
+	case errors.Is(err, models.ErrLegalHold):
+		return http.StatusLocked, "legal_hold"
+	case errors.Is(err, models.ErrNameConflict):
+		return http.StatusConflict, "name_conflict"
+	case errors.Is(err, models.ErrFileCountLimitExceeded):
+		return http.StatusUnprocessableEntity, "file_count_limit_exceeded"
+	case errors.Is(err, models.ErrFileTooLarge):
+		return http.StatusRequestEntityTooLarge, "file_too_large"
+	case errors.Is(err, models.ErrQuotaExceeded):
+		return http.StatusUnprocessableEntity, "quota_exceeded"
+	case errors.Is(err, models.ErrInvalidMetadata):
+		return http.StatusUnprocessableEntity, "invalid_metadata"
+	case errors.As(err, new(*models.MissingFieldsError)):
+		return http.StatusUnprocessableEntity, "missing_required_fields"
+	case utils.IsValidationError(err):
+		return http.StatusUnprocessableEntity, "validation_failed"
+	default:
+		return http.StatusInternalServerError, ""
+	}
+}
+
+// findVisibleFile loads the file with the given ID if it is visible to userID.
+func findVisibleFile(userID uint, id uint64, file *models.File) error {
+	return config.DB.Scopes(repositories.VisibleTo(userID, repositories.VisibilityOptions{})).First(file, id).Error
+}
+
+// fileCacheKey is the cache key for the file row with the given ID.
+func fileCacheKey(id uint64) string {
+	return "file:" + strconv.FormatUint(id, 10)
+}
+
+// findVisibleFileCached is findVisibleFile for hot read paths. A cached row is only served when
+// it belongs to userID; anything else falls through to the visibility-scoped query, so the cache
+// can never grant access the database would refuse. Cache errors are treated as misses.
+func findVisibleFileCached(r *http.Request, userID uint, id uint64, file *models.File) error {
+	ctx, cancel := context.WithTimeout(r.Context(), viper.GetDuration("cache.timeout"))
+	defer cancel()
+
+	if data, err := config.Cache.Get(ctx, fileCacheKey(id)); err == nil {
+		if json.Unmarshal(data, file) == nil && file.UserID == userID {
+			return nil
+		}
+		*file = models.File{}
+	} else if !errors.Is(err, cache.ErrMiss) {
+		log.Printf("Error reading file %d from cache: %s", id, err)
+	}
+
+	if err := findVisibleFile(userID, id, file); err != nil {
+		return err
+	}
+	if data, err := json.Marshal(file); err == nil {
+		if err := config.Cache.Set(ctx, fileCacheKey(id), data, viper.GetDuration("cache.ttl")); err != nil {
+			log.Printf("Error caching file %d: %s", id, err)
+		}
+	}
+	return nil
+}
+
+// invalidateCachedFile drops the cached copy of a file. Every handler that modifies or deletes
+// a file must call it once the change is made. It doesn't use the request context: the change
+// is already committed, so the invalidation must happen even if the request's deadline passed.
+func invalidateCachedFile(id uint) {
+	ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("cache.timeout"))
+	defer cancel()
+
+	if err := config.Cache.Delete(ctx, fileCacheKey(uint64(id))); err != nil {
+		log.Printf("Error invalidating cached file %d: %s", id, err)
+	}
+}
+
+// InvalidateCachedFiles drops the cached copies of files changed outside a request, such as
+// by the cleanup job or a backfill at startup.
+func InvalidateCachedFiles(ids []uint) {
+	for _, id := range ids {
+		invalidateCachedFile(id)
+	}
+}
+
+// fileCreateOptions builds the file creation policies from configuration.
+func fileCreateOptions() models.CreateOptions {
+	return models.CreateOptions{
+		MaxFilesPerUser:     viper.GetInt64("limits.max_files_per_user"),
+		RequiredFields:      viper.GetStringSlice("upload.required_fields"),
+		DescriptionTemplate: viper.GetString("upload.description_template"),
+	}
 }
 
-// CreateFile handles file creation.
+// parseFileID decodes a file ID taken from the request path. Numeric IDs are accepted only
+// while api.numeric_ids is enabled.
+func parseFileID(param string) (uint64, error) {
+	id, err := utils.ParsePublicID(param)
+	return uint64(id), err
+}
+
+// internalFileIDs converts public file IDs from a request body to database IDs.
+func internalFileIDs(ids []utils.PublicID) []uint {
+	internal := make([]uint, len(ids))
+	for i, id := range ids {
+		internal[i] = uint(id)
+	}
+	return internal
+}
+
+// parseIfMatch extracts the file version from an If-Match header such as "3" or W/"3".
+func parseIfMatch(header string) (uint, bool) {
+	header = strings.Trim(strings.TrimPrefix(strings.TrimSpace(header), "W/"), `"`)
+	version, err := strconv.ParseUint(header, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return uint(version), true
+}
+
+// CreateFile handles file creation. ?on_conflict=error|rename|replace overrides upload.on_conflict
+// for a name the user already has; the Upload-Action header reports what was done.
 func CreateFile(w http.ResponseWriter, r *http.Request) {
 	var file models.File
 	if err := json.NewDecoder(r.Body).Decode(&file); err != nil {
@@ -32,73 +199,254 @@ func CreateFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get the user ID from the request context
-	//userID := r.Context().Value("user_id").(uint)
-
-	token := r.Header.Get("Authorization")
-	claims, err := utils.VerifyToken(token)
-	if err != nil {
-		utils.ErrorJsonResponse(w, "Invalid token", http.StatusUnauthorized)
+	userID, _ := utils.GetUserID(r)
+	if rejectSuspended(w, userID) {
 		return
 	}
 
-	file.UserID = claims.UserID
-	if err := file.CreateFile(config.DB); err != nil {
+	createFile(w, r, &file, userID, utils.GetSessionID(r), onConflictMode(r))
+}
+
+// onConflictMode returns the ?on_conflict mode of an upload, or upload.on_conflict if the
+// request doesn't set one.
+func onConflictMode(r *http.Request) string {
+	if onConflict := r.URL.Query().Get("on_conflict"); onConflict != "" {
+		return onConflict
+	}
+	return viper.GetString("upload.on_conflict")
+}
+
+// createFile stores file for userID, resolving a name conflict as onConflict says, and writes
+// the response. It reports whether the file was stored (or an earlier identical request stored
+// it), so that callers can undo anything they reserved for it otherwise.
+func createFile(w http.ResponseWriter, r *http.Request, file *models.File, userID uint, sessionID, onConflict string) bool {
+	switch onConflict {
+	case models.ConflictError, models.ConflictRename, models.ConflictReplace:
+	default:
+		utils.ErrorJsonResponse(w, "on_conflict must be error, rename or replace", http.StatusBadRequest)
+		return false
+	}
+
+	// Retries carrying the same Idempotency-Key get the original response instead of a duplicate file.
+	var idempotencyKey *models.IdempotencyKey
+	if key := r.Header.Get("Idempotency-Key"); key != "" {
+		if len(key) > 255 {
+			utils.ErrorJsonResponse(w, "Idempotency-Key is too long", http.StatusBadRequest)
+			return false
+		}
+
+		record, reserved, err := models.ReserveIdempotencyKey(config.DB, userID, key, viper.GetDuration("idempotency.lease"))
+		if errors.Is(err, models.ErrIdempotencyKeyInProgress) {
+			utils.ErrorCodeJsonResponse(w, "idempotency_key_in_progress", err.Error(), http.StatusConflict)
+			return false
+		}
+		if err != nil {
+			utils.ErrorJsonResponse(w, err.Error(), http.StatusInternalServerError)
+			return false
+		}
+		if !reserved {
+			w.Header().Set("Idempotent-Replayed", "true")
+			utils.JsonResponse(w, record.StatusCode, json.RawMessage(record.Response))
+			return true
+		}
+		idempotencyKey = record
+	}
+
+	plan, err := userPlan(r, userID)
+	if err != nil {
+		releaseIdempotencyKey(idempotencyKey)
 		utils.ErrorJsonResponse(w, err.Error(), http.StatusInternalServerError)
-		return
+		return false
+	}
+
+	opts := fileCreateOptions()
+	opts.OnConflict = onConflict
+	opts.SessionID = sessionID
+	opts.Plan = plan
+
+	file.UserID = userID
+	action, err := file.CreateFile(config.DB, opts)
+	if err != nil {
+		releaseIdempotencyKey(idempotencyKey)
+		writeFileError(w, err)
+		return false
+	}
+
+	status := http.StatusCreated
+	if action == models.UploadReplaced {
+		status = http.StatusOK
+		invalidateCachedFile(file.ID)
+	}
+
+	if idempotencyKey != nil {
+		response, err := json.Marshal(file)
+		if err == nil {
+			err = idempotencyKey.Complete(config.DB, file.ID, status, response, viper.GetDuration("idempotency.ttl"))
+		}
+		if err != nil {
+			log.Printf("Error storing idempotent response for file %d: %s", file.ID, err)
+		}
 	}
 
-	utils.JsonResponse(w, http.StatusCreated, file)
+	w.Header().Set("Upload-Action", action)
+	utils.JsonResponse(w, status, file)
+	return true
 }
 
-// GetFiles returns a list of all files.
+// releaseIdempotencyKey frees key, if there is one, after the request holding it failed. If
+// that fails too, retries get idempotency_key_in_progress until idempotency.lease runs out.
+func releaseIdempotencyKey(key *models.IdempotencyKey) {
+	if key == nil {
+		return
+	}
+	if err := key.Release(config.DB); err != nil {
+		log.Printf("Error releasing idempotency key %d: %s", key.ID, err)
+	}
+}
+
+// GetFiles returns the files visible to the current user, optionally filtered by ?metadata.<key>=<value>,
+// ?pinned=true|false and ?category=<category>. ?fields=id,name,... returns only the listed fields.
 // TODO: Add pagination and filtering for production.
 func GetFiles(w http.ResponseWriter, r *http.Request) {
+	fields, err := repositories.ParseFields(r.URL.Query().Get("fields"))
+	if err != nil {
+		utils.ErrorCodeJsonResponse(w, "invalid_fields", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	metadataFilter := map[string]string{}
+	for param, values := range r.URL.Query() {
+		if key := strings.TrimPrefix(param, "metadata."); key != param && len(values) > 0 {
+			metadataFilter[key] = values[0]
+		}
+	}
+
+	userID, _ := utils.GetUserID(r)
+	query := readDB(r).Scopes(repositories.VisibleTo(userID, repositories.VisibilityOptions{}), repositories.SelectFields(fields))
+	if pinned := r.URL.Query().Get("pinned"); pinned != "" {
+		query = query.Where("pinned = ?", pinned == "true")
+	}
+	if category := r.URL.Query().Get("category"); category != "" {
+		query = query.Where("category = ?", category)
+	}
+
 	var files []models.File
-	if err := config.DB.Find(&files).Error; err != nil {
+	if err := models.FilterByMetadata(query, metadataFilter).Find(&files).Error; err != nil {
 		utils.ErrorJsonResponse(w, "Error getting files", http.StatusInternalServerError)
 		return
 	}
 
-	utils.JsonResponse(w, http.StatusOK, files)
+	results := make([]interface{}, len(files))
+	for i, file := range files {
+		if results[i], err = projectFile(file, fields); err != nil {
+			utils.ErrorJsonResponse(w, "Error encoding files", http.StatusInternalServerError)
+			return
+		}
+	}
+	utils.JsonResponse(w, http.StatusOK, results)
+}
+
+// projectFile returns file with only the given JSON fields, or the whole file if fields is nil.
+func projectFile(file models.File, fields []string) (interface{}, error) {
+	if fields == nil {
+		return file, nil
+	}
+
+	data, err := json.Marshal(file)
+	if err != nil {
+		return nil, err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+
+	projected := make(map[string]json.RawMessage, len(fields))
+	for _, field := range fields {
+		projected[field] = all[field]
+	}
+	return projected, nil
 }
 
 // GetFile retrieves a single file by ID.
 func GetFile(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
-	id, err := strconv.ParseUint(params["id"], 10, 64)
+	id, err := parseFileID(params["id"])
 	if err != nil {
 		utils.ErrorJsonResponse(w, "Invalid file ID", http.StatusBadRequest)
 		return
 	}
 
+	userID, _ := utils.GetUserID(r)
 	var file models.File
-	if err := config.DB.First(&file, id).Error; err != nil {
+	if err := findVisibleFile(userID, id, &file); err != nil {
 		utils.ErrorJsonResponse(w, "File not found", http.StatusNotFound)
 		return
 	}
 
+	w.Header().Set("ETag", fmt.Sprintf(`"%d"`, file.Version))
 	utils.JsonResponse(w, http.StatusOK, file)
 }
 
-// UpdateFile updates a file.
-func UpdateFile(w http.ResponseWriter, r *http.Request) {
-	params := mux.Vars(r)
-	id, err := strconv.ParseUint(params["id"], 10, 64)
+// BatchGetFiles returns several files in one round trip, keyed by ID. IDs the caller cannot
+// see get a per-item error instead of failing the whole request. ?fields= works as for GetFiles.
+func BatchGetFiles(w http.ResponseWriter, r *http.Request) {
+	fields, err := repositories.ParseFields(r.URL.Query().Get("fields"))
 	if err != nil {
-		utils.ErrorJsonResponse(w, "Invalid file ID", http.StatusBadRequest)
+		utils.ErrorCodeJsonResponse(w, "invalid_fields", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		IDs []utils.PublicID `json:"ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		utils.ErrorJsonResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(body.IDs) == 0 {
+		utils.ErrorJsonResponse(w, "ids is required", http.StatusBadRequest)
 		return
 	}
+	if maxIDs := viper.GetInt("files.batch_max_ids"); len(body.IDs) > maxIDs {
+		utils.ErrorCodeJsonResponse(w, "too_many_ids", fmt.Sprintf("At most %d ids may be requested at once", maxIDs), http.StatusUnprocessableEntity)
+		return
+	}
+
+	userID, _ := utils.GetUserID(r)
+	files, err := repositories.NewFileRepository(readDB(r).Scopes(repositories.SelectFields(fields))).GetFilesByIDs(userID, internalFileIDs(body.IDs))
+	if err != nil {
+		utils.ErrorJsonResponse(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Missing and invisible files share one error so the response doesn't reveal which IDs exist.
+	results := make(map[string]interface{}, len(body.IDs))
+	for _, id := range body.IDs {
+		results[utils.EncodePublicID(uint(id))] = map[string]string{"error": "File not found", "code": "not_found"}
+	}
+	for _, file := range files {
+		if results[utils.EncodePublicID(file.ID)], err = projectFile(file, fields); err != nil {
+			utils.ErrorJsonResponse(w, "Error encoding files", http.StatusInternalServerError)
+			return
+		}
+	}
 
-	token := r.Header.Get("Authorization")
-	claims, err := utils.VerifyToken(token)
+	utils.JsonResponse(w, http.StatusOK, map[string]interface{}{"files": results})
+}
+
+// UpdateFile updates a file.
+func UpdateFile(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, err := parseFileID(params["id"])
 	if err != nil {
-		utils.ErrorJsonResponse(w, "Invalid token", http.StatusUnauthorized)
+		utils.ErrorJsonResponse(w, "Invalid file ID", http.StatusBadRequest)
 		return
 	}
 
+	userID, _ := utils.GetUserID(r)
 	var file models.File
-	if err := config.DB.First(&file, id).Error; err != nil {
+	if err := findVisibleFile(userID, id, &file); err != nil {
 		utils.ErrorJsonResponse(w, "File not found", http.StatusNotFound)
 		return
 	}
@@ -109,40 +457,366 @@ func UpdateFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := file.UpdateFile(config.DB, claims.UserID, &updatedFile); err != nil { 
+	// The expected version comes from If-Match, falling back to the "version" body field.
+	expectedVersion, ok := parseIfMatch(r.Header.Get("If-Match"))
+	if !ok {
+		expectedVersion, ok = updatedFile.Version, updatedFile.Version != 0
+	}
+	if !ok {
+		utils.ErrorCodeJsonResponse(w, "version_required", "If-Match header or version field is required", http.StatusPreconditionRequired)
+		return
+	}
+
+	plan, err := userPlan(r, userID)
+	if err != nil {
 		utils.ErrorJsonResponse(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	if err := file.UpdateFile(config.DB, userID, utils.GetSessionID(r), expectedVersion, &updatedFile, plan); err != nil { 
+		writeFileError(w, err)
+		return
+	}
+	invalidateCachedFile(file.ID)
+
+	w.Header().Set("ETag", fmt.Sprintf(`"%d"`, file.Version))
 	utils.JsonResponse(w, http.StatusOK, file)
 }
 
 // DeleteFile deletes a file.
 func DeleteFile(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
-	id, err := strconv.ParseUint(params["id"], 10, 64)
+	id, err := parseFileID(params["id"])
 	if err != nil {
 		utils.ErrorJsonResponse(w, "Invalid file ID", http.StatusBadRequest)
 		return
 	}
 
-	token := r.Header.Get("Authorization")
-	claims, err := utils.VerifyToken(token)
+	userID, _ := utils.GetUserID(r)
+	var file models.File
+	if err := findVisibleFile(userID, id, &file); err != nil {
+		utils.ErrorJsonResponse(w, "File not found", http.StatusNotFound)
+		return
+	}
+
+	if err := file.DeleteFile(config.DB, userID, utils.GetSessionID(r)); err != nil {
+		writeFileError(w, err)
+		return
+	}
+	invalidateCachedFile(file.ID)
+
+	utils.JsonResponse(w, http.StatusOK, file) 
+}
+
+// BulkDeleteFiles deletes several files at once and reports the outcome per ID.
+// ?permanent=true purges the rows instead of moving them to the trash; it is refused to
+// impersonation tokens. If the X-Request-Timeout
+// deadline passes, the files deleted so far stay deleted and the 504 response lists them along
+// with the IDs that were skipped.
+func BulkDeleteFiles(w http.ResponseWriter, r *http.Request) {
+	// The raw body is kept because confirmation tokens are bound to it.
+	raw, err := io.ReadAll(r.Body)
 	if err != nil {
-		utils.ErrorJsonResponse(w, "Invalid token", http.StatusUnauthorized)
+		utils.ErrorJsonResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	var body struct {
+		IDs []utils.PublicID `json:"ids"`
+	}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		utils.ErrorJsonResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(body.IDs) == 0 {
+		utils.ErrorJsonResponse(w, "ids is required", http.StatusBadRequest)
+		return
+	}
+	if maxIDs := viper.GetInt("files.batch_max_ids"); len(body.IDs) > maxIDs {
+		utils.ErrorCodeJsonResponse(w, "too_many_ids", fmt.Sprintf("At most %d ids may be deleted at once", maxIDs), http.StatusUnprocessableEntity)
+		return
+	}
+
+	userID, _ := utils.GetUserID(r)
+	files, err := repositories.NewFileRepository(config.DB).GetFilesByIDs(userID, internalFileIDs(body.IDs))
+	if err != nil {
+		utils.ErrorJsonResponse(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	permanent := r.URL.Query().Get("permanent") == "true"
+	// An admin acting as the user may trash files, which can be restored, but not purge them.
+	if _, impersonating := utils.GetImpersonatorID(r); impersonating && permanent {
+		utils.ErrorCodeJsonResponse(w, "impersonation_forbidden", "Files cannot be permanently deleted while impersonating", http.StatusForbidden)
+		return
+	}
+	var totalSize int64
+	for _, file := range files {
+		totalSize += file.Size
+	}
+	summary := map[string]interface{}{"files": len(files), "bytes": totalSize, "permanent": permanent}
+	if !requireConfirmation(w, r, "bulk_delete", userID, raw, summary) {
+		return
+	}
+
+	deleted, failures, err := models.BulkDeleteFiles(r.Context(), config.DB, userID, utils.GetSessionID(r), files, permanent)
+	timedOut := errors.Is(err, context.DeadlineExceeded)
+	if err != nil && !timedOut {
+		utils.ErrorJsonResponse(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	wasDeleted := make(map[uint]bool, len(deleted))
+	for _, id := range deleted {
+		wasDeleted[id] = true
+		invalidateCachedFile(id)
+	}
+
+	type failure struct {
+		ID   utils.PublicID `json:"id"`
+		Code string         `json:"code"`
+	}
+	found := make(map[uint]bool, len(files))
+	for _, file := range files {
+		found[file.ID] = true
+	}
+	failed := []failure{}
+	skipped := []utils.PublicID{}
+	seen := map[utils.PublicID]bool{}
+	for _, id := range body.IDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		if !found[uint(id)] {
+			failed = append(failed, failure{ID: id, Code: "not_found"})
+			continue
+		}
+		if err, ok := failures[uint(id)]; ok {
+			_, code := fileErrorStatus(err)
+			if code == "" {
+				code = "internal_error"
+			}
+			failed = append(failed, failure{ID: id, Code: code})
+			continue
+		}
+		if !wasDeleted[uint(id)] {
+			skipped = append(skipped, id)
+		}
+	}
+	deletedIDs := make([]utils.PublicID, len(deleted))
+	for i, id := range deleted {
+		deletedIDs[i] = utils.PublicID(id)
+	}
+
+	if timedOut {
+		writeDeadlineExceeded(w, map[string]interface{}{
+			"deleted": deletedIDs,
+			"failed":  failed,
+			"skipped": skipped,
+		})
+		return
+	}
+	utils.JsonResponse(w, http.StatusOK, map[string]interface{}{
+		"deleted": deletedIDs,
+		"failed":  failed,
+	})
+}
+
+// LockFile gives the caller's session exclusive write access to a file for a limited time.
+func LockFile(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, err := parseFileID(params["id"])
+	if err != nil {
+		utils.ErrorJsonResponse(w, "Invalid file ID", http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		TTLSeconds int `json:"ttl_seconds"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			utils.ErrorJsonResponse(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	ttl := viper.GetDuration("files.lock_ttl")
+	if body.TTLSeconds > 0 {
+		ttl = time.Duration(body.TTLSeconds) * time.Second
+	}
+	if maxTTL := viper.GetDuration("files.lock_max_ttl"); ttl > maxTTL {
+		ttl = maxTTL
+	}
+
+	userID, _ := utils.GetUserID(r)
+	var file models.File
+	if err := findVisibleFile(userID, id, &file); err != nil {
+		utils.ErrorJsonResponse(w, "File not found", http.StatusNotFound)
+		return
+	}
+
+	if err := file.Lock(config.DB, userID, utils.GetSessionID(r), ttl); err != nil {
+		writeFileError(w, err)
+		return
+	}
+	invalidateCachedFile(file.ID)
+
+	utils.JsonResponse(w, http.StatusOK, file)
+}
+
+// UnlockFile releases the caller's lock on a file.
+func UnlockFile(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, err := parseFileID(params["id"])
+	if err != nil {
+		utils.ErrorJsonResponse(w, "Invalid file ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, _ := utils.GetUserID(r)
+	var file models.File
+	if err := findVisibleFile(userID, id, &file); err != nil {
+		utils.ErrorJsonResponse(w, "File not found", http.StatusNotFound)
+		return
+	}
+
+	if err := file.Unlock(config.DB, userID, utils.GetSessionID(r)); err != nil {
+		writeFileError(w, err)
+		return
+	}
+	invalidateCachedFile(file.ID)
+
+	utils.JsonResponse(w, http.StatusOK, file)
+}
+
+// PinFile exempts a file from automatic deletion.
+func PinFile(w http.ResponseWriter, r *http.Request) {
+	setPinned(w, r, true)
+}
+
+// UnpinFile makes a file subject to automatic deletion again.
+func UnpinFile(w http.ResponseWriter, r *http.Request) {
+	setPinned(w, r, false)
+}
+
+// setPinned handles both pin endpoints.
+func setPinned(w http.ResponseWriter, r *http.Request, pinned bool) {
+	params := mux.Vars(r)
+	id, err := parseFileID(params["id"])
+	if err != nil {
+		utils.ErrorJsonResponse(w, "Invalid file ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, _ := utils.GetUserID(r)
+	var file models.File
+	if err := findVisibleFile(userID, id, &file); err != nil {
+		utils.ErrorJsonResponse(w, "File not found", http.StatusNotFound)
+		return
+	}
+
+	if err := file.SetPinned(config.DB, userID, utils.GetSessionID(r), pinned); err != nil {
+		writeFileError(w, err)
+		return
+	}
+	invalidateCachedFile(file.ID)
+
+	w.Header().Set("ETag", fmt.Sprintf(`"%d"`, file.Version))
+	utils.JsonResponse(w, http.StatusOK, file)
+}
+
+// UpdateFileMetadata merges the request body into a file's metadata. A null value deletes the key.
+func UpdateFileMetadata(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, err := parseFileID(params["id"])
+	if err != nil {
+		utils.ErrorJsonResponse(w, "Invalid file ID", http.StatusBadRequest)
+		return
+	}
+
+	var patch map[string]*string
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		utils.ErrorJsonResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	userID, _ := utils.GetUserID(r)
+	var file models.File
+	if err := findVisibleFile(userID, id, &file); err != nil {
+		utils.ErrorJsonResponse(w, "File not found", http.StatusNotFound)
+		return
+	}
+
+	if err := file.UpdateMetadata(config.DB, userID, utils.GetSessionID(r), patch); err != nil {
+		writeFileError(w, err)
+		return
+	}
+	invalidateCachedFile(file.ID)
+
+	w.Header().Set("ETag", fmt.Sprintf(`"%d"`, file.Version))
+	utils.JsonResponse(w, http.StatusOK, file)
+}
+
+// CreateDownloadToken issues a short-lived signed token for GET /files/{id}?token=...
+func CreateDownloadToken(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, err := parseFileID(params["id"])
+	if err != nil {
+		utils.ErrorJsonResponse(w, "Invalid file ID", http.StatusBadRequest)
 		return
 	}
 
+	userID, _ := utils.GetUserID(r)
+	if rejectSuspended(w, userID) {
+		return
+	}
 	var file models.File
-	if err := config.DB.First(&file, id).Error; err != nil {
+	if err := findVisibleFile(userID, id, &file); err != nil {
 		utils.ErrorJsonResponse(w, "File not found", http.StatusNotFound)
 		return
 	}
 
-	if err := file.DeleteFile(config.DB, claims.UserID); err != nil {
+	token, expiresAt := utils.GenerateDownloadToken(file.ID, userID, viper.GetDuration("files.download_token_ttl"))
+	utils.JsonResponse(w, http.StatusCreated, map[string]interface{}{
+		"token":      token,
+		"url":        utils.AbsoluteURL(r, fmt.Sprintf("/files/%s?token=%s", utils.EncodePublicID(file.ID), token)),
+		"expires_at": expiresAt,
+	})
+}
+
+// GetFileWithDownloadToken serves a file to a request authorized by a signed download token.
+func GetFileWithDownloadToken(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, err := parseFileID(params["id"])
+	if err != nil {
+		utils.ErrorJsonResponse(w, "Invalid file ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := utils.VerifyDownloadToken(params["token"], uint(id))
+	if err != nil {
+		utils.ErrorJsonResponse(w, "Invalid or expired download token", http.StatusUnauthorized)
+		return
+	}
+
+	var file models.File
+	if err := findVisibleFileCached(r, userID, id, &file); err != nil {
+		utils.ErrorJsonResponse(w, "File not found", http.StatusNotFound)
+		return
+	}
+	// Download links are the owner's public surface, so they go dark while the owner is
+	// suspended or deleted, even for links the owner made.
+	active, err := ownerActive(r, file.UserID)
+	if err != nil {
 		utils.ErrorJsonResponse(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if !active {
+		utils.ErrorJsonResponse(w, "File not found", http.StatusNotFound)
+		return
+	}
 
-	utils.JsonResponse(w, http.StatusOK, file) 
-}
\ No newline at end of file
+	w.Header().Set("ETag", fmt.Sprintf(`"%d"`, file.Version))
+	utils.JsonResponse(w, http.StatusOK, file)
+}
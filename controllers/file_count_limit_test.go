@@ -0,0 +1,40 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/spf13/viper"
+	"go-share/config"
+	"go-share/models"
+)
+
+func TestFileCountLimit(t *testing.T) {
+	api := newTestAPI(t)
+	viper.Set("limits.max_files_per_user", 1)
+	owner, token := createTestUser(t, "owner@example.com")
+	_, adminToken := createTestAdmin(t, "admin@example.com")
+
+	expectStatus(t, api, uploadFile(t, token, "a.txt", 1, ""), http.StatusCreated)
+	expectError(t, api, uploadFile(t, token, "b.txt", 1, ""), http.StatusUnprocessableEntity, "file_count_limit_exceeded")
+
+	// Replacing a file doesn't add one, so it is allowed at the limit.
+	expectStatus(t, api, uploadFile(t, token, "a.txt", 2, "replace"), http.StatusOK)
+
+	// An admin rebuilds a counter that drifted.
+	if err := config.DB.Model(owner).Update("file_count", 7).Error; err != nil {
+		t.Fatal(err)
+	}
+	w := serve(api, newRequest(t, "POST", "/admin/file-counts/recalculate", adminToken, nil))
+	var body map[string]int64
+	decode(t, w, &body)
+	if w.Code != http.StatusOK || body["corrected_users"] != 1 {
+		t.Errorf("recalculate: got %d %s, want one corrected user", w.Code, w.Body)
+	}
+	var stored models.User
+	config.DB.First(&stored, owner.ID)
+	if stored.FileCount != 1 {
+		t.Errorf("file_count %d after recalculating, want 1", stored.FileCount)
+	}
+	expectStatus(t, api, newRequest(t, "POST", "/admin/file-counts/recalculate", token, nil), http.StatusForbidden)
+}
@@ -0,0 +1,151 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"go-share/config"
+	"go-share/models"
+	"go-share/repositories"
+	"go-share/utils"
+)
+
+// keysOf returns the sorted keys of a decoded JSON object.
+func keysOf(object map[string]json.RawMessage) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestGetFilesFields(t *testing.T) {
+	api := newTestAPI(t)
+	owner, token := createTestUser(t, "owner@example.com")
+	file := createTestFile(t, owner, "a.txt", 42)
+
+	w := serve(api, newRequest(t, "GET", "/files?fields=id,name,size,created_at", token, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d %s", w.Code, w.Body)
+	}
+	var files []map[string]json.RawMessage
+	decode(t, w, &files)
+	if len(files) != 1 {
+		t.Fatalf("got %d files, want 1", len(files))
+	}
+	if got, want := keysOf(files[0]), []string{"created_at", "id", "name", "size"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("keys = %v, want %v", got, want)
+	}
+	want := fmt.Sprintf(`{"created_at":%q,"id":%q,"name":"a.txt","size":42}`, file.CreatedAt.Format("2006-01-02T15:04:05.999Z07:00"), utils.EncodePublicID(file.ID))
+	if got, _ := json.Marshal(files[0]); string(got) != want {
+		t.Errorf("projected file = %s, want %s", got, want)
+	}
+
+	// Without ?fields= the whole file is returned.
+	w = serve(api, newRequest(t, "GET", "/files", token, nil))
+	decode(t, w, &files)
+	for _, key := range []string{"description", "metadata", "path", "version"} {
+		if _, ok := files[0][key]; !ok {
+			t.Errorf("the full file has no %q", key)
+		}
+	}
+}
+
+func TestBatchGetFilesFields(t *testing.T) {
+	api := newTestAPI(t)
+	owner, token := createTestUser(t, "owner@example.com")
+	file := createTestFile(t, owner, "a.txt", 1)
+	id, missing := utils.EncodePublicID(file.ID), utils.EncodePublicID(file.ID+100)
+
+	body := map[string]interface{}{"ids": []string{id, missing}}
+	w := serve(api, newRequest(t, "POST", "/files/batch-get?fields=name", token, body))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d %s", w.Code, w.Body)
+	}
+	var got struct {
+		Files map[string]map[string]json.RawMessage `json:"files"`
+	}
+	decode(t, w, &got)
+	if keys := keysOf(got.Files[id]); !reflect.DeepEqual(keys, []string{"name"}) {
+		t.Errorf("projected file has keys %v, want [name]", keys)
+	}
+	if keys := keysOf(got.Files[missing]); !reflect.DeepEqual(keys, []string{"code", "error"}) {
+		t.Errorf("missing file has keys %v, want the per-item error", keys)
+	}
+}
+
+func TestFieldsUnknown(t *testing.T) {
+	api := newTestAPI(t)
+	_, token := createTestUser(t, "owner@example.com")
+
+	for _, r := range []*http.Request{
+		newRequest(t, "GET", "/files?fields=name,locked_by", token, nil),
+		newRequest(t, "POST", "/files/batch-get?fields=name,locked_by", token, map[string]interface{}{"ids": []string{"x"}}),
+	} {
+		w := serve(api, r)
+		var body map[string]string
+		decode(t, w, &body)
+		if w.Code != http.StatusBadRequest || body["code"] != "invalid_fields" {
+			t.Errorf("%s %s: got %d %s, want 400 invalid_fields", r.Method, r.URL, w.Code, w.Body)
+		}
+		if !strings.Contains(body["error"], strings.Join(repositories.ProjectableFields, ", ")) {
+			t.Errorf("%s %s: error %q doesn't list the valid fields", r.Method, r.URL, body["error"])
+		}
+	}
+}
+
+// benchmarkRows is the size of the listing the payload benchmark serves.
+const benchmarkRows = 10000
+
+// BenchmarkGetFilesFields serves a 10k-file listing in full and with the fields a mobile
+// client needs, reporting the response size of each:
+//
+//	go test ./controllers -run '^$' -bench GetFilesFields
+func BenchmarkGetFilesFields(b *testing.B) {
+	api := newTestAPI(b)
+	owner, token := createTestUser(b, "owner@example.com")
+
+	files := make([]models.File, benchmarkRows)
+	for i := range files {
+		name := fmt.Sprintf("photo-%05d.jpg", i)
+		files[i] = models.File{
+			Name:         name,
+			OriginalName: name,
+			Path:         "/photos/" + name,
+			ContentType:  "image/jpeg",
+			Description:  "Holiday photo uploaded from the phone, kept for the family album",
+			Size:         int64(1 << 20),
+			UserID:       owner.ID,
+			Category:     models.CategoryImage,
+			Metadata:     models.Metadata{"camera": "Pixel 7", "album": "Summer 2026", "location": "Lisbon"},
+			Version:      1,
+		}
+	}
+	if err := config.DB.CreateInBatches(files, 500).Error; err != nil {
+		b.Fatal(err)
+	}
+
+	for _, fields := range []string{"", "id,name,size,created_at"} {
+		name, query := "all", ""
+		if fields != "" {
+			name, query = fields, "?fields="+fields
+		}
+		b.Run(name, func(b *testing.B) {
+			var size int
+			for i := 0; i < b.N; i++ {
+				w := serve(api, newRequest(b, "GET", "/files"+query, token, nil))
+				if w.Code != http.StatusOK {
+					b.Fatalf("got %d %s", w.Code, w.Body)
+				}
+				size = w.Body.Len()
+			}
+			b.ReportMetric(float64(size), "bytes/response")
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"go-share/config"
+	"go-share/internal/asserts"
+	"go-share/models"
+	"go-share/utils"
+)
+
+// listingRows is how many rows the listing tests seed: enough that a query per row would
+// break the query budget.
+const listingRows = 20
+
+// seedFiles stores listingRows files owned by owner, each with metadata.
+func seedFiles(t *testing.T, owner *models.User) []*models.File {
+	t.Helper()
+
+	files := make([]*models.File, listingRows)
+	for i := range files {
+		files[i] = createTestFile(t, owner, fmt.Sprintf("f%d.txt", i), 1)
+		value := "x"
+		if err := files[i].UpdateMetadata(config.DB, owner.ID, "", map[string]*string{"project": &value}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return files
+}
+
+func TestGetFilesQueries(t *testing.T) {
+	api := newTestAPI(t)
+	owner, token := createTestUser(t, "owner@example.com")
+	seedFiles(t, owner)
+
+	asserts.MaxQueries(t, 1)
+	w := serve(api, newRequest(t, "GET", "/files?metadata.project=x", token, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d %s", w.Code, w.Body)
+	}
+	var files []models.File
+	decode(t, w, &files)
+	if len(files) != listingRows {
+		t.Errorf("got %d files, want %d", len(files), listingRows)
+	}
+}
+
+func TestBatchGetFilesQueries(t *testing.T) {
+	api := newTestAPI(t)
+	owner, token := createTestUser(t, "owner@example.com")
+	files := seedFiles(t, owner)
+	ids := make([]string, len(files))
+	for i, file := range files {
+		ids[i] = utils.EncodePublicID(file.ID)
+	}
+
+	asserts.MaxQueries(t, 1)
+	w := serve(api, newRequest(t, "POST", "/files/batch-get", token, map[string]interface{}{"ids": ids}))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d %s", w.Code, w.Body)
+	}
+	var body struct {
+		Files map[string]models.File `json:"files"`
+	}
+	decode(t, w, &body)
+	if len(body.Files) != listingRows {
+		t.Errorf("got %d files, want %d", len(body.Files), listingRows)
+	}
+}
+
+func TestGetSharedWithMeQueries(t *testing.T) {
+	api := newTestAPI(t)
+	owner, _ := createTestUser(t, "owner@example.com")
+	grantee, token := createTestUser(t, "grantee@example.com")
+	for _, file := range seedFiles(t, owner) {
+		if _, err := file.CreateGrant(config.DB, grantee, models.PermissionRead, time.Now().Add(time.Hour), models.AuditLog{ActorID: owner.ID}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	asserts.MaxQueries(t, 2)
+	w := serve(api, newRequest(t, "GET", "/files/shared-with-me", token, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d %s", w.Code, w.Body)
+	}
+	var body struct {
+		Files []models.SharedFile `json:"files"`
+	}
+	decode(t, w, &body)
+	if len(body.Files) != listingRows {
+		t.Errorf("got %d files, want %d", len(body.Files), listingRows)
+	}
+}
@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/spf13/viper"
+	"go-share/config"
+	"go-share/models"
+	"go-share/utils"
+	"gorm.io/gorm"
+)
+
+// registerGrantRoutes registers the access grant routes on the authenticated file router.
+func registerGrantRoutes(fileRouter *mux.Router) {
+	fileRouter.HandleFunc("/{id}/grants", CreateGrant).Methods("POST")
+	fileRouter.HandleFunc("/{id}/grants", GetGrants).Methods("GET")
+	fileRouter.HandleFunc("/{id}/grants/{grantID}", RevokeGrant).Methods("DELETE")
+}
+
+// CreateGrant gives another user temporary access to a file.
+func CreateGrant(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Email      string    `json:"email"`
+		Permission string    `json:"permission"`
+		ExpiresAt  time.Time `json:"expires_at"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		utils.ErrorJsonResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if body.Permission == "" {
+		body.Permission = models.PermissionRead
+	}
+	if body.Permission != models.PermissionRead {
+		utils.ErrorJsonResponse(w, "Permission must be read", http.StatusBadRequest)
+		return
+	}
+	if !body.ExpiresAt.After(time.Now()) {
+		utils.ErrorJsonResponse(w, "expires_at must be in the future", http.StatusBadRequest)
+		return
+	}
+	if maxTTL := viper.GetDuration("files.grant_max_ttl"); time.Until(body.ExpiresAt) > maxTTL {
+		utils.ErrorJsonResponse(w, "expires_at is too far in the future", http.StatusBadRequest)
+		return
+	}
+
+	var file models.File
+	userID, ok := loadAccessibleFile(w, r, &file)
+	if !ok || rejectSuspended(w, userID) {
+		return
+	}
+
+	var grantee models.User
+	if err := config.DB.Where("email = ?", body.Email).First(&grantee).Error; err != nil {
+		utils.ErrorJsonResponse(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if grantee.ID == file.UserID {
+		utils.ErrorJsonResponse(w, "The owner already has access", http.StatusBadRequest)
+		return
+	}
+
+	audit := auditEntry(r, userID)
+	grant, err := file.CreateGrant(config.DB, &grantee, body.Permission, body.ExpiresAt, audit)
+	if err != nil {
+		writeFileError(w, err)
+		return
+	}
+
+	utils.JsonResponse(w, http.StatusCreated, grant)
+}
+
+// GetGrants lists a file's active grants. Only the owner may see them.
+func GetGrants(w http.ResponseWriter, r *http.Request) {
+	var file models.File
+	userID, ok := loadAccessibleFile(w, r, &file)
+	if !ok {
+		return
+	}
+	if file.UserID != userID {
+		writeFileError(w, models.ErrNotFileOwner)
+		return
+	}
+
+	grants, err := file.ListGrants(readDB(r))
+	if err != nil {
+		utils.ErrorJsonResponse(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.JsonResponse(w, http.StatusOK, map[string]interface{}{"grants": grants})
+}
+
+// RevokeGrant ends a grant before it expires.
+func RevokeGrant(w http.ResponseWriter, r *http.Request) {
+	grantID, err := strconv.ParseUint(mux.Vars(r)["grantID"], 10, 64)
+	if err != nil {
+		utils.ErrorJsonResponse(w, "Invalid grant ID", http.StatusBadRequest)
+		return
+	}
+
+	var file models.File
+	userID, ok := loadAccessibleFile(w, r, &file)
+	if !ok {
+		return
+	}
+
+	audit := auditEntry(r, userID)
+	if err := file.RevokeGrant(config.DB, uint(grantID), audit); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.ErrorJsonResponse(w, "Grant not found", http.StatusNotFound)
+			return
+		}
+		writeFileError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetSharedWithMe lists the files other users have granted the caller access to.
+func GetSharedWithMe(w http.ResponseWriter, r *http.Request) {
+	userID, _ := utils.GetUserID(r)
+	shared, err := models.ListSharedWithUser(readDB(r), userID)
+	if err != nil {
+		utils.ErrorJsonResponse(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.JsonResponse(w, http.StatusOK, map[string]interface{}{"files": shared})
+}
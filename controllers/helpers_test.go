@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/spf13/viper"
+	"go-share/cache"
+	"go-share/config"
+	"go-share/internal/testdb"
+	"go-share/models"
+	"go-share/utils"
+)
+
+// newTestAPI gives the test a fresh database, an in-memory cache and the default settings,
+// and returns the API routed the way main serves it. Settings changed with viper.Set after
+// the call apply to the test's requests.
+func newTestAPI(t testing.TB) http.Handler {
+	t.Helper()
+	return MethodHandler(newTestRouter(t))
+}
+
+// newTestRouter sets the test up like newTestAPI and returns the router without MethodHandler,
+// for tests that inspect the route table.
+func newTestRouter(t testing.TB) *mux.Router {
+	t.Helper()
+
+	viper.Reset()
+	config.SetDefaults()
+	config.DB = testdb.Open(t, models.All...)
+	c, err := cache.New(cache.Options{Driver: "memory", MaxEntries: 1000})
+	if err != nil {
+		t.Fatalf("creating cache: %s", err)
+	}
+	config.Cache = c
+
+	return NewRouter()
+}
+
+// createTestUser stores a user and returns it with a login token.
+func createTestUser(t testing.TB, email string) (*models.User, string) {
+	t.Helper()
+
+	user := &models.User{Email: email, Password: "correct horse"}
+	if err := user.CreateUser(config.DB); err != nil {
+		t.Fatalf("creating user %s: %s", email, err)
+	}
+	token, err := utils.GenerateToken(user.ID)
+	if err != nil {
+		t.Fatalf("generating token: %s", err)
+	}
+	return user, token
+}
+
+// createTestAdmin stores an admin and returns it with a login token.
+func createTestAdmin(t *testing.T, email string) (*models.User, string) {
+	t.Helper()
+
+	admin, token := createTestUser(t, email)
+	if err := config.DB.Model(admin).Update("is_admin", true).Error; err != nil {
+		t.Fatalf("making %s an admin: %s", email, err)
+	}
+	admin.IsAdmin = true
+	return admin, token
+}
+
+// createTestFile stores a file owned by owner.
+func createTestFile(t *testing.T, owner *models.User, name string, size int64) *models.File {
+	t.Helper()
+
+	file := &models.File{Name: name, Path: "/" + name, ContentType: "text/plain", Size: size, UserID: owner.ID}
+	if _, err := file.CreateFile(config.DB, models.CreateOptions{}); err != nil {
+		t.Fatalf("creating file %s: %s", name, err)
+	}
+	return file
+}
+
+// newRequest builds a request with a JSON body, if body is not nil, authenticated with token,
+// if it is not empty.
+func newRequest(t testing.TB, method, target, token string, body interface{}) *http.Request {
+	t.Helper()
+
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("encoding request body: %s", err)
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	r := httptest.NewRequest(method, target, reader)
+	if body != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	return r
+}
+
+// serve sends r to api and returns the recorded response.
+func serve(api http.Handler, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, r)
+	return w
+}
+
+// decode unmarshals the JSON body of a response into v.
+func decode(t testing.TB, w *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("decoding response %q: %s", w.Body.String(), err)
+	}
+}
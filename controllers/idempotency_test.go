@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+
+	"go-share/config"
+	"go-share/models"
+)
+
+// createWithKey builds POST /files carrying an Idempotency-Key.
+func createWithKey(t *testing.T, token, key string) *http.Request {
+	t.Helper()
+	r := newRequest(t, "POST", "/files", token, map[string]interface{}{"name": "a.txt", "path": "/a.txt", "size": 1})
+	r.Header.Set("Idempotency-Key", key)
+	return r
+}
+
+func TestIdempotentCreateReplay(t *testing.T) {
+	api := newTestAPI(t)
+	_, token := createTestUser(t, "owner@example.com")
+
+	first := serve(api, createWithKey(t, token, "key"))
+	if first.Code != http.StatusCreated {
+		t.Fatalf("first request: got %d %s", first.Code, first.Body)
+	}
+	retry := serve(api, createWithKey(t, token, "key"))
+	if retry.Code != http.StatusCreated || retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("retry: got %d, Idempotent-Replayed %q", retry.Code, retry.Header().Get("Idempotent-Replayed"))
+	}
+	if retry.Body.String() != first.Body.String() {
+		t.Errorf("retry returned %s, want the original %s", retry.Body, first.Body)
+	}
+
+	// A failed request gives its key back, so the retry runs again.
+	failed := serve(api, createWithKey(t, token, "other"))
+	if failed.Code != http.StatusConflict {
+		t.Fatalf("duplicate name: got %d %s", failed.Code, failed.Body)
+	}
+	if w := serve(api, createWithKey(t, token, "other")); w.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("retry of a failed request was replayed")
+	}
+}
+
+func TestIdempotentCreateConcurrentRetries(t *testing.T) {
+	api := newTestAPI(t)
+	owner, token := createTestUser(t, "owner@example.com")
+
+	const retries = 6
+	codes := make([]int, retries)
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := range codes {
+		r := createWithKey(t, token, "key")
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			codes[i] = serve(api, r).Code
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	for i, code := range codes {
+		if code != http.StatusCreated && code != http.StatusConflict {
+			t.Errorf("request %d: got %d, want 201 or 409", i, code)
+		}
+	}
+	var files int64
+	config.DB.Model(&models.File{}).Where("user_id = ?", owner.ID).Count(&files)
+	if files != 1 {
+		t.Errorf("%d files created, want 1", files)
+	}
+}
@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"go-share/config"
+	"go-share/models"
+	"go-share/utils"
+	"gorm.io/gorm"
+)
+
+// GetJobs lists background jobs, newest first. ?state= filters by state.
+func GetJobs(w http.ResponseWriter, r *http.Request) {
+	page, pageSize := parsePagination(r)
+	jobs, total, err := models.ListJobs(readDB(r), r.URL.Query().Get("state"), (page-1)*pageSize, pageSize)
+	if err != nil {
+		utils.ErrorJsonResponse(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.JsonResponse(w, http.StatusOK, map[string]interface{}{
+		"jobs":      jobs,
+		"page":      page,
+		"page_size": pageSize,
+		"total":     total,
+	})
+}
+
+// RetryJob puts a failed or cancelled job back in the queue.
+func RetryJob(w http.ResponseWriter, r *http.Request) {
+	changeJob(w, r, "job.retry", models.RetryJob)
+}
+
+// CancelJob stops a pending job from running.
+func CancelJob(w http.ResponseWriter, r *http.Request) {
+	changeJob(w, r, "job.cancel", models.CancelJob)
+}
+
+// changeJob applies an admin action to the job named in the path and audits it.
+func changeJob(w http.ResponseWriter, r *http.Request, action string, change func(*gorm.DB, uint) (*models.Job, error)) {
+	id, err := strconv.ParseUint(mux.Vars(r)["jobID"], 10, 64)
+	if err != nil {
+		utils.ErrorJsonResponse(w, "Invalid job ID", http.StatusBadRequest)
+		return
+	}
+
+	job, err := change(config.DB, uint(id))
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		utils.ErrorJsonResponse(w, "Job not found", http.StatusNotFound)
+		return
+	case errors.Is(err, models.ErrJobNotRetryable), errors.Is(err, models.ErrJobNotCancellable):
+		utils.ErrorCodeJsonResponse(w, "conflict", err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		utils.ErrorJsonResponse(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	adminID, _ := utils.GetUserID(r)
+	entry := auditEntry(r, adminID)
+	entry.Action, entry.Reason = action, "job "+strconv.FormatUint(id, 10)
+	if err := models.RecordAudit(config.DB, &entry); err != nil {
+		log.Printf("Error recording job audit entry: %s", err)
+	}
+
+	utils.JsonResponse(w, http.StatusOK, job)
+}
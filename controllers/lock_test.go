@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"go-share/models"
+	"go-share/utils"
+)
+
+// Locks belong to a login session: the owner signed in elsewhere is refused like anyone
+// else until the holder unlocks.
+func TestLockNonHolder(t *testing.T) {
+	api := newTestAPI(t)
+	owner, holderToken := createTestUser(t, "owner@example.com")
+	otherSession, err := utils.GenerateToken(owner.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	grantee, granteeToken := createTestUser(t, "grantee@example.com")
+	_, strangerToken := createTestUser(t, "stranger@example.com")
+	file := createTestFile(t, owner, "a.txt", 1)
+	grantAccess(t, grantee, file, time.Now().Add(time.Hour))
+
+	expectStatus(t, api, newRequest(t, "POST", fileURL(file)+"/lock", holderToken, nil), http.StatusOK)
+
+	for _, tt := range []struct {
+		caller string
+		token  string
+		status int
+		code   string
+	}{
+		{"other session", otherSession, http.StatusLocked, "locked"},
+		{"grantee", granteeToken, http.StatusForbidden, "forbidden"},
+	} {
+		for _, action := range []string{"/lock", "/unlock"} {
+			expectError(t, api, newRequest(t, "POST", fileURL(file)+action, tt.token, nil), tt.status, tt.code)
+		}
+	}
+	for _, action := range []string{"/lock", "/unlock"} {
+		expectStatus(t, api, newRequest(t, "POST", fileURL(file)+action, strangerToken, nil), http.StatusNotFound)
+	}
+
+	body := map[string]interface{}{"description": "theirs", "version": file.Version}
+	expectError(t, api, newRequest(t, "PUT", fileURL(file), otherSession, body), http.StatusLocked, "locked")
+
+	expectStatus(t, api, newRequest(t, "POST", fileURL(file)+"/unlock", holderToken, nil), http.StatusOK)
+	expectStatus(t, api, newRequest(t, "POST", fileURL(file)+"/lock", otherSession, nil), http.StatusOK)
+}
+
+// Two sessions edit the same version of a file. The one holding the lock wins; the other can
+// neither overwrite the file while it is locked nor afterwards with its stale version.
+func TestLockPreventsLostUpdate(t *testing.T) {
+	api := newTestAPI(t)
+	owner, holderToken := createTestUser(t, "owner@example.com")
+	otherSession, err := utils.GenerateToken(owner.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := createTestFile(t, owner, "a.txt", 1)
+	version := file.Version
+
+	expectStatus(t, api, newRequest(t, "POST", fileURL(file)+"/lock", holderToken, nil), http.StatusOK)
+	expectError(t, api, newRequest(t, "PUT", fileURL(file), otherSession, map[string]interface{}{"description": "theirs", "version": version}), http.StatusLocked, "locked")
+	expectStatus(t, api, newRequest(t, "PUT", fileURL(file), holderToken, map[string]interface{}{"description": "mine", "version": version}), http.StatusOK)
+	expectStatus(t, api, newRequest(t, "POST", fileURL(file)+"/unlock", holderToken, nil), http.StatusOK)
+	expectError(t, api, newRequest(t, "PUT", fileURL(file), otherSession, map[string]interface{}{"description": "theirs", "version": version}), http.StatusConflict, "conflict")
+
+	var stored models.File
+	decode(t, serve(api, newRequest(t, "GET", fileURL(file), holderToken, nil)), &stored)
+	if stored.Description != "mine" || stored.Version != version+1 {
+		t.Errorf("stored %q at version %d, want the holder's update at %d", stored.Description, stored.Version, version+1)
+	}
+}
@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+	"go-share/config"
+	"go-share/internal/buildinfo"
+	"go-share/models"
+	"go-share/utils"
+)
+
+// Maintenance modes accepted by POST /admin/maintenance.
+const (
+	MaintenanceOff      = "off"
+	MaintenanceReadOnly = "read_only"
+	MaintenanceFull     = "full"
+)
+
+// maintenanceSettingKey is the settings row that persists the current mode.
+const maintenanceSettingKey = "maintenance.mode"
+
+// maintenanceRefreshInterval bounds how stale the cached mode may be, so that a toggle
+// on one instance reaches the others without a database read on every request.
+const maintenanceRefreshInterval = 5 * time.Second
+
+var maintenance struct {
+	sync.Mutex
+	mode      string
+	checkedAt time.Time
+}
+
+// MaintenanceMode returns the current maintenance mode.
+func MaintenanceMode() string {
+	maintenance.Lock()
+	defer maintenance.Unlock()
+
+	if maintenance.mode != "" && time.Since(maintenance.checkedAt) < maintenanceRefreshInterval {
+		return maintenance.mode
+	}
+
+	mode, err := models.GetSetting(config.DB, maintenanceSettingKey)
+	if err != nil {
+		// Keep serving with the last known mode rather than failing every request.
+		log.Printf("Error loading maintenance mode: %s", err)
+		if maintenance.mode == "" {
+			return MaintenanceOff
+		}
+		return maintenance.mode
+	}
+	if mode == "" {
+		mode = MaintenanceOff
+	}
+	setCachedMaintenanceMode(mode)
+	return mode
+}
+
+// setCachedMaintenanceMode must be called with maintenance locked.
+func setCachedMaintenanceMode(mode string) {
+	maintenance.mode = mode
+	maintenance.checkedAt = time.Now()
+	buildinfo.SetFeature("maintenance", mode)
+}
+
+// MaintenanceMiddleware rejects requests that the current maintenance mode does not allow.
+// read_only blocks state-changing requests other than authentication and the maintenance
+// toggle; full blocks everything except the health check and admin routes.
+func MaintenanceMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mode := MaintenanceMode()
+		if maintenanceAllows(mode, r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Retry-After", strconv.Itoa(int(viper.GetDuration("maintenance.retry_after").Seconds())))
+		if mode == MaintenanceReadOnly {
+			utils.ErrorCodeJsonResponse(w, "maintenance_read_only", "The service is in read-only maintenance mode", http.StatusServiceUnavailable)
+			return
+		}
+		utils.ErrorCodeJsonResponse(w, "maintenance", "The service is down for maintenance", http.StatusServiceUnavailable)
+	})
+}
+
+// maintenanceAllows reports whether r may proceed in the given mode.
+func maintenanceAllows(mode string, r *http.Request) bool {
+	path := r.URL.Path
+	switch mode {
+	case MaintenanceReadOnly:
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			return true
+		}
+		return path == "/admin/maintenance" || isAuthPath(path)
+	case MaintenanceFull:
+		return path == "/healthz" || strings.HasPrefix(path, "/admin/")
+	default:
+		return true
+	}
+}
+
+// isAuthPath reports whether path belongs to the sign-in and sign-out routes.
+func isAuthPath(path string) bool {
+	return path == "/login" || path == "/register" || path == "/logout" || strings.HasPrefix(path, "/auth/")
+}
+
+// SetMaintenance switches the maintenance mode and persists it.
+func SetMaintenance(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Mode string `json:"mode"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		utils.ErrorJsonResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if body.Mode != MaintenanceOff && body.Mode != MaintenanceReadOnly && body.Mode != MaintenanceFull {
+		utils.ErrorJsonResponse(w, "Mode must be off, read_only or full", http.StatusBadRequest)
+		return
+	}
+
+	maintenance.Lock()
+	defer maintenance.Unlock()
+
+	if err := models.SetSetting(config.DB, maintenanceSettingKey, body.Mode); err != nil {
+		utils.ErrorJsonResponse(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	setCachedMaintenanceMode(body.Mode)
+
+	adminID, _ := utils.GetUserID(r)
+	entry := auditEntry(r, adminID)
+	entry.Action = "maintenance." + body.Mode
+	if err := models.RecordAudit(config.DB, &entry); err != nil {
+		log.Printf("Error recording maintenance audit entry: %s", err)
+	}
+
+	utils.JsonResponse(w, http.StatusOK, map[string]string{"mode": body.Mode})
+}
@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"go-share/utils"
+)
+
+// Each maintenance mode against reads, writes, authentication, the health check and admin
+// routes. Refused requests get 503 with a Retry-After of maintenance.retry_after.
+func TestMaintenanceModes(t *testing.T) {
+	// Expected outcomes: allowed, or refused with the error code.
+	const (
+		allowed    = ""
+		noWrites   = "maintenance_read_only"
+		noRequests = "maintenance"
+	)
+	tests := []struct {
+		name     string
+		request  func(t *testing.T, userToken, adminToken, fileID string) *http.Request
+		off      string
+		readOnly string
+		full     string
+	}{
+		{"list files", func(t *testing.T, userToken, adminToken, fileID string) *http.Request {
+			return newRequest(t, "GET", "/files", userToken, nil)
+		}, allowed, allowed, noRequests},
+		{"get a file", func(t *testing.T, userToken, adminToken, fileID string) *http.Request {
+			return newRequest(t, "GET", "/files/"+fileID, userToken, nil)
+		}, allowed, allowed, noRequests},
+		{"upload", func(t *testing.T, userToken, adminToken, fileID string) *http.Request {
+			return uploadFile(t, userToken, "new.txt", 1, "")
+		}, allowed, noWrites, noRequests},
+		{"update", func(t *testing.T, userToken, adminToken, fileID string) *http.Request {
+			return newRequest(t, "PUT", "/files/"+fileID, userToken, map[string]interface{}{"description": "x", "version": 1})
+		}, allowed, noWrites, noRequests},
+		{"delete", func(t *testing.T, userToken, adminToken, fileID string) *http.Request {
+			return newRequest(t, "DELETE", "/files/"+fileID, userToken, nil)
+		}, allowed, noWrites, noRequests},
+		{"log in", func(t *testing.T, userToken, adminToken, fileID string) *http.Request {
+			return newRequest(t, "POST", "/login", "", map[string]string{"email": "user@example.com", "password": "correct horse"})
+		}, allowed, allowed, noRequests},
+		{"health check", func(t *testing.T, userToken, adminToken, fileID string) *http.Request {
+			return newRequest(t, "GET", "/healthz", "", nil)
+		}, allowed, allowed, allowed},
+		{"admin read", func(t *testing.T, userToken, adminToken, fileID string) *http.Request {
+			return newRequest(t, "GET", "/admin/stats", adminToken, nil)
+		}, allowed, allowed, allowed},
+		{"admin write", func(t *testing.T, userToken, adminToken, fileID string) *http.Request {
+			return newRequest(t, "POST", "/admin/file-counts/recalculate", adminToken, nil)
+		}, allowed, noWrites, allowed},
+	}
+	modes := []string{MaintenanceOff, MaintenanceReadOnly, MaintenanceFull}
+	for _, mode := range modes {
+		t.Run(mode, func(t *testing.T) {
+			for _, tt := range tests {
+				api := newTestAPI(t)
+				user, userToken := createTestUser(t, "user@example.com")
+				_, adminToken := createTestAdmin(t, "admin@example.com")
+				fileID := utils.EncodePublicID(createTestFile(t, user, "a.txt", 1).ID)
+				expectStatus(t, api, newRequest(t, "POST", "/admin/maintenance", adminToken, map[string]string{"mode": mode}), http.StatusOK)
+
+				want := map[string]string{MaintenanceOff: tt.off, MaintenanceReadOnly: tt.readOnly, MaintenanceFull: tt.full}[mode]
+				w := serve(api, tt.request(t, userToken, adminToken, fileID))
+				if want == allowed {
+					if w.Code == http.StatusServiceUnavailable {
+						t.Errorf("%s: refused with %s, want it allowed", tt.name, w.Body)
+					}
+					continue
+				}
+				var body map[string]string
+				decode(t, w, &body)
+				if w.Code != http.StatusServiceUnavailable || body["code"] != want || w.Header().Get("Retry-After") != "300" {
+					t.Errorf("%s: got %d %s, Retry-After %q; want 503 %s, Retry-After 300", tt.name, w.Code, w.Body, w.Header().Get("Retry-After"), want)
+				}
+			}
+		})
+	}
+}
+
+// Admins can always switch maintenance off, and the mode is reported by the health check and
+// kept in the database, so that it survives a restart.
+func TestMaintenanceToggle(t *testing.T) {
+	api := newTestAPI(t)
+	_, adminToken := createTestAdmin(t, "admin@example.com")
+	_, userToken := createTestUser(t, "user@example.com")
+
+	expectStatus(t, api, newRequest(t, "POST", "/admin/maintenance", userToken, map[string]string{"mode": MaintenanceFull}), http.StatusForbidden)
+	expectStatus(t, api, newRequest(t, "POST", "/admin/maintenance", adminToken, map[string]string{"mode": "sometimes"}), http.StatusBadRequest)
+	expectStatus(t, api, newRequest(t, "POST", "/admin/maintenance", adminToken, map[string]string{"mode": MaintenanceFull}), http.StatusOK)
+
+	w := serve(api, newRequest(t, "GET", "/healthz", "", nil))
+	if !strings.Contains(w.Body.String(), `"maintenance":"full"`) {
+		t.Errorf("healthz %s, want the full mode", w.Body)
+	}
+
+	// A new router forgets the cached mode and reads it back from the database.
+	api = MethodHandler(NewRouter())
+	expectStatus(t, api, newRequest(t, "GET", "/files", userToken, nil), http.StatusServiceUnavailable)
+
+	for _, mode := range []string{MaintenanceReadOnly, MaintenanceOff} {
+		expectStatus(t, api, newRequest(t, "POST", "/admin/maintenance", adminToken, map[string]string{"mode": mode}), http.StatusOK)
+	}
+	expectStatus(t, api, newRequest(t, "GET", "/files", userToken, nil), http.StatusOK)
+}
@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"go-share/utils"
+)
+
+// routableMethods are the methods probed when working out what a path supports.
+var routableMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// MethodHandler wraps router so that every route answers HEAD and OPTIONS consistently:
+//   - OPTIONS returns 204 with an Allow header listing the methods the path supports,
+//     without authentication.
+//   - HEAD is served by the GET handler; net/http drops the body.
+//   - Unsupported methods get a JSON 405 with the same Allow header.
+func MethodHandler(router *mux.Router) http.Handler {
+	router.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", allowedMethods(router, r))
+		utils.ErrorJsonResponse(w, "Method not allowed", http.StatusMethodNotAllowed)
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodOptions:
+			allow := allowedMethods(router, r)
+			if allow == "" {
+				router.ServeHTTP(w, r) // falls through to the 404 handler
+				return
+			}
+			w.Header().Set("Allow", allow)
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodHead:
+			get := r.Clone(r.Context())
+			get.Method = http.MethodGet
+			router.ServeHTTP(w, get)
+		default:
+			router.ServeHTTP(w, r)
+		}
+	})
+}
+
+// allowedMethods returns the Allow header value for r's path, or "" if no route matches it.
+func allowedMethods(router *mux.Router, r *http.Request) string {
+	var allowed []string
+	for _, method := range routableMethods {
+		probe := r.Clone(r.Context())
+		probe.Method = method
+
+		var match mux.RouteMatch
+		if router.Match(probe, &match) && match.MatchErr == nil {
+			allowed = append(allowed, method)
+			if method == http.MethodGet {
+				allowed = append(allowed, http.MethodHead)
+			}
+		}
+	}
+	if len(allowed) == 0 {
+		return ""
+	}
+	return strings.Join(append(allowed, http.MethodOptions), ", ")
+}
@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// routeVariable matches a path variable such as {id} or {id:[0-9]+}.
+var routeVariable = regexp.MustCompile(`\{[^}]+\}`)
+
+// samplePath fills the variables of a route template with a value no literal segment uses.
+func samplePath(template string) string {
+	return routeVariable.ReplaceAllString(template, "sample")
+}
+
+// templateMatches reports whether a route template matches path, segment by segment.
+func templateMatches(template, path string) bool {
+	want, got := strings.Split(template, "/"), strings.Split(path, "/")
+	if len(want) != len(got) {
+		return false
+	}
+	for i := range want {
+		if want[i] != got[i] && !routeVariable.MatchString(want[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// expectedAllow works out the Allow header for path from the route table: every method of
+// every route matching the path, in routableMethods order, with HEAD after GET and OPTIONS last.
+func expectedAllow(routes []RouteInfo, path string) string {
+	methods := map[string]bool{}
+	for _, route := range routes {
+		if templateMatches(route.Path, path) {
+			for _, method := range route.Methods {
+				methods[method] = true
+			}
+		}
+	}
+
+	var allow []string
+	for _, method := range routableMethods {
+		if methods[method] {
+			allow = append(allow, method)
+			if method == http.MethodGet {
+				allow = append(allow, http.MethodHead)
+			}
+		}
+	}
+	return strings.Join(append(allow, http.MethodOptions), ", ")
+}
+
+// Walks every registered path and checks that OPTIONS, HEAD and the 405 handler agree with
+// the methods the route table actually implements.
+func TestMethodHandlerRouteTable(t *testing.T) {
+	router := newTestRouter(t)
+	api := MethodHandler(router)
+	routes, err := ListRoutes(router)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paths := map[string]bool{}
+	for _, route := range routes {
+		for _, method := range route.Methods {
+			if method == "*" {
+				t.Errorf("route %s accepts every method, so its Allow header can't be accurate", route.Path)
+			}
+		}
+		paths[samplePath(route.Path)] = true
+	}
+
+	for path := range paths {
+		t.Run(path, func(t *testing.T) {
+			allow := expectedAllow(routes, path)
+
+			// OPTIONS needs no credentials.
+			w := serve(api, newRequest(t, http.MethodOptions, path, "", nil))
+			if w.Code != http.StatusNoContent || w.Header().Get("Allow") != allow {
+				t.Errorf("OPTIONS: got %d with Allow %q, want 204 with %q", w.Code, w.Header().Get("Allow"), allow)
+			}
+
+			for _, method := range routableMethods {
+				w := serve(api, newRequest(t, method, path, "", nil))
+				implemented := strings.Contains(", "+allow+",", ", "+method+",")
+				switch {
+				case implemented && w.Code == http.StatusMethodNotAllowed:
+					t.Errorf("%s: got 405, but the route table implements it", method)
+				case !implemented && w.Code != http.StatusMethodNotAllowed:
+					t.Errorf("%s: got %d, want 405", method, w.Code)
+				case !implemented && w.Header().Get("Allow") != allow:
+					t.Errorf("%s: 405 with Allow %q, want %q", method, w.Header().Get("Allow"), allow)
+				}
+			}
+
+			// HEAD is routed to the GET handler; net/http drops the body when serving it.
+			get := serve(api, newRequest(t, http.MethodGet, path, "", nil))
+			head := serve(api, newRequest(t, http.MethodHead, path, "", nil))
+			if head.Code != get.Code {
+				t.Errorf("HEAD: got %d, GET got %d", head.Code, get.Code)
+			}
+		})
+	}
+}
+
+func TestMethodHandlerUnknownPath(t *testing.T) {
+	api := newTestAPI(t)
+
+	for _, method := range []string{http.MethodOptions, http.MethodGet, http.MethodDelete} {
+		if w := serve(api, newRequest(t, method, "/no-such-path", "", nil)); w.Code != http.StatusNotFound {
+			t.Errorf("%s /no-such-path: got %d, want 404", method, w.Code)
+		}
+	}
+}
+
+// Query matchers don't change what a path allows: GET /files/{id}?token= is the same path as
+// GET /files/{id}.
+func TestMethodHandlerQueryRoutes(t *testing.T) {
+	api := newTestAPI(t)
+
+	w := serve(api, newRequest(t, http.MethodOptions, "/files/sample?token=x", "", nil))
+	if got, want := w.Header().Get("Allow"), "GET, HEAD, PUT, DELETE, OPTIONS"; got != want {
+		t.Errorf("Allow = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"go-share/config"
+	"go-share/models"
+	"go-share/utils"
+	"gorm.io/gorm"
+)
+
+// registerNotificationRoutes registers the notification routes on the authenticated users subrouter.
+func registerNotificationRoutes(userRouter *mux.Router) {
+	userRouter.HandleFunc("/me/notifications", GetNotifications).Methods("GET")
+	userRouter.HandleFunc("/me/notifications/read-all", MarkAllNotificationsRead).Methods("POST")
+	userRouter.HandleFunc("/me/notifications/{id}/read", MarkNotificationRead).Methods("POST")
+	userRouter.HandleFunc("/me/notification-preferences", GetNotificationPreferences).Methods("GET")
+	userRouter.HandleFunc("/me/notification-preferences", UpdateNotificationPreferences).Methods("PATCH")
+}
+
+// GetNotifications lists the current user's notifications, newest first. ?unread=true filters to unread ones.
+func GetNotifications(w http.ResponseWriter, r *http.Request) {
+	userID, _ := utils.GetUserID(r)
+	unreadOnly, _ := strconv.ParseBool(r.URL.Query().Get("unread"))
+	page, pageSize := parsePagination(r)
+
+	notifications, total, err := models.ListNotifications(readDB(r), userID, unreadOnly, (page-1)*pageSize, pageSize)
+	if err != nil {
+		utils.ErrorJsonResponse(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.JsonResponse(w, http.StatusOK, map[string]interface{}{
+		"notifications": notifications,
+		"page":          page,
+		"page_size":     pageSize,
+		"total":         total,
+	})
+}
+
+// MarkNotificationRead marks a single notification as read.
+func MarkNotificationRead(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.ErrorJsonResponse(w, "Invalid notification ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, _ := utils.GetUserID(r)
+	if err := models.MarkNotificationRead(config.DB, userID, uint(id)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.ErrorJsonResponse(w, "Notification not found", http.StatusNotFound)
+			return
+		}
+		utils.ErrorJsonResponse(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MarkAllNotificationsRead marks all of the current user's notifications as read.
+func MarkAllNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	userID, _ := utils.GetUserID(r)
+	if err := models.MarkAllNotificationsRead(config.DB, userID); err != nil {
+		utils.ErrorJsonResponse(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetNotificationPreferences returns the delivery channel for each notification type.
+func GetNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	userID, _ := utils.GetUserID(r)
+	preferences, err := models.GetNotificationPreferences(config.DB, userID)
+	if err != nil {
+		utils.ErrorJsonResponse(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.JsonResponse(w, http.StatusOK, preferences)
+}
+
+// UpdateNotificationPreferences sets delivery channels from a {"type": "channel"} object.
+func UpdateNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	var body map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		utils.ErrorJsonResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	for notificationType, channel := range body {
+		if !models.IsNotificationType(notificationType) {
+			utils.ErrorJsonResponse(w, "Unknown notification type: "+notificationType, http.StatusUnprocessableEntity)
+			return
+		}
+		if channel != models.ChannelInApp && channel != models.ChannelNone {
+			utils.ErrorJsonResponse(w, "Channel must be in_app or none", http.StatusUnprocessableEntity)
+			return
+		}
+	}
+
+	userID, _ := utils.GetUserID(r)
+	for notificationType, channel := range body {
+		if err := models.SetNotificationPreference(config.DB, userID, notificationType, channel); err != nil {
+			utils.ErrorJsonResponse(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	GetNotificationPreferences(w, r)
+}
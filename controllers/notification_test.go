@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"go-share/config"
+	"go-share/models"
+)
+
+// notify adds n notifications to user's feed.
+func notify(t *testing.T, user *models.User, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if err := models.Notify(config.DB, user.ID, models.NotificationCommentAdded, models.Metadata{"n": fmt.Sprint(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestGetNotificationsPagination(t *testing.T) {
+	api := newTestAPI(t)
+	user, token := createTestUser(t, "user@example.com")
+	other, _ := createTestUser(t, "other@example.com")
+	notify(t, user, 3)
+	notify(t, other, 2)
+
+	var page struct {
+		Notifications []models.Notification `json:"notifications"`
+		Page          int                   `json:"page"`
+		PageSize      int                   `json:"page_size"`
+		Total         int64                 `json:"total"`
+	}
+	decode(t, serve(api, newRequest(t, "GET", "/users/me/notifications?page=2&page_size=2", token, nil)), &page)
+	if page.Total != 3 || page.Page != 2 || page.PageSize != 2 || len(page.Notifications) != 1 {
+		t.Fatalf("got page %d of size %d with %d of %d notifications", page.Page, page.PageSize, len(page.Notifications), page.Total)
+	}
+	if page.Notifications[0].Payload["n"] != "0" {
+		t.Errorf("last page holds notification %q, want the oldest", page.Notifications[0].Payload["n"])
+	}
+
+	expectStatus(t, api, newRequest(t, "POST", fmt.Sprintf("/users/me/notifications/%d/read", page.Notifications[0].ID), token, nil), http.StatusNoContent)
+	decode(t, serve(api, newRequest(t, "GET", "/users/me/notifications?unread=true", token, nil)), &page)
+	if page.Total != 2 {
+		t.Errorf("%d unread, want 2", page.Total)
+	}
+}
+
+// Marking another user's notification read looks exactly like marking one that doesn't exist.
+func TestMarkNotificationReadOwnership(t *testing.T) {
+	api := newTestAPI(t)
+	_, token := createTestUser(t, "user@example.com")
+	other, _ := createTestUser(t, "other@example.com")
+	notify(t, other, 1)
+	var theirs models.Notification
+	config.DB.Where("user_id = ?", other.ID).First(&theirs)
+
+	expectStatus(t, api, newRequest(t, "POST", fmt.Sprintf("/users/me/notifications/%d/read", theirs.ID), token, nil), http.StatusNotFound)
+	expectStatus(t, api, newRequest(t, "POST", "/users/me/notifications/999/read", token, nil), http.StatusNotFound)
+	expectStatus(t, api, newRequest(t, "POST", "/users/me/notifications/nope/read", token, nil), http.StatusBadRequest)
+
+	config.DB.First(&theirs, theirs.ID)
+	if theirs.Read {
+		t.Error("another user's notification was marked read")
+	}
+}
+
+func TestUpdateNotificationPreferences(t *testing.T) {
+	api := newTestAPI(t)
+	_, token := createTestUser(t, "user@example.com")
+
+	tests := []struct {
+		name   string
+		body   interface{}
+		status int
+		want   map[string]string
+	}{
+		{"opt out", map[string]string{models.NotificationCommentAdded: models.ChannelNone}, http.StatusOK,
+			map[string]string{models.NotificationCommentAdded: models.ChannelNone}},
+		{"unknown type", map[string]string{"file.deleted": models.ChannelNone}, http.StatusUnprocessableEntity, nil},
+		{"unknown channel", map[string]string{models.NotificationCommentAdded: "email"}, http.StatusUnprocessableEntity, nil},
+		{"one bad entry rejects all", map[string]string{models.NotificationCommentAdded: models.ChannelInApp, "file.deleted": models.ChannelNone},
+			http.StatusUnprocessableEntity, nil},
+		{"not an object", []string{models.NotificationCommentAdded}, http.StatusBadRequest, nil},
+		{"opt back in", map[string]string{models.NotificationCommentAdded: models.ChannelInApp}, http.StatusOK,
+			map[string]string{models.NotificationCommentAdded: models.ChannelInApp}},
+	}
+	stored := map[string]string{models.NotificationCommentAdded: models.ChannelInApp}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(api, newRequest(t, "PATCH", "/users/me/notification-preferences", token, tt.body))
+			if w.Code != tt.status {
+				t.Fatalf("got %d %s, want %d", w.Code, w.Body, tt.status)
+			}
+			if tt.want != nil {
+				stored = tt.want
+			}
+
+			var preferences map[string]string
+			decode(t, serve(api, newRequest(t, "GET", "/users/me/notification-preferences", token, nil)), &preferences)
+			if !reflect.DeepEqual(preferences, stored) {
+				t.Errorf("preferences = %v, want %v", preferences, stored)
+			}
+		})
+	}
+}
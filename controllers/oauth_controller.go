@@ -0,0 +1,245 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/spf13/viper"
+	"go-share/config"
+	"go-share/models"
+	"go-share/utils"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/endpoints"
+)
+
+// oauthStateCookie carries the state and PKCE verifier between login and callback.
+const oauthStateCookie = "goshare_oauth"
+
+// oauthProvider describes an external identity provider.
+type oauthProvider struct {
+	endpoint oauth2.Endpoint
+	scopes   []string
+	// fetchEmail returns the account's verified email using an authorized client.
+	fetchEmail func(ctx context.Context, client *http.Client, apiURL string) (string, error)
+	// apiURL is the default base URL fetchEmail queries; tests and proxies can override it.
+	apiURL string
+}
+
+// oauthProviders lists the supported providers by route name.
+var oauthProviders = map[string]oauthProvider{
+	"google": {
+		endpoint:   endpoints.Google,
+		scopes:     []string{"openid", "email"},
+		fetchEmail: fetchGoogleEmail,
+		apiURL:     "https://openidconnect.googleapis.com/v1/userinfo",
+	},
+	"github": {
+		endpoint:   endpoints.GitHub,
+		scopes:     []string{"user:email"},
+		fetchEmail: fetchGitHubEmail,
+		apiURL:     "https://api.github.com/user/emails",
+	},
+}
+
+// RegisterOAuthRoutes registers the social login routes.
+func RegisterOAuthRoutes(router *mux.Router) {
+	oauthRouter := subrouter(router, "/auth")
+	UseMiddleware(oauthRouter, requireFeature(FeatureSocialLogin))
+
+	oauthRouter.HandleFunc("/{provider}/login", OAuthLogin).Methods("GET")
+	oauthRouter.HandleFunc("/{provider}/callback", OAuthCallback).Methods("GET")
+}
+
+// oauthConfig builds the OAuth2 client configuration for a provider from oauth.<provider>.*.
+// It returns false if the provider is unknown or not configured.
+func oauthConfig(name string) (*oauth2.Config, oauthProvider, bool) {
+	provider, ok := oauthProviders[name]
+	key := "oauth." + name
+	if !ok || viper.GetString(key+".client_id") == "" {
+		return nil, provider, false
+	}
+
+	endpoint := provider.endpoint
+	if authURL := viper.GetString(key + ".auth_url"); authURL != "" {
+		endpoint.AuthURL = authURL
+	}
+	if tokenURL := viper.GetString(key + ".token_url"); tokenURL != "" {
+		endpoint.TokenURL = tokenURL
+	}
+	if apiURL := viper.GetString(key + ".api_url"); apiURL != "" {
+		provider.apiURL = apiURL
+	}
+
+	return &oauth2.Config{
+		ClientID:     viper.GetString(key + ".client_id"),
+		ClientSecret: viper.GetString(key + ".client_secret"),
+		RedirectURL:  viper.GetString(key + ".redirect_url"),
+		Endpoint:     endpoint,
+		Scopes:       provider.scopes,
+	}, provider, true
+}
+
+// OAuthLogin redirects the browser to the provider's consent page.
+func OAuthLogin(w http.ResponseWriter, r *http.Request) {
+	providerName := mux.Vars(r)["provider"]
+	oauthCfg, _, ok := oauthConfig(providerName)
+	if !ok {
+		utils.ErrorJsonResponse(w, "Unknown login provider", http.StatusNotFound)
+		return
+	}
+
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		utils.ErrorJsonResponse(w, "Error starting login", http.StatusInternalServerError)
+		return
+	}
+	state := hex.EncodeToString(raw)
+	verifier := oauth2.GenerateVerifier()
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    state + "." + verifier,
+		Path:     "/auth/" + providerName,
+		MaxAge:   int((10 * time.Minute).Seconds()),
+		HttpOnly: true,
+		Secure:   viper.GetBool("auth.cookie.secure"),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, oauthCfg.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier)), http.StatusFound)
+}
+
+// OAuthCallback completes the code flow, signs the user in by verified email, and
+// redirects to oauth.success_redirect with our own token.
+func OAuthCallback(w http.ResponseWriter, r *http.Request) {
+	providerName := mux.Vars(r)["provider"]
+	oauthCfg, provider, ok := oauthConfig(providerName)
+	if !ok {
+		utils.ErrorJsonResponse(w, "Unknown login provider", http.StatusNotFound)
+		return
+	}
+
+	cookie, err := r.Cookie(oauthStateCookie)
+	if err != nil {
+		utils.ErrorJsonResponse(w, "Login session expired", http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: oauthStateCookie, Value: "", Path: "/auth/" + providerName, MaxAge: -1, HttpOnly: true})
+
+	state, verifier, _ := strings.Cut(cookie.Value, ".")
+	if state == "" || r.URL.Query().Get("state") != state {
+		utils.ErrorJsonResponse(w, "Invalid login state", http.StatusBadRequest)
+		return
+	}
+	if errParam := r.URL.Query().Get("error"); errParam != "" {
+		utils.ErrorJsonResponse(w, "Login was cancelled: "+errParam, http.StatusUnauthorized)
+		return
+	}
+
+	token, err := oauthCfg.Exchange(r.Context(), r.URL.Query().Get("code"), oauth2.VerifierOption(verifier))
+	if err != nil {
+		utils.ErrorJsonResponse(w, "Error exchanging authorization code", http.StatusUnauthorized)
+		return
+	}
+
+	email, err := provider.fetchEmail(r.Context(), oauthCfg.Client(r.Context(), token), provider.apiURL)
+	if err != nil {
+		utils.ErrorJsonResponse(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	user, err := models.FindOrCreateProviderUser(config.DB, email, providerName, Features.Enabled(FeatureRegistration))
+	if errors.Is(err, models.ErrRegistrationClosed) {
+		utils.ErrorCodeJsonResponse(w, "feature_disabled", err.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		utils.ErrorJsonResponse(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := user.RecordLogin(config.DB); err != nil {
+		utils.ErrorJsonResponse(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jwtToken, err := utils.GenerateToken(user.ID)
+	if err != nil {
+		utils.ErrorJsonResponse(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	redirect := viper.GetString("oauth.success_redirect")
+	if utils.CookieAuthEnabled() {
+		if _, err := utils.SetSessionCookies(w, jwtToken, utils.TokenTTL); err != nil {
+			utils.ErrorJsonResponse(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, redirect, http.StatusFound)
+		return
+	}
+
+	// The token goes in the fragment so it never reaches server or proxy logs.
+	http.Redirect(w, r, redirect+"#token="+url.QueryEscape(jwtToken), http.StatusFound)
+}
+
+// fetchJSON GETs apiURL with the authorized client and decodes the JSON response into v.
+func fetchJSON(ctx context.Context, client *http.Client, apiURL string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("provider returned status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// fetchGoogleEmail reads the verified email from the OpenID Connect userinfo endpoint.
+func fetchGoogleEmail(ctx context.Context, client *http.Client, apiURL string) (string, error) {
+	var info struct {
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+	}
+	if err := fetchJSON(ctx, client, apiURL, &info); err != nil {
+		return "", errors.New("error fetching account email")
+	}
+	if info.Email == "" || !info.EmailVerified {
+		return "", errors.New("account email is not verified")
+	}
+	return info.Email, nil
+}
+
+// fetchGitHubEmail reads the primary verified email from GitHub's user emails API.
+func fetchGitHubEmail(ctx context.Context, client *http.Client, apiURL string) (string, error) {
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := fetchJSON(ctx, client, apiURL, &emails); err != nil {
+		return "", errors.New("error fetching account email")
+	}
+	for _, e := range emails {
+		if e.Primary && e.Verified {
+			return e.Email, nil
+		}
+	}
+	return "", errors.New("account has no verified primary email")
+}
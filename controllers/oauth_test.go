@@ -0,0 +1,257 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/spf13/viper"
+	"go-share/config"
+	"go-share/models"
+	"go-share/utils"
+)
+
+// stubProvider is an OpenID Connect provider that issues one code per simulated consent and
+// checks the PKCE verifier when the code is exchanged.
+type stubProvider struct {
+	*httptest.Server
+
+	mu sync.Mutex
+	// challenges maps issued codes to the PKCE challenge sent with the consent request.
+	challenges map[string]string
+	// userinfo is what the userinfo endpoint returns for access tokens it issued.
+	userinfo map[string]interface{}
+}
+
+func newStubProvider(t *testing.T) *stubProvider {
+	t.Helper()
+	p := &stubProvider{challenges: map[string]string{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		p.mu.Lock()
+		challenge, ok := p.challenges[r.Form.Get("code")]
+		delete(p.challenges, r.Form.Get("code"))
+		p.mu.Unlock()
+
+		sum := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+		if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != challenge {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"stub-access-token","token_type":"Bearer","expires_in":3600}`))
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer stub-access-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		json.NewEncoder(w).Encode(p.userinfo)
+	})
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+
+	viper.Set("oauth.google.client_id", "go-share")
+	viper.Set("oauth.google.client_secret", "secret")
+	viper.Set("oauth.google.redirect_url", "https://share.example.com/auth/google/callback")
+	viper.Set("oauth.google.auth_url", p.URL+"/authorize")
+	viper.Set("oauth.google.token_url", p.URL+"/token")
+	viper.Set("oauth.google.api_url", p.URL+"/userinfo")
+	viper.Set("oauth.success_redirect", "https://app.example.com/login/done")
+	return p
+}
+
+// consent plays the user approving the login at the provider: it reads the consent URL go-share
+// redirected to and returns the callback query the provider would send back.
+func (p *stubProvider) consent(t *testing.T, consentURL, email string, verified bool) url.Values {
+	t.Helper()
+	u, err := url.Parse(consentURL)
+	if err != nil || !strings.HasPrefix(consentURL, p.URL+"/authorize") {
+		t.Fatalf("login redirected to %q, want the provider", consentURL)
+	}
+	query := u.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		t.Fatalf("consent URL %s has no S256 PKCE challenge", consentURL)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	code := "code-" + query.Get("state")
+	p.challenges[code] = query.Get("code_challenge")
+	p.userinfo = map[string]interface{}{"email": email, "email_verified": verified}
+	return url.Values{"code": {code}, "state": {query.Get("state")}}
+}
+
+// startLogin requests /auth/google/login and returns the consent URL and the state cookie.
+func startLogin(t *testing.T, api http.Handler) (string, *http.Cookie) {
+	t.Helper()
+	w := serve(api, newRequest(t, "GET", "/auth/google/login", "", nil))
+	if w.Code != http.StatusFound {
+		t.Fatalf("login: got %d %s", w.Code, w.Body)
+	}
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == oauthStateCookie {
+			return w.Header().Get("Location"), cookie
+		}
+	}
+	t.Fatal("login set no state cookie")
+	return "", nil
+}
+
+// callback requests the callback with query and cookie, if not nil.
+func callback(t *testing.T, api http.Handler, query url.Values, cookie *http.Cookie) *httptest.ResponseRecorder {
+	t.Helper()
+	r := newRequest(t, "GET", "/auth/google/callback?"+query.Encode(), "", nil)
+	if cookie != nil {
+		r.AddCookie(cookie)
+	}
+	return serve(api, r)
+}
+
+// signedInUser returns the user whose token the callback redirected with.
+func signedInUser(t *testing.T, w *httptest.ResponseRecorder) *models.User {
+	t.Helper()
+	location := w.Header().Get("Location")
+	token := strings.TrimPrefix(location, "https://app.example.com/login/done#token=")
+	if w.Code != http.StatusFound || token == location {
+		t.Fatalf("callback: got %d %s, Location %q; want a redirect with a token", w.Code, w.Body, location)
+	}
+	claims, err := utils.VerifyToken(token)
+	if err != nil {
+		t.Fatalf("callback token: %s", err)
+	}
+	var user models.User
+	if err := config.DB.First(&user, claims.UserID).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &user
+}
+
+func TestOAuthLoginCreatesUser(t *testing.T) {
+	api := newTestAPI(t)
+	provider := newStubProvider(t)
+
+	consentURL, cookie := startLogin(t, api)
+	user := signedInUser(t, callback(t, api, provider.consent(t, consentURL, "ada@example.com", true), cookie))
+	if user.Email != "ada@example.com" || user.AuthProvider != "google" || user.Password != "" {
+		t.Errorf("created %+v, want a google account without a password", user)
+	}
+
+	// Signing in again finds the same account.
+	consentURL, cookie = startLogin(t, api)
+	if again := signedInUser(t, callback(t, api, provider.consent(t, consentURL, "ada@example.com", true), cookie)); again.ID != user.ID {
+		t.Errorf("second login signed in as user %d, want %d", again.ID, user.ID)
+	}
+}
+
+// The state must come back unchanged and match the caller's own cookie, so that a login
+// started by someone else can't be completed in the victim's browser.
+func TestOAuthCallbackState(t *testing.T) {
+	api := newTestAPI(t)
+	provider := newStubProvider(t)
+
+	consentURL, cookie := startLogin(t, api)
+	query := provider.consent(t, consentURL, "ada@example.com", true)
+
+	tampered := url.Values{"code": query["code"], "state": {"forged"}}
+	if w := callback(t, api, tampered, cookie); w.Code != http.StatusBadRequest {
+		t.Errorf("mismatched state: got %d %s, want 400", w.Code, w.Body)
+	}
+	if w := callback(t, api, query, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("no state cookie: got %d %s, want 400", w.Code, w.Body)
+	}
+	_, otherCookie := startLogin(t, api)
+	if w := callback(t, api, query, otherCookie); w.Code != http.StatusBadRequest {
+		t.Errorf("another login's cookie: got %d %s, want 400", w.Code, w.Body)
+	}
+
+	var users int64
+	config.DB.Model(&models.User{}).Count(&users)
+	if users != 0 {
+		t.Errorf("%d users created by rejected callbacks", users)
+	}
+}
+
+// The code is exchanged with the PKCE verifier from the caller's cookie; an intercepted code
+// is useless without it.
+func TestOAuthCallbackPKCE(t *testing.T) {
+	api := newTestAPI(t)
+	provider := newStubProvider(t)
+
+	consentURL, cookie := startLogin(t, api)
+	query := provider.consent(t, consentURL, "ada@example.com", true)
+	state, _, _ := strings.Cut(cookie.Value, ".")
+	forged := &http.Cookie{Name: cookie.Name, Value: state + ".wrong-verifier-wrong-verifier-wrong-verifier"}
+
+	if w := callback(t, api, query, forged); w.Code != http.StatusUnauthorized {
+		t.Errorf("wrong verifier: got %d %s, want 401", w.Code, w.Body)
+	}
+}
+
+// An existing password account is linked by its verified email: the provider login signs into
+// it and the password keeps working. An unverified email never links.
+func TestOAuthLinksExistingAccount(t *testing.T) {
+	api := newTestAPI(t)
+	provider := newStubProvider(t)
+	existing, _ := createTestUser(t, "ada@example.com")
+
+	consentURL, cookie := startLogin(t, api)
+	if w := callback(t, api, provider.consent(t, consentURL, "ada@example.com", false), cookie); w.Code != http.StatusUnauthorized {
+		t.Fatalf("unverified email: got %d %s, want 401", w.Code, w.Body)
+	}
+	var stored models.User
+	config.DB.First(&stored, existing.ID)
+	if stored.AuthProvider != "" {
+		t.Errorf("an unverified email linked the account to %s", stored.AuthProvider)
+	}
+
+	consentURL, cookie = startLogin(t, api)
+	user := signedInUser(t, callback(t, api, provider.consent(t, consentURL, "ada@example.com", true), cookie))
+	if user.ID != existing.ID || user.AuthProvider != "google" || user.Password != existing.Password {
+		t.Errorf("signed in as %+v, want the existing account linked to google with its password", user)
+	}
+	if code, body := login(t, api, "ada@example.com", "correct horse"); code != http.StatusOK {
+		t.Errorf("password login after linking: got %d %s", code, body)
+	}
+}
+
+// With registration closed, social login still signs in existing accounts but creates none.
+func TestOAuthRegistrationClosed(t *testing.T) {
+	api := newTestAPI(t)
+	provider := newStubProvider(t)
+	existing, _ := createTestUser(t, "ada@example.com")
+	viper.Set("features.registration", false)
+
+	consentURL, cookie := startLogin(t, api)
+	w := callback(t, api, provider.consent(t, consentURL, "new@example.com", true), cookie)
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "feature_disabled") {
+		t.Errorf("new account: got %d %s, want 403 feature_disabled", w.Code, w.Body)
+	}
+
+	consentURL, cookie = startLogin(t, api)
+	if user := signedInUser(t, callback(t, api, provider.consent(t, consentURL, "ada@example.com", true), cookie)); user.ID != existing.ID {
+		t.Errorf("signed in as user %d, want %d", user.ID, existing.ID)
+	}
+}
+
+func TestOAuthUnknownProvider(t *testing.T) {
+	api := newTestAPI(t)
+	newStubProvider(t)
+
+	for _, path := range []string{"/auth/github/login", "/auth/myspace/login"} {
+		if w := serve(api, newRequest(t, "GET", path, "", nil)); w.Code != http.StatusNotFound {
+			t.Errorf("GET %s: got %d, want 404 for a provider that isn't configured", path, w.Code)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+
+	"go-share/config"
+	"go-share/models"
+	"go-share/utils"
+)
+
+// nastyNames are original file names that sanitization changes or that are risky to echo.
+var nastyNames = []string{
+	"../../etc/passwd",
+	`C:\Users\ada\report.pdf`,
+	"CON.txt",
+	`"quoted" name.txt`,
+	"tab\there\nnewline.txt",
+	"<script>alert(1)</script>.html",
+	"\u202egnp.exe",
+	"naïve résumé 🎉.pdf",
+	"trailing dot. ",
+	"   ",
+	"%2e%2e%2fencoded.txt",
+}
+
+// The original name survives upload, a rename, a conflict rename and every way the file is
+// read back, byte for byte, while the display name is sanitized.
+func TestOriginalNameRoundTrip(t *testing.T) {
+	for _, name := range nastyNames {
+		t.Run(name, func(t *testing.T) {
+			api := newTestAPI(t)
+			owner, token := createTestUser(t, "ada@example.com")
+			createTestFile(t, owner, utils.SanitizeFileName(name), 1)
+
+			w := serve(api, newRequest(t, "POST", "/files?on_conflict=rename", token, map[string]interface{}{"name": name, "path": "/upload", "size": 1}))
+			if w.Code != http.StatusCreated {
+				t.Fatalf("upload: got %d %s", w.Code, w.Body)
+			}
+			var file models.File
+			decode(t, w, &file)
+			if file.OriginalName != name {
+				t.Errorf("upload: original_name = %q, want %q", file.OriginalName, name)
+			}
+			if file.Name == name && utils.SanitizeFileName(name) != name {
+				t.Errorf("display name %q was not sanitized", file.Name)
+			}
+
+			// Renaming, even to a different original_name in the body, only changes the display name.
+			body := map[string]interface{}{"name": "renamed.txt", "original_name": "forged.txt", "path": "/upload", "size": 1, "version": file.Version}
+			expectStatus(t, api, newRequest(t, "PUT", fileURL(&file), token, body), http.StatusOK)
+
+			expectOriginalName := func(source string, got models.File) {
+				t.Helper()
+				if got.OriginalName != name {
+					t.Errorf("%s: original_name = %q, want %q", source, got.OriginalName, name)
+				}
+			}
+			var fetched models.File
+			decode(t, serve(api, newRequest(t, "GET", fileURL(&file), token, nil)), &fetched)
+			expectOriginalName("lookup", fetched)
+			if fetched.Name != "renamed.txt" {
+				t.Errorf("name = %q after the rename", fetched.Name)
+			}
+
+			var projected []map[string]interface{}
+			decode(t, serve(api, newRequest(t, "GET", "/files?fields=id,original_name", token, nil)), &projected)
+			if len(projected) != 2 {
+				t.Errorf("?fields=id,original_name: got %v", projected)
+			}
+			for _, listed := range projected {
+				if listed["id"] == utils.EncodePublicID(file.ID) && listed["original_name"] != name {
+					t.Errorf("?fields=id,original_name: got %v", listed)
+				}
+			}
+
+			var listing []models.File
+			decode(t, serve(api, newRequest(t, "GET", "/files", token, nil)), &listing)
+			if len(listing) != 2 {
+				t.Fatalf("listing has %d files, want 2", len(listing))
+			}
+			for _, listed := range listing {
+				if listed.ID == file.ID {
+					expectOriginalName("listing", listed)
+				}
+			}
+
+			var linked models.File
+			decode(t, serve(api, newRequest(t, "GET", issueDownloadToken(t, api, token, utils.EncodePublicID(file.ID)), "", nil)), &linked)
+			expectOriginalName("download link", linked)
+
+			var stored models.File
+			config.DB.First(&stored, file.ID)
+			expectOriginalName("database", stored)
+		})
+	}
+}
+
+// Backfilling gives older files their current name and leaves recorded originals alone.
+func TestBackfillOriginalNames(t *testing.T) {
+	newTestAPI(t)
+	owner, _ := createTestUser(t, "ada@example.com")
+	legacy := createTestFile(t, owner, "legacy.txt", 1)
+	recorded := createTestFile(t, owner, "recorded.txt", 1)
+	if err := config.DB.Model(legacy).UpdateColumn("original_name", "").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := config.DB.Model(recorded).UpdateColumn("original_name", "../recorded.txt").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	backfilled, err := models.BackfillOriginalNames(config.DB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backfilled) != 1 || backfilled[0] != legacy.ID {
+		t.Errorf("backfilled %v, want [%d]", backfilled, legacy.ID)
+	}
+	for id, want := range map[uint]string{legacy.ID: "legacy.txt", recorded.ID: "../recorded.txt"} {
+		var stored models.File
+		config.DB.First(&stored, id)
+		if stored.OriginalName != want {
+			t.Errorf("file %d: original_name = %q, want %q", id, stored.OriginalName, want)
+		}
+	}
+}
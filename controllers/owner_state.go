@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/spf13/viper"
+	"go-share/cache"
+	"go-share/config"
+	"go-share/models"
+	"gorm.io/gorm"
+)
+
+// ownerState is the cached part of a user row that decides whether their files can be reached
+// by others. Only timestamps are cached, so a suspension that runs out is noticed without
+// invalidation.
+type ownerState struct {
+	DeletedAt      *time.Time `json:"deleted_at"`
+	SuspendedAt    *time.Time `json:"suspended_at"`
+	SuspendedUntil *time.Time `json:"suspended_until"`
+}
+
+// ownerCacheKey is the cache key for the state of the user with the given ID.
+func ownerCacheKey(userID uint) string {
+	return "owner:" + strconv.FormatUint(uint64(userID), 10)
+}
+
+// ownerActive reports whether the owner of a file is active, for paths that reach a file
+// without going through repositories.VisibleTo, such as cached lookups. Cache errors are
+// treated as misses.
+func ownerActive(r *http.Request, ownerID uint) (bool, error) {
+	ctx, cancel := context.WithTimeout(r.Context(), viper.GetDuration("cache.timeout"))
+	defer cancel()
+
+	var state ownerState
+	data, err := config.Cache.Get(ctx, ownerCacheKey(ownerID))
+	if err == nil && json.Unmarshal(data, &state) == nil {
+		return state.active(), nil
+	}
+	if err != nil && !errors.Is(err, cache.ErrMiss) {
+		log.Printf("Error reading user %d from cache: %s", ownerID, err)
+	}
+
+	var owner models.User
+	if err := config.DB.Unscoped().Select("id", "deleted_at", "suspended_at", "suspended_until").First(&owner, ownerID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, errors.New("error loading file owner")
+	}
+
+	state = ownerState{SuspendedAt: owner.SuspendedAt, SuspendedUntil: owner.SuspendedUntil}
+	if owner.DeletedAt.Valid {
+		state.DeletedAt = &owner.DeletedAt.Time
+	}
+	if data, err := json.Marshal(state); err == nil {
+		if err := config.Cache.Set(ctx, ownerCacheKey(ownerID), data, viper.GetDuration("cache.ttl")); err != nil {
+			log.Printf("Error caching user %d: %s", ownerID, err)
+		}
+	}
+	return state.active(), nil
+}
+
+// active applies models.User.IsActive to the cached state.
+func (s ownerState) active() bool {
+	user := models.User{SuspendedAt: s.SuspendedAt, SuspendedUntil: s.SuspendedUntil}
+	if s.DeletedAt != nil {
+		user.DeletedAt = gorm.DeletedAt{Time: *s.DeletedAt, Valid: true}
+	}
+	return user.IsActive()
+}
+
+// invalidateOwnerState drops the cached state of a user. Handlers that suspend, reinstate or
+// delete an account must call it once the change is made, so that their files go dark or come
+// back on every instance at once.
+func invalidateOwnerState(userID uint) {
+	ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("cache.timeout"))
+	defer cancel()
+
+	if err := config.Cache.Delete(ctx, ownerCacheKey(userID)); err != nil {
+		log.Printf("Error invalidating cached user %d: %s", userID, err)
+	}
+}
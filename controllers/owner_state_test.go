@@ -0,0 +1,174 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go-share/config"
+	"go-share/models"
+	"go-share/utils"
+)
+
+// sharedFile is a file reachable by someone other than its owner: through a grant to grantee
+// and through a download link.
+type sharedFile struct {
+	api          http.Handler
+	owner        *models.User
+	adminToken   string
+	granteeToken string
+	file         *models.File
+	link         string
+}
+
+// newSharedFile stores a file for a new owner, shares it with a grantee and issues a link.
+func newSharedFile(t *testing.T) *sharedFile {
+	t.Helper()
+	api := newTestAPI(t)
+	owner, ownerToken := createTestUser(t, "owner@example.com")
+	grantee, granteeToken := createTestUser(t, "grantee@example.com")
+	_, adminToken := createTestAdmin(t, "admin@example.com")
+	file := createTestFile(t, owner, "a.txt", 1)
+	if _, err := file.CreateGrant(config.DB, grantee, models.PermissionRead, time.Now().Add(time.Hour), models.AuditLog{ActorID: owner.ID}); err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, api, newRequest(t, "POST", fileURL(file)+"/comments", ownerToken, map[string]string{"body": "Draft"}), http.StatusCreated)
+
+	return &sharedFile{
+		api:          api,
+		owner:        owner,
+		adminToken:   adminToken,
+		granteeToken: granteeToken,
+		file:         file,
+		link:         issueDownloadToken(t, api, ownerToken, utils.EncodePublicID(file.ID)),
+	}
+}
+
+// reachable reports, for each way in, whether someone other than the owner reaches the file.
+func (s *sharedFile) reachable(t *testing.T) map[string]bool {
+	t.Helper()
+	id := utils.EncodePublicID(s.file.ID)
+	get := func(r *http.Request) (int, string) {
+		w := serve(s.api, r)
+		return w.Code, w.Body.String()
+	}
+	listed := func(r *http.Request) bool {
+		code, body := get(r)
+		if code != http.StatusOK {
+			t.Fatalf("%s %s: got %d %s", r.Method, r.URL, code, body)
+		}
+		return strings.Contains(body, `"id":"`+id+`"`)
+	}
+	ok := func(r *http.Request) bool {
+		code, _ := get(r)
+		return code == http.StatusOK
+	}
+
+	_, batch := get(newRequest(t, "POST", "/files/batch-get", s.granteeToken, map[string]interface{}{"ids": []string{id}}))
+	return map[string]bool{
+		"listing":        listed(newRequest(t, "GET", "/files", s.granteeToken, nil)),
+		"shared with me": listed(newRequest(t, "GET", "/files/shared-with-me", s.granteeToken, nil)),
+		"lookup":         ok(newRequest(t, "GET", "/files/"+id, s.granteeToken, nil)),
+		"batch get":      !strings.Contains(batch, "not_found"),
+		"comments":       ok(newRequest(t, "GET", "/files/"+id+"/comments", s.granteeToken, nil)),
+		"download link":  ok(newRequest(t, "GET", s.link, "", nil)),
+	}
+}
+
+// expectReachable checks every way in against want.
+func (s *sharedFile) expectReachable(t *testing.T, want bool) {
+	t.Helper()
+	for resource, got := range s.reachable(t) {
+		if got != want {
+			t.Errorf("%s: reachable = %v, want %v", resource, got, want)
+		}
+	}
+}
+
+// suspend suspends the owner through the admin API, until the given time if it is not zero.
+func (s *sharedFile) suspend(t *testing.T, until time.Time) {
+	t.Helper()
+	body := map[string]interface{}{"reason": "abuse report"}
+	if !until.IsZero() {
+		body["until"] = until
+	}
+	expectStatus(t, s.api, newRequest(t, "POST", fmt.Sprintf("/admin/users/%d/suspend", s.owner.ID), s.adminToken, body), http.StatusOK)
+}
+
+// Each owner state against each way another user reaches the owner's file. Everything is
+// reached once first, so the cached file and owner state must be invalidated as well.
+func TestOwnerStateMatrix(t *testing.T) {
+	tests := []struct {
+		state     string
+		apply     func(t *testing.T, s *sharedFile)
+		reachable bool
+	}{
+		{"active", func(t *testing.T, s *sharedFile) {}, true},
+		{"suspended", func(t *testing.T, s *sharedFile) { s.suspend(t, time.Time{}) }, false},
+		{"suspended for a while", func(t *testing.T, s *sharedFile) { s.suspend(t, time.Now().Add(time.Hour)) }, false},
+		{"reinstated", func(t *testing.T, s *sharedFile) {
+			s.suspend(t, time.Time{})
+			r := newRequest(t, "POST", fmt.Sprintf("/admin/users/%d/reinstate", s.owner.ID), s.adminToken, map[string]string{"reason": "appeal upheld"})
+			expectStatus(t, s.api, r, http.StatusOK)
+		}, true},
+		{"deleted", func(t *testing.T, s *sharedFile) {
+			if err := config.DB.Delete(s.owner).Error; err != nil {
+				t.Fatal(err)
+			}
+			invalidateOwnerState(s.owner.ID)
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.state, func(t *testing.T) {
+			s := newSharedFile(t)
+			before := s.reachable(t)
+			for resource, reachable := range before {
+				if !reachable {
+					t.Fatalf("%s: not reachable while the owner is active", resource)
+				}
+			}
+
+			tt.apply(t, s)
+			after := s.reachable(t)
+			want := map[string]bool{}
+			for resource := range before {
+				want[resource] = tt.reachable
+			}
+			if !reflect.DeepEqual(after, want) {
+				t.Errorf("reachable %v, want %v", after, want)
+			}
+		})
+	}
+}
+
+// Access comes back by itself when a time-boxed suspension runs out, although the owner's
+// state is cached.
+func TestOwnerSuspensionExpires(t *testing.T) {
+	s := newSharedFile(t)
+	s.suspend(t, time.Now().Add(300*time.Millisecond))
+	s.expectReachable(t, false)
+
+	time.Sleep(400 * time.Millisecond)
+	s.expectReachable(t, true)
+}
+
+// Suspension hides files without changing them: the grant, the comments and the link all work
+// again after reinstatement.
+func TestOwnerSuspensionKeepsData(t *testing.T) {
+	s := newSharedFile(t)
+	s.suspend(t, time.Time{})
+
+	var grants, comments int64
+	config.DB.Model(&models.FileGrant{}).Where("file_id = ?", s.file.ID).Count(&grants)
+	config.DB.Model(&models.Comment{}).Where("file_id = ?", s.file.ID).Count(&comments)
+	if grants != 1 || comments != 1 {
+		t.Errorf("while suspended: %d grants and %d comments, want 1 and 1", grants, comments)
+	}
+
+	r := newRequest(t, "POST", fmt.Sprintf("/admin/users/%d/reinstate", s.owner.ID), s.adminToken, map[string]string{"reason": "appeal upheld"})
+	expectStatus(t, s.api, r, http.StatusOK)
+	s.expectReachable(t, true)
+}
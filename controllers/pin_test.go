@@ -0,0 +1,125 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"go-share/config"
+	"go-share/models"
+	"go-share/utils"
+)
+
+func TestPinFile(t *testing.T) {
+	api := newTestAPI(t)
+	owner, token := createTestUser(t, "owner@example.com")
+	file := createTestFile(t, owner, "a.txt", 1)
+
+	for _, action := range []string{"pin", "unpin"} {
+		w := serve(api, newRequest(t, "POST", fileURL(file)+"/"+action, token, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: got %d %s", action, w.Code, w.Body)
+		}
+		var got models.File
+		decode(t, w, &got)
+		if got.Pinned != (action == "pin") {
+			t.Errorf("after %s, the response has pinned = %v", action, got.Pinned)
+		}
+	}
+}
+
+// Only the owner decides what survives the sweepers, not users the file is shared with.
+func TestPinFileOwnerOnly(t *testing.T) {
+	api := newTestAPI(t)
+	owner, _ := createTestUser(t, "owner@example.com")
+	grantee, granteeToken := createTestUser(t, "grantee@example.com")
+	_, strangerToken := createTestUser(t, "stranger@example.com")
+	file := createTestFile(t, owner, "a.txt", 1)
+	if _, err := file.CreateGrant(config.DB, grantee, models.PermissionRead, time.Now().Add(time.Hour), models.AuditLog{ActorID: owner.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	if w := serve(api, newRequest(t, "POST", fileURL(file)+"/pin", granteeToken, nil)); w.Code != http.StatusForbidden {
+		t.Errorf("grantee: got %d %s, want 403", w.Code, w.Body)
+	}
+	if w := serve(api, newRequest(t, "POST", fileURL(file)+"/pin", strangerToken, nil)); w.Code != http.StatusNotFound {
+		t.Errorf("stranger: got %d %s, want 404", w.Code, w.Body)
+	}
+
+	var stored models.File
+	if err := config.DB.First(&stored, file.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.Pinned {
+		t.Error("file was pinned by someone other than its owner")
+	}
+}
+
+func TestGetFilesPinnedFilter(t *testing.T) {
+	api := newTestAPI(t)
+	owner, token := createTestUser(t, "owner@example.com")
+	pinned := createTestFile(t, owner, "pinned.txt", 1)
+	unpinned := createTestFile(t, owner, "unpinned.txt", 1)
+	expectStatus(t, api, newRequest(t, "POST", fileURL(pinned)+"/pin", token, nil), http.StatusOK)
+
+	tests := []struct {
+		query string
+		want  []uint
+	}{
+		{"", []uint{pinned.ID, unpinned.ID}},
+		{"?pinned=true", []uint{pinned.ID}},
+		{"?pinned=false", []uint{unpinned.ID}},
+	}
+	for _, tt := range tests {
+		w := serve(api, newRequest(t, "GET", "/files"+tt.query, token, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET /files%s: got %d %s", tt.query, w.Code, w.Body)
+		}
+		var files []models.File
+		decode(t, w, &files)
+		got := make([]uint, len(files))
+		for i, file := range files {
+			got[i] = file.ID
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("GET /files%s returned %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+// The owner can still delete a pinned file; pinning only stops automatic deletion.
+func TestDeletePinnedFile(t *testing.T) {
+	api := newTestAPI(t)
+	owner, token := createTestUser(t, "owner@example.com")
+	file := createTestFile(t, owner, "a.txt", 1)
+	expectStatus(t, api, newRequest(t, "POST", fileURL(file)+"/pin", token, nil), http.StatusOK)
+
+	expectStatus(t, api, newRequest(t, "DELETE", fileURL(file), token, nil), http.StatusOK)
+}
+
+// Pinning bumps the version like any other change and respects another session's lock.
+func TestPinFileVersionAndLock(t *testing.T) {
+	api := newTestAPI(t)
+	owner, holderToken := createTestUser(t, "owner@example.com")
+	otherSession, err := utils.GenerateToken(owner.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := createTestFile(t, owner, "a.txt", 1)
+
+	w := serve(api, newRequest(t, "POST", fileURL(file)+"/pin", holderToken, nil))
+	var pinned models.File
+	decode(t, w, &pinned)
+	if pinned.Version != file.Version+1 || w.Header().Get("ETag") != fmt.Sprintf(`"%d"`, file.Version+1) {
+		t.Errorf("after pinning: version %d, ETag %s; want %d", pinned.Version, w.Header().Get("ETag"), file.Version+1)
+	}
+	// A client still holding the version from before the pin gets a conflict.
+	body := map[string]interface{}{"description": "edited", "version": file.Version}
+	expectError(t, api, newRequest(t, "PUT", fileURL(file), holderToken, body), http.StatusConflict, "conflict")
+
+	expectStatus(t, api, newRequest(t, "POST", fileURL(file)+"/lock", holderToken, nil), http.StatusOK)
+	expectError(t, api, newRequest(t, "POST", fileURL(file)+"/unpin", otherSession, nil), http.StatusLocked, "locked")
+	expectStatus(t, api, newRequest(t, "POST", fileURL(file)+"/unpin", holderToken, nil), http.StatusOK)
+}
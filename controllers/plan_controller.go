@@ -0,0 +1,218 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/spf13/viper"
+	"go-share/cache"
+	"go-share/config"
+	"go-share/models"
+	"go-share/utils"
+	"gorm.io/gorm"
+)
+
+// plansCacheKey caches the whole plans table, which is small and read on every upload.
+const plansCacheKey = "plans"
+
+// registerPlanRoutes registers the plan management routes on the admin router.
+func registerPlanRoutes(adminRouter *mux.Router) {
+	adminRouter.HandleFunc("/plans", GetPlans).Methods("GET")
+	adminRouter.HandleFunc("/plans", CreatePlan).Methods("POST")
+	adminRouter.HandleFunc("/plans/{planID}", UpdatePlan).Methods("PATCH")
+	adminRouter.HandleFunc("/users/{userID}/plan", SetUserPlan).Methods("PUT")
+}
+
+// loadPlans returns every plan, from the cache when possible. Cache errors are treated as misses.
+func loadPlans(r *http.Request) ([]models.Plan, error) {
+	ctx, cancel := context.WithTimeout(r.Context(), viper.GetDuration("cache.timeout"))
+	defer cancel()
+
+	if data, err := config.Cache.Get(ctx, plansCacheKey); err == nil {
+		var plans []models.Plan
+		if json.Unmarshal(data, &plans) == nil {
+			return plans, nil
+		}
+	} else if !errors.Is(err, cache.ErrMiss) {
+		log.Printf("Error reading plans from cache: %s", err)
+	}
+
+	plans, err := models.ListPlans(config.DB)
+	if err != nil {
+		return nil, err
+	}
+	if data, err := json.Marshal(plans); err == nil {
+		if err := config.Cache.Set(ctx, plansCacheKey, data, viper.GetDuration("cache.ttl")); err != nil {
+			log.Printf("Error caching plans: %s", err)
+		}
+	}
+	return plans, nil
+}
+
+// invalidatePlans drops the cached plans so that a change applies to the next request on
+// every instance. Like invalidateCachedFile, it runs even if the request's deadline passed.
+func invalidatePlans() {
+	ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("cache.timeout"))
+	defer cancel()
+
+	if err := config.Cache.Delete(ctx, plansCacheKey); err != nil {
+		log.Printf("Error invalidating cached plans: %s", err)
+	}
+}
+
+// userPlan returns the plan that currently applies to userID. The assignment is read from the
+// database on every call, so moving a user to another plan takes effect immediately.
+func userPlan(r *http.Request, userID uint) (*models.Plan, error) {
+	var user models.User
+	if err := config.DB.Select("id", "plan_id").First(&user, userID).Error; err != nil {
+		return nil, errors.New("error loading user plan")
+	}
+	plans, err := loadPlans(r)
+	if err != nil {
+		return nil, err
+	}
+	return models.EffectivePlan(plans, user.PlanID, viper.GetString("plans.default")), nil
+}
+
+// requirePlanFeature writes a 403 plan_feature_unavailable response and returns false unless
+// userID's plan includes the named feature.
+func requirePlanFeature(w http.ResponseWriter, r *http.Request, userID uint, feature string) bool {
+	plan, err := userPlan(r, userID)
+	if err != nil {
+		utils.ErrorJsonResponse(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	if !plan.Allows(feature) {
+		utils.ErrorCodeJsonResponse(w, "plan_feature_unavailable", "Your plan does not include this feature", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// writePlanError maps errors from saving a plan to a response.
+func writePlanError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, models.ErrPlanNameTaken):
+		utils.ErrorCodeJsonResponse(w, "plan_name_taken", err.Error(), http.StatusConflict)
+	case errors.Is(err, models.ErrInvalidPlan), utils.IsValidationError(err):
+		utils.ErrorCodeJsonResponse(w, "validation_failed", err.Error(), http.StatusUnprocessableEntity)
+	default:
+		utils.ErrorJsonResponse(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// GetPlans returns every plan along with the name of the default plan.
+func GetPlans(w http.ResponseWriter, r *http.Request) {
+	plans, err := models.ListPlans(config.DB)
+	if err != nil {
+		utils.ErrorJsonResponse(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.JsonResponse(w, http.StatusOK, map[string]interface{}{
+		"plans":   plans,
+		"default": viper.GetString("plans.default"),
+	})
+}
+
+// CreatePlan creates a plan, e.g. {"name": "pro", "quota_bytes": 107374182400,
+// "max_file_size": 5368709120, "features": {"upload_grants": true}}.
+func CreatePlan(w http.ResponseWriter, r *http.Request) {
+	var plan models.Plan
+	if err := json.NewDecoder(r.Body).Decode(&plan); err != nil {
+		utils.ErrorJsonResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	plan.ID = 0
+
+	adminID, _ := utils.GetUserID(r)
+	if err := plan.CreatePlan(config.DB, auditEntry(r, adminID)); err != nil {
+		writePlanError(w, err)
+		return
+	}
+	invalidatePlans()
+
+	utils.JsonResponse(w, http.StatusCreated, plan)
+}
+
+// UpdatePlan changes a plan. Fields left out of the body keep their value. The new limits
+// apply to the next upload of every user on the plan; stored files are not re-checked.
+func UpdatePlan(w http.ResponseWriter, r *http.Request) {
+	planID, err := strconv.ParseUint(mux.Vars(r)["planID"], 10, 64)
+	if err != nil {
+		utils.ErrorJsonResponse(w, "Invalid plan ID", http.StatusBadRequest)
+		return
+	}
+
+	var plan models.Plan
+	if err := config.DB.First(&plan, planID).Error; err != nil {
+		utils.ErrorJsonResponse(w, "Plan not found", http.StatusNotFound)
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(&plan); err != nil {
+		utils.ErrorJsonResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	plan.ID = uint(planID)
+
+	adminID, _ := utils.GetUserID(r)
+	if err := plan.UpdatePlan(config.DB, auditEntry(r, adminID)); err != nil {
+		writePlanError(w, err)
+		return
+	}
+	invalidatePlans()
+
+	utils.JsonResponse(w, http.StatusOK, plan)
+}
+
+// SetUserPlan moves a user to another plan with {"plan_id": 2}, or back to the default plan
+// with {"plan_id": null}. Files the user already stores are kept even above the new limits.
+func SetUserPlan(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseUint(mux.Vars(r)["userID"], 10, 64)
+	if err != nil {
+		utils.ErrorJsonResponse(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		PlanID *uint  `json:"plan_id"`
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		utils.ErrorJsonResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var target models.User
+	if err := config.DB.First(&target, targetID).Error; err != nil {
+		utils.ErrorJsonResponse(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	adminID, _ := utils.GetUserID(r)
+	audit := auditEntry(r, adminID)
+	audit.Reason = body.Reason
+	if err := target.AssignPlan(config.DB, body.PlanID, audit); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.ErrorJsonResponse(w, "Plan not found", http.StatusUnprocessableEntity)
+			return
+		}
+		utils.ErrorJsonResponse(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	plan, err := userPlan(r, target.ID)
+	if err != nil {
+		utils.ErrorJsonResponse(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	utils.JsonResponse(w, http.StatusOK, map[string]interface{}{
+		"user_id": target.ID,
+		"plan":    plan,
+	})
+}
@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/spf13/viper"
+	"go-share/models"
+)
+
+// createPlan creates a plan through the admin API and returns its ID.
+func createPlan(t *testing.T, api http.Handler, adminToken string, plan map[string]interface{}) uint {
+	t.Helper()
+	w := serve(api, newRequest(t, "POST", "/admin/plans", adminToken, plan))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("creating plan: got %d %s", w.Code, w.Body)
+	}
+	var created models.Plan
+	decode(t, w, &created)
+	return created.ID
+}
+
+// assignPlan moves user to planID, or to the default plan if planID is nil, through the admin API.
+func assignPlan(t *testing.T, api http.Handler, adminToken string, user *models.User, planID *uint) {
+	t.Helper()
+	r := newRequest(t, "PUT", fmt.Sprintf("/admin/users/%d/plan", user.ID), adminToken, map[string]interface{}{"plan_id": planID})
+	expectStatus(t, api, r, http.StatusOK)
+}
+
+// expectError checks that r gets status with the given error code.
+func expectError(t *testing.T, api http.Handler, r *http.Request, status int, code string) {
+	t.Helper()
+	w := serve(api, r)
+	var body map[string]string
+	decode(t, w, &body)
+	if w.Code != status || body["code"] != code {
+		t.Errorf("%s %s: got %d %s, want %d %s", r.Method, r.URL, w.Code, w.Body, status, code)
+	}
+}
+
+// updateFileSize changes the size of file through PUT /files/{id}.
+func updateFileSize(t *testing.T, token string, file *models.File, size int64) *http.Request {
+	t.Helper()
+	body := map[string]interface{}{"name": file.Name, "path": file.Path, "size": size, "version": file.Version}
+	return newRequest(t, "PUT", fileURL(file), token, body)
+}
+
+func TestPlanLimits(t *testing.T) {
+	api := newTestAPI(t)
+	_, adminToken := createTestAdmin(t, "admin@example.com")
+	user, token := createTestUser(t, "user@example.com")
+	planID := createPlan(t, api, adminToken, map[string]interface{}{"name": "free", "quota_bytes": 100, "max_file_size": 60})
+	assignPlan(t, api, adminToken, user, &planID)
+
+	// The largest file allowed, then up to the quota exactly.
+	expectStatus(t, api, uploadFile(t, token, "a.txt", 60, ""), http.StatusCreated)
+	w := serve(api, uploadFile(t, token, "b.txt", 40, ""))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("uploading up to the quota: got %d %s", w.Code, w.Body)
+	}
+	var b models.File
+	decode(t, w, &b)
+
+	expectError(t, api, uploadFile(t, token, "c.txt", 61, ""), http.StatusRequestEntityTooLarge, "file_too_large")
+	expectError(t, api, uploadFile(t, token, "c.txt", 1, ""), http.StatusUnprocessableEntity, "quota_exceeded")
+	expectError(t, api, uploadFile(t, token, "b.txt", 41, models.ConflictReplace), http.StatusUnprocessableEntity, "quota_exceeded")
+
+	expectError(t, api, updateFileSize(t, token, &b, 41), http.StatusUnprocessableEntity, "quota_exceeded")
+	expectStatus(t, api, updateFileSize(t, token, &b, 30), http.StatusOK)
+}
+
+func TestPlanFeatureGate(t *testing.T) {
+	api := newTestAPI(t)
+	_, adminToken := createTestAdmin(t, "admin@example.com")
+	user, token := createTestUser(t, "user@example.com")
+	planID := createPlan(t, api, adminToken, map[string]interface{}{"name": "free", "features": map[string]bool{models.PlanFeatureUploadGrants: false}})
+
+	expectStatus(t, api, newRequest(t, "POST", "/files/upload-grants", token, nil), http.StatusCreated)
+	assignPlan(t, api, adminToken, user, &planID)
+	expectError(t, api, newRequest(t, "POST", "/files/upload-grants", token, nil), http.StatusForbidden, "plan_feature_unavailable")
+}
+
+// Moving a user who already stores more than the new plan allows keeps their files: they can
+// read, shrink and delete them, but not add more until they are back under the limits.
+func TestPlanSwitchOverLimits(t *testing.T) {
+	api := newTestAPI(t)
+	_, adminToken := createTestAdmin(t, "admin@example.com")
+	user, token := createTestUser(t, "user@example.com")
+	big := createTestFile(t, user, "big.txt", 80)
+	small := createTestFile(t, user, "small.txt", 40)
+	planID := createPlan(t, api, adminToken, map[string]interface{}{"name": "free", "quota_bytes": 100, "max_file_size": 50})
+
+	assignPlan(t, api, adminToken, user, &planID)
+	expectStatus(t, api, newRequest(t, "GET", fileURL(big), token, nil), http.StatusOK)
+	expectError(t, api, uploadFile(t, token, "c.txt", 1, ""), http.StatusUnprocessableEntity, "quota_exceeded")
+	expectError(t, api, updateFileSize(t, token, small, 41), http.StatusUnprocessableEntity, "quota_exceeded")
+
+	// Shrinking is allowed even above the largest file size.
+	expectStatus(t, api, updateFileSize(t, token, big, 70), http.StatusOK)
+	expectStatus(t, api, newRequest(t, "DELETE", fileURL(small), token, nil), http.StatusOK)
+	expectStatus(t, api, uploadFile(t, token, "c.txt", 30, ""), http.StatusCreated)
+
+	// Back on the default plan, which has no limits here.
+	assignPlan(t, api, adminToken, user, nil)
+	expectStatus(t, api, uploadFile(t, token, "d.txt", 1000, ""), http.StatusCreated)
+}
+
+// A change to the default plan applies to the very next upload, although plans are cached.
+func TestPlanChangesApplyImmediately(t *testing.T) {
+	api := newTestAPI(t)
+	_, adminToken := createTestAdmin(t, "admin@example.com")
+	_, token := createTestUser(t, "user@example.com")
+	planID := createPlan(t, api, adminToken, map[string]interface{}{"name": "free", "max_file_size": 100})
+	viper.Set("plans.default", "free")
+
+	expectStatus(t, api, uploadFile(t, token, "a.txt", 100, ""), http.StatusCreated)
+	r := newRequest(t, "PATCH", fmt.Sprintf("/admin/plans/%d", planID), adminToken, map[string]interface{}{"max_file_size": 10})
+	expectStatus(t, api, r, http.StatusOK)
+	expectError(t, api, uploadFile(t, token, "b.txt", 11, ""), http.StatusRequestEntityTooLarge, "file_too_large")
+}
+
+func TestPlanAdminValidation(t *testing.T) {
+	api := newTestAPI(t)
+	_, adminToken := createTestAdmin(t, "admin@example.com")
+	user, _ := createTestUser(t, "user@example.com")
+	createPlan(t, api, adminToken, map[string]interface{}{"name": "free"})
+
+	expectError(t, api, newRequest(t, "POST", "/admin/plans", adminToken, map[string]interface{}{"name": "free"}), http.StatusConflict, "plan_name_taken")
+	expectError(t, api, newRequest(t, "POST", "/admin/plans", adminToken, map[string]interface{}{"name": "odd", "features": map[string]bool{"teleport": true}}), http.StatusUnprocessableEntity, "validation_failed")
+	expectError(t, api, newRequest(t, "POST", "/admin/plans", adminToken, map[string]interface{}{"name": "odd", "quota_bytes": -1}), http.StatusUnprocessableEntity, "validation_failed")
+
+	missing := uint(999)
+	r := newRequest(t, "PUT", fmt.Sprintf("/admin/users/%d/plan", user.ID), adminToken, map[string]interface{}{"plan_id": missing})
+	if w := serve(api, r); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("assigning a missing plan: got %d %s, want 422", w.Code, w.Body)
+	}
+
+	_, userToken := createTestUser(t, "other@example.com")
+	if w := serve(api, newRequest(t, "POST", "/admin/plans", userToken, map[string]interface{}{"name": "mine"})); w.Code != http.StatusForbidden {
+		t.Errorf("non-admin creating a plan: got %d, want 403", w.Code)
+	}
+}
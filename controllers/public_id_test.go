@@ -0,0 +1,43 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/spf13/viper"
+	"go-share/utils"
+)
+
+func TestNumericFileIDs(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled bool
+		numeric int
+	}{
+		{"numeric IDs on", true, http.StatusOK},
+		{"numeric IDs off", false, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newTestAPI(t)
+			viper.Set("api.numeric_ids", tt.enabled)
+			owner, token := createTestUser(t, "owner@example.com")
+			file := createTestFile(t, owner, "a.txt", 1)
+
+			if w := serve(api, newRequest(t, "GET", "/files/"+utils.EncodePublicID(file.ID), token, nil)); w.Code != http.StatusOK {
+				t.Errorf("public ID: got %d %s", w.Code, w.Body)
+			}
+			if w := serve(api, newRequest(t, "GET", "/files/"+strconv.FormatUint(uint64(file.ID), 10), token, nil)); w.Code != tt.numeric {
+				t.Errorf("numeric ID in the path: got %d, want %d", w.Code, tt.numeric)
+			}
+
+			body := map[string]interface{}{"ids": []uint{file.ID}}
+			w := serve(api, newRequest(t, "POST", "/files/batch-get", token, body))
+			if tt.enabled && w.Code != http.StatusOK || !tt.enabled && w.Code != http.StatusBadRequest {
+				t.Errorf("numeric ID in a body: got %d %s", w.Code, w.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/spf13/viper"
+	"go-share/config"
+	"go-share/querystats"
+	"gorm.io/gorm"
+)
+
+// QueryStatsMiddleware counts the database queries each request makes and logs a warning when
+// a request exceeds queries.max_per_request or queries.max_time_per_request. A query is only
+// counted if it runs with the request context, see readDB.
+func QueryStatsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, rec := querystats.WithRecorder(r.Context())
+		next.ServeHTTP(w, r.WithContext(ctx))
+		querystats.ObserveRequest(rec)
+
+		maxQueries := viper.GetInt64("queries.max_per_request")
+		maxTime := viper.GetDuration("queries.max_time_per_request")
+		if (maxQueries > 0 && rec.Queries() > maxQueries) || (maxTime > 0 && rec.Duration() > maxTime) {
+			log.Printf("Request %s %s made %d queries taking %s", r.Method, routeTemplate(r), rec.Queries(), rec.Duration())
+		}
+	})
+}
+
+// routeTemplate returns the matched route pattern, e.g. /files/{id}, so that warnings for the
+// same endpoint group together.
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return r.URL.Path
+}
+
+// readDB returns the database handle for listings made on behalf of r. It reads from the
+// replica when one is usable, and carries the request context so that the queries are counted
+// by QueryStatsMiddleware and cancelled with the request. Listing handlers, where N+1 patterns
+// tend to appear, must use it; reads that must see the caller's own writes must not.
+func readDB(r *http.Request) *gorm.DB {
+	return config.ReadDB().WithContext(r.Context())
+}
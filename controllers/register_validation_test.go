@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"go-share/config"
+	"go-share/models"
+)
+
+// Registration validates the account before storing anything: a bad email or a short password
+// gets 422 and leaves no user behind.
+func TestRegisterValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		body map[string]string
+		code int
+	}{
+		{"valid", map[string]string{"email": "ada@example.com", "password": "correct horse"}, http.StatusCreated},
+		{"not an email", map[string]string{"email": "ada", "password": "correct horse"}, http.StatusUnprocessableEntity},
+		{"no email", map[string]string{"password": "correct horse"}, http.StatusUnprocessableEntity},
+		{"short password", map[string]string{"email": "ada@example.com", "password": "x"}, http.StatusUnprocessableEntity},
+		{"seven characters", map[string]string{"email": "ada@example.com", "password": "1234567"}, http.StatusUnprocessableEntity},
+		{"no password", map[string]string{"email": "ada@example.com"}, http.StatusUnprocessableEntity},
+		{"long display name", map[string]string{"email": "ada@example.com", "password": "correct horse", "display_name": strings.Repeat("x", 65)}, http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newTestAPI(t)
+			w := serve(api, newRequest(t, "POST", "/register", "", tt.body))
+			if w.Code != tt.code {
+				t.Fatalf("got %d %s, want %d", w.Code, w.Body, tt.code)
+			}
+			if tt.code == http.StatusUnprocessableEntity && !strings.Contains(w.Body.String(), `"code":"validation_failed"`) {
+				t.Errorf("body %s, want validation_failed", w.Body)
+			}
+
+			var users, want int64
+			config.DB.Model(&models.User{}).Count(&users)
+			if tt.code == http.StatusCreated {
+				want = 1
+			}
+			if users != want {
+				t.Errorf("%d users stored, want %d", users, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package controllers
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"go-share/config"
+	"go-share/internal/testdb"
+	"go-share/models"
+	"gorm.io/gorm"
+)
+
+// useReplica gives the test a second database as the read replica, holding a copy of users
+// but none of the primary's files.
+func useReplica(t *testing.T, users ...*models.User) *gorm.DB {
+	t.Helper()
+	replica := testdb.Open(t, models.All...)
+	for _, user := range users {
+		if err := replica.Create(user).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	config.SetReplica(replica)
+	t.Cleanup(func() { config.SetReplica(nil) })
+	return replica
+}
+
+// listFileNames returns the names GET /files lists for token.
+func listFileNames(t *testing.T, api http.Handler, token string) []string {
+	t.Helper()
+	w := serve(api, newRequest(t, "GET", "/files", token, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /files: got %d %s", w.Code, w.Body)
+	}
+	var files []models.File
+	decode(t, w, &files)
+	names := make([]string, len(files))
+	for i, file := range files {
+		names[i] = file.Name
+	}
+	return names
+}
+
+func TestListingsReadFromReplica(t *testing.T) {
+	api := newTestAPI(t)
+	owner, token := createTestUser(t, "owner@example.com")
+	createTestFile(t, owner, "primary.txt", 1)
+	replica := useReplica(t, owner)
+	if err := replica.Create(&models.File{Name: "replica.txt", Path: "/replica.txt", UserID: owner.ID}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if got := listFileNames(t, api, token); !reflect.DeepEqual(got, []string{"replica.txt"}) {
+		t.Errorf("GET /files listed %v, want the replica's files", got)
+	}
+}
+
+// A file can be fetched, and a download link for it resolved, straight after it is uploaded,
+// before the replica has it.
+func TestReadAfterWriteUsesPrimary(t *testing.T) {
+	api := newTestAPI(t)
+	owner, token := createTestUser(t, "owner@example.com")
+	useReplica(t, owner)
+
+	w := serve(api, uploadFile(t, token, "a.txt", 1, ""))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("upload: got %d %s", w.Code, w.Body)
+	}
+	var file struct {
+		ID string `json:"id"`
+	}
+	decode(t, w, &file)
+
+	expectStatus(t, api, newRequest(t, "GET", "/files/"+file.ID, token, nil), http.StatusOK)
+	link := issueDownloadToken(t, api, token, file.ID)
+	expectStatus(t, api, newRequest(t, "GET", link, "", nil), http.StatusOK)
+}
+
+func TestListingsFallBackToPrimary(t *testing.T) {
+	api := newTestAPI(t)
+	owner, token := createTestUser(t, "owner@example.com")
+	createTestFile(t, owner, "primary.txt", 1)
+	replica := useReplica(t, owner)
+
+	sqlDB, err := replica.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.Close()
+	// The next health check finds the replica down.
+	config.SetReplica(replica)
+
+	if got := listFileNames(t, api, token); !reflect.DeepEqual(got, []string{"primary.txt"}) {
+		t.Errorf("GET /files listed %v, want the primary's files", got)
+	}
+}
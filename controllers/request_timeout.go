@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/spf13/viper"
+	"go-share/utils"
+)
+
+// requestTimeoutHeader lets a client bound how long the server works on its request.
+const requestTimeoutHeader = "X-Request-Timeout"
+
+// RequestTimeoutMiddleware gives the request context a deadline taken from X-Request-Timeout,
+// either in seconds ("30") or as a duration ("1m30s"), capped at server.max_request_timeout.
+// Handlers that support it stop early once the deadline passes and answer 504 deadline_exceeded.
+func RequestTimeoutMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get(requestTimeoutHeader)
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		timeout, ok := parseRequestTimeout(header)
+		if !ok {
+			utils.ErrorCodeJsonResponse(w, "invalid_request_timeout", requestTimeoutHeader+" must be a positive number of seconds or a duration such as 30s", http.StatusBadRequest)
+			return
+		}
+		if maxTimeout := viper.GetDuration("server.max_request_timeout"); maxTimeout > 0 && timeout > maxTimeout {
+			timeout = maxTimeout
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// parseRequestTimeout parses an X-Request-Timeout value.
+func parseRequestTimeout(header string) (time.Duration, bool) {
+	if seconds, err := strconv.ParseFloat(header, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second)), seconds > 0
+	}
+	timeout, err := time.ParseDuration(header)
+	return timeout, err == nil && timeout > 0
+}
+
+// writeDeadlineExceeded reports that the request's deadline passed before the work was done.
+// partial describes what was completed, for handlers that can report it.
+func writeDeadlineExceeded(w http.ResponseWriter, partial map[string]interface{}) {
+	body := map[string]interface{}{
+		"error": "The request deadline was exceeded",
+		"code":  "deadline_exceeded",
+	}
+	for key, value := range partial {
+		body[key] = value
+	}
+	utils.JsonResponse(w, http.StatusGatewayTimeout, body)
+}
@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"go-share/config"
+	"go-share/models"
+	"go-share/utils"
+	"gorm.io/gorm"
+)
+
+func TestParseRequestTimeout(t *testing.T) {
+	tests := []struct {
+		header string
+		want   time.Duration
+		ok     bool
+	}{
+		{"30", 30 * time.Second, true},
+		{"0.25", 250 * time.Millisecond, true},
+		{"1m30s", 90 * time.Second, true},
+		{"50ms", 50 * time.Millisecond, true},
+		{"0", 0, false},
+		{"-5", 0, false},
+		{"-1s", 0, false},
+		{"soon", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseRequestTimeout(tt.header)
+		if ok != tt.ok || (ok && got != tt.want) {
+			t.Errorf("parseRequestTimeout(%q) = %s, %v, want %s, %v", tt.header, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestRequestTimeoutMiddleware(t *testing.T) {
+	viper.Reset()
+	config.SetDefaults()
+	viper.Set("server.max_request_timeout", "1s")
+
+	tests := []struct {
+		header   string
+		status   int
+		deadline time.Duration // 0 means no deadline
+	}{
+		{"", http.StatusOK, 0},
+		{"0.5", http.StatusOK, 500 * time.Millisecond},
+		{"1h", http.StatusOK, time.Second},
+		{"never", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		var deadline time.Duration
+		handler := RequestTimeoutMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if d, ok := r.Context().Deadline(); ok {
+				deadline = time.Until(d)
+			}
+		}))
+
+		r := httptest.NewRequest("GET", "/", nil)
+		if tt.header != "" {
+			r.Header.Set("X-Request-Timeout", tt.header)
+		}
+		w := serve(handler, r)
+		if w.Code != tt.status {
+			t.Errorf("%q: got %d %s, want %d", tt.header, w.Code, w.Body, tt.status)
+		}
+		if deadline > tt.deadline || deadline < tt.deadline-100*time.Millisecond {
+			t.Errorf("%q: deadline in %s, want %s", tt.header, deadline, tt.deadline)
+		}
+	}
+}
+
+// slowDeletes makes every DELETE on the test database take delay, standing in for a slow
+// database or storage backend.
+func slowDeletes(t *testing.T, delay time.Duration) {
+	t.Helper()
+	err := config.DB.Callback().Delete().Before("gorm:delete").Register("test:slow_delete", func(*gorm.DB) {
+		time.Sleep(delay)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// bulkDeleteResult is the body of a bulk delete response.
+type bulkDeleteResult struct {
+	Code    string           `json:"code"`
+	Deleted []utils.PublicID `json:"deleted"`
+	Skipped []utils.PublicID `json:"skipped"`
+}
+
+// When the deadline passes part-way through, the files already deleted stay deleted, the rest
+// are untouched, and the 504 says which is which.
+func TestBulkDeleteDeadlineExceeded(t *testing.T) {
+	api := newTestAPI(t)
+	owner, token := createTestUser(t, "owner@example.com")
+	ids := make([]string, 5)
+	for i := range ids {
+		ids[i] = utils.EncodePublicID(createTestFile(t, owner, fmt.Sprintf("f%d.txt", i), 1).ID)
+	}
+	slowDeletes(t, 50*time.Millisecond)
+
+	r := newRequest(t, "POST", "/files/bulk-delete", token, map[string]interface{}{"ids": ids})
+	r.Header.Set("X-Request-Timeout", "120ms")
+	w := serve(api, r)
+	if w.Code != http.StatusGatewayTimeout {
+		t.Fatalf("got %d %s, want 504", w.Code, w.Body)
+	}
+	var result bulkDeleteResult
+	decode(t, w, &result)
+	if result.Code != "deadline_exceeded" {
+		t.Errorf("code = %q, want deadline_exceeded", result.Code)
+	}
+	if len(result.Deleted) == 0 || len(result.Skipped) == 0 || len(result.Deleted)+len(result.Skipped) != len(ids) {
+		t.Fatalf("deleted %v and skipped %v, want a split of all %d files", result.Deleted, result.Skipped, len(ids))
+	}
+
+	for _, list := range []struct {
+		ids    []utils.PublicID
+		stored bool
+	}{{result.Deleted, false}, {result.Skipped, true}} {
+		for _, id := range list.ids {
+			var count int64
+			if err := config.DB.Model(&models.File{}).Where("id = ?", uint(id)).Count(&count).Error; err != nil {
+				t.Fatal(err)
+			}
+			if stored := count == 1; stored != list.stored {
+				t.Errorf("file %d stored = %v, want %v", uint(id), stored, list.stored)
+			}
+		}
+	}
+}
+
+// A deadline the work fits in changes nothing.
+func TestBulkDeleteWithinDeadline(t *testing.T) {
+	api := newTestAPI(t)
+	owner, token := createTestUser(t, "owner@example.com")
+	ids := []string{utils.EncodePublicID(createTestFile(t, owner, "a.txt", 1).ID)}
+	slowDeletes(t, 10*time.Millisecond)
+
+	r := newRequest(t, "POST", "/files/bulk-delete", token, map[string]interface{}{"ids": ids})
+	r.Header.Set("X-Request-Timeout", "5")
+	w := serve(api, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d %s, want 200", w.Code, w.Body)
+	}
+	var result bulkDeleteResult
+	decode(t, w, &result)
+	if len(result.Deleted) != 1 || result.Skipped != nil {
+		t.Errorf("deleted %v and skipped %v, want the file deleted", result.Deleted, result.Skipped)
+	}
+}
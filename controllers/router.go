@@ -0,0 +1,42 @@
+package controllers
+
+import (
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// NewRouter returns the router for the full API. main serves it and the tests boot it, so
+// both see the same routes and middleware.
+//
+// State cached from the database, such as the maintenance mode, feature overrides and admin
+// stats, is dropped: a new router starts from what the database holds now.
+func NewRouter() *mux.Router {
+	resetCachedState()
+
+	router := mux.NewRouter()
+	UseMiddleware(router, MaintenanceMiddleware, RequestTimeoutMiddleware, QueryStatsMiddleware)
+
+	RegisterAuthRoutes(router)
+	RegisterOAuthRoutes(router)
+	RegisterFileRoutes(router)
+	RegisterUserRoutes(router)
+	RegisterAdminRoutes(router)
+	RegisterSystemRoutes(router)
+	RegisterDebugRoutes(router)
+	return router
+}
+
+// resetCachedState forgets the package state cached from the database.
+func resetCachedState() {
+	maintenance.Lock()
+	maintenance.mode = ""
+	maintenance.checkedAt = time.Time{}
+	maintenance.Unlock()
+
+	Features = &FeatureGate{}
+
+	statsCache.Lock()
+	statsCache.stats = nil
+	statsCache.Unlock()
+}
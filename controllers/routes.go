@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
+	"go-share/utils"
+)
+
+// RouteInfo describes one registered route.
+type RouteInfo struct {
+	Path    string   `json:"path"`
+	Methods []string `json:"methods"`
+	Queries []string `json:"queries,omitempty"`
+	// Name distinguishes routes narrowed by other matchers, such as a required header, from
+	// routes with the same method and path.
+	Name string `json:"name,omitempty"`
+	// Middleware lists the router middleware that wraps the route, outermost first. Wrappers
+	// applied to a single handler are not included.
+	Middleware []string `json:"middleware"`
+}
+
+// mux doesn't expose a router's middleware or parent, so subrouter and useMiddleware record
+// them for ListRoutes.
+var (
+	routeTreeMu      sync.Mutex
+	routerParents    = map[*mux.Router]*mux.Router{}
+	routerMiddleware = map[*mux.Router][]string{}
+)
+
+// subrouter returns a router for the routes under prefix. Every subrouter must be created
+// through it so that ListRoutes can report the middleware its routes inherit.
+func subrouter(parent *mux.Router, prefix string) *mux.Router {
+	router := parent.PathPrefix(prefix).Subrouter()
+
+	routeTreeMu.Lock()
+	defer routeTreeMu.Unlock()
+	routerParents[router] = parent
+	return router
+}
+
+// UseMiddleware adds middleware to router, like router.Use, and records it for ListRoutes.
+func UseMiddleware(router *mux.Router, middleware ...mux.MiddlewareFunc) {
+	router.Use(middleware...)
+
+	routeTreeMu.Lock()
+	defer routeTreeMu.Unlock()
+	for _, mw := range middleware {
+		routerMiddleware[router] = append(routerMiddleware[router], middlewareName(mw))
+	}
+}
+
+// funcSuffix matches the ".func1" suffix the compiler gives closures.
+var funcSuffix = regexp.MustCompile(`(\.func\d+)+$`)
+
+// middlewareName returns the name of the function that implements or built mw, such as
+// "utils.AuthMiddleware" or "controllers.trackAPIUsage".
+func middlewareName(mw mux.MiddlewareFunc) string {
+	name := runtime.FuncForPC(reflect.ValueOf(mw).Pointer()).Name()
+	name = funcSuffix.ReplaceAllString(name, "")
+	return name[strings.LastIndex(name, "/")+1:]
+}
+
+// middlewareChain returns the middleware applied to router's routes, outermost first.
+// It must be called with routeTreeMu held.
+func middlewareChain(router *mux.Router) []string {
+	chain := []string{}
+	if parent, ok := routerParents[router]; ok {
+		chain = middlewareChain(parent)
+	}
+	return append(chain, routerMiddleware[router]...)
+}
+
+// ListRoutes returns every route registered on router, sorted by path. mux silently serves
+// the first of two routes that match the same requests, so it fails if a method and path are
+// registered twice with the same query matchers and name.
+func ListRoutes(router *mux.Router) ([]RouteInfo, error) {
+	routeTreeMu.Lock()
+	defer routeTreeMu.Unlock()
+
+	routes := []RouteInfo{}
+	seen := map[string]bool{}
+	err := router.Walk(func(route *mux.Route, parent *mux.Router, ancestors []*mux.Route) error {
+		if route.GetHandler() == nil {
+			return nil // A path prefix that only holds a subrouter.
+		}
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+
+		info := RouteInfo{Path: path, Name: route.GetName(), Middleware: middlewareChain(parent)}
+		info.Methods, _ = route.GetMethods()
+		if len(info.Methods) == 0 {
+			info.Methods = []string{"*"}
+		}
+		info.Queries, _ = route.GetQueriesTemplates()
+
+		for _, method := range info.Methods {
+			key := fmt.Sprintf("%s %s?%s#%s", method, path, strings.Join(info.Queries, "&"), info.Name)
+			if seen[key] {
+				return fmt.Errorf("route %s %s is registered twice", method, path)
+			}
+			seen[key] = true
+		}
+		routes = append(routes, info)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(routes, func(i, j int) bool { return routes[i].Path < routes[j].Path })
+	return routes, nil
+}
+
+// getRoutes returns a handler listing the routes of router, for security reviews.
+func getRoutes(router *mux.Router) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		routes, err := ListRoutes(router)
+		if err != nil {
+			utils.ErrorJsonResponse(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		utils.JsonResponse(w, http.StatusOK, routes)
+	}
+}
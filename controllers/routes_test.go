@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func noop(w http.ResponseWriter, r *http.Request) {}
+
+func TestListRoutesDuplicates(t *testing.T) {
+	tests := []struct {
+		name     string
+		register func(router *mux.Router)
+		err      string
+	}{
+		{"same method and path", func(router *mux.Router) {
+			router.HandleFunc("/files", noop).Methods("GET")
+			router.HandleFunc("/files", noop).Methods("GET")
+		}, "route GET /files is registered twice"},
+		{"one of several methods", func(router *mux.Router) {
+			router.HandleFunc("/files", noop).Methods("GET", "POST")
+			router.HandleFunc("/files", noop).Methods("POST")
+		}, "route POST /files is registered twice"},
+		{"across subrouters", func(router *mux.Router) {
+			subrouter(router, "/files").HandleFunc("/{id}", noop).Methods("GET")
+			subrouter(router, "/files").HandleFunc("/{id}", noop).Methods("GET")
+		}, "route GET /files/{id} is registered twice"},
+		{"different methods", func(router *mux.Router) {
+			router.HandleFunc("/files", noop).Methods("GET")
+			router.HandleFunc("/files", noop).Methods("POST")
+		}, ""},
+		{"told apart by queries", func(router *mux.Router) {
+			router.HandleFunc("/files/{id}", noop).Methods("GET").Queries("token", "{token}")
+			router.HandleFunc("/files/{id}", noop).Methods("GET")
+		}, ""},
+		{"told apart by name", func(router *mux.Router) {
+			router.HandleFunc("/files", noop).Methods("POST").Headers("Upload-Grant", "").Name("upload-grant")
+			router.HandleFunc("/files", noop).Methods("POST")
+		}, ""},
+	}
+	for _, tt := range tests {
+		router := mux.NewRouter()
+		tt.register(router)
+		_, err := ListRoutes(router)
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%s: ListRoutes = %s, want no error", tt.name, err)
+		case tt.err != "" && (err == nil || err.Error() != tt.err):
+			t.Errorf("%s: ListRoutes = %v, want %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+// Subrouters inherit their parent's middleware, which is reported outermost first.
+func TestListRoutesMiddleware(t *testing.T) {
+	router := mux.NewRouter()
+	UseMiddleware(router, MaintenanceMiddleware)
+	router.HandleFunc("/healthz", noop).Methods("GET")
+	admin := subrouter(router, "/admin")
+	UseMiddleware(admin, AdminMiddleware)
+	admin.HandleFunc("/stats", noop).Methods("GET")
+
+	routes, err := ListRoutes(router)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []RouteInfo{
+		{Path: "/admin/stats", Methods: []string{"GET"}, Queries: []string{}, Middleware: []string{"controllers.MaintenanceMiddleware", "controllers.AdminMiddleware"}},
+		{Path: "/healthz", Methods: []string{"GET"}, Queries: []string{}, Middleware: []string{"controllers.MaintenanceMiddleware"}},
+	}
+	if !reflect.DeepEqual(routes, want) {
+		t.Errorf("ListRoutes = %+v, want %+v", routes, want)
+	}
+}
+
+func TestGetRoutes(t *testing.T) {
+	router := newTestRouter(t)
+	api := MethodHandler(router)
+	_, adminToken := createTestAdmin(t, "admin@example.com")
+	_, userToken := createTestUser(t, "user@example.com")
+
+	if w := serve(api, newRequest(t, "GET", "/admin/routes", userToken, nil)); w.Code != http.StatusForbidden {
+		t.Errorf("non-admin: got %d, want 403", w.Code)
+	}
+
+	w := serve(api, newRequest(t, "GET", "/admin/routes", adminToken, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d %s", w.Code, w.Body)
+	}
+	var got []RouteInfo
+	decode(t, w, &got)
+	want, err := ListRoutes(router)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Compared as JSON, in which routes without queries have none.
+	gotJSON, _ := json.Marshal(got)
+	wantJSON, _ := json.Marshal(want)
+	if !bytes.Equal(gotJSON, wantJSON) {
+		t.Errorf("GET /admin/routes returned\n%s\nListRoutes\n%s", gotJSON, wantJSON)
+	}
+	for _, route := range got {
+		if strings.HasPrefix(route.Path, "/admin/") && !contains(route.Middleware, "controllers.AdminMiddleware") {
+			t.Errorf("%s is not behind AdminMiddleware: %v", route.Path, route.Middleware)
+		}
+	}
+}
+
+// contains reports whether list holds s.
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
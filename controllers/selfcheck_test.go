@@ -0,0 +1,38 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+
+	"go-share/selfcheck"
+)
+
+func TestRunSelfCheck(t *testing.T) {
+	api := newTestAPI(t)
+	_, adminToken := createTestAdmin(t, "admin@example.com")
+	_, userToken := createTestUser(t, "user@example.com")
+
+	if w := serve(api, newRequest(t, "POST", "/admin/selfcheck", userToken, nil)); w.Code != http.StatusForbidden {
+		t.Errorf("non-admin: got %d, want 403", w.Code)
+	}
+
+	w := serve(api, newRequest(t, "POST", "/admin/selfcheck", adminToken, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d %s", w.Code, w.Body)
+	}
+	var report selfcheck.Report
+	decode(t, w, &report)
+	results := map[string]selfcheck.Status{}
+	for _, result := range report.Checks {
+		results[result.Name] = result.Status
+	}
+	// The test database answers and the in-memory cache works.
+	for _, name := range []string{"database", "cache"} {
+		if results[name] != selfcheck.Pass {
+			t.Errorf("%s: got %q, want pass; report %s", name, results[name], w.Body)
+		}
+	}
+	if _, ok := results["jwt_secret"]; !ok {
+		t.Errorf("the report has no jwt_secret check: %s", w.Body)
+	}
+}
@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"go-share/config"
+	"go-share/models"
+)
+
+// seedFile stores a file owned by owner that was uploaded age ago.
+func seedFile(t *testing.T, owner *models.User, name string, size int64, age time.Duration) *models.File {
+	t.Helper()
+
+	file := createTestFile(t, owner, name, size)
+	if err := config.DB.Model(file).UpdateColumn("created_at", time.Now().Add(-age)).Error; err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+// getStats fetches GET /admin/stats as the holder of token.
+func getStats(t *testing.T, api http.Handler, token string) models.Stats {
+	t.Helper()
+
+	w := serve(api, newRequest(t, "GET", "/admin/stats", token, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /admin/stats: got %d %s", w.Code, w.Body)
+	}
+	var stats models.Stats
+	decode(t, w, &stats)
+	return stats
+}
+
+func TestGetStats(t *testing.T) {
+	api := newTestAPI(t)
+	admin, adminToken := createTestAdmin(t, "admin@example.com")
+	alice, _ := createTestUser(t, "alice@example.com")
+	bob, _ := createTestUser(t, "bob@example.com")
+
+	// Only the admin logged in within the last 30 days.
+	for user, lastLogin := range map[*models.User]time.Time{admin: time.Now().Add(-24 * time.Hour), alice: time.Now().AddDate(0, 0, -40)} {
+		if err := config.DB.Model(user).Update("last_login_at", lastLogin).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	seedFile(t, alice, "a.txt", 100, time.Minute)
+	seedFile(t, alice, "b.txt", 200, 3*24*time.Hour)
+	pinned := seedFile(t, alice, "c.txt", 300, 10*24*time.Hour)
+	if err := config.DB.Model(pinned).Update("pinned", true).Error; err != nil {
+		t.Fatal(err)
+	}
+	seedFile(t, bob, "d.txt", 400, 2*time.Hour)
+	trashed := seedFile(t, bob, "e.txt", 50, time.Minute)
+	if err := config.DB.Delete(trashed).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	stats := getStats(t, api, adminToken)
+
+	want := models.Stats{
+		TotalUsers:     3,
+		ActiveUsers30d: 1,
+		TotalFiles:     4,
+		TotalBytes:     1000,
+		Uploads24h:     2,
+		Uploads7d:      3,
+		TrashFiles:     1,
+		TrashBytes:     50,
+		PinnedFiles:    1,
+		PinnedBytes:    300,
+		TopUsers: []models.UserUsage{
+			{UserID: alice.ID, Email: "alice@example.com", Files: 3, Bytes: 600, PinnedBytes: 300},
+			{UserID: bob.ID, Email: "bob@example.com", Files: 1, Bytes: 400},
+		},
+		ByCategory: []models.CategoryUsage{{Category: models.CategoryDocument, Files: 4, Bytes: 1000}},
+	}
+	stats.GeneratedAt = time.Time{}
+	if !reflect.DeepEqual(stats, want) {
+		t.Errorf("got stats\n%+v\nwant\n%+v", stats, want)
+	}
+}
+
+// Dashboard polling within admin.stats_cache_ttl is answered from the cached aggregate.
+func TestGetStatsCached(t *testing.T) {
+	api := newTestAPI(t)
+	_, adminToken := createTestAdmin(t, "admin@example.com")
+	owner, _ := createTestUser(t, "owner@example.com")
+	createTestFile(t, owner, "a.txt", 100)
+
+	first := getStats(t, api, adminToken)
+	createTestFile(t, owner, "b.txt", 200)
+	second := getStats(t, api, adminToken)
+
+	if second.TotalFiles != 1 || second.TotalBytes != 100 || !second.GeneratedAt.Equal(first.GeneratedAt) {
+		t.Errorf("second request got %d files, %d bytes generated at %s; want the cached 1 file, 100 bytes generated at %s",
+			second.TotalFiles, second.TotalBytes, second.GeneratedAt, first.GeneratedAt)
+	}
+}
@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"go-share/config"
+	"go-share/models"
+)
+
+// What a user can still do with their own account and files in each suspension state. Reads,
+// sign-in and deletes always work; uploads and new shares are refused while suspended.
+func TestSuspendedUserOperations(t *testing.T) {
+	const (
+		allowed = true
+		refused = false
+	)
+	operations := []struct {
+		name string
+		// request builds the operation for a user holding token, with file one of their files
+		// and grant an upload grant issued before the account changed state.
+		request func(t *testing.T, token string, file *models.File, grant string) *http.Request
+		// whileSuspended is whether the operation works while the suspension is in force.
+		whileSuspended bool
+	}{
+		{"profile", func(t *testing.T, token string, _ *models.File, _ string) *http.Request {
+			return newRequest(t, "GET", "/users/me", token, nil)
+		}, allowed},
+		{"list files", func(t *testing.T, token string, _ *models.File, _ string) *http.Request {
+			return newRequest(t, "GET", "/files", token, nil)
+		}, allowed},
+		{"read file", func(t *testing.T, token string, file *models.File, _ string) *http.Request {
+			return newRequest(t, "GET", fileURL(file), token, nil)
+		}, allowed},
+		{"read comments", func(t *testing.T, token string, file *models.File, _ string) *http.Request {
+			return newRequest(t, "GET", fileURL(file)+"/comments", token, nil)
+		}, allowed},
+		{"upload", func(t *testing.T, token string, _ *models.File, _ string) *http.Request {
+			return newRequest(t, "POST", "/files", token, map[string]interface{}{"name": "new.txt", "path": "/new.txt", "size": 1})
+		}, refused},
+		{"issue upload grant", func(t *testing.T, token string, _ *models.File, _ string) *http.Request {
+			return newRequest(t, "POST", "/files/upload-grants", token, nil)
+		}, refused},
+		{"upload with earlier grant", func(t *testing.T, _ string, _ *models.File, grant string) *http.Request {
+			return uploadWithGrant(t, "/files", grant, map[string]interface{}{"name": "granted.txt", "path": "/granted.txt", "size": 1})
+		}, refused},
+		{"create download link", func(t *testing.T, token string, file *models.File, _ string) *http.Request {
+			return newRequest(t, "POST", fileURL(file)+"/download-token", token, nil)
+		}, refused},
+		{"share", func(t *testing.T, token string, file *models.File, _ string) *http.Request {
+			body := map[string]interface{}{"email": "friend@example.com", "expires_at": time.Now().Add(time.Hour)}
+			return newRequest(t, "POST", fileURL(file)+"/grants", token, body)
+		}, refused},
+		{"delete file", func(t *testing.T, token string, file *models.File, _ string) *http.Request {
+			return newRequest(t, "DELETE", fileURL(file), token, nil)
+		}, allowed},
+	}
+
+	past, future := time.Now().Add(-time.Minute), time.Now().Add(time.Hour)
+	states := []struct {
+		name      string
+		suspend   bool
+		until     *time.Time
+		suspended bool
+	}{
+		{"active", false, nil, false},
+		{"suspended", true, nil, true},
+		{"suspended for a while", true, &future, true},
+		{"suspension ran out", true, &past, false},
+	}
+	for _, state := range states {
+		t.Run(state.name, func(t *testing.T) {
+			api := newTestAPI(t)
+			user, token := createTestUser(t, "user@example.com")
+			createTestUser(t, "friend@example.com")
+			grant := issueUploadGrant(t, api, token, nil)
+			if state.suspend {
+				if err := user.Suspend(config.DB, state.until, models.AuditLog{Reason: "abuse report"}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if code, body := login(t, api, "user@example.com", "correct horse"); code != http.StatusOK {
+				t.Errorf("sign in: got %d %s", code, body)
+			}
+			for _, op := range operations {
+				file := createTestFile(t, user, op.name+".txt", 1)
+				w := serve(api, op.request(t, token, file, grant))
+				want := allowed
+				if state.suspended {
+					want = op.whileSuspended
+				}
+				switch {
+				case want && w.Code >= 300:
+					t.Errorf("%s: got %d %s, want it allowed", op.name, w.Code, w.Body)
+				case !want && (w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), `"account_suspended"`)):
+					t.Errorf("%s: got %d %s, want 403 account_suspended", op.name, w.Code, w.Body)
+				}
+			}
+
+			var profile models.UserProfile
+			decode(t, serve(api, newRequest(t, "GET", "/users/me", token, nil)), &profile)
+			if got := profile.Suspension != nil; got != state.suspended {
+				t.Errorf("profile shows a suspension notice: %v, want %v", got, state.suspended)
+			} else if got && profile.Suspension.Reason != "abuse report" {
+				t.Errorf("suspension notice reason = %q, want %q", profile.Suspension.Reason, "abuse report")
+			}
+		})
+	}
+}
+
+// The refusal carries the reason, so clients can show it without another request.
+func TestSuspendedUploadReason(t *testing.T) {
+	api := newTestAPI(t)
+	user, token := createTestUser(t, "user@example.com")
+	if err := user.Suspend(config.DB, nil, models.AuditLog{Reason: "abuse report"}); err != nil {
+		t.Fatal(err)
+	}
+
+	w := serve(api, newRequest(t, "POST", "/files", token, map[string]interface{}{"name": "a.txt", "path": "/a.txt", "size": 1}))
+	var body struct {
+		Error string `json:"error"`
+	}
+	decode(t, w, &body)
+	if body.Error != "Account suspended: abuse report" {
+		t.Errorf("error = %q, want the suspension reason", body.Error)
+	}
+
+	var files int64
+	config.DB.Model(&models.File{}).Where("user_id = ?", user.ID).Count(&files)
+	if files != 0 {
+		t.Errorf("%d files stored by a suspended user", files)
+	}
+}
@@ -0,0 +1,38 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go-share/internal/buildinfo"
+	"go-share/utils"
+)
+
+// RegisterSystemRoutes registers the unauthenticated operational routes.
+func RegisterSystemRoutes(router *mux.Router) {
+	router.HandleFunc("/healthz", GetHealth).Methods("GET")
+	router.HandleFunc("/version", GetVersion).Methods("GET")
+}
+
+// RegisterPublicRoutes registers the routes served on server.public_address: the health
+// check, downloads authorized by a signed token, and uploads authorized by an upload grant.
+// Everything else stays on the private listener.
+func RegisterPublicRoutes(router *mux.Router) {
+	router.HandleFunc("/healthz", GetHealth).Methods("GET")
+	registerDownloadTokenRoute(router)
+	registerUploadGrantRoute(router)
+}
+
+// GetHealth reports that the server is up, along with the current maintenance mode.
+func GetHealth(w http.ResponseWriter, r *http.Request) {
+	utils.JsonResponse(w, http.StatusOK, map[string]string{
+		"status":      "ok",
+		"maintenance": MaintenanceMode(),
+	})
+}
+
+// GetVersion returns the build information of the running server.
+func GetVersion(w http.ResponseWriter, r *http.Request) {
+	Features.All() // make sure the feature flags in the build info are current
+	utils.JsonResponse(w, http.StatusOK, buildinfo.Get())
+}
@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/spf13/viper"
+	"go-share/config"
+	"go-share/models"
+)
+
+// uploadFile posts a new file named name, with ?on_conflict=onConflict unless it is empty.
+func uploadFile(t *testing.T, token, name string, size int64, onConflict string) *http.Request {
+	t.Helper()
+	target := "/files"
+	if onConflict != "" {
+		target += "?on_conflict=" + onConflict
+	}
+	return newRequest(t, "POST", target, token, map[string]interface{}{"name": name, "path": "/" + name, "size": size})
+}
+
+func TestUploadOnConflict(t *testing.T) {
+	tests := []struct {
+		onConflict string
+		status     int
+		action     string
+		name       string
+		files      int64
+	}{
+		{"error", http.StatusConflict, "", "", 1},
+		{"rename", http.StatusCreated, models.UploadRenamed, "a (1).txt", 2},
+		{"replace", http.StatusOK, models.UploadReplaced, "a.txt", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.onConflict, func(t *testing.T) {
+			api := newTestAPI(t)
+			owner, token := createTestUser(t, "owner@example.com")
+			existing := createTestFile(t, owner, "a.txt", 1)
+
+			w := serve(api, uploadFile(t, token, "a.txt", 2, tt.onConflict))
+			if w.Code != tt.status {
+				t.Fatalf("got %d %s, want %d", w.Code, w.Body, tt.status)
+			}
+			if got := w.Header().Get("Upload-Action"); got != tt.action {
+				t.Errorf("Upload-Action = %q, want %q", got, tt.action)
+			}
+
+			var count int64
+			if err := config.DB.Model(&models.File{}).Where("user_id = ?", owner.ID).Count(&count).Error; err != nil {
+				t.Fatal(err)
+			}
+			if count != tt.files {
+				t.Errorf("owner has %d files, want %d", count, tt.files)
+			}
+			if tt.action == "" {
+				return
+			}
+
+			var got models.File
+			decode(t, w, &got)
+			if got.Name != tt.name || got.Size != 2 {
+				t.Errorf("response has name %q and size %d, want %q and 2", got.Name, got.Size, tt.name)
+			}
+			if replaced := got.ID == existing.ID; replaced != (tt.action == models.UploadReplaced) {
+				t.Errorf("response has ID %d, existing file is %d", got.ID, existing.ID)
+			}
+			if tt.action == models.UploadReplaced && got.Version != existing.Version+1 {
+				t.Errorf("replaced file has version %d, want %d", got.Version, existing.Version+1)
+			}
+		})
+	}
+}
+
+// Without ?on_conflict, upload.on_conflict decides; a name that doesn't clash is just created.
+func TestUploadOnConflictDefault(t *testing.T) {
+	api := newTestAPI(t)
+	owner, token := createTestUser(t, "owner@example.com")
+	createTestFile(t, owner, "a.txt", 1)
+
+	if w := serve(api, uploadFile(t, token, "a.txt", 1, "")); w.Code != http.StatusConflict {
+		t.Errorf("default setting: got %d %s, want 409", w.Code, w.Body)
+	}
+
+	viper.Set("upload.on_conflict", models.ConflictRename)
+	w := serve(api, uploadFile(t, token, "a.txt", 1, ""))
+	if w.Code != http.StatusCreated || w.Header().Get("Upload-Action") != models.UploadRenamed {
+		t.Errorf("upload.on_conflict = rename: got %d with Upload-Action %q", w.Code, w.Header().Get("Upload-Action"))
+	}
+
+	w = serve(api, uploadFile(t, token, "b.txt", 1, models.ConflictReplace))
+	if w.Code != http.StatusCreated || w.Header().Get("Upload-Action") != models.UploadCreated {
+		t.Errorf("new name: got %d with Upload-Action %q, want 201 created", w.Code, w.Header().Get("Upload-Action"))
+	}
+}
+
+func TestUploadOnConflictInvalid(t *testing.T) {
+	api := newTestAPI(t)
+	_, token := createTestUser(t, "owner@example.com")
+
+	if w := serve(api, uploadFile(t, token, "a.txt", 1, "overwrite")); w.Code != http.StatusBadRequest {
+		t.Errorf("got %d %s, want 400", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/spf13/viper"
+	"go-share/config"
+	"go-share/models"
+	"go-share/utils"
+)
+
+// uploadGrantHeader carries an upload grant in place of the Authorization header.
+const uploadGrantHeader = "X-Upload-Grant"
+
+// registerUploadGrantRoute registers POST /files authorized by an X-Upload-Grant header, which
+// needs no other credentials.
+func registerUploadGrantRoute(router *mux.Router) {
+	router.HandleFunc("/files", CreateFileWithUploadGrant).Methods("POST").Headers(uploadGrantHeader, "").Name("upload-grant")
+}
+
+// CreateUploadGrant issues a short-lived, single-use token that lets a frontend upload one file
+// on the caller's behalf without holding their JWT. The body may narrow the grant with max_size
+// (capped at upload.grant_max_size) and content_types.
+func CreateUploadGrant(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		MaxSize      int64    `json:"max_size"`
+		ContentTypes []string `json:"content_types"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			utils.ErrorJsonResponse(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	maxSize := viper.GetInt64("upload.grant_max_size")
+	if body.MaxSize < 0 || body.MaxSize > maxSize {
+		utils.ErrorJsonResponse(w, "max_size must be between 0 and upload.grant_max_size", http.StatusBadRequest)
+		return
+	}
+	if body.MaxSize > 0 {
+		maxSize = body.MaxSize
+	}
+
+	userID, _ := utils.GetUserID(r)
+	if rejectSuspended(w, userID) || !requirePlanFeature(w, r, userID, models.PlanFeatureUploadGrants) {
+		return
+	}
+
+	grant := &utils.UploadGrant{UserID: userID, MaxSize: maxSize, ContentTypes: body.ContentTypes}
+	token, err := utils.GenerateUploadGrant(grant, viper.GetDuration("upload.grant_ttl"))
+	if err != nil {
+		utils.ErrorJsonResponse(w, "Error creating upload grant", http.StatusInternalServerError)
+		return
+	}
+
+	utils.JsonResponse(w, http.StatusCreated, map[string]interface{}{
+		"token":         token,
+		"expires_at":    grant.ExpiresAt,
+		"max_size":      grant.MaxSize,
+		"content_types": grant.ContentTypes,
+	})
+}
+
+// CreateFileWithUploadGrant creates a file owned by the user an upload grant was issued to.
+// The grant is used up once the file is stored. ?on_conflict may be error or rename.
+func CreateFileWithUploadGrant(w http.ResponseWriter, r *http.Request) {
+	grant, err := utils.VerifyUploadGrant(r.Header.Get(uploadGrantHeader))
+	if err != nil {
+		utils.ErrorCodeJsonResponse(w, "invalid_upload_grant", "Invalid or expired upload grant", http.StatusUnauthorized)
+		return
+	}
+
+	var file models.File
+	if err := json.NewDecoder(r.Body).Decode(&file); err != nil {
+		utils.ErrorJsonResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if file.Size > grant.MaxSize {
+		utils.ErrorCodeJsonResponse(w, "upload_grant_size_exceeded", "The file is larger than the upload grant allows", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if !grant.AllowsContentType(file.ContentType) {
+		utils.ErrorCodeJsonResponse(w, "upload_grant_content_type", "The upload grant does not allow this content type", http.StatusUnsupportedMediaType)
+		return
+	}
+	if rejectSuspended(w, grant.UserID) || !requirePlanFeature(w, r, grant.UserID, models.PlanFeatureUploadGrants) {
+		return
+	}
+
+	// A grant only adds files. Replacing would let anyone holding it overwrite any of the
+	// user's files by name, so a replace default falls back to error.
+	onConflict := onConflictMode(r)
+	if onConflict == models.ConflictReplace {
+		if r.URL.Query().Get("on_conflict") != "" {
+			utils.ErrorJsonResponse(w, "on_conflict must be error or rename with an upload grant", http.StatusBadRequest)
+			return
+		}
+		onConflict = models.ConflictError
+	}
+
+	if err := models.ConsumeUploadGrant(config.DB, grant.ID, grant.ExpiresAt); err != nil {
+		if errors.Is(err, models.ErrUploadGrantUsed) {
+			utils.ErrorCodeJsonResponse(w, "upload_grant_used", err.Error(), http.StatusConflict)
+			return
+		}
+		utils.ErrorJsonResponse(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if !createFile(w, r, &file, grant.UserID, "", onConflict) {
+		// The upload failed, so the grant may be tried again until it expires.
+		if err := models.ReleaseUploadGrant(config.DB, grant.ID); err != nil {
+			log.Printf("Error releasing upload grant %s: %s", grant.ID, err)
+		}
+	}
+}
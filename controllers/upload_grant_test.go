@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"go-share/config"
+	"go-share/models"
+	"go-share/utils"
+)
+
+// uploadWithGrant builds POST /files authorized by an upload grant.
+func uploadWithGrant(t *testing.T, target, grant string, file map[string]interface{}) *http.Request {
+	t.Helper()
+	r := newRequest(t, "POST", target, "", file)
+	r.Header.Set(uploadGrantHeader, grant)
+	return r
+}
+
+// issueUploadGrant asks the API for an upload grant for the holder of token.
+func issueUploadGrant(t *testing.T, api http.Handler, token string, body map[string]interface{}) string {
+	t.Helper()
+	w := serve(api, newRequest(t, "POST", "/files/upload-grants", token, body))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("creating upload grant: got %d %s", w.Code, w.Body)
+	}
+	var grant struct {
+		Token string `json:"token"`
+	}
+	decode(t, w, &grant)
+	return grant.Token
+}
+
+func TestUploadGrantIsSingleUse(t *testing.T) {
+	api := newTestAPI(t)
+	owner, token := createTestUser(t, "owner@example.com")
+	grant := issueUploadGrant(t, api, token, nil)
+
+	w := serve(api, uploadWithGrant(t, "/files", grant, map[string]interface{}{"name": "a.txt", "path": "/a.txt", "size": 1}))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("first upload: got %d %s", w.Code, w.Body)
+	}
+	var created models.File
+	decode(t, w, &created)
+	if created.UserID != owner.ID {
+		t.Errorf("file owned by %d, want the grant's user %d", created.UserID, owner.ID)
+	}
+
+	w = serve(api, uploadWithGrant(t, "/files", grant, map[string]interface{}{"name": "b.txt", "path": "/b.txt", "size": 1}))
+	if w.Code != http.StatusConflict {
+		t.Fatalf("second upload: got %d %s, want 409", w.Code, w.Body)
+	}
+}
+
+func TestUploadGrantFailedUploadCanBeRetried(t *testing.T) {
+	api := newTestAPI(t)
+	_, token := createTestUser(t, "owner@example.com")
+	grant := issueUploadGrant(t, api, token, nil)
+
+	// An invalid name fails after the grant was consumed, which must give it back.
+	if w := serve(api, uploadWithGrant(t, "/files", grant, map[string]interface{}{"path": "/a.txt", "size": 1})); w.Code < 400 {
+		t.Fatalf("upload without a name: got %d, want an error", w.Code)
+	}
+	if w := serve(api, uploadWithGrant(t, "/files", grant, map[string]interface{}{"name": "a.txt", "path": "/a.txt", "size": 1})); w.Code != http.StatusCreated {
+		t.Fatalf("retry: got %d %s", w.Code, w.Body)
+	}
+}
+
+func TestUploadGrantExpired(t *testing.T) {
+	api := newTestAPI(t)
+	owner, _ := createTestUser(t, "owner@example.com")
+	grant, err := utils.GenerateUploadGrant(&utils.UploadGrant{UserID: owner.ID, MaxSize: 100}, -time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := serve(api, uploadWithGrant(t, "/files", grant, map[string]interface{}{"name": "a.txt", "path": "/a.txt", "size": 1}))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("got %d %s, want 401", w.Code, w.Body)
+	}
+}
+
+func TestUploadGrantLimits(t *testing.T) {
+	tests := []struct {
+		name   string
+		file   map[string]interface{}
+		status int
+	}{
+		{"within limits", map[string]interface{}{"name": "a.png", "path": "/a.png", "content_type": "image/png", "size": 100}, http.StatusCreated},
+		{"oversize", map[string]interface{}{"name": "a.png", "path": "/a.png", "content_type": "image/png", "size": 101}, http.StatusRequestEntityTooLarge},
+		{"content type not allowed", map[string]interface{}{"name": "a.pdf", "path": "/a.pdf", "content_type": "application/pdf", "size": 1}, http.StatusUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newTestAPI(t)
+			_, token := createTestUser(t, "owner@example.com")
+			grant := issueUploadGrant(t, api, token, map[string]interface{}{"max_size": 100, "content_types": []string{"image/png"}})
+
+			if w := serve(api, uploadWithGrant(t, "/files", grant, tt.file)); w.Code != tt.status {
+				t.Fatalf("got %d %s, want %d", w.Code, w.Body, tt.status)
+			}
+		})
+	}
+}
+
+// A grant may add files but never overwrite one, whatever the request or the config asks for.
+func TestUploadGrantNeverReplaces(t *testing.T) {
+	tests := []struct {
+		name    string
+		setting string
+		query   string
+		status  int
+	}{
+		{"explicit replace", "error", "?on_conflict=replace", http.StatusBadRequest},
+		{"replace by default", "replace", "", http.StatusConflict},
+		{"rename", "replace", "?on_conflict=rename", http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newTestAPI(t)
+			viper.Set("upload.on_conflict", tt.setting)
+			owner, token := createTestUser(t, "owner@example.com")
+			existing := createTestFile(t, owner, "a.txt", 1)
+			grant := issueUploadGrant(t, api, token, nil)
+
+			w := serve(api, uploadWithGrant(t, "/files"+tt.query, grant, map[string]interface{}{"name": "a.txt", "path": "/other.txt", "size": 2}))
+			if w.Code != tt.status {
+				t.Fatalf("got %d %s, want %d", w.Code, w.Body, tt.status)
+			}
+
+			var stored models.File
+			if err := config.DB.First(&stored, existing.ID).Error; err != nil {
+				t.Fatal(err)
+			}
+			if stored.Path != "/a.txt" || stored.Size != 1 || stored.Version != 1 {
+				t.Errorf("existing file was changed: %+v", stored)
+			}
+		})
+	}
+}
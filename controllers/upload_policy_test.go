@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"go-share/config"
+	"go-share/models"
+)
+
+// uploadPaths are the ways a file is created. Each returns the request for a file with the
+// given fields, for the holder of token.
+var uploadPaths = []struct {
+	name    string
+	request func(t *testing.T, api http.Handler, token string, file map[string]interface{}) *http.Request
+}{
+	{"json", func(t *testing.T, api http.Handler, token string, file map[string]interface{}) *http.Request {
+		return newRequest(t, "POST", "/files", token, file)
+	}},
+	{"replace", func(t *testing.T, api http.Handler, token string, file map[string]interface{}) *http.Request {
+		return newRequest(t, "POST", "/files?on_conflict=replace", token, file)
+	}},
+	{"upload grant", func(t *testing.T, api http.Handler, token string, file map[string]interface{}) *http.Request {
+		return uploadWithGrant(t, "/files", issueUploadGrant(t, api, token, nil), file)
+	}},
+}
+
+// newUpload returns the body of an upload of name with the given extra fields.
+func newUpload(name string, fields map[string]interface{}) map[string]interface{} {
+	file := map[string]interface{}{"name": name, "path": "/" + name, "size": 1}
+	for key, value := range fields {
+		file[key] = value
+	}
+	return file
+}
+
+func TestUploadRequiredFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		fields  map[string]interface{}
+		missing string
+	}{
+		{"nothing", nil, "description, metadata.project"},
+		{"blank description", map[string]interface{}{"description": "  ", "metadata": map[string]string{"project": "apollo"}}, "description"},
+		{"empty metadata value", map[string]interface{}{"description": "Q3", "metadata": map[string]string{"project": ""}}, "metadata.project"},
+		{"other metadata", map[string]interface{}{"description": "Q3", "metadata": map[string]string{"team": "apollo"}}, "metadata.project"},
+		{"everything", map[string]interface{}{"description": "Q3", "metadata": map[string]string{"project": "apollo"}}, ""},
+	}
+	for _, path := range uploadPaths {
+		t.Run(path.name, func(t *testing.T) {
+			api := newTestAPI(t)
+			user, token := createTestUser(t, "ada@example.com")
+			viper.Set("upload.required_fields", []string{"description", "metadata.project"})
+
+			for _, tt := range tests {
+				w := serve(api, path.request(t, api, token, newUpload("report.pdf", tt.fields)))
+				var body struct {
+					Error string `json:"error"`
+					Code  string `json:"code"`
+				}
+				decode(t, w, &body)
+				switch {
+				case tt.missing == "" && w.Code >= 300:
+					t.Errorf("%s: got %d %s, want it stored", tt.name, w.Code, w.Body)
+				case tt.missing != "" && (w.Code != http.StatusUnprocessableEntity || body.Code != "missing_required_fields"):
+					t.Errorf("%s: got %d %s, want 422 missing_required_fields", tt.name, w.Code, w.Body)
+				case tt.missing != "" && !strings.HasSuffix(body.Error, ": "+tt.missing):
+					t.Errorf("%s: error %q, want it to list %s", tt.name, body.Error, tt.missing)
+				}
+			}
+
+			var files int64
+			config.DB.Model(&models.File{}).Where("user_id = ?", user.ID).Count(&files)
+			if files != 1 {
+				t.Errorf("%d files stored, want only the complete one", files)
+			}
+		})
+	}
+}
+
+func TestUploadDescriptionTemplate(t *testing.T) {
+	for _, path := range uploadPaths {
+		t.Run(path.name, func(t *testing.T) {
+			api := newTestAPI(t)
+			_, token := createTestUser(t, "ada@example.com")
+			viper.Set("upload.description_template", "{filename} from {user_email} on {date} {unknown}")
+			viper.Set("upload.required_fields", []string{"description"})
+
+			today := time.Now().UTC().Format("2006-01-02")
+			tests := []struct {
+				name        string
+				description string
+				want        string
+			}{
+				{"a.txt", "", "a.txt from ada@example.com on " + today + " {unknown}"},
+				{"b.txt", "Written by hand", "Written by hand"},
+			}
+			for _, tt := range tests {
+				w := serve(api, path.request(t, api, token, newUpload(tt.name, map[string]interface{}{"description": tt.description})))
+				if w.Code >= 300 {
+					t.Fatalf("%s: got %d %s", tt.name, w.Code, w.Body)
+				}
+				var file models.File
+				decode(t, w, &file)
+				if file.Description != tt.want {
+					t.Errorf("%s: description = %q, want %q", tt.name, file.Description, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go-share/config"
+	"go-share/models"
+	"go-share/utils"
+)
+
+// RegisterUserRoutes registers the routes for the authenticated user's own account.
+func RegisterUserRoutes(router *mux.Router) {
+	userRouter := subrouter(router, "/users")
+	UseMiddleware(userRouter, utils.AuthMiddleware, trackAPIUsage("users"))
+
+	userRouter.HandleFunc("/me", GetCurrentUser).Methods("GET")
+	userRouter.HandleFunc("/me", UpdateCurrentUser).Methods("PATCH")
+	userRouter.HandleFunc("/me/api-usage", GetAPIUsage).Methods("GET")
+
+	registerNotificationRoutes(userRouter)
+}
+
+// loadCurrentUser fetches the authenticated user, writing an error response on failure.
+func loadCurrentUser(w http.ResponseWriter, r *http.Request, user *models.User) bool {
+	userID, _ := utils.GetUserID(r)
+	if err := config.DB.First(user, userID).Error; err != nil {
+		utils.ErrorJsonResponse(w, "User not found", http.StatusNotFound)
+		return false
+	}
+	return true
+}
+
+// rejectSuspended writes a 403 account_suspended response and returns true if userID's
+// account is suspended. Write routes that suspended users may not use call it first.
+func rejectSuspended(w http.ResponseWriter, userID uint) bool {
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		utils.ErrorJsonResponse(w, "User not found", http.StatusNotFound)
+		return true
+	}
+	if !user.IsSuspended() {
+		return false
+	}
+
+	utils.ErrorCodeJsonResponse(w, "account_suspended", "Account suspended: "+user.SuspensionReason, http.StatusForbidden)
+	return true
+}
+
+// GetCurrentUser returns the authenticated user's profile.
+func GetCurrentUser(w http.ResponseWriter, r *http.Request) {
+	var user models.User
+	if !loadCurrentUser(w, r, &user) {
+		return
+	}
+
+	utils.JsonResponse(w, http.StatusOK, user.Profile())
+}
+
+// UpdateCurrentUser updates the authenticated user's profile fields.
+func UpdateCurrentUser(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		DisplayName *string `json:"display_name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		utils.ErrorJsonResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var user models.User
+	if !loadCurrentUser(w, r, &user) {
+		return
+	}
+
+	if body.DisplayName != nil {
+		if err := user.UpdateDisplayName(config.DB, *body.DisplayName); err != nil {
+			utils.ErrorJsonResponse(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+	}
+
+	utils.JsonResponse(w, http.StatusOK, user.Profile())
+}
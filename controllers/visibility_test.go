@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"go-share/config"
+	"go-share/models"
+	"go-share/utils"
+)
+
+// Every read endpoint agrees on who sees a file, for each file state and viewer. The states
+// are applied directly to the database, like writes from another replica or an admin tool.
+func TestReadEndpointsAgreeOnVisibility(t *testing.T) {
+	tests := []struct {
+		state string
+		apply func(t *testing.T, grantee *models.User, file *models.File)
+		// visible is whether the owner, the grantee and a stranger see the file.
+		visible [3]bool
+	}{
+		{"private", func(t *testing.T, grantee *models.User, file *models.File) {}, [3]bool{true, false, false}},
+		{"shared", func(t *testing.T, grantee *models.User, file *models.File) {
+			grantAccess(t, grantee, file, time.Now().Add(time.Hour))
+		}, [3]bool{true, true, false}},
+		{"share expired", func(t *testing.T, grantee *models.User, file *models.File) {
+			grantAccess(t, grantee, file, time.Now().Add(-time.Minute))
+		}, [3]bool{true, false, false}},
+		{"shared and deleted", func(t *testing.T, grantee *models.User, file *models.File) {
+			grantAccess(t, grantee, file, time.Now().Add(time.Hour))
+			if err := config.DB.Delete(file).Error; err != nil {
+				t.Fatal(err)
+			}
+		}, [3]bool{false, false, false}},
+		{"shared under legal hold", func(t *testing.T, grantee *models.User, file *models.File) {
+			grantAccess(t, grantee, file, time.Now().Add(time.Hour))
+			if err := config.DB.Model(file).Update("legal_hold", true).Error; err != nil {
+				t.Fatal(err)
+			}
+		}, [3]bool{true, true, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.state, func(t *testing.T) {
+			api := newTestAPI(t)
+			owner, ownerToken := createTestUser(t, "owner@example.com")
+			grantee, granteeToken := createTestUser(t, "grantee@example.com")
+			_, strangerToken := createTestUser(t, "stranger@example.com")
+			file := createTestFile(t, owner, "a.txt", 1)
+			tt.apply(t, grantee, file)
+
+			id := utils.EncodePublicID(file.ID)
+			for i, token := range []string{ownerToken, granteeToken, strangerToken} {
+				viewer := []string{"owner", "grantee", "stranger"}[i]
+				want := tt.visible[i]
+
+				w := serve(api, newRequest(t, "GET", "/files", token, nil))
+				if got := strings.Contains(w.Body.String(), `"id":"`+id+`"`); got != want {
+					t.Errorf("%s: listed = %v, want %v", viewer, got, want)
+				}
+				for _, path := range []string{"/files/" + id, "/files/" + id + "/comments"} {
+					if got := serve(api, newRequest(t, "GET", path, token, nil)).Code == http.StatusOK; got != want {
+						t.Errorf("%s: GET %s reachable = %v, want %v", viewer, path, got, want)
+					}
+				}
+				w = serve(api, newRequest(t, "POST", "/files/batch-get", token, map[string]interface{}{"ids": []string{id}}))
+				if got := w.Code == http.StatusOK && !strings.Contains(w.Body.String(), "not_found"); got != want {
+					t.Errorf("%s: batch-get found = %v, want %v (%s)", viewer, got, want, w.Body)
+				}
+			}
+		})
+	}
+}
+
+// grantAccess stores a read grant on file for grantee, bypassing the API's expiry checks.
+func grantAccess(t *testing.T, grantee *models.User, file *models.File, expiresAt time.Time) {
+	t.Helper()
+	err := config.DB.Create(&models.FileGrant{FileID: utils.PublicID(file.ID), GranteeID: grantee.ID, Permission: models.PermissionRead, ExpiresAt: expiresAt, CreatedBy: file.UserID}).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,26 @@
+// Package asserts holds test assertions about how the application behaves rather than what it
+// returns, such as how many database queries an endpoint makes.
+package asserts
+
+import (
+	"testing"
+
+	"go-share/querystats"
+)
+
+// MaxQueries fails the test if a request served after the call makes more than n database
+// queries. It applies until the test ends, so call it after any setup done through the API.
+// Only queries run with the request context are counted, as for queries.max_per_request.
+//
+// Listing tests use it with enough rows that a query per row would exceed n, so that an N+1
+// pattern fails the test instead of reaching production.
+func MaxQueries(t testing.TB, n int64) {
+	t.Helper()
+
+	remove := querystats.OnRequest(func(rec *querystats.Recorder) {
+		if got := rec.Queries(); got > n {
+			t.Errorf("request made %d queries, want at most %d", got, n)
+		}
+	})
+	t.Cleanup(remove)
+}
@@ -0,0 +1,79 @@
+// Package buildinfo exposes the version information baked into the binary.
+//
+// The variables are meant to be set at build time, for example:
+//
+//	go build -ldflags "-X go-share/internal/buildinfo.Version=v1.2.0 -X go-share/internal/buildinfo.Commit=$(git rev-parse --short HEAD) -X go-share/internal/buildinfo.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+package buildinfo
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Build metadata injected via -ldflags. They default to "dev" for local builds.
+var (
+	Version   = "dev"
+	Commit    = "dev"
+	BuildDate = "dev"
+)
+
+var (
+	featuresMu sync.RWMutex
+	features   = map[string]string{}
+)
+
+// Info describes the running build.
+type Info struct {
+	Version   string            `json:"version"`
+	Commit    string            `json:"commit"`
+	BuildDate string            `json:"build_date"`
+	GoVersion string            `json:"go_version"`
+	Features  map[string]string `json:"features"`
+}
+
+// SetFeature records the mode of an optional feature (e.g. "registration" -> "open").
+// Only non-sensitive mode names should be recorded here since they are served publicly.
+func SetFeature(name, mode string) {
+	featuresMu.Lock()
+	defer featuresMu.Unlock()
+	features[name] = mode
+}
+
+// Get returns a snapshot of the build information.
+func Get() Info {
+	featuresMu.RLock()
+	defer featuresMu.RUnlock()
+
+	snapshot := make(map[string]string, len(features))
+	for name, mode := range features {
+		snapshot[name] = mode
+	}
+
+	return Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+		Features:  snapshot,
+	}
+}
+
+// String formats the build information as a single log line.
+func (i Info) String() string {
+	names := make([]string, 0, len(i.Features))
+	for name := range i.Features {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, name+"="+i.Features[name])
+	}
+
+	return fmt.Sprintf("version=%s commit=%s build_date=%s go=%s features=[%s]",
+		i.Version, i.Commit, i.BuildDate, i.GoVersion, strings.Join(pairs, " "))
+}
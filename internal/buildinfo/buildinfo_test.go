@@ -0,0 +1,98 @@
+package buildinfo
+
+import (
+	"encoding/json"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+// withFeatures replaces the recorded features for the duration of the test.
+func withFeatures(t *testing.T, modes map[string]string) {
+	t.Helper()
+
+	featuresMu.Lock()
+	saved := features
+	features = modes
+	featuresMu.Unlock()
+	t.Cleanup(func() {
+		featuresMu.Lock()
+		features = saved
+		featuresMu.Unlock()
+	})
+}
+
+// GET /version serves Info as is, so its JSON is the public contract: exactly these keys, with
+// string values and nothing taken from the configuration.
+func TestInfoJSONShape(t *testing.T) {
+	withFeatures(t, map[string]string{})
+	SetFeature("registration", "open")
+	SetFeature("cache", "memory")
+
+	data, err := json.Marshal(Get())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]interface{}{
+		"version":    "dev",
+		"commit":     "dev",
+		"build_date": "dev",
+		"go_version": runtime.Version(),
+		"features":   map[string]interface{}{"registration": "open", "cache": "memory"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %s, want %v", data, want)
+	}
+}
+
+func TestInfoJSONShapeWithoutFeatures(t *testing.T) {
+	withFeatures(t, map[string]string{})
+
+	data, err := json.Marshal(Get())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]json.RawMessage
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if string(got["features"]) != "{}" {
+		t.Errorf("features = %s, want {} rather than null", got["features"])
+	}
+}
+
+func TestGetReturnsSnapshot(t *testing.T) {
+	withFeatures(t, map[string]string{})
+	SetFeature("maintenance", "off")
+
+	info := Get()
+	info.Features["maintenance"] = "read_only"
+	SetFeature("registration", "closed")
+
+	if got := info.Features["registration"]; got != "" {
+		t.Errorf("snapshot picked up a later feature: registration=%q", got)
+	}
+	if got := Get().Features["maintenance"]; got != "off" {
+		t.Errorf("changing a snapshot changed the recorded mode: maintenance=%q", got)
+	}
+}
+
+func TestInfoString(t *testing.T) {
+	info := Info{
+		Version:   "v1.2.0",
+		Commit:    "abc1234",
+		BuildDate: "2026-01-01T00:00:00Z",
+		GoVersion: "go1.22.0",
+		Features:  map[string]string{"registration": "open", "cache": "redis"},
+	}
+
+	want := "version=v1.2.0 commit=abc1234 build_date=2026-01-01T00:00:00Z go=go1.22.0 features=[cache=redis registration=open]"
+	if got := info.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
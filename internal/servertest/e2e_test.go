@@ -0,0 +1,127 @@
+package servertest_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"go-share/internal/servertest"
+	"go-share/models"
+)
+
+// The life of a file, from the client's side: upload, list, download through a link, update,
+// delete. The tree stores file records, not contents, so there is no encrypted variant and the
+// download link serves the record.
+func TestFileLifecycle(t *testing.T) {
+	s := servertest.Start(t)
+
+	var me models.UserProfile
+	if resp := s.Call(t, "GET", "/users/me", nil, &me); resp.StatusCode != http.StatusOK || me.Email != "user@example.com" {
+		t.Fatalf("GET /users/me: got %d %+v", resp.StatusCode, me)
+	}
+
+	// Upload.
+	file := s.CreateFile(t, "report.txt", 1024)
+	if file.ID == 0 || file.Name != "report.txt" || file.Size != 1024 || file.Version != 1 {
+		t.Fatalf("uploaded %+v", file)
+	}
+	path := servertest.FilePath(file.ID)
+
+	// List.
+	var listed []models.File
+	if resp := s.Call(t, "GET", "/files", nil, &listed); resp.StatusCode != http.StatusOK || len(listed) != 1 || listed[0].ID != file.ID {
+		t.Fatalf("GET /files: got %d %+v", resp.StatusCode, listed)
+	}
+
+	// Download through a link anyone holding it can follow.
+	var link struct {
+		URL string `json:"url"`
+	}
+	if resp := s.Call(t, "POST", path+"/download-token", nil, &link); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("creating a download link: got %d", resp.StatusCode)
+	}
+	var downloaded models.File
+	if resp := s.Anonymous().Call(t, "GET", link.URL, nil, &downloaded); resp.StatusCode != http.StatusOK || downloaded.ID != file.ID {
+		t.Fatalf("following the download link: got %d %+v", resp.StatusCode, downloaded)
+	}
+
+	// Update, with the version the client last saw.
+	r := s.NewRequest(t, "PUT", path, map[string]string{"description": "Final"})
+	r.Header.Set("If-Match", `"1"`)
+	var updated models.File
+	resp := s.Do(t, r, &updated)
+	if resp.StatusCode != http.StatusOK || updated.Description != "Final" || updated.Version != 2 || resp.Header.Get("ETag") != `"2"` {
+		t.Fatalf("PUT %s: got %d %+v, ETag %s", path, resp.StatusCode, updated, resp.Header.Get("ETag"))
+	}
+	// A client still holding the first version is told it is out of date.
+	r = s.NewRequest(t, "PUT", path, map[string]string{"description": "Stale"})
+	r.Header.Set("If-Match", `"1"`)
+	if resp := s.Do(t, r, nil); resp.StatusCode != http.StatusConflict {
+		t.Errorf("PUT with a stale version: got %d, want 409", resp.StatusCode)
+	}
+
+	// Delete.
+	if resp := s.Call(t, "DELETE", path, nil, nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("DELETE %s: got %d", path, resp.StatusCode)
+	}
+	if resp := s.Call(t, "GET", path, nil, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET after delete: got %d, want 404", resp.StatusCode)
+	}
+	if resp := s.Anonymous().Call(t, "GET", link.URL, nil, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("download link after delete: got %d, want 404", resp.StatusCode)
+	}
+	listed = nil
+	if s.Call(t, "GET", "/files", nil, &listed); len(listed) != 0 {
+		t.Errorf("GET /files after delete: %+v", listed)
+	}
+}
+
+// Files are private until shared: another user sees nothing until granted access, and then
+// only gets the access granted.
+func TestSharingBetweenUsers(t *testing.T) {
+	s := servertest.Start(t)
+	other := s.Register(t, "other@example.com")
+
+	file := s.CreateFile(t, "plan.txt", 10)
+	path := servertest.FilePath(file.ID)
+	if resp := other.Call(t, "GET", path, nil, nil); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("another user's file: got %d, want 404", resp.StatusCode)
+	}
+
+	grant := map[string]interface{}{"email": other.Email, "permission": "read", "expires_at": time.Now().Add(time.Hour)}
+	if resp := s.Call(t, "POST", path+"/grants", grant, nil); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("granting access: got %d", resp.StatusCode)
+	}
+
+	var shared struct {
+		Files []models.SharedFile `json:"files"`
+	}
+	if resp := other.Call(t, "GET", "/files/shared-with-me", nil, &shared); resp.StatusCode != http.StatusOK || len(shared.Files) != 1 || shared.Files[0].File.ID != file.ID {
+		t.Fatalf("shared with me: got %d %+v", resp.StatusCode, shared)
+	}
+	if resp := other.Call(t, "GET", path, nil, nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("the shared file: got %d, want 200", resp.StatusCode)
+	}
+	// Read access doesn't extend to changing or deleting the file.
+	if resp := other.Call(t, "DELETE", path, nil, nil); resp.StatusCode == http.StatusOK {
+		t.Error("a reader deleted the file")
+	}
+}
+
+// Requests without credentials only reach the public routes.
+func TestAnonymousRequests(t *testing.T) {
+	s := servertest.Start(t)
+	anonymous := s.Anonymous()
+
+	for _, path := range []string{"/healthz", "/version"} {
+		if resp := anonymous.Call(t, "GET", path, nil, nil); resp.StatusCode != http.StatusOK {
+			t.Errorf("GET %s: got %d, want 200", path, resp.StatusCode)
+		}
+	}
+	file := s.CreateFile(t, "a.txt", 1)
+	for _, path := range []string{"/files", "/users/me", servertest.FilePath(file.ID)} {
+		if resp := anonymous.Call(t, "GET", path, nil, nil); resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("GET %s: got %d, want 401", path, resp.StatusCode)
+		}
+	}
+}
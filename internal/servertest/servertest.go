@@ -0,0 +1,165 @@
+// Package servertest boots the whole API on an httptest server, for end-to-end tests that talk
+// to it over HTTP the way clients do.
+//
+// The server is the router main serves, running against a testdb database, an in-memory cache
+// and the default settings. Those are process globals, so tests that call Start must not run
+// in parallel with each other or with other tests of the same package that set them.
+package servertest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/spf13/viper"
+	"go-share/cache"
+	"go-share/config"
+	"go-share/controllers"
+	"go-share/internal/testdb"
+	"go-share/models"
+	"go-share/utils"
+)
+
+// Password is the password of every user Register creates.
+const Password = "correct horse"
+
+// Server is a running API.
+type Server struct {
+	URL string
+	// Client is signed in as the user Start registered, user@example.com.
+	*Client
+}
+
+// Start boots the API and registers a user, and returns the server with a client signed in as
+// that user. Settings changed with viper.Set after the call apply to the server's requests.
+// Everything is torn down when the test ends.
+func Start(t testing.TB) *Server {
+	t.Helper()
+
+	viper.Reset()
+	config.SetDefaults()
+	config.DB = testdb.Open(t, models.All...)
+	c, err := cache.New(cache.Options{Driver: "memory", MaxEntries: 1000})
+	if err != nil {
+		t.Fatalf("creating cache: %s", err)
+	}
+	config.Cache = c
+
+	// The real server refuses to start without a public ID key.
+	oldKey := utils.PublicIDKey
+	utils.PublicIDKey = []byte("servertest public id key")
+	t.Cleanup(func() { utils.PublicIDKey = oldKey })
+
+	server := httptest.NewServer(controllers.MethodHandler(controllers.NewRouter()))
+	t.Cleanup(server.Close)
+
+	s := &Server{URL: server.URL}
+	s.Client = s.Register(t, "user@example.com")
+	return s
+}
+
+// Register registers a user through the API and returns a client signed in as them.
+func (s *Server) Register(t testing.TB, email string) *Client {
+	t.Helper()
+
+	var registered struct {
+		Token string `json:"token"`
+	}
+	resp := s.Anonymous().Call(t, "POST", "/register", map[string]string{"email": email, "password": Password}, &registered)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("registering %s: got %d", email, resp.StatusCode)
+	}
+	return &Client{URL: s.URL, Email: email, Token: registered.Token}
+}
+
+// Anonymous returns a client that sends no credentials.
+func (s *Server) Anonymous() *Client {
+	return &Client{URL: s.URL}
+}
+
+// Client sends requests to the server as one user.
+type Client struct {
+	URL   string
+	Email string
+	// Token authenticates the client's requests. It is empty for anonymous clients.
+	Token string
+}
+
+// NewRequest builds a request for path, which may include a query, with body encoded as JSON
+// if it is not nil. Absolute URLs, such as download links, are used as they are.
+func (c *Client) NewRequest(t testing.TB, method, path string, body interface{}) *http.Request {
+	t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("encoding request body: %s", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	target := path
+	if len(path) > 0 && path[0] == '/' {
+		target = c.URL + path
+	}
+	r, err := http.NewRequest(method, target, reader)
+	if err != nil {
+		t.Fatalf("building request: %s", err)
+	}
+	if body != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		r.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	return r
+}
+
+// Do sends r and decodes a successful JSON response into out, if it is not nil. The returned
+// response's body is already read and closed.
+func (c *Client) Do(t testing.TB, r *http.Request, out interface{}) *http.Response {
+	t.Helper()
+
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatalf("%s %s: %s", r.Method, r.URL, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("%s %s: reading response: %s", r.Method, r.URL, err)
+	}
+	if out != nil && resp.StatusCode < 300 {
+		if err := json.Unmarshal(data, out); err != nil {
+			t.Fatalf("%s %s: decoding %s: %s", r.Method, r.URL, data, err)
+		}
+	}
+	return resp
+}
+
+// Call sends a request built by NewRequest and decodes the response like Do.
+func (c *Client) Call(t testing.TB, method, path string, body, out interface{}) *http.Response {
+	t.Helper()
+	return c.Do(t, c.NewRequest(t, method, path, body), out)
+}
+
+// CreateFile uploads a plain text file and returns it as stored.
+func (c *Client) CreateFile(t testing.TB, name string, size int64) models.File {
+	t.Helper()
+
+	var file models.File
+	body := map[string]interface{}{"name": name, "path": "/" + name, "content_type": "text/plain", "size": size}
+	if resp := c.Call(t, "POST", "/files", body, &file); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("uploading %s: got %d", name, resp.StatusCode)
+	}
+	return file
+}
+
+// FilePath returns the API path of the file with the given ID.
+func FilePath(id uint) string {
+	return fmt.Sprintf("/files/%s", utils.EncodePublicID(id))
+}
@@ -0,0 +1,37 @@
+package testdb
+
+import (
+	"os"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// PostgresDSNEnv names the environment variable that points Postgres-only tests at a server.
+const PostgresDSNEnv = "TEST_POSTGRES_DSN"
+
+// OpenPostgres connects to the Postgres server named by TEST_POSTGRES_DSN, skipping the test
+// when it is unset. Each call opens a separate pool, so a test can act as several replicas
+// sharing one database. Nothing is migrated: the tests that need it, such as those of
+// advisory locks, don't use tables.
+func OpenPostgres(t testing.TB) *gorm.DB {
+	t.Helper()
+
+	dsn := os.Getenv(PostgresDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", PostgresDSNEnv)
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("connecting to Postgres: %s", err)
+	}
+
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
@@ -0,0 +1,93 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/spf13/viper"
+	"go-share/models"
+	"gorm.io/gorm"
+)
+
+// cleanupTask is a housekeeping function run periodically by StartCleanup. Tasks that rewrite
+// file rows return the IDs of the files they changed.
+type cleanupTask struct {
+	name string
+	run  func(db *gorm.DB) (changedFiles []uint, err error)
+}
+
+// changesNoFiles adapts a task that leaves file rows alone.
+func changesNoFiles(run func(db *gorm.DB) error) func(db *gorm.DB) ([]uint, error) {
+	return func(db *gorm.DB) ([]uint, error) {
+		return nil, run(db)
+	}
+}
+
+// cleanupTasks lists the housekeeping run on every cleanup tick.
+var cleanupTasks = []cleanupTask{
+	{name: "prune idempotency keys", run: changesNoFiles(models.PruneExpiredIdempotencyKeys)},
+	{name: "prune used upload grants", run: changesNoFiles(models.PruneUsedUploadGrants)},
+	{name: "prune read notifications", run: changesNoFiles(func(db *gorm.DB) error {
+		return models.PruneReadNotifications(db, viper.GetDuration("notifications.retention"))
+	})},
+	{name: "lift expired suspensions", run: changesNoFiles(models.LiftExpiredSuspensions)},
+	{name: "prune expired grants", run: changesNoFiles(models.PruneExpiredGrants)},
+	{name: "categorize files", run: models.CategorizeFiles},
+	{name: "normalize content types", run: func(db *gorm.DB) ([]uint, error) {
+		if !viper.GetBool("content_types.normalize_on_cleanup") {
+			return nil, nil
+		}
+		report, err := models.NormalizeContentTypes(db, viper.GetInt("content_types.normalize_batch_size"))
+		if err != nil {
+			return nil, err
+		}
+		changed := make([]uint, len(report.Changed))
+		for i, change := range report.Changed {
+			changed[i] = uint(change.FileID)
+		}
+		if len(changed) > 0 {
+			log.Printf("Normalized the content type of %d files", len(changed))
+		}
+		return changed, nil
+	}},
+	{name: "prune finished jobs", run: changesNoFiles(func(db *gorm.DB) error {
+		return models.PruneFinishedJobs(db, viper.GetDuration("jobs.retention"))
+	})},
+	{name: "roll up API usage", run: changesNoFiles(func(db *gorm.DB) error {
+		return models.RollupAPIUsage(db, viper.GetDuration("api_usage.retention"))
+	})},
+}
+
+// StartCleanup runs the cleanup tasks in the background every interval until ctx is done.
+// invalidate is given the IDs of the files a task changed, so that cached copies can be
+// dropped. Only one replica should run it; see RunAsLeader.
+func StartCleanup(ctx context.Context, db *gorm.DB, interval time.Duration, invalidate func(fileIDs []uint)) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				RunCleanup(db, invalidate)
+			}
+		}
+	}()
+}
+
+// RunCleanup runs every cleanup task once, logging failures without stopping the others.
+// Files a task changed are passed to invalidate even if it failed part way.
+func RunCleanup(db *gorm.DB, invalidate func(fileIDs []uint)) {
+	for _, task := range cleanupTasks {
+		changed, err := task.run(db)
+		if len(changed) > 0 {
+			invalidate(changed)
+		}
+		if err != nil {
+			log.Printf("Cleanup task %q failed: %s", task.name, err)
+		}
+	}
+}
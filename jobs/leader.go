@@ -0,0 +1,102 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Postgres advisory lock keys. They only need to be unique within the database.
+const (
+	migrationLockKey int64 = 0x676f7368617265 // "goshare"
+	leaderLockKey    int64 = migrationLockKey + 1
+)
+
+// WithMigrationLock runs migrate while holding a Postgres advisory lock, so that replicas
+// starting together apply migrations one at a time. Other databases run migrate directly.
+func WithMigrationLock(db *gorm.DB, migrate func() error) error {
+	if db.Dialector.Name() != "postgres" {
+		return migrate()
+	}
+
+	conn, err := dedicatedConn(db)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx := context.Background()
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockKey); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", migrationLockKey)
+
+	return migrate()
+}
+
+// RunAsLeader elects one leader among the replicas sharing the database and calls run on
+// that replica only. The leader holds a Postgres advisory lock on a dedicated connection;
+// if the connection is lost, the context passed to run is cancelled and the replicas
+// compete again. Other databases have a single instance, which always leads.
+func RunAsLeader(db *gorm.DB, retryInterval time.Duration, run func(ctx context.Context)) {
+	if db.Dialector.Name() != "postgres" {
+		run(context.Background())
+		return
+	}
+
+	go func() {
+		for {
+			if err := lead(db, retryInterval, run); err != nil {
+				log.Printf("Leader election: %s", err)
+			}
+			time.Sleep(retryInterval)
+		}
+	}()
+}
+
+// lead tries once to become leader. When it succeeds it calls run and blocks until
+// leadership is lost.
+func lead(db *gorm.DB, checkInterval time.Duration, run func(ctx context.Context)) error {
+	conn, err := dedicatedConn(db)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var acquired bool
+	if err := conn.QueryRowContext(context.Background(), "SELECT pg_try_advisory_lock($1)", leaderLockKey).Scan(&acquired); err != nil {
+		return err
+	}
+	if !acquired {
+		return nil
+	}
+
+	log.Printf("This instance is now the background job leader")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	run(ctx)
+
+	// The lock lives as long as the session, so leadership ends when the connection does.
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := conn.PingContext(context.Background()); err != nil {
+			log.Printf("Lost background job leadership")
+			return err
+		}
+	}
+	return nil
+}
+
+// dedicatedConn takes a connection out of the pool. Session-level advisory locks are tied
+// to it, so it must be used for both locking and unlocking.
+func dedicatedConn(db *gorm.DB) (*sql.Conn, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	return sqlDB.Conn(context.Background())
+}
@@ -0,0 +1,112 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-share/internal/testdb"
+	"gorm.io/gorm"
+)
+
+// waitFor polls cond until it holds or the timeout passes.
+func waitFor(t *testing.T, timeout time.Duration, cond func() bool) bool {
+	t.Helper()
+	for deadline := time.Now().Add(timeout); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if cond() {
+			return true
+		}
+	}
+	return cond()
+}
+
+// Two replicas sharing one Postgres database elect a single leader, and only it runs the jobs.
+func TestRunAsLeaderElectsOneReplica(t *testing.T) {
+	replicas := []*gorm.DB{testdb.OpenPostgres(t), testdb.OpenPostgres(t)}
+
+	var runs atomic.Int32
+	leaders := make(chan context.Context, len(replicas))
+	for _, db := range replicas {
+		RunAsLeader(db, 50*time.Millisecond, func(ctx context.Context) {
+			runs.Add(1)
+			leaders <- ctx
+		})
+	}
+
+	if !waitFor(t, 5*time.Second, func() bool { return runs.Load() > 0 }) {
+		t.Fatal("no replica became leader")
+	}
+	// Give the other replica several chances to take the lock as well.
+	time.Sleep(500 * time.Millisecond)
+	if got := runs.Load(); got != 1 {
+		t.Fatalf("jobs started %d times, want once", got)
+	}
+
+	// When the leader's session ends, the jobs stop there and start on the other replica.
+	leader := <-leaders
+	if err := replicas[0].Exec("SELECT pg_terminate_backend(pid) FROM pg_locks WHERE locktype = 'advisory' AND pid <> pg_backend_pid()").Error; err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-leader.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("the old leader kept running its jobs after losing the lock")
+	}
+	if !waitFor(t, 5*time.Second, func() bool { return runs.Load() == 2 }) {
+		t.Fatalf("jobs started %d times after the leader was lost, want 2", runs.Load())
+	}
+}
+
+// Replicas starting together apply migrations one at a time.
+func TestWithMigrationLockSerializes(t *testing.T) {
+	replicas := []*gorm.DB{testdb.OpenPostgres(t), testdb.OpenPostgres(t), testdb.OpenPostgres(t)}
+
+	var running, overlaps atomic.Int32
+	var wg sync.WaitGroup
+	for _, db := range replicas {
+		wg.Add(1)
+		go func(db *gorm.DB) {
+			defer wg.Done()
+			err := WithMigrationLock(db, func() error {
+				if running.Add(1) > 1 {
+					overlaps.Add(1)
+				}
+				time.Sleep(50 * time.Millisecond)
+				running.Add(-1)
+				return nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}(db)
+	}
+	wg.Wait()
+
+	if got := overlaps.Load(); got != 0 {
+		t.Errorf("migrations overlapped %d times", got)
+	}
+}
+
+// A database other than Postgres has a single instance, which runs everything itself.
+func TestRunAsLeaderWithoutPostgres(t *testing.T) {
+	db := testdb.Open(t)
+
+	var runs int
+	RunAsLeader(db, time.Hour, func(ctx context.Context) {
+		runs++
+		if ctx.Err() != nil {
+			t.Error("the jobs were started with a cancelled context")
+		}
+	})
+	if runs != 1 {
+		t.Errorf("jobs started %d times, want once before RunAsLeader returns", runs)
+	}
+
+	failed := errors.New("migration failed")
+	if err := WithMigrationLock(db, func() error { return failed }); err != failed {
+		t.Errorf("WithMigrationLock returned %v, want the migration's error", err)
+	}
+}
@@ -0,0 +1,41 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/spf13/viper"
+	"go-share/models"
+	"gorm.io/gorm"
+)
+
+// TypeNotify is the job type that delivers a notification to a user's feed.
+const TypeNotify = "notification.deliver"
+
+// notifyPayload is the payload of a TypeNotify job.
+type notifyPayload struct {
+	UserID  uint            `json:"user_id"`
+	Type    string          `json:"type"`
+	Payload models.Metadata `json:"payload"`
+}
+
+func init() {
+	Register(TypeNotify, deliverNotification)
+}
+
+// EnqueueNotification queues a notification for userID, so that a failure to deliver it is
+// retried instead of failing or slowing down the request that caused it.
+func EnqueueNotification(db *gorm.DB, userID uint, notificationType string, payload models.Metadata) error {
+	_, err := models.EnqueueJob(db, TypeNotify, notifyPayload{UserID: userID, Type: notificationType, Payload: payload},
+		viper.GetInt("jobs.max_attempts"))
+	return err
+}
+
+// deliverNotification runs a TypeNotify job.
+func deliverNotification(ctx context.Context, db *gorm.DB, data json.RawMessage) error {
+	var payload notifyPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return err
+	}
+	return models.Notify(db.WithContext(ctx), payload.UserID, payload.Type, payload.Payload)
+}
@@ -0,0 +1,130 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"go-share/models"
+	"gorm.io/gorm"
+)
+
+// Handler runs one job. Returning an error schedules a retry with exponential backoff.
+type Handler func(ctx context.Context, db *gorm.DB, payload json.RawMessage) error
+
+var (
+	handlersMu sync.RWMutex
+	handlers   = map[string]Handler{}
+)
+
+// Register makes the workers run jobs of the given type with handler. It must be called
+// before StartWorkers.
+func Register(jobType string, handler Handler) {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	handlers[jobType] = handler
+}
+
+// WorkerOptions configures the worker pool.
+type WorkerOptions struct {
+	// Workers is the number of jobs run concurrently by this instance.
+	Workers int
+	// PollInterval is how long an idle worker waits before looking for work again.
+	PollInterval time.Duration
+	// Lease is how long a job may run before another worker may assume it crashed and
+	// claim it again.
+	Lease time.Duration
+	// BaseBackoff and MaxBackoff bound the delay before a failed job is retried. The delay
+	// doubles with every attempt.
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+}
+
+// StartWorkers runs the job workers in the background until ctx is done. Every replica runs
+// its own workers; claiming is safe under concurrency, so no leader is needed.
+func StartWorkers(ctx context.Context, db *gorm.DB, opts WorkerOptions) {
+	handlersMu.RLock()
+	types := make([]string, 0, len(handlers))
+	for jobType := range handlers {
+		types = append(types, jobType)
+	}
+	handlersMu.RUnlock()
+	if len(types) == 0 {
+		return
+	}
+
+	for i := 0; i < opts.Workers; i++ {
+		go work(ctx, db, types, opts)
+	}
+}
+
+// work claims and runs jobs until ctx is done, sleeping while the queue is empty.
+func work(ctx context.Context, db *gorm.DB, types []string, opts WorkerOptions) {
+	for {
+		job, err := models.ClaimJob(db, types, opts.Lease)
+		if err != nil {
+			log.Printf("Job queue: %s", err)
+		}
+		if job == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(opts.PollInterval):
+			}
+			continue
+		}
+
+		run(ctx, db, job, opts)
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// run executes one claimed job and records the outcome.
+func run(ctx context.Context, db *gorm.DB, job *models.Job, opts WorkerOptions) {
+	handlersMu.RLock()
+	handler := handlers[job.Type]
+	handlersMu.RUnlock()
+
+	jobCtx, cancel := context.WithTimeout(ctx, opts.Lease)
+	defer cancel()
+
+	err := safeRun(jobCtx, db, handler, job.Payload)
+	if err == nil {
+		if err := job.Succeed(db); err != nil {
+			log.Printf("Job %d (%s): %s", job.ID, job.Type, err)
+		}
+		return
+	}
+
+	log.Printf("Job %d (%s) attempt %d/%d failed: %s", job.ID, job.Type, job.Attempts, job.MaxAttempts, err)
+	if err := job.Fail(db, err, backoff(job.Attempts, opts)); err != nil {
+		log.Printf("Job %d (%s): %s", job.ID, job.Type, err)
+	}
+}
+
+// safeRun calls handler, turning a panic into an error so one bad job can't stop a worker.
+func safeRun(ctx context.Context, db *gorm.DB, handler Handler, payload json.RawMessage) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("panic: %v", recovered)
+		}
+	}()
+	return handler(ctx, db, payload)
+}
+
+// backoff returns the delay before retrying a job that has failed attempts times.
+func backoff(attempts int, opts WorkerOptions) time.Duration {
+	delay := opts.BaseBackoff
+	for i := 1; i < attempts && delay < opts.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > opts.MaxBackoff {
+		delay = opts.MaxBackoff
+	}
+	return delay
+}
@@ -0,0 +1,124 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"go-share/internal/testdb"
+	"go-share/models"
+	"gorm.io/gorm"
+)
+
+func TestBackoff(t *testing.T) {
+	opts := WorkerOptions{BaseBackoff: 10 * time.Second, MaxBackoff: time.Minute}
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{3, 40 * time.Second},
+		{4, time.Minute},
+		{50, time.Minute},
+	}
+	for _, tt := range tests {
+		if got := backoff(tt.attempts, opts); got != tt.want {
+			t.Errorf("backoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+// withHandler registers handler for jobType for the rest of the test.
+func withHandler(t *testing.T, jobType string, handler Handler) {
+	t.Helper()
+	Register(jobType, handler)
+	t.Cleanup(func() {
+		handlersMu.Lock()
+		delete(handlers, jobType)
+		handlersMu.Unlock()
+	})
+}
+
+// A handler's error or panic schedules a retry after the backoff; success completes the job.
+func TestRunRecordsOutcome(t *testing.T) {
+	db := testdb.Open(t, models.All...)
+	opts := WorkerOptions{Lease: time.Minute, BaseBackoff: time.Hour, MaxBackoff: time.Hour}
+	withHandler(t, "test.ok", func(ctx context.Context, db *gorm.DB, payload json.RawMessage) error { return nil })
+	withHandler(t, "test.error", func(ctx context.Context, db *gorm.DB, payload json.RawMessage) error {
+		return errors.New("upstream down")
+	})
+	withHandler(t, "test.panic", func(ctx context.Context, db *gorm.DB, payload json.RawMessage) error { panic("nil map") })
+
+	tests := []struct {
+		jobType   string
+		state     string
+		lastError string
+	}{
+		{"test.ok", models.JobSucceeded, ""},
+		{"test.error", models.JobPending, "upstream down"},
+		{"test.panic", models.JobPending, "panic: nil map"},
+	}
+	for _, tt := range tests {
+		if _, err := models.EnqueueJob(db, tt.jobType, nil, 3); err != nil {
+			t.Fatal(err)
+		}
+		job, err := models.ClaimJob(db, []string{tt.jobType}, opts.Lease)
+		if err != nil || job == nil {
+			t.Fatalf("%s: claimed %v, %v", tt.jobType, job, err)
+		}
+		run(context.Background(), db, job, opts)
+
+		var stored models.Job
+		db.First(&stored, job.ID)
+		if stored.State != tt.state || stored.LastError != tt.lastError {
+			t.Errorf("%s: stored %s %q, want %s %q", tt.jobType, stored.State, stored.LastError, tt.state, tt.lastError)
+		}
+		if tt.state == models.JobPending && stored.RunAt.Before(time.Now().Add(59*time.Minute)) {
+			t.Errorf("%s: retry at %s, want after the hour of backoff", tt.jobType, stored.RunAt)
+		}
+	}
+}
+
+// The workers drain the queue in the background and stop when their context ends.
+func TestStartWorkers(t *testing.T) {
+	db := testdb.Open(t, models.All...)
+	done := make(chan int, 10)
+	withHandler(t, "test.count", func(ctx context.Context, db *gorm.DB, payload json.RawMessage) error {
+		var n int
+		json.Unmarshal(payload, &n)
+		done <- n
+		return nil
+	})
+	for i := 0; i < 10; i++ {
+		if _, err := models.EnqueueJob(db, "test.count", i, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	StartWorkers(ctx, db, WorkerOptions{Workers: 3, PollInterval: 10 * time.Millisecond, Lease: time.Minute, BaseBackoff: time.Second, MaxBackoff: time.Second})
+
+	seen := map[int]bool{}
+	for len(seen) < 10 {
+		select {
+		case n := <-done:
+			if seen[n] {
+				t.Errorf("job %d ran twice", n)
+			}
+			seen[n] = true
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%d of 10 jobs ran", len(seen))
+		}
+	}
+	if !waitFor(t, 5*time.Second, func() bool {
+		var succeeded int64
+		db.Model(&models.Job{}).Where("state = ?", models.JobSucceeded).Count(&succeeded)
+		return succeeded == 10
+	}) {
+		t.Error("not every job was marked succeeded")
+	}
+}
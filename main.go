@@ -1,32 +1,201 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
 	"github.com/gorilla/mux"
+	"github.com/spf13/viper"
 	"go-share/config"
 	"go-share/controllers"
+	"go-share/internal/buildinfo"
+	"go-share/jobs"
 	"go-share/models"
+	"go-share/selfcheck"
+	"go-share/utils"
 )
 
+func init() {
+	selfcheck.Register(selfcheck.Func("migrations", checkMigrations))
+}
+
 func main() {
 	config.LoadConfig()      // Load configuration
+	utils.PublicIDKey = []byte(viper.GetString("api.public_id_key"))
+	if len(os.Args) > 1 && os.Args[1] == "check" {
+		os.Exit(check())
+	}
+	if err := utils.ValidatePublicIDKey(utils.PublicIDKey); err != nil {
+		log.Fatalf("Error in configuration: %s", err)
+	}
+
 	config.ConnectDB()       // Connect to database
 	defer config.CloseDB()   // Close database connection
+	config.ConnectReplica()
+	config.ConnectCache()
+	models.LegacyJSONFields = viper.GetBool("api.legacy_field_names")
 
-	router := mux.NewRouter()
+	buildinfo.SetFeature("cache", viper.GetString("cache.driver"))
+	log.Printf("Starting go-share: %s", buildinfo.Get())
 
-	// Register routes
-	controllers.RegisterAuthRoutes(router)
-	controllers.RegisterFileRoutes(router)
+	router := newRouter()
 
-	// AutoMigrate database (this should be done only once, usually during initial setup)
-	if err := config.DB.AutoMigrate(&models.User{}, &models.File{}); err != nil {
+	var plans []models.Plan
+	if err := viper.UnmarshalKey("plans.seed", &plans); err != nil {
+		log.Fatalf("Error reading plans.seed: %s", err)
+	}
+
+	// AutoMigrate database (this should be done only once, usually during initial setup).
+	// Replicas starting together take turns through the migration lock.
+	err := jobs.WithMigrationLock(config.DB, func() error {
+		if err := config.DB.AutoMigrate(models.All...); err != nil {
+			return err
+		}
+		backfilled, err := models.BackfillOriginalNames(config.DB)
+		controllers.InvalidateCachedFiles(backfilled)
+		if err != nil {
+			return err
+		}
+		return models.SeedPlans(config.DB, plans)
+	})
+	if err != nil {
 		log.Fatalf("Error migrating database: %s", err)
 	}
 
-	fmt.Println("Server is running on port 8080")
-	log.Fatal(http.ListenAndServe(":8080", router))
+	// Housekeeping runs on a single elected replica. Usage counters live in each replica's
+	// memory, so every replica flushes its own.
+	jobs.RunAsLeader(config.DB, viper.GetDuration("leader.retry_interval"), func(ctx context.Context) {
+		jobs.StartCleanup(ctx, config.DB, viper.GetDuration("cleanup.interval"), controllers.InvalidateCachedFiles)
+	})
+	controllers.StartAPIUsageFlush(config.DB, viper.GetDuration("api_usage.flush_interval"))
+	// Queued jobs are claimed with row locks, so every replica runs workers.
+	jobs.StartWorkers(context.Background(), config.DB, jobs.WorkerOptions{
+		Workers:      viper.GetInt("jobs.workers"),
+		PollInterval: viper.GetDuration("jobs.poll_interval"),
+		Lease:        viper.GetDuration("jobs.lease"),
+		BaseBackoff:  viper.GetDuration("jobs.base_backoff"),
+		MaxBackoff:   viper.GetDuration("jobs.max_backoff"),
+	})
+
+	servers := newServers(router, viper.GetString("server.public_address"), viper.GetString("server.private_address"))
+	serve(servers)
+}
+
+// check runs the deployment self-checks without serving traffic and prints the report as
+// JSON. It returns the process exit code: 1 if any check failed.
+func check() int {
+	if err := config.TryConnectDB(); err != nil {
+		log.Printf("Error connecting to database: %s", err)
+	}
+	config.ConnectReplica()
+	config.ConnectCache()
+
+	report := selfcheck.Run(context.Background(), viper.GetDuration("selfcheck.timeout"))
+	out, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		log.Printf("Error encoding self-check report: %s", err)
+		return 1
+	}
+	fmt.Println(string(out))
+	if report.Status == selfcheck.Fail {
+		return 1
+	}
+	return 0
+}
+
+// checkMigrations fails while the database lacks tables or columns the models define.
+func checkMigrations(ctx context.Context) (selfcheck.Status, string) {
+	if config.DB == nil {
+		return selfcheck.Fail, "no database connection"
+	}
+	pending, err := models.PendingMigrations(config.DB.WithContext(ctx))
+	if err != nil {
+		return selfcheck.Fail, err.Error()
+	}
+	if len(pending) > 0 {
+		return selfcheck.Fail, fmt.Sprintf("pending: %s", strings.Join(pending, ", "))
+	}
+	return selfcheck.Pass, ""
+}
+
+// newRouter returns the router for the full API, stopping startup if two routes collide.
+func newRouter() *mux.Router {
+	router := controllers.NewRouter()
+	checkRoutes(router)
+	return router
+}
+
+// newServers returns the servers for the configured listeners. The routes are split only when
+// both addresses are set: the public listener gets the routes that need no other credentials
+// and the private one the full API. With a single address, the full API is served there, so
+// setting only one never hides routes; with neither, it is served on :8080. The pprof listener,
+// if debug.pprof selects one, is added to them.
+func newServers(router *mux.Router, publicAddress, privateAddress string) []*http.Server {
+	var servers []*http.Server
+	if publicAddress == "" || privateAddress == "" {
+		address := publicAddress + privateAddress
+		if address == "" {
+			address = ":8080"
+		}
+		servers = []*http.Server{{Addr: address, Handler: controllers.MethodHandler(router)}}
+	} else {
+		publicRouter := mux.NewRouter()
+		controllers.UseMiddleware(publicRouter, controllers.MaintenanceMiddleware)
+		controllers.RegisterPublicRoutes(publicRouter)
+		checkRoutes(publicRouter)
+		servers = []*http.Server{
+			{Addr: publicAddress, Handler: controllers.MethodHandler(publicRouter)},
+			{Addr: privateAddress, Handler: controllers.MethodHandler(router)},
+		}
+	}
+
+	if debugServer := controllers.NewDebugServer(); debugServer != nil {
+		servers = append(servers, debugServer)
+	}
+	return servers
+}
+
+// checkRoutes stops startup if two routes on router would match the same requests.
+func checkRoutes(router *mux.Router) {
+	if _, err := controllers.ListRoutes(router); err != nil {
+		log.Fatalf("Error registering routes: %s", err)
+	}
+}
+
+// serve runs every server until one of them fails or the process is asked to stop,
+// then shuts all of them down together.
+func serve(servers []*http.Server) {
+	errs := make(chan error, len(servers))
+	for _, server := range servers {
+		server := server
+		go func() {
+			fmt.Printf("Server is listening on %s\n", server.Addr)
+			errs <- server.ListenAndServe()
+		}()
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+
+	select {
+	case err := <-errs:
+		log.Printf("Server stopped: %s", err)
+	case sig := <-stop:
+		log.Printf("Received %s, shutting down", sig)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("server.shutdown_timeout"))
+	defer cancel()
+	for _, server := range servers {
+		if err := server.Shutdown(ctx); err != nil {
+			log.Printf("Error shutting down %s: %s", server.Addr, err)
+		}
+	}
 } 
\ No newline at end of file
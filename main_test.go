@@ -0,0 +1,127 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/spf13/viper"
+	"go-share/cache"
+	"go-share/config"
+	"go-share/internal/testdb"
+	"go-share/models"
+)
+
+// setupMain gives the test the default settings and an empty database.
+func setupMain(t *testing.T) {
+	t.Helper()
+	viper.Reset()
+	config.SetDefaults()
+	config.DB = testdb.Open(t, models.All...)
+	config.Cache, _ = cache.New(cache.Options{})
+}
+
+// start serves server on a random local port and returns its base URL.
+func start(t *testing.T, server *http.Server) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+	return "http://" + listener.Addr().String()
+}
+
+// statusOf returns the status of GET baseURL+path.
+func statusOf(t *testing.T, baseURL, path string) int {
+	t.Helper()
+	resp, err := http.Get(baseURL + path)
+	if err != nil {
+		t.Fatalf("GET %s: %s", path, err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestNewServersSplitListeners(t *testing.T) {
+	setupMain(t)
+
+	servers := newServers(newRouter(), "public:1", "private:2")
+	if len(servers) != 2 || servers[0].Addr != "public:1" || servers[1].Addr != "private:2" {
+		t.Fatalf("got servers %+v, want the public and the private listener", servers)
+	}
+	public, private := start(t, servers[0]), start(t, servers[1])
+
+	tests := []struct {
+		path    string
+		public  int
+		private int
+	}{
+		{"/healthz", http.StatusOK, http.StatusOK},
+		{"/version", http.StatusNotFound, http.StatusOK},
+		{"/files", http.StatusNotFound, http.StatusUnauthorized},
+		{"/files/1?token=invalid", http.StatusUnauthorized, http.StatusUnauthorized},
+		{"/users/me", http.StatusNotFound, http.StatusUnauthorized},
+		{"/admin/stats", http.StatusNotFound, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		if got := statusOf(t, public, tt.path); got != tt.public {
+			t.Errorf("public GET %s = %d, want %d", tt.path, got, tt.public)
+		}
+		if got := statusOf(t, private, tt.path); got != tt.private {
+			t.Errorf("private GET %s = %d, want %d", tt.path, got, tt.private)
+		}
+	}
+}
+
+// A single configured address serves the full API, whichever of the two it is.
+func TestNewServersSingleListener(t *testing.T) {
+	tests := []struct {
+		name            string
+		public, private string
+		want            string
+	}{
+		{"neither", "", "", ":8080"},
+		{"public only", "public:1", "", "public:1"},
+		{"private only", "", "private:2", "private:2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupMain(t)
+
+			servers := newServers(newRouter(), tt.public, tt.private)
+			if len(servers) != 1 || servers[0].Addr != tt.want {
+				t.Fatalf("got servers %+v, want one on %s", servers, tt.want)
+			}
+			url := start(t, servers[0])
+			for path, want := range map[string]int{"/healthz": http.StatusOK, "/files": http.StatusUnauthorized, "/admin/stats": http.StatusUnauthorized} {
+				if got := statusOf(t, url, path); got != want {
+					t.Errorf("GET %s = %d, want %d", path, got, want)
+				}
+			}
+		})
+	}
+}
+
+// With debug.pprof set to localhost, the pprof listener is one of the servers, so it starts and
+// shuts down with the API, and the API itself doesn't expose the profiles.
+func TestNewServersDebugListener(t *testing.T) {
+	setupMain(t)
+	viper.Set("debug.pprof", "localhost")
+	viper.Set("debug.pprof_address", "127.0.0.1:6061")
+
+	servers := newServers(newRouter(), "", "")
+	if len(servers) != 2 || servers[1].Addr != "127.0.0.1:6061" {
+		t.Fatalf("got servers %+v, want the API and the pprof listener", servers)
+	}
+	api, debug := start(t, servers[0]), start(t, servers[1])
+
+	if got := statusOf(t, debug, "/debug/pprof/goroutine?debug=1"); got != http.StatusOK {
+		t.Errorf("pprof listener GET /debug/pprof/goroutine = %d, want 200", got)
+	}
+	if got := statusOf(t, api, "/debug/pprof/goroutine?debug=1"); got != http.StatusNotFound {
+		t.Errorf("API GET /debug/pprof/goroutine = %d, want 404", got)
+	}
+}
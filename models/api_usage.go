@@ -0,0 +1,81 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// APIUsage counts one user's requests to one route group on one UTC day.
+type APIUsage struct {
+	UserID     uint      `json:"-" gorm:"primaryKey"`
+	Day        time.Time `json:"day" gorm:"primaryKey;type:date"`
+	RouteGroup string    `json:"route_group" gorm:"primaryKey;size:32"`
+	Requests   int64     `json:"requests" gorm:"not null;default:0"`
+	Errors     int64     `json:"errors" gorm:"not null;default:0"`
+}
+
+// APIUsageMonth holds daily usage rows that were rolled up once they passed the retention period.
+type APIUsageMonth struct {
+	UserID     uint      `json:"-" gorm:"primaryKey"`
+	Month      time.Time `json:"month" gorm:"primaryKey;type:date"`
+	RouteGroup string    `json:"route_group" gorm:"primaryKey;size:32"`
+	Requests   int64     `json:"requests" gorm:"not null;default:0"`
+	Errors     int64     `json:"errors" gorm:"not null;default:0"`
+}
+
+// AddAPIUsage adds the given counts to the stored daily totals.
+func AddAPIUsage(db *gorm.DB, usage []APIUsage) error {
+	if len(usage) == 0 {
+		return nil
+	}
+
+	err := db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "day"}, {Name: "route_group"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"requests": gorm.Expr("api_usages.requests + EXCLUDED.requests"),
+			"errors":   gorm.Expr("api_usages.errors + EXCLUDED.errors"),
+		}),
+	}).Create(&usage).Error
+	if err != nil {
+		return errors.New("error recording API usage")
+	}
+	return nil
+}
+
+// ListAPIUsage returns userID's daily usage between from and to (inclusive), oldest first.
+func ListAPIUsage(db *gorm.DB, userID uint, from, to time.Time) ([]APIUsage, error) {
+	var usage []APIUsage
+	err := db.Where("user_id = ? AND day >= ? AND day <= ?", userID, from, to).
+		Order("day, route_group").
+		Find(&usage).Error
+	if err != nil {
+		return nil, errors.New("error loading API usage")
+	}
+	return usage, nil
+}
+
+// RollupAPIUsage folds daily rows older than retention into monthly totals and deletes them.
+func RollupAPIUsage(db *gorm.DB, retention time.Duration) error {
+	cutoff := time.Now().UTC().Add(-retention)
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`INSERT INTO api_usage_months (user_id, month, route_group, requests, errors)
+			SELECT user_id, date_trunc('month', day)::date, route_group, SUM(requests), SUM(errors)
+			FROM api_usages WHERE day < ?
+			GROUP BY user_id, date_trunc('month', day), route_group
+			ON CONFLICT (user_id, month, route_group) DO UPDATE SET
+				requests = api_usage_months.requests + EXCLUDED.requests,
+				errors = api_usage_months.errors + EXCLUDED.errors`, cutoff).Error
+		if err != nil {
+			return errors.New("error rolling up API usage")
+		}
+
+		if err := tx.Where("day < ?", cutoff).Delete(&APIUsage{}).Error; err != nil {
+			return errors.New("error pruning API usage")
+		}
+		return nil
+	})
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"go-share/utils"
+	"gorm.io/gorm"
+)
+
+// AuditLog is an append-only record of a security-relevant action.
+type AuditLog struct {
+	ID        uint            `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time       `json:"created_at" gorm:"index"`
+	ActorID   uint            `json:"actor_id" gorm:"index"`
+	Action    string          `json:"action" gorm:"index;not null"`
+	FileID    *utils.PublicID `json:"file_id,omitempty" gorm:"index"`
+	// TargetUserID is the user acted upon, e.g. the impersonated account.
+	TargetUserID *uint `json:"target_user_id,omitempty" gorm:"index"`
+	// ImpersonatorID is the admin who acted as ActorID through an impersonation token.
+	ImpersonatorID *uint  `json:"impersonator_id,omitempty" gorm:"index"`
+	Reason         string `json:"reason,omitempty"`
+	IP             string `json:"ip,omitempty"`
+}
+
+// RecordAudit appends an entry to the audit log.
+func RecordAudit(db *gorm.DB, entry *AuditLog) error {
+	if err := db.Create(entry).Error; err != nil {
+		return errors.New("error writing audit log")
+	}
+	return nil
+}
+
+// ListAuditLogs returns a page of audit entries, newest first, optionally filtered by action.
+func ListAuditLogs(db *gorm.DB, action string, offset, limit int) ([]AuditLog, int64, error) {
+	query := db.Model(&AuditLog{})
+	if action != "" {
+		query = query.Where("action = ?", action)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, errors.New("error counting audit logs")
+	}
+
+	entries := []AuditLog{}
+	if err := query.Order("created_at DESC, id DESC").Offset(offset).Limit(limit).Find(&entries).Error; err != nil {
+		return nil, 0, errors.New("error retrieving audit logs")
+	}
+	return entries, total, nil
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Base replaces gorm.Model so that responses use the same snake_case keys as every other field.
+type Base struct {
+	ID        uint           `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+}
+
+// LegacyJSONFields also emits the old gorm.Model keys (ID, CreatedAt, UpdatedAt, DeletedAt)
+// for clients that have not moved to the snake_case ones yet. It is set from
+// api.legacy_field_names and will be removed in the next release.
+var LegacyJSONFields bool
+
+// withLegacyFields adds the gorm.Model keys of b to the encoded object when LegacyJSONFields is set.
+func withLegacyFields(data []byte, b Base) ([]byte, error) {
+	if !LegacyJSONFields {
+		return data, nil
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	// ID repeats "id" rather than b.ID: files encode their public ID there, and the internal
+	// one must not leak through the legacy key.
+	fields["ID"] = fields["id"]
+	fields["CreatedAt"] = b.CreatedAt
+	fields["UpdatedAt"] = b.UpdatedAt
+	fields["DeletedAt"] = b.DeletedAt
+	return json.Marshal(fields)
+}
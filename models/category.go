@@ -0,0 +1,156 @@
+package models
+
+import (
+	"errors"
+	"mime"
+	"path/filepath"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// File categories, for clients that pick an icon per kind of file.
+const (
+	CategoryDocument = "document"
+	CategoryImage    = "image"
+	CategoryVideo    = "video"
+	CategoryAudio    = "audio"
+	CategoryArchive  = "archive"
+	CategoryCode     = "code"
+	CategoryOther    = "other"
+)
+
+// categoriesByContentType maps exact content types to categories. It is consulted before
+// categoriesByContentTypePrefix.
+var categoriesByContentType = map[string]string{
+	"application/pdf":    CategoryDocument,
+	"application/msword": CategoryDocument,
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document": CategoryDocument,
+	"application/vnd.ms-excel": CategoryDocument,
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":         CategoryDocument,
+	"application/vnd.ms-powerpoint":                                             CategoryDocument,
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation": CategoryDocument,
+	"application/vnd.oasis.opendocument.text":                                   CategoryDocument,
+	"application/vnd.oasis.opendocument.spreadsheet":                            CategoryDocument,
+	"application/rtf":              CategoryDocument,
+	"text/plain":                   CategoryDocument,
+	"text/markdown":                CategoryDocument,
+	"text/csv":                     CategoryDocument,
+	"application/zip":              CategoryArchive,
+	"application/gzip":             CategoryArchive,
+	"application/x-tar":            CategoryArchive,
+	"application/x-7z-compressed":  CategoryArchive,
+	"application/x-rar-compressed": CategoryArchive,
+	"application/vnd.rar":          CategoryArchive,
+	"application/x-bzip2":          CategoryArchive,
+	"application/x-xz":             CategoryArchive,
+	"application/json":             CategoryCode,
+	"application/xml":              CategoryCode,
+	"application/javascript":       CategoryCode,
+	"application/x-sh":             CategoryCode,
+	"application/x-yaml":           CategoryCode,
+	"text/html":                    CategoryCode,
+	"text/css":                     CategoryCode,
+	"text/javascript":              CategoryCode,
+	"text/xml":                     CategoryCode,
+	"text/x-go":                    CategoryCode,
+	"text/x-python":                CategoryCode,
+}
+
+// categoriesByContentTypePrefix maps content type families to categories.
+var categoriesByContentTypePrefix = map[string]string{
+	"image/": CategoryImage,
+	"video/": CategoryVideo,
+	"audio/": CategoryAudio,
+}
+
+// categoriesByExtension maps lower-case file extensions to categories.
+var categoriesByExtension = map[string]string{
+	".pdf": CategoryDocument, ".doc": CategoryDocument, ".docx": CategoryDocument,
+	".xls": CategoryDocument, ".xlsx": CategoryDocument, ".ppt": CategoryDocument,
+	".pptx": CategoryDocument, ".odt": CategoryDocument, ".ods": CategoryDocument,
+	".rtf": CategoryDocument, ".txt": CategoryDocument, ".md": CategoryDocument,
+	".csv": CategoryDocument,
+
+	".jpg": CategoryImage, ".jpeg": CategoryImage, ".png": CategoryImage, ".gif": CategoryImage,
+	".webp": CategoryImage, ".svg": CategoryImage, ".heic": CategoryImage, ".bmp": CategoryImage,
+	".tiff": CategoryImage,
+
+	".mp4": CategoryVideo, ".mov": CategoryVideo, ".mkv": CategoryVideo, ".webm": CategoryVideo,
+	".avi": CategoryVideo, ".m4v": CategoryVideo,
+
+	".mp3": CategoryAudio, ".wav": CategoryAudio, ".flac": CategoryAudio, ".ogg": CategoryAudio,
+	".m4a": CategoryAudio, ".aac": CategoryAudio,
+
+	".zip": CategoryArchive, ".gz": CategoryArchive, ".tgz": CategoryArchive, ".tar": CategoryArchive,
+	".7z": CategoryArchive, ".rar": CategoryArchive, ".bz2": CategoryArchive, ".xz": CategoryArchive,
+
+	".go": CategoryCode, ".py": CategoryCode, ".js": CategoryCode, ".ts": CategoryCode,
+	".java": CategoryCode, ".c": CategoryCode, ".h": CategoryCode, ".cpp": CategoryCode,
+	".rs": CategoryCode, ".rb": CategoryCode, ".php": CategoryCode, ".sh": CategoryCode,
+	".json": CategoryCode, ".yaml": CategoryCode, ".yml": CategoryCode, ".xml": CategoryCode,
+	".html": CategoryCode, ".css": CategoryCode, ".sql": CategoryCode,
+}
+
+// genericContentTypes say little about a file, so its extension is trusted over them.
+var genericContentTypes = map[string]bool{
+	"":                         true,
+	"application/octet-stream": true,
+	"binary/octet-stream":      true,
+	"text/plain":               true,
+}
+
+// FileCategory classifies a file from its content type and name. Specific content types win;
+// for generic ones such as application/octet-stream the extension decides, so "clip.mp4"
+// uploaded as application/octet-stream is a video. Listings, filters and usage figures all
+// go through this function so they agree.
+func FileCategory(contentType, name string) string {
+	mediaType := strings.ToLower(strings.TrimSpace(contentType))
+	if parsed, _, err := mime.ParseMediaType(contentType); err == nil {
+		mediaType = parsed
+	}
+	ext := strings.ToLower(filepath.Ext(name))
+
+	if genericContentTypes[mediaType] {
+		if category, ok := categoriesByExtension[ext]; ok {
+			return category
+		}
+	}
+	if category, ok := categoriesByContentType[mediaType]; ok {
+		return category
+	}
+	for prefix, category := range categoriesByContentTypePrefix {
+		if strings.HasPrefix(mediaType, prefix) {
+			return category
+		}
+	}
+	if category, ok := categoriesByExtension[ext]; ok {
+		return category
+	}
+	return CategoryOther
+}
+
+// categorizeBatchSize bounds how many uncategorized files CategorizeFiles updates per run.
+const categorizeBatchSize = 1000
+
+// CategorizeFiles fills in the category of files stored before categories existed and returns
+// the IDs of the files it changed.
+func CategorizeFiles(db *gorm.DB) ([]uint, error) {
+	var files []File
+	err := db.Unscoped().Select("id", "name", "content_type").Where("category = ''").
+		Limit(categorizeBatchSize).Find(&files).Error
+	if err != nil {
+		return nil, errors.New("error loading uncategorized files")
+	}
+
+	changed := make([]uint, 0, len(files))
+	for _, f := range files {
+		err := db.Unscoped().Model(&File{}).Where("id = ?", f.ID).
+			UpdateColumn("category", FileCategory(f.ContentType, f.Name)).Error
+		if err != nil {
+			return changed, errors.New("error categorizing files")
+		}
+		changed = append(changed, f.ID)
+	}
+	return changed, nil
+}
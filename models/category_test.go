@@ -0,0 +1,88 @@
+package models
+
+import "testing"
+
+func TestFileCategory(t *testing.T) {
+	tests := []struct {
+		contentType string
+		name        string
+		want        string
+	}{
+		{"application/pdf", "report.pdf", CategoryDocument},
+		{"image/png", "photo.png", CategoryImage},
+		{"image/svg+xml", "logo.svg", CategoryImage},
+		{"video/mp4", "clip.mp4", CategoryVideo},
+		{"audio/mpeg", "song.mp3", CategoryAudio},
+		{"application/zip", "bundle.zip", CategoryArchive},
+		{"application/json", "data.json", CategoryCode},
+		{"text/html", "index.html", CategoryCode},
+
+		// Generic content types defer to the extension.
+		{"application/octet-stream", "clip.mp4", CategoryVideo},
+		{"binary/octet-stream", "song.flac", CategoryAudio},
+		{"", "photo.jpeg", CategoryImage},
+		{"text/plain", "main.go", CategoryCode},
+		{"text/plain; charset=utf-8", "script.py", CategoryCode},
+		{"application/octet-stream", "blob.bin", CategoryOther},
+		{"application/octet-stream", "README", CategoryOther},
+		{"text/plain", "notes", CategoryDocument},
+		{"", "", CategoryOther},
+
+		// Specific content types win over the extension.
+		{"text/csv", "data.csv", CategoryDocument},
+		{"text/csv", "export.mp4", CategoryDocument},
+		{"image/png", "photo.pdf", CategoryImage},
+		{"application/zip", "code.go", CategoryArchive},
+
+		// Unknown content types fall back to the extension.
+		{"application/x-unknown", "bundle.tgz", CategoryArchive},
+		{"application/x-unknown", "thing", CategoryOther},
+
+		// Case, parameters and whitespace don't matter.
+		{"IMAGE/PNG", "photo", CategoryImage},
+		{" Video/MP4 ", "clip", CategoryVideo},
+		{"Application/PDF; name=x", "x", CategoryDocument},
+		{"", "PHOTO.JPG", CategoryImage},
+		{"application/octet-stream", "Archive.TAR", CategoryArchive},
+		{"application/octet-stream", "archive.tar.gz", CategoryArchive},
+	}
+	for _, tt := range tests {
+		if got := FileCategory(tt.contentType, tt.name); got != tt.want {
+			t.Errorf("FileCategory(%q, %q) = %q, want %q", tt.contentType, tt.name, got, tt.want)
+		}
+	}
+}
+
+// Files stored before categories existed are categorized by the same function.
+func TestCategorizeFiles(t *testing.T) {
+	db := openTestDB(t)
+	owner := createTestUser(t, db, "owner@example.com")
+	legacy := map[string]string{
+		"clip.mp4":   "application/octet-stream",
+		"report.pdf": "application/pdf",
+		"blob":       "",
+	}
+	for name, contentType := range legacy {
+		insertLegacyFile(t, db, owner, name, contentType)
+	}
+	createTestFile(t, db, owner, "notes.txt", 1)
+
+	changed, err := CategorizeFiles(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changed) != len(legacy) {
+		t.Errorf("CategorizeFiles changed %d files, want %d", len(changed), len(legacy))
+	}
+
+	var files []File
+	db.Find(&files)
+	for _, file := range files {
+		if want := FileCategory(file.ContentType, file.Name); file.Category != want {
+			t.Errorf("%s: category = %q, want %q", file.Name, file.Category, want)
+		}
+	}
+	if again, _ := CategorizeFiles(db); len(again) != 0 {
+		t.Errorf("second run changed %d files, want none", len(again))
+	}
+}
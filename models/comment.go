@@ -0,0 +1,137 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"unicode"
+
+	"go-share/utils"
+	"gorm.io/gorm"
+)
+
+// Comment is a plain-text remark left on a file.
+type Comment struct {
+	Base
+	FileID   uint   `json:"file_id" gorm:"index;not null"`
+	AuthorID uint   `json:"author_id" gorm:"index;not null"`
+	Body     string `json:"body" validate:"required,max=4000"`
+
+	// AuthorDisplayName is filled from the users table when comments are listed.
+	AuthorDisplayName string `json:"author_display_name" gorm:"->;-:migration"`
+}
+
+// plainComment has Comment's fields without its JSON methods.
+type plainComment Comment
+
+// MarshalJSON encodes the comment with the file's public ID as "file_id", adding the legacy
+// gorm.Model keys when LegacyJSONFields is set.
+func (c Comment) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(struct {
+		plainComment
+		FileID utils.PublicID `json:"file_id"`
+	}{plainComment(c), utils.PublicID(c.FileID)})
+	if err != nil {
+		return nil, err
+	}
+	return withLegacyFields(data, c.Base)
+}
+
+// UnmarshalJSON decodes a comment encoded by MarshalJSON.
+func (c *Comment) UnmarshalJSON(data []byte) error {
+	var decoded struct {
+		*plainComment
+		FileID *utils.PublicID `json:"file_id"`
+	}
+	decoded.plainComment = (*plainComment)(c)
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	if decoded.FileID != nil {
+		c.FileID = uint(*decoded.FileID)
+	}
+	return nil
+}
+
+// SanitizeCommentBody strips control characters (other than newlines and tabs) and
+// surrounding whitespace. Bodies are always served as plain text, never HTML.
+func SanitizeCommentBody(body string) string {
+	body = strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return r
+		}
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, body)
+	return strings.TrimSpace(body)
+}
+
+// CreateComment validates and stores a new comment.
+func (c *Comment) CreateComment(db *gorm.DB) error {
+	c.Body = SanitizeCommentBody(c.Body)
+	if err := utils.ValidateStruct(c); err != nil {
+		return err
+	}
+
+	if err := db.Create(c).Error; err != nil {
+		return errors.New("error creating comment")
+	}
+	return nil
+}
+
+// CanModify reports whether userID may edit or delete the comment: its author or the file owner.
+func (c *Comment) CanModify(file *File, userID uint) bool {
+	return c.AuthorID == userID || file.UserID == userID
+}
+
+// UpdateComment replaces the comment body.
+func (c *Comment) UpdateComment(db *gorm.DB, file *File, userID uint, body string) error {
+	if !c.CanModify(file, userID) {
+		return errors.New("unauthorized to update comment")
+	}
+
+	c.Body = SanitizeCommentBody(body)
+	if err := utils.ValidateStruct(c); err != nil {
+		return err
+	}
+
+	if err := db.Model(c).Update("body", c.Body).Error; err != nil {
+		return errors.New("error updating comment")
+	}
+	return nil
+}
+
+// DeleteComment removes the comment.
+func (c *Comment) DeleteComment(db *gorm.DB, file *File, userID uint) error {
+	if !c.CanModify(file, userID) {
+		return errors.New("unauthorized to delete comment")
+	}
+
+	if err := db.Delete(c).Error; err != nil {
+		return errors.New("error deleting comment")
+	}
+	return nil
+}
+
+// ListFileComments returns a page of a file's comments, newest first, and the total count.
+func ListFileComments(db *gorm.DB, fileID uint, offset, limit int) ([]Comment, int64, error) {
+	var total int64
+	if err := db.Model(&Comment{}).Where("file_id = ?", fileID).Count(&total).Error; err != nil {
+		return nil, 0, errors.New("error counting comments")
+	}
+
+	comments := []Comment{}
+	err := db.Select("comments.*, users.display_name AS author_display_name").
+		Joins("LEFT JOIN users ON users.id = comments.author_id").
+		Where("comments.file_id = ?", fileID).
+		Order("comments.created_at DESC, comments.id DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&comments).Error
+	if err != nil {
+		return nil, 0, errors.New("error retrieving comments")
+	}
+	return comments, total, nil
+}
@@ -0,0 +1,132 @@
+package models
+
+import (
+	"errors"
+	"mime"
+	"path/filepath"
+	"strings"
+
+	"go-share/utils"
+	"gorm.io/gorm"
+)
+
+// placeholderContentTypes are sent by browsers and download tools that don't know the real type.
+// Like the generic types, they say nothing about the file.
+var placeholderContentTypes = map[string]bool{
+	"application/x-download":     true,
+	"application/force-download": true,
+	"application/download":       true,
+	"application/unknown":        true,
+	"application/x-unknown":      true,
+	"application/binary":         true,
+}
+
+// contentTypesByExtension maps lower-case file extensions to their canonical content type.
+var contentTypesByExtension = map[string]string{
+	".pdf":  "application/pdf",
+	".doc":  "application/msword",
+	".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".xls":  "application/vnd.ms-excel",
+	".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".ppt":  "application/vnd.ms-powerpoint",
+	".pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	".odt":  "application/vnd.oasis.opendocument.text",
+	".ods":  "application/vnd.oasis.opendocument.spreadsheet",
+	".rtf":  "application/rtf",
+	".txt":  "text/plain",
+	".md":   "text/markdown",
+	".csv":  "text/csv",
+
+	".jpg": "image/jpeg", ".jpeg": "image/jpeg", ".png": "image/png", ".gif": "image/gif",
+	".webp": "image/webp", ".svg": "image/svg+xml", ".heic": "image/heic", ".bmp": "image/bmp",
+	".tiff": "image/tiff",
+
+	".mp4": "video/mp4", ".mov": "video/quicktime", ".mkv": "video/x-matroska", ".webm": "video/webm",
+	".avi": "video/x-msvideo", ".m4v": "video/x-m4v",
+
+	".mp3": "audio/mpeg", ".wav": "audio/wav", ".flac": "audio/flac", ".ogg": "audio/ogg",
+	".m4a": "audio/mp4", ".aac": "audio/aac",
+
+	".zip": "application/zip", ".gz": "application/gzip", ".tgz": "application/gzip",
+	".tar": "application/x-tar", ".7z": "application/x-7z-compressed", ".rar": "application/vnd.rar",
+	".bz2": "application/x-bzip2", ".xz": "application/x-xz",
+
+	".json": "application/json", ".xml": "application/xml", ".js": "text/javascript",
+	".html": "text/html", ".css": "text/css", ".sh": "application/x-sh", ".yaml": "application/x-yaml",
+	".yml": "application/x-yaml",
+}
+
+// NormalizedContentType returns the content type a file named name should have, and whether it
+// differs from the stored contentType. File contents aren't stored, so the extension is the
+// only evidence: it replaces types that are empty, malformed, generic or placeholders, and
+// types whose category contradicts it, such as "photo.jpg" stored as video/mp4. Types the
+// extension doesn't contradict are kept.
+func NormalizedContentType(contentType, name string) (string, bool) {
+	expected, ok := contentTypesByExtension[strings.ToLower(filepath.Ext(name))]
+	if !ok {
+		return contentType, false
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err == nil && mediaType == expected {
+		return contentType, false
+	}
+	if err != nil || !strings.Contains(mediaType, "/") || genericContentTypes[mediaType] || placeholderContentTypes[mediaType] {
+		return expected, expected != contentType
+	}
+
+	stored, wanted := FileCategory(mediaType, ""), FileCategory(expected, "")
+	if stored != CategoryOther && stored != wanted {
+		return expected, true
+	}
+	return contentType, false
+}
+
+// ContentTypeChange records one corrected content type.
+type ContentTypeChange struct {
+	FileID utils.PublicID `json:"file_id"`
+	Name   string         `json:"name"`
+	From   string         `json:"from"`
+	To     string         `json:"to"`
+}
+
+// ContentTypeReport summarizes a NormalizeContentTypes run.
+type ContentTypeReport struct {
+	Checked   int                 `json:"checked"`
+	Changed   []ContentTypeChange `json:"changed"`
+	Remaining int64               `json:"remaining"`
+}
+
+// NormalizeContentTypes checks the content type of up to limit files that haven't been
+// verified yet, corrects it with NormalizedContentType, re-derives the category, and marks
+// each file verified so later runs skip it. Corrections don't bump the file version: they
+// fix stored data rather than change the file.
+func NormalizeContentTypes(db *gorm.DB, limit int) (*ContentTypeReport, error) {
+	var files []File
+	err := db.Unscoped().Select("id", "name", "content_type").Where("content_type_verified = ?", false).
+		Order("id").Limit(limit).Find(&files).Error
+	if err != nil {
+		return nil, errors.New("error loading unverified files")
+	}
+
+	report := &ContentTypeReport{Changed: []ContentTypeChange{}}
+	for _, f := range files {
+		updates := map[string]interface{}{"content_type_verified": true}
+		if normalized, changed := NormalizedContentType(f.ContentType, f.Name); changed {
+			updates["content_type"] = normalized
+			updates["category"] = FileCategory(normalized, f.Name)
+			report.Changed = append(report.Changed, ContentTypeChange{
+				FileID: f.PublicID(), Name: f.Name, From: f.ContentType, To: normalized,
+			})
+		}
+		if err := db.Unscoped().Model(&File{}).Where("id = ?", f.ID).UpdateColumns(updates).Error; err != nil {
+			return nil, errors.New("error normalizing content types")
+		}
+		report.Checked++
+	}
+
+	if err := db.Unscoped().Model(&File{}).Where("content_type_verified = ?", false).Count(&report.Remaining).Error; err != nil {
+		return nil, errors.New("error counting unverified files")
+	}
+	return report, nil
+}
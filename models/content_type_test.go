@@ -0,0 +1,115 @@
+package models
+
+import (
+	"testing"
+)
+
+// mislabeledFiles are stored names and content types as clients sent them over the years,
+// with the content type and category normalization should leave them with.
+var mislabeledFiles = []struct {
+	name, stored       string
+	want, wantCategory string
+}{
+	// Empty, generic and placeholder types give way to the extension.
+	{"photo.jpg", "", "image/jpeg", CategoryImage},
+	{"report.pdf", "application/octet-stream", "application/pdf", CategoryDocument},
+	{"clip.mp4", "application/x-download", "video/mp4", CategoryVideo},
+	{"song.MP3", "application/force-download", "audio/mpeg", CategoryAudio},
+	{"bundle.zip", "binary/octet-stream", "application/zip", CategoryArchive},
+	{"data.json", "text/plain", "application/json", CategoryCode},
+	// Malformed types too.
+	{"photo.png", "image", "image/png", CategoryImage},
+	{"photo.gif", "image/gif;;", "image/gif", CategoryImage},
+	// A type whose category contradicts the extension is replaced.
+	{"holiday.jpg", "video/mp4", "image/jpeg", CategoryImage},
+	{"notes.txt", "application/zip", "text/plain", CategoryDocument},
+
+	// Types the extension agrees with or doesn't contradict are kept, parameters and all. So
+	// is the category, which CategorizeFiles fills in for rows as old as these.
+	{"notes.txt", "text/plain; charset=utf-8", "text/plain; charset=utf-8", ""},
+	{"photo.jpg", "image/pjpeg", "image/pjpeg", ""},
+	{"scan.pdf", "application/x-pdf", "application/x-pdf", ""},
+	// Without a known extension there is nothing to go on.
+	{"blob", "application/x-download", "application/x-download", ""},
+	{"model.stl", "", "", ""},
+}
+
+func TestNormalizeContentTypes(t *testing.T) {
+	db := openTestDB(t)
+	owner := createTestUser(t, db, "owner@example.com")
+	ids := make([]uint, len(mislabeledFiles))
+	for i, tt := range mislabeledFiles {
+		file := insertLegacyFile(t, db, owner, tt.name, tt.stored)
+		ids[i] = file.ID
+	}
+	// Deleted files are normalized too, so that restoring one doesn't bring bad data back.
+	if err := db.Delete(&File{}, ids[0]).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := NormalizeContentTypes(db, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Checked != len(mislabeledFiles) || report.Remaining != 0 {
+		t.Errorf("checked %d with %d remaining, want %d and 0", report.Checked, report.Remaining, len(mislabeledFiles))
+	}
+
+	changes := map[uint]ContentTypeChange{}
+	for _, change := range report.Changed {
+		changes[uint(change.FileID)] = change
+	}
+	for i, tt := range mislabeledFiles {
+		var file File
+		if err := db.Unscoped().First(&file, ids[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+		if file.ContentType != tt.want || file.Category != tt.wantCategory || !file.ContentTypeVerified {
+			t.Errorf("%s stored as %q: now %q (%s, verified %v), want %q (%s)",
+				tt.name, tt.stored, file.ContentType, file.Category, file.ContentTypeVerified, tt.want, tt.wantCategory)
+		}
+		if file.Version != 1 {
+			t.Errorf("%s: version = %d, want 1", tt.name, file.Version)
+		}
+
+		change, reported := changes[ids[i]]
+		if wantReported := tt.want != tt.stored; reported != wantReported {
+			t.Errorf("%s: reported = %v, want %v", tt.name, reported, wantReported)
+		} else if reported && (change.From != tt.stored || change.To != tt.want || change.Name != tt.name) {
+			t.Errorf("%s: reported %+v", tt.name, change)
+		}
+	}
+}
+
+// Verified files are skipped, so each file is checked once and runs work through the rest in
+// batches.
+func TestNormalizeContentTypesSkipsVerified(t *testing.T) {
+	db := openTestDB(t)
+	owner := createTestUser(t, db, "owner@example.com")
+	verified := insertLegacyFile(t, db, owner, "kept.jpg", "application/octet-stream")
+	if err := db.Model(verified).UpdateColumn("content_type_verified", true).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.pdf", "b.pdf", "c.pdf"} {
+		insertLegacyFile(t, db, owner, name, "")
+	}
+
+	for _, want := range []struct {
+		checked   int
+		remaining int64
+	}{{2, 1}, {1, 0}, {0, 0}} {
+		report, err := NormalizeContentTypes(db, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Checked != want.checked || report.Remaining != want.remaining || len(report.Changed) != want.checked {
+			t.Errorf("checked %d, changed %d, remaining %d; want %d, %d, %d",
+				report.Checked, len(report.Changed), report.Remaining, want.checked, want.checked, want.remaining)
+		}
+	}
+
+	db.First(verified, verified.ID)
+	if verified.ContentType != "application/octet-stream" {
+		t.Errorf("verified file's content type changed to %q", verified.ContentType)
+	}
+}
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// uploadConcurrently creates n files named name for owner at the same time and returns the
+// names of those stored and the errors of those that failed.
+func uploadConcurrently(t *testing.T, n int, owner *User, name, onConflict string) ([]string, []error) {
+	t.Helper()
+	db := openTestDB(t)
+	if err := db.Create(owner).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var names []string
+	var errs []error
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			file := &File{Name: name, Path: "/" + name, ContentType: "text/plain", Size: 1, UserID: owner.ID}
+			_, err := file.CreateFile(db, CreateOptions{OnConflict: onConflict})
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+			} else {
+				names = append(names, file.Name)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var stored []string
+	if err := db.Model(&File{}).Where("user_id = ?", owner.ID).Pluck("name", &stored).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != len(names) {
+		t.Errorf("%d uploads succeeded, but %d files are stored: %v", len(names), len(stored), stored)
+	}
+	return names, errs
+}
+
+// Concurrent uploads of one name never leave the owner with two files of that name, whichever
+// way conflicts are resolved.
+func TestCreateFileConcurrentSameName(t *testing.T) {
+	const uploads = 8
+
+	t.Run("error", func(t *testing.T) {
+		names, errs := uploadConcurrently(t, uploads, &User{Email: "owner@example.com"}, "a.txt", ConflictError)
+		if len(names) != 1 {
+			t.Fatalf("%d uploads succeeded, want exactly one: %v", len(names), names)
+		}
+		for _, err := range errs {
+			if !errors.Is(err, ErrNameConflict) {
+				t.Errorf("a losing upload failed with %q, want ErrNameConflict", err)
+			}
+		}
+	})
+
+	t.Run("rename", func(t *testing.T) {
+		names, errs := uploadConcurrently(t, uploads, &User{Email: "owner@example.com"}, "a.txt", ConflictRename)
+		if len(errs) != 0 {
+			t.Errorf("uploads failed: %v", errs)
+		}
+		seen := map[string]bool{}
+		for _, name := range names {
+			if seen[name] {
+				t.Errorf("two uploads were stored as %q", name)
+			}
+			seen[name] = true
+		}
+		if !seen["a.txt"] {
+			t.Errorf("no upload kept the requested name: %v", names)
+		}
+	})
+}
+
+// A replacing upload brings its own metadata rather than keeping the old file's.
+func TestCreateFileReplaceMetadata(t *testing.T) {
+	db := openTestDB(t)
+	owner := createTestUser(t, db, "owner@example.com")
+	old := &File{Name: "a.txt", Path: "/a.txt", ContentType: "text/plain", Size: 1, UserID: owner.ID, Metadata: Metadata{"project": "old", "stale": "yes"}}
+	if _, err := old.CreateFile(db, CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	file := &File{Name: "a.txt", Path: "/a.txt", ContentType: "text/plain", Size: 2, UserID: owner.ID, Metadata: Metadata{"project": "new"}}
+	action, err := file.CreateFile(db, CreateOptions{OnConflict: ConflictReplace})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if action != UploadReplaced || file.ID != old.ID || file.Version != old.Version+1 {
+		t.Fatalf("action %q, ID %d, version %d; want %q, %d, %d", action, file.ID, file.Version, UploadReplaced, old.ID, old.Version+1)
+	}
+
+	want := Metadata{"project": "new"}
+	var stored File
+	if err := db.First(&stored, old.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(file.Metadata, want) || !reflect.DeepEqual(stored.Metadata, want) {
+		t.Errorf("metadata %v, stored %v; want %v", file.Metadata, stored.Metadata, want)
+	}
+}
@@ -0,0 +1,118 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// fileCount returns the owner's counter and the number of files they actually have.
+func fileCount(t *testing.T, db *gorm.DB, owner *User) (counter, actual int64) {
+	t.Helper()
+	if err := db.Model(&User{}).Where("id = ?", owner.ID).Pluck("file_count", &counter).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Model(&File{}).Where("user_id = ?", owner.ID).Count(&actual).Error; err != nil {
+		t.Fatal(err)
+	}
+	return counter, actual
+}
+
+// Parallel uploads by a user one file below the limit: exactly one gets the last slot.
+func TestCreateFileConcurrentAtCountLimit(t *testing.T) {
+	const limit, uploads = 5, 8
+	db := openTestDB(t)
+	owner := createTestUser(t, db, "owner@example.com")
+	opts := CreateOptions{MaxFilesPerUser: limit}
+	for i := 0; i < limit-1; i++ {
+		file := &File{Name: fmt.Sprintf("%d.txt", i), Path: "/", Size: 1, UserID: owner.ID}
+		if _, err := file.CreateFile(db, opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var mu sync.Mutex
+	var stored int
+	var errs []error
+	var wg sync.WaitGroup
+	for i := 0; i < uploads; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			file := &File{Name: fmt.Sprintf("parallel-%d.txt", i), Path: "/", Size: 1, UserID: owner.ID}
+			_, err := file.CreateFile(db, opts)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+			} else {
+				stored++
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if stored != 1 {
+		t.Errorf("%d uploads succeeded at the limit, want exactly one", stored)
+	}
+	for _, err := range errs {
+		if !errors.Is(err, ErrFileCountLimitExceeded) {
+			t.Errorf("got %v, want ErrFileCountLimitExceeded", err)
+		}
+	}
+	if counter, actual := fileCount(t, db, owner); counter != limit || actual != limit {
+		t.Errorf("file_count %d and %d files stored, want %d of each", counter, actual, limit)
+	}
+}
+
+// Deleting a file frees its slot, and the counter stays in step with the files.
+func TestFileCountFollowsDeletes(t *testing.T) {
+	db := openTestDB(t)
+	owner := createTestUser(t, db, "owner@example.com")
+	opts := CreateOptions{MaxFilesPerUser: 1}
+
+	first := &File{Name: "a.txt", Path: "/", UserID: owner.ID}
+	if _, err := first.CreateFile(db, opts); err != nil {
+		t.Fatal(err)
+	}
+	second := &File{Name: "b.txt", Path: "/", UserID: owner.ID}
+	if _, err := second.CreateFile(db, opts); !errors.Is(err, ErrFileCountLimitExceeded) {
+		t.Fatalf("at the limit: got %v, want ErrFileCountLimitExceeded", err)
+	}
+
+	if err := first.DeleteFile(db, owner.ID, ""); err != nil {
+		t.Fatal(err)
+	}
+	if counter, actual := fileCount(t, db, owner); counter != 0 || actual != 0 {
+		t.Errorf("after the delete: file_count %d and %d files, want 0", counter, actual)
+	}
+	if _, err := second.CreateFile(db, opts); err != nil {
+		t.Errorf("after the delete: %s", err)
+	}
+}
+
+// A counter that drifted, for instance through rows written outside CreateFile, is rebuilt.
+func TestRecalculateFileCounts(t *testing.T) {
+	db := openTestDB(t)
+	owner := createTestUser(t, db, "owner@example.com")
+	other := createTestUser(t, db, "other@example.com")
+	createTestFile(t, db, owner, "a.txt", 1)
+	insertLegacyFile(t, db, owner, "b.txt", "text/plain")
+	createTestFile(t, db, other, "c.txt", 1)
+
+	corrected, err := RecalculateFileCounts(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if corrected != 1 {
+		t.Errorf("corrected %d users, want only the one that drifted", corrected)
+	}
+	for _, user := range []*User{owner, other} {
+		if counter, actual := fileCount(t, db, user); counter != actual {
+			t.Errorf("%s: file_count %d, but %d files", user.Email, counter, actual)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestCreateFileIgnoresServerOwnedFields(t *testing.T) {
+	db := openTestDB(t)
+	owner := createTestUser(t, db, "owner@example.com")
+
+	lockExpiresAt := time.Now().Add(time.Hour)
+	file := &File{
+		Base: Base{
+			ID:        999,
+			CreatedAt: time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC),
+			DeletedAt: gorm.DeletedAt{Time: time.Now(), Valid: true},
+		},
+		Name:          "evidence.pdf",
+		Path:          "/evidence.pdf",
+		UserID:        owner.ID,
+		Version:       7,
+		LockedBy:      "someone-else",
+		LockExpiresAt: &lockExpiresAt,
+		LegalHold:     true,
+		Pinned:        true,
+	}
+	if _, err := file.CreateFile(db, CreateOptions{}); err != nil {
+		t.Fatalf("CreateFile = %s", err)
+	}
+
+	var stored File
+	if err := db.First(&stored, file.ID).Error; err != nil {
+		t.Fatalf("loading the new file: %s", err)
+	}
+	if stored.ID == 999 {
+		t.Errorf("the client chose the ID")
+	}
+	if stored.CreatedAt.Year() == 2001 {
+		t.Errorf("the client chose created_at")
+	}
+	if stored.Version != 1 || file.Version != 1 {
+		t.Errorf("version = %d (response %d), want 1", stored.Version, file.Version)
+	}
+	if stored.LockedBy != "" || stored.LockExpiresAt != nil {
+		t.Errorf("the new file is locked by %q until %v", stored.LockedBy, stored.LockExpiresAt)
+	}
+	if stored.LegalHold || stored.Pinned {
+		t.Errorf("legal_hold = %v, pinned = %v, want both false", stored.LegalHold, stored.Pinned)
+	}
+
+	// The owner can delete what they uploaded.
+	if err := stored.DeleteFile(db, owner.ID, ""); err != nil {
+		t.Errorf("DeleteFile = %s", err)
+	}
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"go-share/utils"
+)
+
+func TestUpdateFileLegacyRow(t *testing.T) {
+	db := openTestDB(t)
+	owner := createTestUser(t, db, "owner@example.com")
+
+	tests := []struct {
+		name    string
+		update  File
+		wantErr bool
+		check   func(t *testing.T, f *File)
+	}{
+		{
+			name:   "description only",
+			update: File{Description: "quarterly numbers"},
+			check: func(t *testing.T, f *File) {
+				if f.Description != "quarterly numbers" || f.Name != "CON" || f.ContentType != "junk" {
+					t.Errorf("got %+v, want only the description changed", f)
+				}
+			},
+		},
+		{
+			name:   "size only",
+			update: File{Size: 42},
+			check: func(t *testing.T, f *File) {
+				if f.Size != 42 {
+					t.Errorf("size = %d, want 42", f.Size)
+				}
+			},
+		},
+		{
+			name:   "rename sanitizes the new name",
+			update: File{Name: "../report.pdf"},
+			check: func(t *testing.T, f *File) {
+				if f.Name != ".._report.pdf" {
+					t.Errorf("name = %q, want %q", f.Name, ".._report.pdf")
+				}
+			},
+		},
+		{
+			name:   "valid new content type",
+			update: File{ContentType: "application/pdf"},
+			check: func(t *testing.T, f *File) {
+				if f.ContentType != "application/pdf" || f.ContentTypeVerified {
+					t.Errorf("got content type %q verified=%v", f.ContentType, f.ContentTypeVerified)
+				}
+			},
+		},
+		{
+			name:    "invalid new content type",
+			update:  File{ContentType: "not a type"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := insertLegacyFile(t, db, owner, "CON", "junk")
+
+			err := file.UpdateFile(db, owner.ID, "", file.Version, &tt.update, nil)
+			if tt.wantErr {
+				if !utils.IsValidationError(err) {
+					t.Fatalf("UpdateFile = %v, want a validation error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("UpdateFile = %s", err)
+			}
+
+			var stored File
+			if err := db.First(&stored, file.ID).Error; err != nil {
+				t.Fatal(err)
+			}
+			if stored.Version != 2 {
+				t.Errorf("version = %d, want 2", stored.Version)
+			}
+			tt.check(t, &stored)
+		})
+	}
+}
+
+func TestUpdateFileVersionConflict(t *testing.T) {
+	db := openTestDB(t)
+	owner := createTestUser(t, db, "owner@example.com")
+	file := createTestFile(t, db, owner, "a.txt", 1)
+
+	stale := *file
+	if err := file.UpdateFile(db, owner.ID, "", 1, &File{Description: "first"}, nil); err != nil {
+		t.Fatalf("first update: %s", err)
+	}
+	if err := stale.UpdateFile(db, owner.ID, "", 1, &File{Description: "second"}, nil); !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("stale update = %v, want ErrVersionConflict", err)
+	}
+}
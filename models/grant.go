@@ -0,0 +1,143 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"go-share/utils"
+	"gorm.io/gorm"
+)
+
+// Grant permissions.
+const (
+	PermissionRead = "read"
+)
+
+// FileGrant gives one user temporary access to one file. Expired grants are ignored by
+// every visibility check and later pruned by the cleanup job. Expiry is judged by the
+// session's NowFunc rather than time.Now, so that it can be tested with a fixed clock.
+type FileGrant struct {
+	ID         uint           `json:"id" gorm:"primarykey"`
+	FileID     utils.PublicID `json:"file_id" gorm:"index;not null"`
+	GranteeID  uint           `json:"grantee_id" gorm:"index:idx_file_grants_grantee_expiry;not null"`
+	Permission string         `json:"permission" gorm:"not null"`
+	ExpiresAt  time.Time      `json:"expires_at" gorm:"index:idx_file_grants_grantee_expiry;not null"`
+	CreatedBy  uint           `json:"created_by" gorm:"not null"`
+	CreatedAt  time.Time      `json:"created_at"`
+
+	// GranteeEmail is filled from the users table when grants are listed.
+	GranteeEmail string `json:"grantee_email" gorm:"->;-:migration"`
+}
+
+// SharedFile is a file visible to a user through a grant, along with how long it stays visible.
+type SharedFile struct {
+	File       File      `json:"file"`
+	Permission string    `json:"permission"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// CreateGrant gives grantee access to the file until expiresAt and records it in the audit log.
+// audit carries the actor and client IP.
+func (f *File) CreateGrant(db *gorm.DB, grantee *User, permission string, expiresAt time.Time, audit AuditLog) (*FileGrant, error) {
+	if f.UserID != audit.ActorID {
+		return nil, ErrNotFileOwner
+	}
+
+	grant := &FileGrant{FileID: f.PublicID(), GranteeID: grantee.ID, Permission: permission, ExpiresAt: expiresAt, CreatedBy: audit.ActorID}
+	audit.Action = "file.grant.create"
+	fileID := f.PublicID()
+	audit.FileID = &fileID
+	audit.TargetUserID = &grantee.ID
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(grant).Error; err != nil {
+			return errors.New("error creating grant")
+		}
+		return RecordAudit(tx, &audit)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	grant.GranteeEmail = grantee.Email
+	return grant, nil
+}
+
+// ListGrants returns the file's unexpired grants, soonest to expire first.
+func (f *File) ListGrants(db *gorm.DB) ([]FileGrant, error) {
+	var grants []FileGrant
+	err := db.Model(&FileGrant{}).
+		Select("file_grants.*, users.email AS grantee_email").
+		Joins("JOIN users ON users.id = file_grants.grantee_id").
+		Where("file_grants.file_id = ? AND file_grants.expires_at > ?", f.ID, db.NowFunc()).
+		Order("file_grants.expires_at").
+		Find(&grants).Error
+	if err != nil {
+		return nil, errors.New("error loading grants")
+	}
+	return grants, nil
+}
+
+// RevokeGrant ends a grant before it expires and records it in the audit log.
+func (f *File) RevokeGrant(db *gorm.DB, grantID uint, audit AuditLog) error {
+	if f.UserID != audit.ActorID {
+		return ErrNotFileOwner
+	}
+
+	var grant FileGrant
+	if err := db.Where("id = ? AND file_id = ?", grantID, f.ID).First(&grant).Error; err != nil {
+		return gorm.ErrRecordNotFound
+	}
+	audit.Action = "file.grant.revoke"
+	fileID := f.PublicID()
+	audit.FileID = &fileID
+	audit.TargetUserID = &grant.GranteeID
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&grant).Error; err != nil {
+			return errors.New("error revoking grant")
+		}
+		return RecordAudit(tx, &audit)
+	})
+}
+
+// ListSharedWithUser returns the files shared with userID through unexpired grants, leaving out
+// files whose owner is suspended or deleted.
+func ListSharedWithUser(db *gorm.DB, userID uint) ([]SharedFile, error) {
+	var grants []FileGrant
+	if err := db.Where("grantee_id = ? AND expires_at > ?", userID, db.NowFunc()).Order("expires_at").Find(&grants).Error; err != nil {
+		return nil, errors.New("error loading grants")
+	}
+	if len(grants) == 0 {
+		return []SharedFile{}, nil
+	}
+
+	fileIDs := make([]uint, len(grants))
+	for i, grant := range grants {
+		fileIDs[i] = uint(grant.FileID)
+	}
+	var files []File
+	if err := db.Where("id IN ?", fileIDs).Where(ActiveOwnerCondition, db.NowFunc()).Find(&files).Error; err != nil {
+		return nil, errors.New("error loading shared files")
+	}
+	byID := make(map[uint]File, len(files))
+	for _, file := range files {
+		byID[file.ID] = file
+	}
+
+	shared := make([]SharedFile, 0, len(grants))
+	for _, grant := range grants {
+		if file, ok := byID[uint(grant.FileID)]; ok {
+			shared = append(shared, SharedFile{File: file, Permission: grant.Permission, ExpiresAt: grant.ExpiresAt})
+		}
+	}
+	return shared, nil
+}
+
+// PruneExpiredGrants deletes grants that have expired. Their creation stays in the audit log.
+func PruneExpiredGrants(db *gorm.DB) error {
+	if err := db.Where("expires_at <= ?", db.NowFunc()).Delete(&FileGrant{}).Error; err != nil {
+		return errors.New("error pruning grants")
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// atTime returns a session of db whose clock reads now.
+func atTime(db *gorm.DB, now time.Time) *gorm.DB {
+	return db.Session(&gorm.Session{NowFunc: func() time.Time { return now }})
+}
+
+func TestGrantExpiry(t *testing.T) {
+	db := openTestDB(t)
+	owner := createTestUser(t, db, "owner@example.com")
+	grantee := createTestUser(t, db, "grantee@example.com")
+	file := createTestFile(t, db, owner, "a.txt", 1)
+
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	expiresAt := start.Add(time.Hour)
+	if _, err := file.CreateGrant(db, grantee, PermissionRead, expiresAt, AuditLog{ActorID: owner.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		now    time.Time
+		active bool
+	}{
+		{"just granted", start, true},
+		{"a millisecond before expiry", expiresAt.Add(-time.Millisecond), true},
+		{"at expiry", expiresAt, false},
+		{"long after", expiresAt.Add(24 * time.Hour), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := atTime(db, tt.now)
+
+			grants, err := file.ListGrants(db)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := len(grants) == 1; got != tt.active {
+				t.Errorf("ListGrants returned %d grants, want active = %v", len(grants), tt.active)
+			}
+
+			shared, err := ListSharedWithUser(db, grantee.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := len(shared) == 1; got != tt.active {
+				t.Errorf("ListSharedWithUser returned %d files, want active = %v", len(shared), tt.active)
+			}
+		})
+	}
+}
+
+func TestPruneExpiredGrants(t *testing.T) {
+	db := openTestDB(t)
+	owner := createTestUser(t, db, "owner@example.com")
+	grantee := createTestUser(t, db, "grantee@example.com")
+	file := createTestFile(t, db, owner, "a.txt", 1)
+
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	for _, ttl := range []time.Duration{time.Hour, 2 * time.Hour} {
+		if _, err := file.CreateGrant(db, grantee, PermissionRead, start.Add(ttl), AuditLog{ActorID: owner.ID}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := PruneExpiredGrants(atTime(db, start.Add(90*time.Minute))); err != nil {
+		t.Fatal(err)
+	}
+	var left []FileGrant
+	db.Find(&left)
+	if len(left) != 1 || !left[0].ExpiresAt.Equal(start.Add(2*time.Hour)) {
+		t.Fatalf("grants left after pruning: %+v, want only the unexpired one", left)
+	}
+
+	// Pruning keeps the audit trail of the expired grant.
+	var created int64
+	db.Model(&AuditLog{}).Where("action = ?", "file.grant.create").Count(&created)
+	if created != 2 {
+		t.Errorf("got %d grant creation audit entries, want 2", created)
+	}
+}
+
+func TestGrantAuditRecordsImpersonator(t *testing.T) {
+	db := openTestDB(t)
+	owner := createTestUser(t, db, "owner@example.com")
+	grantee := createTestUser(t, db, "grantee@example.com")
+	admin := createTestUser(t, db, "admin@example.com")
+	file := createTestFile(t, db, owner, "a.txt", 1)
+
+	audit := AuditLog{ActorID: owner.ID, ImpersonatorID: &admin.ID}
+	grant, err := file.CreateGrant(db, grantee, PermissionRead, time.Now().Add(time.Hour), audit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := file.RevokeGrant(db, grant.ID, audit); err != nil {
+		t.Fatal(err)
+	}
+
+	var entries []AuditLog
+	db.Order("id").Find(&entries)
+	if len(entries) != 2 {
+		t.Fatalf("got %d audit entries, want 2", len(entries))
+	}
+	for _, entry := range entries {
+		if entry.ActorID != owner.ID || entry.ImpersonatorID == nil || *entry.ImpersonatorID != admin.ID {
+			t.Errorf("%s entry: actor %d, impersonator %v; want %d acting through %d", entry.Action, entry.ActorID, entry.ImpersonatorID, owner.ID, admin.ID)
+		}
+	}
+}
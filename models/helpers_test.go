@@ -0,0 +1,46 @@
+package models
+
+import (
+	"fmt"
+	"testing"
+
+	"go-share/internal/testdb"
+	"gorm.io/gorm"
+)
+
+// openTestDB returns an empty database with every table.
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	return testdb.Open(t, All...)
+}
+
+// createTestUser stores a user with the given email and a fixed password.
+func createTestUser(t *testing.T, db *gorm.DB, email string) *User {
+	t.Helper()
+	user := &User{Email: email, Password: "correct horse"}
+	if err := user.CreateUser(db); err != nil {
+		t.Fatalf("creating user %s: %s", email, err)
+	}
+	return user
+}
+
+// createTestFile stores a file named name for owner through CreateFile.
+func createTestFile(t *testing.T, db *gorm.DB, owner *User, name string, size int64) *File {
+	t.Helper()
+	file := &File{Name: name, Path: "/" + name, ContentType: "text/plain", Size: size, UserID: owner.ID}
+	if _, err := file.CreateFile(db, CreateOptions{}); err != nil {
+		t.Fatalf("creating file %s: %s", name, err)
+	}
+	return file
+}
+
+// insertLegacyFile stores a file row directly, bypassing CreateFile's sanitization and
+// validation, like rows written before those rules existed.
+func insertLegacyFile(t *testing.T, db *gorm.DB, owner *User, name, contentType string) *File {
+	t.Helper()
+	file := &File{Name: name, Path: fmt.Sprintf("/legacy/%s", name), ContentType: contentType, UserID: owner.ID, Version: 1}
+	if err := db.Create(file).Error; err != nil {
+		t.Fatalf("inserting legacy file %s: %s", name, err)
+	}
+	return file
+}
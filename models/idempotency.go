@@ -0,0 +1,94 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrIdempotencyKeyInProgress is returned when a request with the same key is still being processed.
+var ErrIdempotencyKeyInProgress = errors.New("a request with this idempotency key is still in progress")
+
+// IdempotencyKey records the outcome of a request sent with an Idempotency-Key header so
+// that retries can be answered with the original response.
+type IdempotencyKey struct {
+	ID         uint   `gorm:"primarykey"`
+	UserID     uint   `gorm:"uniqueIndex:idx_idempotency_user_key;not null"`
+	Key        string `gorm:"uniqueIndex:idx_idempotency_user_key;size:255;not null"`
+	FileID     *uint
+	StatusCode int    // zero while the original request is in flight
+	Response   string // JSON body of the original response
+	CreatedAt  time.Time
+	// ExpiresAt ends the lease of an in-flight request, and the retention of a completed one.
+	ExpiresAt time.Time `gorm:"index"`
+}
+
+// ReserveIdempotencyKey claims key for userID. The reservation is a lease: if the request
+// holding it neither completes nor releases it within lease, for instance because the server
+// stopped, a retry takes it over. When the key was already used it returns the stored record
+// with reserved set to false, or ErrIdempotencyKeyInProgress while the lease is held.
+func ReserveIdempotencyKey(db *gorm.DB, userID uint, key string, lease time.Duration) (record *IdempotencyKey, reserved bool, err error) {
+	now := db.NowFunc()
+	if err := db.Where("user_id = ? AND key = ? AND expires_at <= ?", userID, key, now).Delete(&IdempotencyKey{}).Error; err != nil {
+		return nil, false, errors.New("error expiring idempotency key")
+	}
+
+	record = &IdempotencyKey{UserID: userID, Key: key, CreatedAt: now, ExpiresAt: now.Add(lease)}
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+	if result.Error != nil {
+		return nil, false, errors.New("error reserving idempotency key")
+	}
+	if result.RowsAffected == 1 {
+		return record, true, nil
+	}
+
+	var existing IdempotencyKey
+	if err := db.Where("user_id = ? AND key = ?", userID, key).First(&existing).Error; err != nil {
+		return nil, false, errors.New("error loading idempotency key")
+	}
+	if existing.StatusCode == 0 {
+		return nil, false, ErrIdempotencyKeyInProgress
+	}
+	return &existing, false, nil
+}
+
+// Complete stores the response of the original request and keeps it for ttl. A reservation
+// that was taken over after its lease ran out is not updated.
+func (k *IdempotencyKey) Complete(db *gorm.DB, fileID uint, statusCode int, response []byte, ttl time.Duration) error {
+	// The lease's expiry identifies this reservation; a row that took it over has another.
+	lease := k.ExpiresAt
+	k.FileID = &fileID
+	k.StatusCode = statusCode
+	k.Response = string(response)
+	k.ExpiresAt = db.NowFunc().Add(ttl)
+
+	if err := db.Model(k).Where("expires_at = ?", lease).Updates(map[string]interface{}{
+		"file_id":     fileID,
+		"status_code": statusCode,
+		"response":    k.Response,
+		"expires_at":  k.ExpiresAt,
+	}).Error; err != nil {
+		return errors.New("error completing idempotency key")
+	}
+	return nil
+}
+
+// Release frees a reserved key after the original request failed so that it can be retried.
+// Like Complete, it leaves a reservation that took this one over alone.
+func (k *IdempotencyKey) Release(db *gorm.DB) error {
+	if err := db.Where("expires_at = ?", k.ExpiresAt).Delete(k).Error; err != nil {
+		return errors.New("error releasing idempotency key")
+	}
+	return nil
+}
+
+// PruneExpiredIdempotencyKeys deletes completed keys whose TTL has passed and reservations
+// whose lease ran out.
+func PruneExpiredIdempotencyKeys(db *gorm.DB) error {
+	if err := db.Where("expires_at <= ?", db.NowFunc()).Delete(&IdempotencyKey{}).Error; err != nil {
+		return errors.New("error pruning idempotency keys")
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package models
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReserveIdempotencyKeyLease(t *testing.T) {
+	db := openTestDB(t)
+	user := createTestUser(t, db, "owner@example.com")
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	lease, ttl := time.Minute, 24*time.Hour
+
+	first, reserved, err := ReserveIdempotencyKey(atTime(db, start), user.ID, "key", lease)
+	if err != nil || !reserved {
+		t.Fatalf("first reservation: reserved = %v, err = %v", reserved, err)
+	}
+
+	// While the lease is held, retries wait for the original request.
+	if _, _, err := ReserveIdempotencyKey(atTime(db, start.Add(lease-time.Millisecond)), user.ID, "key", lease); !errors.Is(err, ErrIdempotencyKeyInProgress) {
+		t.Fatalf("retry within the lease: err = %v, want ErrIdempotencyKeyInProgress", err)
+	}
+
+	// A request that never finished gives its key up when the lease runs out.
+	takeover, reserved, err := ReserveIdempotencyKey(atTime(db, start.Add(lease)), user.ID, "key", lease)
+	if err != nil || !reserved {
+		t.Fatalf("retry after the lease: reserved = %v, err = %v", reserved, err)
+	}
+
+	// The original request completing late must not overwrite the new reservation.
+	if err := first.Complete(atTime(db, start.Add(2*lease)), 1, 201, []byte(`{"late":true}`), ttl); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ReserveIdempotencyKey(atTime(db, start.Add(lease+time.Second)), user.ID, "key", lease); !errors.Is(err, ErrIdempotencyKeyInProgress) {
+		t.Fatalf("after the late completion: err = %v, want ErrIdempotencyKeyInProgress", err)
+	}
+
+	completedAt := start.Add(lease + 10*time.Second)
+	if err := takeover.Complete(atTime(db, completedAt), 2, 201, []byte(`{"id":2}`), ttl); err != nil {
+		t.Fatal(err)
+	}
+
+	// A completed key is kept for ttl, well past the lease.
+	replay, reserved, err := ReserveIdempotencyKey(atTime(db, completedAt.Add(ttl-time.Second)), user.ID, "key", lease)
+	if err != nil || reserved {
+		t.Fatalf("retry after completion: reserved = %v, err = %v", reserved, err)
+	}
+	if replay.StatusCode != 201 || replay.Response != `{"id":2}` {
+		t.Errorf("replayed %d %s, want the second request's response", replay.StatusCode, replay.Response)
+	}
+
+	if _, reserved, err := ReserveIdempotencyKey(atTime(db, completedAt.Add(ttl)), user.ID, "key", lease); err != nil || !reserved {
+		t.Fatalf("retry after ttl: reserved = %v, err = %v", reserved, err)
+	}
+}
+
+func TestReserveIdempotencyKeyRelease(t *testing.T) {
+	db := openTestDB(t)
+	user := createTestUser(t, db, "owner@example.com")
+
+	record, _, err := ReserveIdempotencyKey(db, user.ID, "key", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := record.Release(db); err != nil {
+		t.Fatal(err)
+	}
+	if _, reserved, err := ReserveIdempotencyKey(db, user.ID, "key", time.Minute); err != nil || !reserved {
+		t.Fatalf("retry after release: reserved = %v, err = %v", reserved, err)
+	}
+}
+
+func TestReleaseAfterTakeover(t *testing.T) {
+	db := openTestDB(t)
+	user := createTestUser(t, db, "owner@example.com")
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	first, _, err := ReserveIdempotencyKey(atTime(db, start), user.ID, "key", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, reserved, err := ReserveIdempotencyKey(atTime(db, start.Add(time.Minute)), user.ID, "key", time.Minute); err != nil || !reserved {
+		t.Fatalf("takeover: reserved = %v, err = %v", reserved, err)
+	}
+
+	// The original request failing late must not free the key the retry now holds.
+	if err := first.Release(db); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ReserveIdempotencyKey(atTime(db, start.Add(time.Minute+time.Second)), user.ID, "key", time.Minute); !errors.Is(err, ErrIdempotencyKeyInProgress) {
+		t.Fatalf("after the late release: err = %v, want ErrIdempotencyKeyInProgress", err)
+	}
+}
+
+func TestReserveIdempotencyKeyConcurrent(t *testing.T) {
+	db := openTestDB(t)
+	user := createTestUser(t, db, "owner@example.com")
+
+	const retries = 8
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	reservedCount, inProgress := 0, 0
+	start := make(chan struct{})
+	for i := 0; i < retries; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			_, reserved, err := ReserveIdempotencyKey(db, user.ID, "key", time.Minute)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case reserved:
+				reservedCount++
+			case errors.Is(err, ErrIdempotencyKeyInProgress):
+				inProgress++
+			default:
+				t.Errorf("reserved = %v, err = %v", reserved, err)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if reservedCount != 1 || inProgress != retries-1 {
+		t.Errorf("%d reserved and %d in progress, want 1 and %d", reservedCount, inProgress, retries-1)
+	}
+}
@@ -0,0 +1,211 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Job states.
+const (
+	JobPending   = "pending"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+// ErrJobNotRetryable and ErrJobNotCancellable are returned by admin actions on jobs in the wrong state.
+var (
+	ErrJobNotRetryable   = errors.New("only failed or cancelled jobs can be retried")
+	ErrJobNotCancellable = errors.New("only pending jobs can be cancelled")
+)
+
+// ErrJobLeaseLost is returned when a worker records the outcome of a job after its lease
+// expired and the job was claimed again, or changed by an admin. The outcome is dropped.
+var ErrJobLeaseLost = errors.New("the job's lease was lost")
+
+// Job is a unit of background work in the persistent queue. Workers claim pending jobs whose
+// RunAt has passed; a running job whose lease has expired is assumed to belong to a crashed
+// worker and is claimed again.
+type Job struct {
+	ID          uint            `json:"id" gorm:"primarykey"`
+	Type        string          `json:"type" gorm:"index;not null"`
+	Payload     json.RawMessage `json:"payload" gorm:"type:jsonb;not null"`
+	State       string          `json:"state" gorm:"index:idx_jobs_claim;not null"`
+	RunAt       time.Time       `json:"run_at" gorm:"index:idx_jobs_claim;not null"`
+	Attempts    int             `json:"attempts" gorm:"not null;default:0"`
+	MaxAttempts int             `json:"max_attempts" gorm:"not null"`
+	LastError   string          `json:"last_error,omitempty"`
+	LeaseUntil  *time.Time      `json:"lease_until,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// EnqueueJob adds a job of the given type to the queue, to run as soon as a worker is free.
+func EnqueueJob(db *gorm.DB, jobType string, payload interface{}, maxAttempts int) (*Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, errors.New("error encoding job payload")
+	}
+
+	job := &Job{Type: jobType, Payload: data, State: JobPending, RunAt: db.NowFunc(), MaxAttempts: maxAttempts}
+	if err := db.Create(job).Error; err != nil {
+		return nil, errors.New("error enqueuing job")
+	}
+	return job, nil
+}
+
+// ClaimJob takes the next due job of one of the given types and leases it for lease. It returns
+// nil when no job is due. On Postgres, concurrent workers skip each other's locked rows; other
+// databases have a single writer and need no row locks.
+func ClaimJob(db *gorm.DB, types []string, lease time.Duration) (*Job, error) {
+	var claimed *Job
+	err := db.Transaction(func(tx *gorm.DB) error {
+		now := tx.NowFunc()
+		query := tx.Where("type IN ?", types).
+			Where("(state = ? AND run_at <= ?) OR (state = ? AND lease_until < ?)", JobPending, now, JobRunning, now).
+			Order("run_at").Limit(1)
+		if tx.Dialector.Name() == "postgres" {
+			query = query.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+		}
+
+		var job Job
+		if err := query.Find(&job).Error; err != nil {
+			return errors.New("error claiming job")
+		}
+		if job.ID == 0 {
+			return nil
+		}
+
+		// Truncated like NowFunc, so that the stored lease compares equal to this one whatever
+		// precision the database keeps.
+		leaseUntil := now.Add(lease).Truncate(time.Millisecond)
+		err := tx.Model(&job).Updates(map[string]interface{}{
+			"state":       JobRunning,
+			"attempts":    gorm.Expr("attempts + 1"),
+			"lease_until": leaseUntil,
+		}).Error
+		if err != nil {
+			return errors.New("error claiming job")
+		}
+		job.State = JobRunning
+		job.Attempts++
+		job.LeaseUntil = &leaseUntil
+		claimed = &job
+		return nil
+	})
+	return claimed, err
+}
+
+// Succeed marks a claimed job as done. It returns ErrJobLeaseLost if the worker no longer
+// holds the job.
+func (j *Job) Succeed(db *gorm.DB) error {
+	if err := j.finish(db, map[string]interface{}{"state": JobSucceeded, "last_error": "", "lease_until": nil}); err != nil {
+		return err
+	}
+	j.State, j.LastError, j.LeaseUntil = JobSucceeded, "", nil
+	return nil
+}
+
+// Fail records a failed attempt. The job is retried after backoff, or marked failed once it
+// has used all its attempts. It returns ErrJobLeaseLost if the worker no longer holds the job.
+func (j *Job) Fail(db *gorm.DB, cause error, backoff time.Duration) error {
+	state, runAt := JobPending, db.NowFunc().Add(backoff)
+	if j.Attempts >= j.MaxAttempts {
+		state, runAt = JobFailed, j.RunAt
+	}
+	err := j.finish(db, map[string]interface{}{"state": state, "run_at": runAt, "last_error": cause.Error(), "lease_until": nil})
+	if err != nil {
+		return err
+	}
+	j.State, j.RunAt, j.LastError, j.LeaseUntil = state, runAt, cause.Error(), nil
+	return nil
+}
+
+// finish applies updates to the job only while this claim still holds it: the job is running
+// with the lease ClaimJob gave it. Once the lease expires, another worker may have claimed the
+// job again, and its result must not be overwritten.
+func (j *Job) finish(db *gorm.DB, updates map[string]interface{}) error {
+	if j.LeaseUntil == nil {
+		return ErrJobLeaseLost
+	}
+	result := db.Model(&Job{}).
+		Where("id = ? AND state = ? AND lease_until = ?", j.ID, JobRunning, *j.LeaseUntil).
+		Updates(updates)
+	if result.Error != nil {
+		return errors.New("error recording job outcome")
+	}
+	if result.RowsAffected == 0 {
+		return ErrJobLeaseLost
+	}
+	return nil
+}
+
+// ListJobs returns a page of jobs, newest first, and the total count. state filters by state
+// unless it is empty.
+func ListJobs(db *gorm.DB, state string, offset, limit int) ([]Job, int64, error) {
+	query := db.Model(&Job{})
+	if state != "" {
+		query = query.Where("state = ?", state)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, errors.New("error counting jobs")
+	}
+
+	jobs := []Job{}
+	if err := query.Order("created_at DESC, id DESC").Offset(offset).Limit(limit).Find(&jobs).Error; err != nil {
+		return nil, 0, errors.New("error retrieving jobs")
+	}
+	return jobs, total, nil
+}
+
+// RetryJob puts a failed or cancelled job back in the queue with a fresh set of attempts.
+func RetryJob(db *gorm.DB, id uint) (*Job, error) {
+	return transitionJob(db, id, []string{JobFailed, JobCancelled}, ErrJobNotRetryable, map[string]interface{}{
+		"state":    JobPending,
+		"run_at":   db.NowFunc(),
+		"attempts": 0,
+	})
+}
+
+// CancelJob stops a pending job from running.
+func CancelJob(db *gorm.DB, id uint) (*Job, error) {
+	return transitionJob(db, id, []string{JobPending}, ErrJobNotCancellable, map[string]interface{}{
+		"state": JobCancelled,
+	})
+}
+
+// transitionJob applies updates to the job if it is in one of the from states. It returns
+// gorm.ErrRecordNotFound if there is no such job, and wrongState if it is in another state.
+func transitionJob(db *gorm.DB, id uint, from []string, wrongState error, updates map[string]interface{}) (*Job, error) {
+	result := db.Model(&Job{}).Where("id = ? AND state IN ?", id, from).Updates(updates)
+	if result.Error != nil {
+		return nil, errors.New("error updating job")
+	}
+
+	var job Job
+	if err := db.First(&job, id).Error; err != nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if result.RowsAffected == 0 {
+		return nil, wrongState
+	}
+	return &job, nil
+}
+
+// PruneFinishedJobs deletes succeeded and cancelled jobs last updated before retention ago.
+// Failed jobs are kept for inspection until an admin retries them.
+func PruneFinishedJobs(db *gorm.DB, retention time.Duration) error {
+	err := db.Where("state IN ? AND updated_at < ?", []string{JobSucceeded, JobCancelled}, time.Now().Add(-retention)).
+		Delete(&Job{}).Error
+	if err != nil {
+		return errors.New("error pruning jobs")
+	}
+	return nil
+}
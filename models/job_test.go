@@ -0,0 +1,136 @@
+package models
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Workers claiming at the same time never get the same job, and together they drain the queue.
+func TestClaimJobConcurrent(t *testing.T) {
+	db := openTestDB(t)
+	const jobs, workers = 20, 8
+	for i := 0; i < jobs; i++ {
+		if _, err := EnqueueJob(db, "test", i, 3); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var mu sync.Mutex
+	claims := map[uint]int{}
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				job, err := ClaimJob(db, []string{"test"}, time.Minute)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if job == nil {
+					return
+				}
+				mu.Lock()
+				claims[job.ID]++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(claims) != jobs {
+		t.Errorf("%d jobs claimed, want %d", len(claims), jobs)
+	}
+	for id, n := range claims {
+		if n != 1 {
+			t.Errorf("job %d claimed %d times", id, n)
+		}
+	}
+}
+
+// A failing job is retried after its backoff until it runs out of attempts, then stays failed.
+func TestJobFailRetriesThenFails(t *testing.T) {
+	db := openTestDB(t)
+	if _, err := EnqueueJob(db, "test", nil, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	for attempt := 1; attempt <= 3; attempt++ {
+		job, err := ClaimJob(atTime(db, now), []string{"test"}, time.Minute)
+		if err != nil || job == nil {
+			t.Fatalf("attempt %d: claimed %v, %v", attempt, job, err)
+		}
+		if job.Attempts != attempt {
+			t.Errorf("attempt %d: Attempts = %d", attempt, job.Attempts)
+		}
+		if err := job.Fail(atTime(db, now), errors.New("upstream down"), time.Hour); err != nil {
+			t.Fatal(err)
+		}
+
+		var stored Job
+		db.First(&stored, job.ID)
+		if attempt < 3 {
+			if stored.State != JobPending || !stored.RunAt.Equal(now.Add(time.Hour)) {
+				t.Errorf("attempt %d: stored %s, run at %s; want pending an hour later", attempt, stored.State, stored.RunAt)
+			}
+			// Not due again until the backoff has passed.
+			if job, _ := ClaimJob(atTime(db, now.Add(59*time.Minute)), []string{"test"}, time.Minute); job != nil {
+				t.Errorf("attempt %d: claimed again before the backoff passed", attempt)
+			}
+			now = now.Add(time.Hour)
+		} else if stored.State != JobFailed || stored.LastError != "upstream down" || stored.LeaseUntil != nil {
+			t.Errorf("last attempt: stored %+v, want failed with the error", stored)
+		}
+	}
+
+	if job, _ := ClaimJob(atTime(db, now.Add(24*time.Hour)), []string{"test"}, time.Minute); job != nil {
+		t.Errorf("a failed job was claimed again: %+v", job)
+	}
+}
+
+// A job whose worker crashed is claimed again once the lease expires. The crashed worker, if
+// it comes back, can no longer record an outcome over the new claim's.
+func TestClaimJobAfterLeaseExpires(t *testing.T) {
+	db := openTestDB(t)
+	if _, err := EnqueueJob(db, "test", nil, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().UTC()
+	crashed, err := ClaimJob(atTime(db, now), []string{"test"}, time.Minute)
+	if err != nil || crashed == nil {
+		t.Fatalf("claimed %v, %v", crashed, err)
+	}
+	if job, _ := ClaimJob(atTime(db, now.Add(30*time.Second)), []string{"test"}, time.Minute); job != nil {
+		t.Fatal("a job was claimed again while its lease held")
+	}
+
+	reclaimed, err := ClaimJob(atTime(db, now.Add(2*time.Minute)), []string{"test"}, time.Minute)
+	if err != nil || reclaimed == nil || reclaimed.ID != crashed.ID || reclaimed.Attempts != 2 {
+		t.Fatalf("after the lease expired: claimed %+v, %v; want the job on its second attempt", reclaimed, err)
+	}
+
+	if err := crashed.Succeed(db); !errors.Is(err, ErrJobLeaseLost) {
+		t.Errorf("Succeed by the crashed worker = %v, want ErrJobLeaseLost", err)
+	}
+	if err := crashed.Fail(db, errors.New("late"), time.Minute); !errors.Is(err, ErrJobLeaseLost) {
+		t.Errorf("Fail by the crashed worker = %v, want ErrJobLeaseLost", err)
+	}
+	var stored Job
+	db.First(&stored, crashed.ID)
+	if stored.State != JobRunning || stored.LastError != "" || stored.LeaseUntil == nil || !stored.LeaseUntil.Equal(*reclaimed.LeaseUntil) {
+		t.Errorf("stored %+v, want the new claim untouched", stored)
+	}
+
+	if err := reclaimed.Succeed(db); err != nil {
+		t.Fatalf("Succeed by the new worker: %s", err)
+	}
+	db.First(&stored, crashed.ID)
+	if stored.State != JobSucceeded {
+		t.Errorf("state %s, want succeeded", stored.State)
+	}
+}
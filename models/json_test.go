@@ -0,0 +1,161 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"go-share/utils"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// goldenTime is the timestamp of every fixture, at the millisecond precision timestamps are stored with.
+var goldenTime = time.Date(2026, 1, 2, 3, 4, 5, 678000000, time.UTC)
+
+// checkGolden compares the indented JSON encoding of v with testdata/name, or rewrites the
+// file when the test runs with -update.
+func checkGolden(t *testing.T, name string, v interface{}) {
+	t.Helper()
+
+	got, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = append(got, '\n')
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s changed; if that is intended, run go test ./models -run %s -update\ngot:\n%s\nwant:\n%s", path, t.Name(), got, want)
+	}
+}
+
+// withLegacyJSONFields sets LegacyJSONFields for the rest of the test.
+func withLegacyJSONFields(t *testing.T, enabled bool) {
+	t.Helper()
+	old := LegacyJSONFields
+	LegacyJSONFields = enabled
+	t.Cleanup(func() { LegacyJSONFields = old })
+}
+
+// withGoldenPublicIDKey fixes the key public IDs are encrypted with for the rest of the test,
+// so that the golden files hold the same IDs whatever key another test left behind.
+func withGoldenPublicIDKey(t *testing.T) {
+	t.Helper()
+	old := utils.PublicIDKey
+	utils.PublicIDKey = []byte("golden public id key")
+	t.Cleanup(func() { utils.PublicIDKey = old })
+}
+
+func goldenFile() File {
+	return File{
+		Base:         Base{ID: 1, CreatedAt: goldenTime, UpdatedAt: goldenTime},
+		Name:         "report.pdf",
+		ContentType:  "application/pdf",
+		Path:         "/docs/report.pdf",
+		Description:  "Quarterly report",
+		Size:         1024,
+		UserID:       2,
+		Category:     CategoryDocument,
+		OriginalName: "report.pdf",
+		Metadata:     Metadata{"project": "apollo"},
+		Version:      3,
+		LockedBy:     "session",
+	}
+}
+
+func goldenComment() Comment {
+	return Comment{
+		Base:              Base{ID: 4, CreatedAt: goldenTime, UpdatedAt: goldenTime},
+		FileID:            1,
+		AuthorID:          2,
+		Body:              "Looks good",
+		AuthorDisplayName: "Ada",
+	}
+}
+
+// The golden files lock down the keys clients parse: snake_case throughout, the public file
+// ID, and nothing internal such as the lock session.
+func TestBaseJSONGolden(t *testing.T) {
+	withLegacyJSONFields(t, false)
+	withGoldenPublicIDKey(t)
+
+	user := User{Base: Base{ID: 2, CreatedAt: goldenTime}, Email: "ada@example.com", DisplayName: "Ada"}
+	checkGolden(t, "file.json", goldenFile())
+	checkGolden(t, "comment.json", goldenComment())
+	checkGolden(t, "user_profile.json", user.Profile())
+}
+
+// api.legacy_field_names adds the gorm.Model keys next to the new ones for one release. A
+// file's legacy ID is its public ID, like "id".
+func TestBaseJSONGoldenLegacyFields(t *testing.T) {
+	withLegacyJSONFields(t, true)
+	withGoldenPublicIDKey(t)
+
+	checkGolden(t, "file_legacy.json", goldenFile())
+	checkGolden(t, "comment_legacy.json", goldenComment())
+}
+
+func TestFileJSONRoundTrip(t *testing.T) {
+	withLegacyJSONFields(t, false)
+
+	data, err := json.Marshal(goldenFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded File
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	again, err := json.Marshal(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.ID != 1 || !decoded.CreatedAt.Equal(goldenTime) || !bytes.Equal(again, data) {
+		t.Errorf("round trip changed the file:\n%s\n%s", data, again)
+	}
+}
+
+// serializedTime matches a UTC timestamp with at most millisecond precision.
+var serializedTime = regexp.MustCompile(`^"\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d(\.\d{1,3})?Z"$`)
+
+// Timestamps written through NowFunc, configured as in config.ConnectDB, come back from the
+// database in UTC with millisecond precision.
+func TestStoredTimestampsJSON(t *testing.T) {
+	db := openTestDB(t)
+	owner := createTestUser(t, db, "owner@example.com")
+	file := createTestFile(t, db, owner, "a.txt", 1)
+
+	var stored File
+	if err := db.First(&stored, file.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	var encoded map[string]json.RawMessage
+	data, err := json.Marshal(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"created_at", "updated_at"} {
+		if !serializedTime.Match(encoded[key]) {
+			t.Errorf("%s = %s, want UTC with at most millisecond precision", key, encoded[key])
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Only the session holding a lock can renew or release it, until it expires.
+func TestLockRejectsNonHolder(t *testing.T) {
+	db := openTestDB(t)
+	owner := createTestUser(t, db, "owner@example.com")
+	other := createTestUser(t, db, "other@example.com")
+	file := createTestFile(t, db, owner, "a.txt", 1)
+
+	if err := file.Lock(db, owner.ID, "holder", time.Hour); err != nil {
+		t.Fatalf("Lock = %s", err)
+	}
+	if err := file.Lock(db, owner.ID, "other-session", time.Hour); !errors.Is(err, ErrFileLocked) {
+		t.Errorf("Lock from another session = %v, want ErrFileLocked", err)
+	}
+	if err := file.Unlock(db, owner.ID, "other-session"); !errors.Is(err, ErrFileLocked) {
+		t.Errorf("Unlock from another session = %v, want ErrFileLocked", err)
+	}
+	if err := file.Lock(db, other.ID, "holder", time.Hour); !errors.Is(err, ErrNotFileOwner) {
+		t.Errorf("Lock by another user = %v, want ErrNotFileOwner", err)
+	}
+	if err := file.Unlock(db, other.ID, "holder"); !errors.Is(err, ErrNotFileOwner) {
+		t.Errorf("Unlock by another user = %v, want ErrNotFileOwner", err)
+	}
+
+	var stored File
+	db.First(&stored, file.ID)
+	if stored.LockedBy != "holder" {
+		t.Fatalf("locked by %q after rejected attempts, want holder", stored.LockedBy)
+	}
+
+	if err := file.Lock(db, owner.ID, "holder", 2*time.Hour); err != nil {
+		t.Errorf("renewing = %s", err)
+	}
+	if err := file.Unlock(db, owner.ID, "holder"); err != nil {
+		t.Errorf("Unlock by the holder = %s", err)
+	}
+	if err := file.Lock(db, owner.ID, "other-session", time.Hour); err != nil {
+		t.Errorf("Lock after release = %s", err)
+	}
+}
+
+// An expired lock no longer protects the file: any session may take it over or release it.
+func TestLockExpires(t *testing.T) {
+	db := openTestDB(t)
+	owner := createTestUser(t, db, "owner@example.com")
+	file := createTestFile(t, db, owner, "a.txt", 1)
+
+	if err := file.Lock(db, owner.ID, "holder", 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if file.IsLockedFor("other-session") {
+		t.Error("an expired lock still blocks other sessions")
+	}
+	if err := file.Lock(db, owner.ID, "other-session", time.Hour); err != nil {
+		t.Errorf("taking over an expired lock = %s", err)
+	}
+}
+
+// Of sessions racing for an unlocked file, exactly one gets the lock.
+func TestLockConcurrent(t *testing.T) {
+	db := openTestDB(t)
+	owner := createTestUser(t, db, "owner@example.com")
+	file := createTestFile(t, db, owner, "a.txt", 1)
+
+	const sessions = 8
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var holders []string
+	for i := 0; i < sessions; i++ {
+		wg.Add(1)
+		go func(session string) {
+			defer wg.Done()
+			f := *file
+			err := f.Lock(db, owner.ID, session, time.Hour)
+			if err != nil && !errors.Is(err, ErrFileLocked) {
+				t.Errorf("%s: Lock = %s", session, err)
+			}
+			if err == nil {
+				mu.Lock()
+				defer mu.Unlock()
+				holders = append(holders, session)
+			}
+		}(fmt.Sprintf("session-%d", i))
+	}
+	wg.Wait()
+
+	var stored File
+	db.First(&stored, file.ID)
+	if len(holders) != 1 || stored.LockedBy != holders[0] {
+		t.Errorf("locked by %v, stored holder %q; want exactly one", holders, stored.LockedBy)
+	}
+}
+
+// A session that read the file before another session locked it can't overwrite the locked
+// file, even though its copy doesn't know about the lock. The holder's update goes through.
+func TestLockPreventsLostUpdate(t *testing.T) {
+	db := openTestDB(t)
+	owner := createTestUser(t, db, "owner@example.com")
+	file := createTestFile(t, db, owner, "a.txt", 1)
+
+	stale := *file
+	holder := *file
+	if err := holder.Lock(db, owner.ID, "holder", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := stale.UpdateFile(db, owner.ID, "other-session", stale.Version, &File{Description: "theirs"}, nil); err == nil {
+		t.Error("UpdateFile from another session succeeded under the lock")
+	}
+	patch := map[string]*string{"k": new(string)}
+	if err := stale.UpdateMetadata(db, owner.ID, "other-session", patch); err == nil {
+		t.Error("UpdateMetadata from another session succeeded under the lock")
+	}
+	if err := stale.DeleteFile(db, owner.ID, "other-session"); err == nil {
+		t.Error("DeleteFile from another session succeeded under the lock")
+	}
+
+	if err := holder.UpdateFile(db, owner.ID, "holder", holder.Version, &File{Description: "mine"}, nil); err != nil {
+		t.Fatalf("UpdateFile by the holder = %s", err)
+	}
+	var stored File
+	if err := db.First(&stored, file.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.Description != "mine" || stored.Version != file.Version+1 || len(stored.Metadata) != 0 {
+		t.Errorf("stored %q at version %d with metadata %v; want only the holder's update", stored.Description, stored.Version, stored.Metadata)
+	}
+
+	// Once released, the stale copy is still refused: its version is out of date.
+	if err := holder.Unlock(db, owner.ID, "holder"); err != nil {
+		t.Fatal(err)
+	}
+	if err := stale.UpdateFile(db, owner.ID, "other-session", stale.Version, &File{Description: "theirs"}, nil); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("stale UpdateFile after unlock = %v, want ErrVersionConflict", err)
+	}
+}
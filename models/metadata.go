@@ -0,0 +1,94 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Limits applied to user-supplied file metadata.
+const (
+	MaxMetadataKeyLength   = 64
+	MaxMetadataValueLength = 1024
+	MaxMetadataTotalSize   = 8 * 1024
+)
+
+// reservedMetadataPrefixes are key prefixes kept for the server's own use.
+var reservedMetadataPrefixes = []string{"goshare.", "system."}
+
+// ErrInvalidMetadata wraps every metadata validation failure.
+var ErrInvalidMetadata = errors.New("invalid metadata")
+
+// Metadata holds arbitrary string key-value pairs attached to a file. It is stored as JSON.
+type Metadata map[string]string
+
+// Value implements driver.Valuer.
+func (m Metadata) Value() (driver.Value, error) {
+	if m == nil {
+		return "{}", nil
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan implements sql.Scanner.
+func (m *Metadata) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*m = Metadata{}
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("unsupported metadata type %T", value)
+	}
+	return json.Unmarshal(data, m)
+}
+
+// Validate checks key and value lengths, reserved prefixes, and the total payload size.
+func (m Metadata) Validate() error {
+	total := 0
+	for key, value := range m {
+		if key == "" || len(key) > MaxMetadataKeyLength {
+			return fmt.Errorf("%w: key %q must be 1-%d bytes", ErrInvalidMetadata, key, MaxMetadataKeyLength)
+		}
+		for _, prefix := range reservedMetadataPrefixes {
+			if strings.HasPrefix(strings.ToLower(key), prefix) {
+				return fmt.Errorf("%w: key %q uses reserved prefix %q", ErrInvalidMetadata, key, prefix)
+			}
+		}
+		if len(value) > MaxMetadataValueLength {
+			return fmt.Errorf("%w: value of %q exceeds %d bytes", ErrInvalidMetadata, key, MaxMetadataValueLength)
+		}
+		total += len(key) + len(value)
+	}
+
+	if total > MaxMetadataTotalSize {
+		return fmt.Errorf("%w: total size exceeds %d bytes", ErrInvalidMetadata, MaxMetadataTotalSize)
+	}
+	return nil
+}
+
+// Merge applies a patch: non-nil values set keys and nil values delete them.
+func (m Metadata) Merge(patch map[string]*string) Metadata {
+	merged := make(Metadata, len(m)+len(patch))
+	for key, value := range m {
+		merged[key] = value
+	}
+	for key, value := range patch {
+		if value == nil {
+			delete(merged, key)
+			continue
+		}
+		merged[key] = *value
+	}
+	return merged
+}
@@ -0,0 +1,146 @@
+package models
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestMetadataValidate(t *testing.T) {
+	// fill builds metadata of n keys whose keys and values add up to size bytes each.
+	fill := func(n, size int) Metadata {
+		m := Metadata{}
+		for i := 0; i < n; i++ {
+			key := strings.Repeat(string(rune('a'+i)), MaxMetadataKeyLength)
+			m[key] = strings.Repeat("v", size-len(key))
+		}
+		return m
+	}
+
+	tests := []struct {
+		name     string
+		metadata Metadata
+		valid    bool
+	}{
+		{"empty", Metadata{}, true},
+		{"nil", nil, true},
+		{"empty value", Metadata{"k": ""}, true},
+		{"empty key", Metadata{"": "v"}, false},
+		{"longest key", Metadata{strings.Repeat("k", MaxMetadataKeyLength): "v"}, true},
+		{"key too long", Metadata{strings.Repeat("k", MaxMetadataKeyLength+1): "v"}, false},
+		{"longest value", Metadata{"k": strings.Repeat("v", MaxMetadataValueLength)}, true},
+		{"value too long", Metadata{"k": strings.Repeat("v", MaxMetadataValueLength+1)}, false},
+		{"reserved goshare prefix", Metadata{"goshare.owner": "v"}, false},
+		{"reserved system prefix", Metadata{"system.flag": "v"}, false},
+		{"reserved prefix in another case", Metadata{"GoShare.owner": "v"}, false},
+		{"reserved word without the dot", Metadata{"systematic": "v"}, true},
+		{"reserved word later in the key", Metadata{"my.system.flag": "v"}, true},
+		{"largest total", fill(8, MaxMetadataTotalSize/8), true},
+		{"total too large", fill(8, MaxMetadataTotalSize/8+1), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.metadata.Validate()
+			if tt.valid && err != nil {
+				t.Errorf("Validate = %s, want nil", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidMetadata) {
+				t.Errorf("Validate = %v, want ErrInvalidMetadata", err)
+			}
+		})
+	}
+}
+
+func TestMetadataMerge(t *testing.T) {
+	str := func(s string) *string { return &s }
+	original := Metadata{"keep": "1", "change": "2", "remove": "3"}
+
+	merged := original.Merge(map[string]*string{"change": str("two"), "remove": nil, "missing": nil, "add": str("")})
+	want := Metadata{"keep": "1", "change": "two", "add": ""}
+	if !reflect.DeepEqual(merged, want) {
+		t.Errorf("Merge = %v, want %v", merged, want)
+	}
+	if !reflect.DeepEqual(original, Metadata{"keep": "1", "change": "2", "remove": "3"}) {
+		t.Errorf("Merge changed the original to %v", original)
+	}
+	if merged := Metadata(nil).Merge(map[string]*string{"add": str("1")}); !reflect.DeepEqual(merged, Metadata{"add": "1"}) {
+		t.Errorf("merging into nil metadata = %v", merged)
+	}
+}
+
+// A merge is validated as a whole: a patch that would leave invalid metadata is refused and
+// changes nothing.
+func TestUpdateMetadata(t *testing.T) {
+	db := openTestDB(t)
+	owner := createTestUser(t, db, "owner@example.com")
+	file := createTestFile(t, db, owner, "a.txt", 1)
+	str := func(s string) *string { return &s }
+
+	if err := file.UpdateMetadata(db, owner.ID, "", map[string]*string{"a": str("1"), "b": str("2")}); err != nil {
+		t.Fatalf("UpdateMetadata = %s", err)
+	}
+	if err := file.UpdateMetadata(db, owner.ID, "", map[string]*string{"a": nil, "system.x": str("3")}); !errors.Is(err, ErrInvalidMetadata) {
+		t.Fatalf("reserved key: UpdateMetadata = %v, want ErrInvalidMetadata", err)
+	}
+	if err := file.UpdateMetadata(db, owner.ID, "", map[string]*string{"a": nil}); err != nil {
+		t.Fatalf("UpdateMetadata = %s", err)
+	}
+
+	var stored File
+	if err := db.First(&stored, file.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if want := (Metadata{"b": "2"}); !reflect.DeepEqual(stored.Metadata, want) {
+		t.Errorf("stored metadata = %v, want %v", stored.Metadata, want)
+	}
+	if stored.Version != file.Version {
+		t.Errorf("stored version = %d, want %d", stored.Version, file.Version)
+	}
+}
+
+// The LIKE fallback matches % and _ in a filter literally.
+func TestFilterByMetadataWildcards(t *testing.T) {
+	db := openTestDB(t)
+	owner := createTestUser(t, db, "owner@example.com")
+	files := map[string]Metadata{
+		"percent":    {"discount": "50%"},
+		"digits":     {"discount": "500"},
+		"underscore": {"tag_name": "a_b"},
+		"letters":    {"tagXname": "axb"},
+		"backslash":  {"path": `C:\dir`},
+	}
+	for name, metadata := range files {
+		file := createTestFile(t, db, owner, name, 1)
+		if err := db.Model(file).Update("metadata", metadata).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		filter map[string]string
+		want   []string
+	}{
+		{map[string]string{"discount": "50%"}, []string{"percent"}},
+		{map[string]string{"discount": "5%"}, nil},
+		{map[string]string{"tag_name": "a_b"}, []string{"underscore"}},
+		{map[string]string{"tag_name": "a_b", "discount": "500"}, nil},
+		{map[string]string{"path": `C:\dir`}, []string{"backslash"}},
+		{map[string]string{"discount": "500"}, []string{"digits"}},
+	}
+	for _, tt := range tests {
+		var matched []File
+		if err := FilterByMetadata(db.Model(&File{}), tt.filter).Find(&matched).Error; err != nil {
+			t.Fatalf("%v: %s", tt.filter, err)
+		}
+		var names []string
+		for _, file := range matched {
+			names = append(names, file.Name)
+		}
+		sort.Strings(names)
+		if !reflect.DeepEqual(names, tt.want) {
+			t.Errorf("%v matched %v, want %v", tt.filter, names, tt.want)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// All lists every model with a table, in migration order.
+var All = []interface{}{
+	&User{},
+	&File{},
+	&Comment{},
+	&IdempotencyKey{},
+	&AuditLog{},
+	&Notification{},
+	&NotificationPreference{},
+	&Setting{},
+	&APIUsage{},
+	&APIUsageMonth{},
+	&FileGrant{},
+	&UsedUploadGrant{},
+	&Job{},
+	&Plan{},
+}
+
+// PendingMigrations returns the tables and columns the models define that the database
+// lacks, as "table" or "table.column". It is empty once AutoMigrate has run.
+func PendingMigrations(db *gorm.DB) ([]string, error) {
+	pending := []string{}
+	migrator := db.Migrator()
+	for _, model := range All {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return nil, errors.New("error reading model schema")
+		}
+		table := stmt.Schema.Table
+
+		if !migrator.HasTable(model) {
+			pending = append(pending, table)
+			continue
+		}
+		for _, field := range stmt.Schema.Fields {
+			// Read-only fields filled by joins, such as Comment.AuthorDisplayName, have no column.
+			if field.DBName == "" || field.IgnoreMigration {
+				continue
+			}
+			if !migrator.HasColumn(model, field.DBName) {
+				pending = append(pending, table+"."+field.DBName)
+			}
+		}
+	}
+	return pending, nil
+}
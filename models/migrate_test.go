@@ -0,0 +1,40 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+
+	"go-share/internal/testdb"
+)
+
+func TestPendingMigrations(t *testing.T) {
+	// Read-only fields filled by joins, such as Comment.AuthorDisplayName, are not pending.
+	db := openTestDB(t)
+	if pending, err := PendingMigrations(db); err != nil || len(pending) != 0 {
+		t.Fatalf("after migrating: PendingMigrations = %v, %v, want none", pending, err)
+	}
+
+	if err := db.Migrator().DropColumn(&File{}, "pinned"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Migrator().DropTable(&Plan{}); err != nil {
+		t.Fatal(err)
+	}
+	pending, err := PendingMigrations(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"files.pinned", "plans"}; !reflect.DeepEqual(pending, want) {
+		t.Errorf("PendingMigrations = %v, want %v", pending, want)
+	}
+}
+
+func TestPendingMigrationsEmptyDatabase(t *testing.T) {
+	pending, err := PendingMigrations(testdb.Open(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != len(All) {
+		t.Errorf("PendingMigrations = %v, want every table", pending)
+	}
+}
@@ -0,0 +1,145 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Notification types.
+const (
+	NotificationCommentAdded = "comment.added"
+)
+
+// NotificationTypes lists every type a user can set a preference for.
+var NotificationTypes = []string{NotificationCommentAdded}
+
+// Notification delivery channels.
+const (
+	ChannelInApp = "in_app"
+	ChannelNone  = "none"
+)
+
+// Notification is an entry in a user's in-app notification feed.
+type Notification struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	UserID    uint      `json:"-" gorm:"index:idx_notifications_user_read;not null"`
+	Type      string    `json:"type" gorm:"not null"`
+	Payload   Metadata  `json:"payload" gorm:"type:jsonb;not null;default:'{}'"`
+	Read      bool      `json:"read" gorm:"index:idx_notifications_user_read;not null;default:false"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+}
+
+// NotificationPreference selects how a user is notified about one notification type.
+type NotificationPreference struct {
+	UserID  uint   `json:"-" gorm:"primaryKey"`
+	Type    string `json:"type" gorm:"primaryKey"`
+	Channel string `json:"channel" gorm:"not null"`
+}
+
+// IsNotificationType reports whether t is a known notification type.
+func IsNotificationType(t string) bool {
+	for _, known := range NotificationTypes {
+		if known == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Notify adds a notification to userID's feed unless they opted out of that type.
+func Notify(db *gorm.DB, userID uint, notificationType string, payload Metadata) error {
+	var preference NotificationPreference
+	err := db.Where("user_id = ? AND type = ?", userID, notificationType).First(&preference).Error
+	if err == nil && preference.Channel == ChannelNone {
+		return nil
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return errors.New("error loading notification preferences")
+	}
+
+	notification := Notification{UserID: userID, Type: notificationType, Payload: payload}
+	if err := db.Create(&notification).Error; err != nil {
+		return errors.New("error creating notification")
+	}
+	return nil
+}
+
+// ListNotifications returns a page of a user's notifications, newest first, and the total count.
+func ListNotifications(db *gorm.DB, userID uint, unreadOnly bool, offset, limit int) ([]Notification, int64, error) {
+	query := db.Model(&Notification{}).Where("user_id = ?", userID)
+	if unreadOnly {
+		query = query.Where("read = ?", false)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, errors.New("error counting notifications")
+	}
+
+	notifications := []Notification{}
+	if err := query.Order("created_at DESC, id DESC").Offset(offset).Limit(limit).Find(&notifications).Error; err != nil {
+		return nil, 0, errors.New("error retrieving notifications")
+	}
+	return notifications, total, nil
+}
+
+// MarkNotificationRead marks one of userID's notifications as read.
+func MarkNotificationRead(db *gorm.DB, userID, notificationID uint) error {
+	result := db.Model(&Notification{}).Where("id = ? AND user_id = ?", notificationID, userID).Update("read", true)
+	if result.Error != nil {
+		return errors.New("error updating notification")
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// MarkAllNotificationsRead marks every notification of userID as read.
+func MarkAllNotificationsRead(db *gorm.DB, userID uint) error {
+	if err := db.Model(&Notification{}).Where("user_id = ? AND read = ?", userID, false).Update("read", true).Error; err != nil {
+		return errors.New("error updating notifications")
+	}
+	return nil
+}
+
+// GetNotificationPreferences returns the channel for every notification type, defaulting to in-app.
+func GetNotificationPreferences(db *gorm.DB, userID uint) (map[string]string, error) {
+	var stored []NotificationPreference
+	if err := db.Where("user_id = ?", userID).Find(&stored).Error; err != nil {
+		return nil, errors.New("error loading notification preferences")
+	}
+
+	preferences := make(map[string]string, len(NotificationTypes))
+	for _, t := range NotificationTypes {
+		preferences[t] = ChannelInApp
+	}
+	for _, preference := range stored {
+		preferences[preference.Type] = preference.Channel
+	}
+	return preferences, nil
+}
+
+// SetNotificationPreference stores the channel for one notification type.
+func SetNotificationPreference(db *gorm.DB, userID uint, notificationType, channel string) error {
+	preference := NotificationPreference{UserID: userID, Type: notificationType, Channel: channel}
+	err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "type"}},
+		DoUpdates: clause.AssignmentColumns([]string{"channel"}),
+	}).Create(&preference).Error
+	if err != nil {
+		return errors.New("error saving notification preference")
+	}
+	return nil
+}
+
+// PruneReadNotifications deletes read notifications older than the retention period.
+func PruneReadNotifications(db *gorm.DB, retention time.Duration) error {
+	if err := db.Where("read = ? AND created_at < ?", true, time.Now().Add(-retention)).Delete(&Notification{}).Error; err != nil {
+		return errors.New("error pruning notifications")
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestNotifyOptOut(t *testing.T) {
+	db := openTestDB(t)
+	optedOut := createTestUser(t, db, "opted-out@example.com")
+	optedIn := createTestUser(t, db, "opted-in@example.com")
+	other := createTestUser(t, db, "other@example.com")
+	if err := SetNotificationPreference(db, optedOut.ID, NotificationCommentAdded, ChannelNone); err != nil {
+		t.Fatal(err)
+	}
+	// Opting back in replaces the earlier preference.
+	if err := SetNotificationPreference(db, optedIn.ID, NotificationCommentAdded, ChannelNone); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetNotificationPreference(db, optedIn.ID, NotificationCommentAdded, ChannelInApp); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[uint]int64{optedOut.ID: 0, optedIn.ID: 1, other.ID: 1}
+	for userID := range want {
+		if err := Notify(db, userID, NotificationCommentAdded, Metadata{"file_id": "x"}); err != nil {
+			t.Fatalf("Notify(%d) = %s", userID, err)
+		}
+	}
+	for userID, count := range want {
+		var got int64
+		db.Model(&Notification{}).Where("user_id = ?", userID).Count(&got)
+		if got != count {
+			t.Errorf("user %d has %d notifications, want %d", userID, got, count)
+		}
+	}
+
+	preferences, err := GetNotificationPreferences(db, optedOut.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{NotificationCommentAdded: ChannelNone}; !reflect.DeepEqual(preferences, want) {
+		t.Errorf("preferences = %v, want %v", preferences, want)
+	}
+	if preferences, _ := GetNotificationPreferences(db, other.ID); preferences[NotificationCommentAdded] != ChannelInApp {
+		t.Errorf("default preference = %q, want %q", preferences[NotificationCommentAdded], ChannelInApp)
+	}
+}
+
+func TestListNotifications(t *testing.T) {
+	db := openTestDB(t)
+	user := createTestUser(t, db, "user@example.com")
+	other := createTestUser(t, db, "other@example.com")
+	start := time.Now().Add(-time.Hour)
+	for i := 0; i < 5; i++ {
+		notification := Notification{UserID: user.ID, Type: NotificationCommentAdded, Payload: Metadata{"n": fmt.Sprint(i)},
+			Read: i%2 == 0, CreatedAt: start.Add(time.Duration(i) * time.Minute)}
+		if err := db.Create(&notification).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := Notify(db, other.ID, NotificationCommentAdded, Metadata{"n": "other"}); err != nil {
+		t.Fatal(err)
+	}
+
+	payloads := func(notifications []Notification) []string {
+		var n []string
+		for _, notification := range notifications {
+			n = append(n, notification.Payload["n"])
+		}
+		return n
+	}
+	tests := []struct {
+		unreadOnly    bool
+		offset, limit int
+		want          []string
+		total         int64
+	}{
+		{false, 0, 2, []string{"4", "3"}, 5},
+		{false, 2, 2, []string{"2", "1"}, 5},
+		{false, 4, 2, []string{"0"}, 5},
+		{true, 0, 10, []string{"3", "1"}, 2},
+	}
+	for _, tt := range tests {
+		notifications, total, err := ListNotifications(db, user.ID, tt.unreadOnly, tt.offset, tt.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := payloads(notifications); !reflect.DeepEqual(got, tt.want) || total != tt.total {
+			t.Errorf("unread only %v, offset %d: got %v of %d, want %v of %d", tt.unreadOnly, tt.offset, got, total, tt.want, tt.total)
+		}
+	}
+}
+
+// Users can only mark their own notifications read.
+func TestMarkNotificationReadOwnership(t *testing.T) {
+	db := openTestDB(t)
+	user := createTestUser(t, db, "user@example.com")
+	other := createTestUser(t, db, "other@example.com")
+	for _, userID := range []uint{user.ID, other.ID} {
+		if err := Notify(db, userID, NotificationCommentAdded, Metadata{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var theirs Notification
+	db.Where("user_id = ?", other.ID).First(&theirs)
+
+	if err := MarkNotificationRead(db, user.ID, theirs.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("another user's notification: MarkNotificationRead = %v, want ErrRecordNotFound", err)
+	}
+	if err := MarkAllNotificationsRead(db, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	db.First(&theirs, theirs.ID)
+	if theirs.Read {
+		t.Error("another user's notification was marked read")
+	}
+	if _, unread, _ := ListNotifications(db, user.ID, true, 0, 10); unread != 0 {
+		t.Errorf("%d unread after MarkAllNotificationsRead, want 0", unread)
+	}
+}
@@ -0,0 +1,142 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// Sweepers decide row by row with IsAutoDeletable or in bulk with the AutoDeletable scope; the
+// two must agree for every combination of flags, in the trash or not.
+func TestAutoDeletable(t *testing.T) {
+	tests := []struct {
+		name      string
+		pinned    bool
+		legalHold bool
+		trashed   bool
+		deletable bool
+	}{
+		{"plain", false, false, false, true},
+		{"pinned", true, false, false, false},
+		{"legal hold", false, true, false, false},
+		{"pinned and held", true, true, false, false},
+		{"trashed", false, false, true, true},
+		{"trashed pinned", true, false, true, false},
+		{"trashed held", false, true, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t)
+			owner := createTestUser(t, db, "owner@example.com")
+			file := createTestFile(t, db, owner, "a.txt", 1)
+			if err := db.Model(file).Updates(map[string]interface{}{"pinned": tt.pinned, "legal_hold": tt.legalHold}).Error; err != nil {
+				t.Fatal(err)
+			}
+			if tt.trashed {
+				if err := db.Delete(file).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var stored File
+			if err := db.Unscoped().First(&stored, file.ID).Error; err != nil {
+				t.Fatal(err)
+			}
+			if got := stored.IsAutoDeletable(); got != tt.deletable {
+				t.Errorf("IsAutoDeletable() = %v, want %v", got, tt.deletable)
+			}
+
+			// The trash purge looks at soft-deleted rows, so the scope must work unscoped.
+			var candidates []File
+			if err := db.Unscoped().Scopes(AutoDeletable).Find(&candidates).Error; err != nil {
+				t.Fatal(err)
+			}
+			if got := len(candidates) == 1; got != tt.deletable {
+				t.Errorf("AutoDeletable selected %d files, want deletable = %v", len(candidates), tt.deletable)
+			}
+		})
+	}
+}
+
+func TestSetPinned(t *testing.T) {
+	db := openTestDB(t)
+	owner := createTestUser(t, db, "owner@example.com")
+	other := createTestUser(t, db, "other@example.com")
+	file := createTestFile(t, db, owner, "a.txt", 1)
+
+	if err := file.SetPinned(db, other.ID, "", true); !errors.Is(err, ErrNotFileOwner) {
+		t.Errorf("pinning another user's file: got %v, want ErrNotFileOwner", err)
+	}
+
+	for _, pinned := range []bool{true, false} {
+		if err := file.SetPinned(db, owner.ID, "", pinned); err != nil {
+			t.Fatal(err)
+		}
+		var stored File
+		if err := db.First(&stored, file.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		if stored.Pinned != pinned {
+			t.Errorf("after SetPinned(%v), pinned = %v", pinned, stored.Pinned)
+		}
+		if stored.Version != file.Version {
+			t.Errorf("after SetPinned(%v), stored version = %d, want %d", pinned, stored.Version, file.Version)
+		}
+	}
+	if file.Version != 3 {
+		t.Errorf("version = %d after pinning and unpinning, want 3", file.Version)
+	}
+}
+
+// Pinning is a change like any other: a stale copy of the file or another session's lock
+// refuses it.
+func TestSetPinnedChecksVersionAndLock(t *testing.T) {
+	db := openTestDB(t)
+	owner := createTestUser(t, db, "owner@example.com")
+	file := createTestFile(t, db, owner, "a.txt", 1)
+
+	stale := *file
+	if err := file.UpdateFile(db, owner.ID, "", file.Version, &File{Description: "edited"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := stale.SetPinned(db, owner.ID, "", true); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("stale copy: SetPinned = %v, want ErrVersionConflict", err)
+	}
+
+	if err := file.Lock(db, owner.ID, "holder", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.SetPinned(db, owner.ID, "other-session", true); !errors.Is(err, ErrFileLocked) {
+		t.Errorf("locked by another session: SetPinned = %v, want ErrFileLocked", err)
+	}
+	// A copy loaded before the lock was taken doesn't know about it; the update still checks.
+	unaware := *file
+	unaware.LockedBy, unaware.LockExpiresAt = "", nil
+	if err := unaware.SetPinned(db, owner.ID, "other-session", true); err == nil {
+		t.Error("SetPinned from another session succeeded under the lock")
+	}
+	if err := file.SetPinned(db, owner.ID, "holder", true); err != nil {
+		t.Errorf("SetPinned by the lock holder = %s", err)
+	}
+
+	var stored File
+	db.First(&stored, file.ID)
+	if !stored.Pinned || stored.Version != 3 {
+		t.Errorf("stored pinned = %v at version %d, want pinned at 3", stored.Pinned, stored.Version)
+	}
+}
+
+// Pinning only protects against sweepers: the owner can still delete the file.
+func TestPinnedFileCanBeDeletedByOwner(t *testing.T) {
+	db := openTestDB(t)
+	owner := createTestUser(t, db, "owner@example.com")
+	file := createTestFile(t, db, owner, "a.txt", 1)
+	if err := file.SetPinned(db, owner.ID, "", true); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := file.DeleteFile(db, owner.ID, ""); err != nil {
+		t.Fatalf("deleting a pinned file: %s", err)
+	}
+}
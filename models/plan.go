@@ -0,0 +1,202 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-share/utils"
+	"gorm.io/gorm"
+)
+
+// Plan features that a plan can withhold. A feature a plan doesn't mention is available.
+const (
+	PlanFeatureUploadGrants = "upload_grants"
+)
+
+// KnownPlanFeatures lists every plan feature. Unknown names are rejected by the admin endpoints.
+var KnownPlanFeatures = []string{PlanFeatureUploadGrants}
+
+// Errors returned when an upload or update would break the owner's plan.
+var (
+	ErrFileTooLarge  = errors.New("file is larger than the plan allows")
+	ErrQuotaExceeded = errors.New("storage quota exceeded")
+	ErrPlanNameTaken = errors.New("a plan with this name already exists")
+	ErrInvalidPlan   = errors.New("invalid plan")
+)
+
+// PlanFeatures switches plan features on or off. It is stored as JSON.
+type PlanFeatures map[string]bool
+
+// Value implements driver.Valuer.
+func (f PlanFeatures) Value() (driver.Value, error) {
+	if f == nil {
+		return "{}", nil
+	}
+	b, err := json.Marshal(f)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan implements sql.Scanner.
+func (f *PlanFeatures) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*f = PlanFeatures{}
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("unsupported plan features type %T", value)
+	}
+	return json.Unmarshal(data, f)
+}
+
+// Plan is a class of account with its own limits. Zero limits mean unlimited. Limits are
+// checked when files are stored or grow, never against data already stored, so moving a user
+// to a smaller plan takes effect at once without touching their files.
+type Plan struct {
+	ID          uint         `json:"id" gorm:"primarykey"`
+	Name        string       `json:"name" mapstructure:"name" gorm:"uniqueIndex;not null" validate:"required,max=64"`
+	QuotaBytes  int64        `json:"quota_bytes" mapstructure:"quota_bytes" gorm:"not null;default:0" validate:"gte=0"`
+	MaxFileSize int64        `json:"max_file_size" mapstructure:"max_file_size" gorm:"not null;default:0" validate:"gte=0"`
+	Features    PlanFeatures `json:"features" mapstructure:"features" gorm:"type:jsonb;not null;default:'{}'"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+// Allows reports whether the plan includes the named feature.
+func (p *Plan) Allows(feature string) bool {
+	enabled, ok := p.Features[feature]
+	return !ok || enabled
+}
+
+// Validate checks the plan's fields and features.
+func (p *Plan) Validate() error {
+	if err := utils.ValidateStruct(p); err != nil {
+		return err
+	}
+	for name := range p.Features {
+		if !isKnownPlanFeature(name) {
+			return fmt.Errorf("%w: unknown feature %q", ErrInvalidPlan, name)
+		}
+	}
+	return nil
+}
+
+// checkUsage returns ErrFileTooLarge or ErrQuotaExceeded if userID may not store size bytes
+// in place of replacing bytes. Shrinking is always allowed, so users above their limits can
+// still trim their files.
+func (p *Plan) checkUsage(db *gorm.DB, userID uint, size, replacing int64) error {
+	if p == nil || size <= replacing {
+		return nil
+	}
+	if p.MaxFileSize > 0 && size > p.MaxFileSize {
+		return ErrFileTooLarge
+	}
+	if p.QuotaBytes > 0 {
+		var used int64
+		if err := db.Model(&File{}).Where("user_id = ?", userID).Select("COALESCE(SUM(size), 0)").Scan(&used).Error; err != nil {
+			return errors.New("error computing storage usage")
+		}
+		if used-replacing+size > p.QuotaBytes {
+			return ErrQuotaExceeded
+		}
+	}
+	return nil
+}
+
+// ListPlans returns every plan ordered by name.
+func ListPlans(db *gorm.DB) ([]Plan, error) {
+	plans := []Plan{}
+	if err := db.Order("name").Find(&plans).Error; err != nil {
+		return nil, errors.New("error loading plans")
+	}
+	return plans, nil
+}
+
+// EffectivePlan picks the plan that applies to a user from plans: the assigned plan if
+// planID is set, the plan named defaultName otherwise. A user with neither has no limits.
+func EffectivePlan(plans []Plan, planID *uint, defaultName string) *Plan {
+	for i := range plans {
+		if planID != nil && plans[i].ID == *planID {
+			return &plans[i]
+		}
+	}
+	if planID == nil && defaultName != "" {
+		for i := range plans {
+			if plans[i].Name == defaultName {
+				return &plans[i]
+			}
+		}
+	}
+	return &Plan{}
+}
+
+// SeedPlans creates the given plans unless a plan with the same name exists. Existing plans
+// are left alone so that changes made through the admin endpoints survive restarts.
+func SeedPlans(db *gorm.DB, plans []Plan) error {
+	for _, plan := range plans {
+		if err := plan.Validate(); err != nil {
+			return fmt.Errorf("invalid plan %q: %w", plan.Name, err)
+		}
+		if err := db.Where("name = ?", plan.Name).Attrs(plan).FirstOrCreate(&Plan{}).Error; err != nil {
+			return errors.New("error seeding plans")
+		}
+	}
+	return nil
+}
+
+// CreatePlan stores a new plan and records it in the audit log.
+func (p *Plan) CreatePlan(db *gorm.DB, audit AuditLog) error {
+	audit.Action = "plan.create"
+	return p.save(db, audit, func(tx *gorm.DB) error { return tx.Create(p).Error })
+}
+
+// UpdatePlan stores changes to a plan and records them in the audit log. The new limits apply
+// to the next upload of every user on the plan.
+func (p *Plan) UpdatePlan(db *gorm.DB, audit AuditLog) error {
+	audit.Action = "plan.update"
+	return p.save(db, audit, func(tx *gorm.DB) error {
+		return tx.Model(p).Select("name", "quota_bytes", "max_file_size", "features", "updated_at").Updates(p).Error
+	})
+}
+
+// save validates the plan and writes it with write, together with the audit entry.
+func (p *Plan) save(db *gorm.DB, audit AuditLog, write func(tx *gorm.DB) error) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	audit.Reason = p.Name
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var taken int64
+		if err := tx.Model(&Plan{}).Where("name = ? AND id <> ?", p.Name, p.ID).Count(&taken).Error; err != nil {
+			return errors.New("error checking plan name")
+		}
+		if taken > 0 {
+			return ErrPlanNameTaken
+		}
+		if err := write(tx); err != nil {
+			return errors.New("error saving plan")
+		}
+		return RecordAudit(tx, &audit)
+	})
+}
+
+// isKnownPlanFeature reports whether name is a plan feature.
+func isKnownPlanFeature(name string) bool {
+	for _, known := range KnownPlanFeatures {
+		if known == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckUsage(t *testing.T) {
+	db := openTestDB(t)
+	owner := createTestUser(t, db, "owner@example.com")
+	createTestFile(t, db, owner, "a.txt", 60)
+
+	plan := &Plan{QuotaBytes: 100, MaxFileSize: 50}
+	tests := []struct {
+		name      string
+		plan      *Plan
+		size      int64
+		replacing int64
+		want      error
+	}{
+		{"no plan", nil, 1000, 0, nil},
+		{"unlimited plan", &Plan{}, 1000, 0, nil},
+		{"largest file allowed", plan, 40, 0, nil},
+		{"file too large", plan, 51, 0, ErrFileTooLarge},
+		{"quota reached exactly", plan, 40, 0, nil},
+		{"quota exceeded", plan, 41, 0, ErrQuotaExceeded},
+		{"replacement within quota", plan, 50, 40, nil},
+		{"replacement growing past quota", plan, 50, 9, ErrQuotaExceeded},
+		{"shrinking a file larger than allowed", plan, 55, 60, nil},
+	}
+	for _, tt := range tests {
+		if got := tt.plan.checkUsage(db, owner.ID, tt.size, tt.replacing); !errors.Is(got, tt.want) {
+			t.Errorf("%s: checkUsage = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEffectivePlan(t *testing.T) {
+	plans := []Plan{{ID: 1, Name: "free"}, {ID: 2, Name: "pro"}}
+	pro, missing := uint(2), uint(3)
+
+	tests := []struct {
+		name        string
+		planID      *uint
+		defaultName string
+		want        string
+	}{
+		{"assigned plan", &pro, "free", "pro"},
+		{"default plan", nil, "free", "free"},
+		{"no default", nil, "", ""},
+		{"unknown default", nil, "enterprise", ""},
+		{"deleted plan", &missing, "free", ""},
+	}
+	for _, tt := range tests {
+		if got := EffectivePlan(plans, tt.planID, tt.defaultName); got.Name != tt.want {
+			t.Errorf("%s: got plan %q, want %q", tt.name, got.Name, tt.want)
+		}
+	}
+}
+
+func TestPlanAllows(t *testing.T) {
+	tests := []struct {
+		features PlanFeatures
+		want     bool
+	}{
+		{nil, true},
+		{PlanFeatures{PlanFeatureUploadGrants: true}, true},
+		{PlanFeatures{PlanFeatureUploadGrants: false}, false},
+	}
+	for _, tt := range tests {
+		plan := Plan{Features: tt.features}
+		if got := plan.Allows(PlanFeatureUploadGrants); got != tt.want {
+			t.Errorf("features %v: Allows = %v, want %v", tt.features, got, tt.want)
+		}
+	}
+}
+
+// Seeding creates missing plans and leaves those an admin changed alone.
+func TestSeedPlans(t *testing.T) {
+	db := openTestDB(t)
+	if err := SeedPlans(db, []Plan{{Name: "free", QuotaBytes: 100}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Model(&Plan{}).Where("name = ?", "free").Update("quota_bytes", 200).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := SeedPlans(db, []Plan{{Name: "free", QuotaBytes: 100}, {Name: "pro"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	plans, err := ListPlans(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plans) != 2 || plans[0].Name != "free" || plans[0].QuotaBytes != 200 || plans[1].Name != "pro" {
+		t.Errorf("plans after reseeding: %+v", plans)
+	}
+
+	if err := SeedPlans(db, []Plan{{Name: "odd", Features: PlanFeatures{"teleport": true}}}); !errors.Is(err, ErrInvalidPlan) {
+		t.Errorf("seeding an unknown feature: got %v, want ErrInvalidPlan", err)
+	}
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+// A provider login with the email of a password account signs into that account. The password
+// keeps working and a provider linked earlier is not replaced.
+func TestFindOrCreateProviderUserLinks(t *testing.T) {
+	db := openTestDB(t)
+	existing := createTestUser(t, db, "ada@example.com")
+
+	user, err := FindOrCreateProviderUser(db, "ada@example.com", "google", false)
+	if err != nil {
+		t.Fatalf("FindOrCreateProviderUser = %s", err)
+	}
+	if user.ID != existing.ID {
+		t.Fatalf("got user %d, want the existing user %d", user.ID, existing.ID)
+	}
+	var stored User
+	if err := db.First(&stored, existing.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.AuthProvider != "google" {
+		t.Errorf("auth provider = %q, want google", stored.AuthProvider)
+	}
+	if stored.Password != existing.Password {
+		t.Error("linking changed the password hash")
+	}
+
+	if _, err := FindOrCreateProviderUser(db, "ada@example.com", "github", true); err != nil {
+		t.Fatalf("FindOrCreateProviderUser = %s", err)
+	}
+	db.First(&stored, existing.ID)
+	if stored.AuthProvider != "google" {
+		t.Errorf("auth provider = %q after a github login, want google", stored.AuthProvider)
+	}
+
+	var users int64
+	db.Model(&User{}).Count(&users)
+	if users != 1 {
+		t.Errorf("%d users, want 1", users)
+	}
+}
+
+func TestFindOrCreateProviderUserCreates(t *testing.T) {
+	db := openTestDB(t)
+
+	if _, err := FindOrCreateProviderUser(db, "new@example.com", "github", false); !errors.Is(err, ErrRegistrationClosed) {
+		t.Errorf("registration closed: err = %v, want ErrRegistrationClosed", err)
+	}
+
+	user, err := FindOrCreateProviderUser(db, "new@example.com", "github", true)
+	if err != nil {
+		t.Fatalf("FindOrCreateProviderUser = %s", err)
+	}
+	if user.AuthProvider != "github" || user.Password != "" {
+		t.Errorf("created %+v, want a github account without a password", user)
+	}
+}
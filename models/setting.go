@@ -0,0 +1,53 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Setting is a runtime setting that must survive restarts and be shared between instances.
+type Setting struct {
+	Key       string `gorm:"primaryKey;size:64"`
+	Value     string `gorm:"not null"`
+	UpdatedAt time.Time
+}
+
+// GetSetting returns the stored value for key, or "" if it has never been set.
+func GetSetting(db *gorm.DB, key string) (string, error) {
+	var setting Setting
+	err := db.Where("key = ?", key).Limit(1).Find(&setting).Error
+	if err != nil {
+		return "", errors.New("error loading setting")
+	}
+	return setting.Value, nil
+}
+
+// SetSetting stores value under key, replacing any previous value.
+func SetSetting(db *gorm.DB, key, value string) error {
+	setting := Setting{Key: key, Value: value}
+	err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"}),
+	}).Create(&setting).Error
+	if err != nil {
+		return errors.New("error saving setting")
+	}
+	return nil
+}
+
+// ListSettings returns every setting whose key starts with prefix.
+func ListSettings(db *gorm.DB, prefix string) (map[string]string, error) {
+	var settings []Setting
+	if err := db.Where("key LIKE ?", prefix+"%").Find(&settings).Error; err != nil {
+		return nil, errors.New("error loading settings")
+	}
+
+	values := make(map[string]string, len(settings))
+	for _, setting := range settings {
+		values[setting.Key] = setting.Value
+	}
+	return values, nil
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Stats holds the aggregate figures shown on the admin dashboard.
+type Stats struct {
+	TotalUsers     int64       `json:"total_users"`
+	ActiveUsers30d int64       `json:"active_users_30d"`
+	TotalFiles     int64       `json:"total_files"`
+	TotalBytes     int64       `json:"total_bytes"`
+	Uploads24h     int64       `json:"uploads_24h"`
+	Uploads7d      int64       `json:"uploads_7d"`
+	TrashFiles     int64       `json:"trash_files"`
+	TrashBytes     int64       `json:"trash_bytes"`
+	PinnedFiles    int64       `json:"pinned_files"`
+	PinnedBytes    int64       `json:"pinned_bytes"`
+	TopUsers       []UserUsage `json:"top_users"`
+	// ByCategory breaks the live files down by Category, as derived by FileCategory.
+	ByCategory  []CategoryUsage `json:"by_category"`
+	GeneratedAt time.Time       `json:"generated_at"`
+}
+
+// CategoryUsage is the storage used by the files of one category.
+type CategoryUsage struct {
+	Category string `json:"category"`
+	Files    int64  `json:"files"`
+	Bytes    int64  `json:"bytes"`
+}
+
+// UserUsage is the storage used by a single user.
+type UserUsage struct {
+	UserID      uint   `json:"user_id"`
+	Email       string `json:"email"`
+	Files       int64  `json:"files"`
+	Bytes       int64  `json:"bytes"`
+	PinnedBytes int64  `json:"pinned_bytes"`
+}
+
+// CollectStats computes the dashboard figures using aggregate queries only.
+func CollectStats(db *gorm.DB) (*Stats, error) {
+	now := time.Now()
+	stats := &Stats{GeneratedAt: now}
+
+	if err := db.Model(&User{}).Count(&stats.TotalUsers).Error; err != nil {
+		return nil, errors.New("error counting users")
+	}
+
+	if err := db.Model(&User{}).Where("last_login_at >= ?", now.AddDate(0, 0, -30)).Count(&stats.ActiveUsers30d).Error; err != nil {
+		return nil, errors.New("error counting active users")
+	}
+
+	err := db.Model(&File{}).
+		Select("COUNT(*), COALESCE(SUM(size), 0), COUNT(CASE WHEN created_at >= ? THEN 1 END), COUNT(CASE WHEN created_at >= ? THEN 1 END)",
+			now.Add(-24*time.Hour), now.AddDate(0, 0, -7)).
+		Row().
+		Scan(&stats.TotalFiles, &stats.TotalBytes, &stats.Uploads24h, &stats.Uploads7d)
+	if err != nil {
+		return nil, errors.New("error aggregating files")
+	}
+
+	// Soft-deleted rows stay in the table until purged, so they make up the trash.
+	err = db.Unscoped().Model(&File{}).
+		Where("deleted_at IS NOT NULL").
+		Select("COUNT(*), COALESCE(SUM(size), 0)").
+		Row().
+		Scan(&stats.TrashFiles, &stats.TrashBytes)
+	if err != nil {
+		return nil, errors.New("error aggregating trash")
+	}
+
+	err = db.Model(&File{}).
+		Where("pinned = ?", true).
+		Select("COUNT(*), COALESCE(SUM(size), 0)").
+		Row().
+		Scan(&stats.PinnedFiles, &stats.PinnedBytes)
+	if err != nil {
+		return nil, errors.New("error aggregating pinned files")
+	}
+
+	err = db.Model(&File{}).
+		Select("files.user_id, users.email, COUNT(*) AS files, COALESCE(SUM(files.size), 0) AS bytes, " +
+			"COALESCE(SUM(CASE WHEN files.pinned THEN files.size END), 0) AS pinned_bytes").
+		Joins("JOIN users ON users.id = files.user_id").
+		Group("files.user_id, users.email").
+		Order("bytes DESC").
+		Limit(10).
+		Scan(&stats.TopUsers).Error
+	if err != nil {
+		return nil, errors.New("error aggregating top users")
+	}
+
+	err = db.Model(&File{}).
+		Select("category, COUNT(*) AS files, COALESCE(SUM(size), 0) AS bytes").
+		Where("category <> ''").
+		Group("category").
+		Order("bytes DESC").
+		Scan(&stats.ByCategory).Error
+	if err != nil {
+		return nil, errors.New("error aggregating categories")
+	}
+
+	return stats, nil
+}
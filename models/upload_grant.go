@@ -0,0 +1,49 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrUploadGrantUsed is returned when an upload grant has already been used.
+var ErrUploadGrantUsed = errors.New("upload grant has already been used")
+
+// UsedUploadGrant records an upload grant that was used, so it can't be used again. Rows
+// are kept until the grant would have expired anyway.
+type UsedUploadGrant struct {
+	GrantID   string    `gorm:"primaryKey;size:64"`
+	ExpiresAt time.Time `gorm:"index;not null"`
+	CreatedAt time.Time
+}
+
+// ConsumeUploadGrant marks a grant as used, or returns ErrUploadGrantUsed if it already was.
+func ConsumeUploadGrant(db *gorm.DB, grantID string, expiresAt time.Time) error {
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&UsedUploadGrant{GrantID: grantID, ExpiresAt: expiresAt})
+	if result.Error != nil {
+		return errors.New("error recording upload grant")
+	}
+	if result.RowsAffected == 0 {
+		return ErrUploadGrantUsed
+	}
+	return nil
+}
+
+// ReleaseUploadGrant makes a consumed grant usable again after the upload it was consumed
+// for failed.
+func ReleaseUploadGrant(db *gorm.DB, grantID string) error {
+	if err := db.Where("grant_id = ?", grantID).Delete(&UsedUploadGrant{}).Error; err != nil {
+		return errors.New("error releasing upload grant")
+	}
+	return nil
+}
+
+// PruneUsedUploadGrants deletes the records of grants that have expired.
+func PruneUsedUploadGrants(db *gorm.DB) error {
+	if err := db.Where("expires_at <= ?", time.Now()).Delete(&UsedUploadGrant{}).Error; err != nil {
+		return errors.New("error pruning used upload grants")
+	}
+	return nil
+}
@@ -3,18 +3,46 @@ package models
 import (
 	"errors"
 	"go-share/utils"
+	"strings"
+	"time"
+	"unicode"
+
 	"gorm.io/gorm"
 )
 
 // User represents a user in the system.
 type User struct {
-	gorm.Model
+	Base
 	Email    string `gorm:"uniqueIndex" json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required,min=8"`
+
+	DisplayName string `json:"display_name" validate:"max=64"`
+	// AuthProvider names the external identity provider an account was created through
+	// ("google", "github"). Provider-only accounts have no password.
+	AuthProvider string `json:"-"`
+
+	IsAdmin     bool       `json:"-" gorm:"not null;default:false"`
+	LastLoginAt *time.Time `json:"-"`
+	// FileCount is maintained alongside file creates and deletes to avoid COUNT on hot paths.
+	FileCount int64 `json:"-" gorm:"not null;default:0"`
+	// PlanID is the user's plan. Nil means the plan named by plans.default.
+	PlanID *uint `json:"-" gorm:"index"`
+
+	// SuspendedAt is set while the account is suspended. Suspended users can still sign in,
+	// read and delete their files, but cannot upload. SuspendedUntil ends a time-boxed suspension.
+	SuspendedAt      *time.Time `json:"-"`
+	SuspendedUntil   *time.Time `json:"-"`
+	SuspensionReason string     `json:"-"`
 }
 
-// CreateUser creates a new user with a hashed password.
+// CreateUser creates a new user with a hashed password. The display name is sanitized like
+// UpdateDisplayName does, then the user is validated before anything is hashed or stored.
 func (u *User) CreateUser(db *gorm.DB) error {
+	u.DisplayName = SanitizeDisplayName(u.DisplayName)
+	if err := utils.ValidateStruct(u); err != nil {
+		return err
+	}
+
 	hashedPassword, err := utils.HashPassword(u.Password)
 	if err != nil {
 		return err
@@ -22,21 +50,266 @@ func (u *User) CreateUser(db *gorm.DB) error {
 	u.Password = string(hashedPassword)
 
 	if err := db.Create(&u).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return ErrEmailUnavailable
+		}
 		return errors.New("error creating user")
 	}
 	return nil
 }
 
+// ErrInvalidCredentials is returned for every failed password login, whether or not the
+// account exists, so the response can't be used to discover registered emails.
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// ErrEmailUnavailable is returned when registering an email that already has an account.
+var ErrEmailUnavailable = errors.New("this email cannot be used to register")
+
+// timingEqualizerHash is compared against when there is no real hash to check, so failed
+// logins for unknown and provider-only accounts take as long as a wrong password. It must
+// use bcrypt.DefaultCost like stored hashes.
+const timingEqualizerHash = "$2a$10$kiJ/CcHrWTI4VEW1MyEl2OnVg4CIm73Qe9E/rswwIG8YAUyrdUBDG"
+
 // ValidateUserCredentials checks if the provided email and password match an existing user.
+// Every mismatch returns ErrInvalidCredentials after one bcrypt comparison.
 func (u *User) ValidateUserCredentials(db *gorm.DB) (*User, error) {
 	var foundUser User
-	if err := db.Where("email = ?", u.Email).First(&foundUser).Error; err != nil {
-		return nil, errors.New("user not found")
+	err := db.Where("email = ?", u.Email).First(&foundUser).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.New("error loading user")
 	}
 
-	if err := utils.ComparePassword(u.Password, foundUser.Password); err != nil {
-		return nil, errors.New("invalid password")
+	// Provider-only accounts have no password and can never log in this way.
+	if err != nil || foundUser.Password == "" {
+		utils.ComparePassword(timingEqualizerHash, u.Password)
+		return nil, ErrInvalidCredentials
+	}
+
+	if err := utils.ComparePassword(foundUser.Password, u.Password); err != nil {
+		return nil, ErrInvalidCredentials
 	}
 
 	return &foundUser, nil
+}
+
+// RecordLogin stores the time of the user's latest successful login.
+func (u *User) RecordLogin(db *gorm.DB) error {
+	now := time.Now()
+	if err := db.Model(u).Update("last_login_at", now).Error; err != nil {
+		return errors.New("error recording login")
+	}
+	u.LastLoginAt = &now
+	return nil
+}
+
+// UserProfile is the public representation of a user. It never includes credentials.
+type UserProfile struct {
+	ID          uint      `json:"id"`
+	Email       string    `json:"email"`
+	DisplayName string    `json:"display_name"`
+	CreatedAt   time.Time `json:"created_at"`
+
+	Suspension *SuspensionNotice `json:"suspension,omitempty"`
+}
+
+// SuspensionNotice tells a suspended user why and until when.
+type SuspensionNotice struct {
+	Reason      string     `json:"reason"`
+	SuspendedAt time.Time  `json:"suspended_at"`
+	Until       *time.Time `json:"until,omitempty"`
+}
+
+// Profile returns the user's public representation.
+func (u *User) Profile() UserProfile {
+	profile := UserProfile{ID: u.ID, Email: u.Email, DisplayName: u.DisplayName, CreatedAt: u.CreatedAt}
+	if u.IsSuspended() {
+		profile.Suspension = &SuspensionNotice{Reason: u.SuspensionReason, SuspendedAt: *u.SuspendedAt, Until: u.SuspendedUntil}
+	}
+	return profile
+}
+
+// IsActive reports whether the account is neither deleted nor suspended. Other users can only
+// reach an owner's files, through grants or download links, while it is active.
+func (u *User) IsActive() bool {
+	return !u.DeletedAt.Valid && !u.IsSuspended()
+}
+
+// ActiveOwnerCondition is IsActive as SQL, for files queries: it holds when the file's owner is
+// active. Its one argument is the current time.
+const ActiveOwnerCondition = "EXISTS (SELECT 1 FROM users WHERE users.id = files.user_id AND users.deleted_at IS NULL " +
+	"AND (users.suspended_at IS NULL OR users.suspended_until <= ?))"
+
+// IsSuspended reports whether the account is currently suspended.
+func (u *User) IsSuspended() bool {
+	return u.SuspendedAt != nil && (u.SuspendedUntil == nil || time.Now().Before(*u.SuspendedUntil))
+}
+
+// Suspend suspends the account, indefinitely when until is nil, and records the decision
+// in the audit log. audit carries the actor, reason and client IP.
+func (u *User) Suspend(db *gorm.DB, until *time.Time, audit AuditLog) error {
+	now := time.Now()
+	audit.Action = "user.suspend"
+	audit.TargetUserID = &u.ID
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(u).Updates(map[string]interface{}{
+			"suspended_at":      now,
+			"suspended_until":   until,
+			"suspension_reason": audit.Reason,
+		}).Error
+		if err != nil {
+			return errors.New("error suspending user")
+		}
+		return RecordAudit(tx, &audit)
+	})
+	if err != nil {
+		return err
+	}
+
+	u.SuspendedAt, u.SuspendedUntil, u.SuspensionReason = &now, until, audit.Reason
+	return nil
+}
+
+// AssignPlan moves the user to the given plan, or back to the default plan when planID is nil,
+// and records the change in the audit log. Files already stored are kept even if they exceed
+// the new plan's limits.
+func (u *User) AssignPlan(db *gorm.DB, planID *uint, audit AuditLog) error {
+	audit.Action = "user.plan"
+	audit.TargetUserID = &u.ID
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if planID != nil {
+			var count int64
+			if err := tx.Model(&Plan{}).Where("id = ?", *planID).Count(&count).Error; err != nil {
+				return errors.New("error loading plan")
+			}
+			if count == 0 {
+				return gorm.ErrRecordNotFound
+			}
+		}
+		if err := tx.Model(u).Update("plan_id", planID).Error; err != nil {
+			return errors.New("error assigning plan")
+		}
+		return RecordAudit(tx, &audit)
+	})
+	if err != nil {
+		return err
+	}
+
+	u.PlanID = planID
+	return nil
+}
+
+// Reinstate lifts a suspension and records the decision in the audit log.
+func (u *User) Reinstate(db *gorm.DB, audit AuditLog) error {
+	audit.Action = "user.reinstate"
+	audit.TargetUserID = &u.ID
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(u).Updates(map[string]interface{}{
+			"suspended_at":      nil,
+			"suspended_until":   nil,
+			"suspension_reason": "",
+		}).Error; err != nil {
+			return errors.New("error reinstating user")
+		}
+		return RecordAudit(tx, &audit)
+	})
+	if err != nil {
+		return err
+	}
+
+	u.SuspendedAt, u.SuspendedUntil, u.SuspensionReason = nil, nil, ""
+	return nil
+}
+
+// LiftExpiredSuspensions clears time-boxed suspensions that have ended.
+func LiftExpiredSuspensions(db *gorm.DB) error {
+	err := db.Model(&User{}).
+		Where("suspended_until IS NOT NULL AND suspended_until <= ?", time.Now()).
+		Updates(map[string]interface{}{"suspended_at": nil, "suspended_until": nil, "suspension_reason": ""}).Error
+	if err != nil {
+		return errors.New("error lifting expired suspensions")
+	}
+	return nil
+}
+
+// SanitizeDisplayName removes control characters and collapses runs of whitespace.
+func SanitizeDisplayName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, name)
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// setDisplayName sanitizes name and sets it as the display name if it is short enough. Only
+// the name is validated: provider-only accounts have no password, which would fail the User
+// rules.
+func (u *User) setDisplayName(name string) error {
+	name = SanitizeDisplayName(name)
+	if err := utils.ValidateVar(name, "max=64"); err != nil {
+		return err
+	}
+	u.DisplayName = name
+	return nil
+}
+
+// UpdateDisplayName validates and stores a new display name. An empty name clears it.
+func (u *User) UpdateDisplayName(db *gorm.DB, name string) error {
+	if err := u.setDisplayName(name); err != nil {
+		return err
+	}
+
+	if err := db.Model(u).Update("display_name", u.DisplayName).Error; err != nil {
+		return errors.New("error updating display name")
+	}
+	return nil
+}
+
+// RecalculateFileCounts rebuilds every user's file counter from the files table and
+// returns the number of users whose counter had drifted.
+func RecalculateFileCounts(db *gorm.DB) (int64, error) {
+	actual := "(SELECT COUNT(*) FROM files WHERE files.user_id = users.id AND files.deleted_at IS NULL)"
+	result := db.Model(&User{}).
+		Where("file_count <> " + actual).
+		Update("file_count", gorm.Expr(actual))
+	if result.Error != nil {
+		return 0, errors.New("error recalculating file counts")
+	}
+	return result.RowsAffected, nil
+}
+
+// ErrRegistrationClosed is returned when signing in would create an account while
+// registration is disabled.
+var ErrRegistrationClosed = errors.New("registration is closed")
+
+// FindOrCreateProviderUser returns the user with the given verified email, creating a
+// provider-only account (without a password) if none exists and allowCreate is set.
+// Existing accounts are linked by email and keep their password.
+func FindOrCreateProviderUser(db *gorm.DB, email, provider string, allowCreate bool) (*User, error) {
+	var user User
+	err := db.Where("email = ?", email).First(&user).Error
+	if err == nil {
+		if user.AuthProvider == "" {
+			if err := db.Model(&user).Update("auth_provider", provider).Error; err != nil {
+				return nil, errors.New("error linking user")
+			}
+		}
+		return &user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.New("error loading user")
+	}
+	if !allowCreate {
+		return nil, ErrRegistrationClosed
+	}
+
+	user = User{Email: email, AuthProvider: provider}
+	if err := db.Create(&user).Error; err != nil {
+		return nil, errors.New("error creating user")
+	}
+	return &user, nil
 }
\ No newline at end of file
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"go-share/utils"
+)
+
+func TestUpdateDisplayName(t *testing.T) {
+	db := openTestDB(t)
+	passwordUser := createTestUser(t, db, "password@example.com")
+	providerUser, err := FindOrCreateProviderUser(db, "provider@example.com", "github", true)
+	if err != nil {
+		t.Fatalf("creating provider user: %s", err)
+	}
+
+	for _, user := range []*User{passwordUser, providerUser} {
+		t.Run(user.Email, func(t *testing.T) {
+			if err := user.UpdateDisplayName(db, "  Ada \t Lovelace\x00 "); err != nil {
+				t.Fatalf("UpdateDisplayName = %s", err)
+			}
+
+			var stored User
+			if err := db.First(&stored, user.ID).Error; err != nil {
+				t.Fatal(err)
+			}
+			if stored.DisplayName != "Ada Lovelace" {
+				t.Errorf("display name = %q, want %q", stored.DisplayName, "Ada Lovelace")
+			}
+
+			if err := user.UpdateDisplayName(db, strings.Repeat("x", 65)); !utils.IsValidationError(err) {
+				t.Errorf("65-character name: UpdateDisplayName = %v, want a validation error", err)
+			}
+			if err := user.UpdateDisplayName(db, strings.Repeat("x", 64)); err != nil {
+				t.Errorf("64-character name: UpdateDisplayName = %s", err)
+			}
+		})
+	}
+}
+
+func TestCreateUserDisplayName(t *testing.T) {
+	db := openTestDB(t)
+
+	user := &User{Email: "ada@example.com", Password: "correct horse", DisplayName: "\tAda\n\nLovelace "}
+	if err := user.CreateUser(db); err != nil {
+		t.Fatalf("CreateUser = %s", err)
+	}
+	var stored User
+	if err := db.First(&stored, user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.DisplayName != "Ada Lovelace" {
+		t.Errorf("display name = %q, want %q", stored.DisplayName, "Ada Lovelace")
+	}
+
+	tooLong := &User{Email: "long@example.com", Password: "correct horse", DisplayName: strings.Repeat("x", 65)}
+	if err := tooLong.CreateUser(db); !utils.IsValidationError(err) {
+		t.Fatalf("CreateUser with a 65-character name = %v, want a validation error", err)
+	}
+	var count int64
+	if err := db.Model(&User{}).Where("email = ?", tooLong.Email).Count(&count).Error; err != nil || count != 0 {
+		t.Errorf("user with a rejected name was stored (count %d, err %v)", count, err)
+	}
+}
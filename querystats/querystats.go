@@ -0,0 +1,215 @@
+// Package querystats counts database queries per request so that slow endpoints and N+1
+// query patterns show up in logs and in /admin/runtime before they reach production.
+//
+// The GORM plugin attributes a query to a request through the statement's context, so only
+// queries issued with db.WithContext(r.Context()) are counted against a request. Every query
+// is counted in the process-wide histograms.
+package querystats
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Recorder accumulates the queries made on behalf of one request.
+type Recorder struct {
+	queries atomic.Int64
+	nanos   atomic.Int64
+}
+
+// Queries returns the number of queries recorded.
+func (r *Recorder) Queries() int64 {
+	return r.queries.Load()
+}
+
+// Duration returns the total time spent in the recorded queries.
+func (r *Recorder) Duration() time.Duration {
+	return time.Duration(r.nanos.Load())
+}
+
+type recorderKey struct{}
+
+// WithRecorder returns a context that collects query statistics into the returned Recorder.
+func WithRecorder(ctx context.Context) (context.Context, *Recorder) {
+	rec := &Recorder{}
+	return context.WithValue(ctx, recorderKey{}, rec), rec
+}
+
+// FromContext returns the Recorder attached to ctx, or nil.
+func FromContext(ctx context.Context) *Recorder {
+	rec, _ := ctx.Value(recorderKey{}).(*Recorder)
+	return rec
+}
+
+// Plugin is a GORM plugin that times every statement.
+type Plugin struct {
+	// SlowQuery is the duration above which a statement is logged. Zero disables the log.
+	SlowQuery time.Duration
+}
+
+// Name implements gorm.Plugin.
+func (p *Plugin) Name() string {
+	return "querystats"
+}
+
+const startKey = "querystats:start"
+
+// Initialize implements gorm.Plugin.
+func (p *Plugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	errs := []error{
+		cb.Create().Before("gorm:create").Register("querystats:before_create", start),
+		cb.Create().After("gorm:create").Register("querystats:after_create", p.finish),
+		cb.Query().Before("gorm:query").Register("querystats:before_query", start),
+		cb.Query().After("gorm:query").Register("querystats:after_query", p.finish),
+		cb.Update().Before("gorm:update").Register("querystats:before_update", start),
+		cb.Update().After("gorm:update").Register("querystats:after_update", p.finish),
+		cb.Delete().Before("gorm:delete").Register("querystats:before_delete", start),
+		cb.Delete().After("gorm:delete").Register("querystats:after_delete", p.finish),
+		cb.Row().Before("gorm:row").Register("querystats:before_row", start),
+		cb.Row().After("gorm:row").Register("querystats:after_row", p.finish),
+		cb.Raw().Before("gorm:raw").Register("querystats:before_raw", start),
+		cb.Raw().After("gorm:raw").Register("querystats:after_raw", p.finish),
+	}
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// start records when a statement began.
+func start(db *gorm.DB) {
+	db.InstanceSet(startKey, time.Now())
+}
+
+// finish records a statement's duration and logs it if it was slow.
+func (p *Plugin) finish(db *gorm.DB) {
+	value, ok := db.InstanceGet(startKey)
+	if !ok {
+		return
+	}
+	elapsed := time.Since(value.(time.Time))
+
+	queryDurations.observe(float64(elapsed) / float64(time.Millisecond))
+	if rec := FromContext(db.Statement.Context); rec != nil {
+		rec.queries.Add(1)
+		rec.nanos.Add(int64(elapsed))
+	}
+	if p.SlowQuery > 0 && elapsed > p.SlowQuery {
+		log.Printf("Slow query (%s, %d rows): %s", elapsed, db.RowsAffected, db.Statement.SQL.String())
+	}
+}
+
+// ObserveRequest adds a finished request's query count to the per-request histogram and
+// passes the request's Recorder to the functions registered with OnRequest.
+func ObserveRequest(rec *Recorder) {
+	requestQueries.observe(float64(rec.Queries()))
+
+	requestHooks.Lock()
+	hooks := make([]func(*Recorder), 0, len(requestHooks.fns))
+	for _, fn := range requestHooks.fns {
+		hooks = append(hooks, fn)
+	}
+	requestHooks.Unlock()
+	for _, fn := range hooks {
+		fn(rec)
+	}
+}
+
+// requestHooks holds the functions registered with OnRequest, by registration number.
+var requestHooks struct {
+	sync.Mutex
+	next int
+	fns  map[int]func(*Recorder)
+}
+
+// OnRequest registers fn to be called with the Recorder of every request that finishes from
+// now on, and returns a function that unregisters it. Tests use it to bound the queries made
+// by an endpoint; see the asserts package.
+func OnRequest(fn func(*Recorder)) (remove func()) {
+	requestHooks.Lock()
+	defer requestHooks.Unlock()
+
+	if requestHooks.fns == nil {
+		requestHooks.fns = map[int]func(*Recorder){}
+	}
+	id := requestHooks.next
+	requestHooks.next++
+	requestHooks.fns[id] = fn
+
+	return func() {
+		requestHooks.Lock()
+		defer requestHooks.Unlock()
+		delete(requestHooks.fns, id)
+	}
+}
+
+// Bucket is one bucket of a cumulative histogram: Count observations were at most UpperBound.
+// The last bucket has no upper bound and counts every observation.
+type Bucket struct {
+	UpperBound float64 `json:"le,omitempty"`
+	Count      uint64  `json:"count"`
+}
+
+// Stats summarizes the queries made since the process started.
+type Stats struct {
+	// QueryDurationsMs is a histogram of statement durations in milliseconds.
+	QueryDurationsMs []Bucket `json:"query_durations_ms"`
+	// QueriesPerRequest is a histogram of how many queries each request made.
+	QueriesPerRequest []Bucket `json:"queries_per_request"`
+}
+
+// GetStats returns the process-wide query histograms.
+func GetStats() Stats {
+	return Stats{QueryDurationsMs: queryDurations.snapshot(), QueriesPerRequest: requestQueries.snapshot()}
+}
+
+var (
+	queryDurations = newHistogram(1, 5, 10, 25, 50, 100, 250, 500, 1000)
+	requestQueries = newHistogram(1, 2, 5, 10, 20, 50, 100)
+)
+
+// histogram is a fixed-bucket histogram safe for concurrent use.
+type histogram struct {
+	mu     sync.Mutex
+	bounds []float64
+	counts []uint64 // counts[i] observations fell in bucket i; the last is unbounded
+}
+
+func newHistogram(bounds ...float64) *histogram {
+	return &histogram{bounds: bounds, counts: make([]uint64, len(bounds)+1)}
+}
+
+func (h *histogram) observe(value float64) {
+	i := 0
+	for i < len(h.bounds) && value > h.bounds[i] {
+		i++
+	}
+	h.mu.Lock()
+	h.counts[i]++
+	h.mu.Unlock()
+}
+
+// snapshot returns the histogram as cumulative buckets.
+func (h *histogram) snapshot() []Bucket {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	buckets := make([]Bucket, len(h.counts))
+	var total uint64
+	for i, count := range h.counts {
+		total += count
+		buckets[i].Count = total
+		if i < len(h.bounds) {
+			buckets[i].UpperBound = h.bounds[i]
+		}
+	}
+	return buckets
+}
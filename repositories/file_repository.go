@@ -26,25 +26,35 @@ func (fr *FileRepository) CreateFile(file *models.File) error {
 	return nil
 }
 
-// GetFiles retrieves all files (for now - pagination/filtering should be added).
-func (fr *FileRepository) GetFiles() ([]models.File, error) {
+// GetFiles retrieves the files visible to a user (for now - pagination/filtering should be added).
+func (fr *FileRepository) GetFiles(userID uint) ([]models.File, error) {
 	var files []models.File
-	if err := fr.DB.Find(&files).Error; err != nil {
+	if err := fr.DB.Scopes(VisibleTo(userID, VisibilityOptions{})).Find(&files).Error; err != nil {
 		return nil, errors.New("error retrieving files from database") 
 	}
 	return files, nil
 }
 
-// GetFile retrieves a file by its ID.
-func (fr *FileRepository) GetFile(fileID uint) (*models.File, error) {
+// GetFile retrieves a file by its ID if it is visible to the user.
+func (fr *FileRepository) GetFile(userID, fileID uint) (*models.File, error) {
 	var file models.File
-	if err := fr.DB.First(&file, fileID).Error; err != nil {
+	if err := fr.DB.Scopes(VisibleTo(userID, VisibilityOptions{})).First(&file, fileID).Error; err != nil {
 		return nil, errors.New("file not found") 
 	}
 
 	return &file, nil
 }
 
+// GetFilesByIDs retrieves, in a single query, the files among ids that are visible to the user.
+// IDs that are missing or not visible are simply absent from the result.
+func (fr *FileRepository) GetFilesByIDs(userID uint, ids []uint) ([]models.File, error) {
+	var files []models.File
+	if err := fr.DB.Scopes(VisibleTo(userID, VisibilityOptions{})).Where("files.id IN ?", ids).Find(&files).Error; err != nil {
+		return nil, errors.New("error retrieving files from database")
+	}
+	return files, nil
+}
+
 // UpdateFile updates a file's information.
 func (fr *FileRepository) UpdateFile(file *models.File) error {
 	if err := fr.DB.Save(&file).Error; err != nil {
@@ -0,0 +1,62 @@
+package repositories
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ProjectableFields lists the file fields a client may request with ?fields=, in the order
+// they are reported when an unknown field is requested. Each is a JSON key and a column.
+var ProjectableFields = []string{
+	"id", "name", "original_name", "content_type", "path", "description", "size", "user_id", "category",
+	"content_type_verified", "metadata",
+	"version", "lock_expires_at", "legal_hold", "pinned", "created_at", "updated_at", "deleted_at",
+}
+
+// ParseFields validates a comma-separated ?fields= value. It returns nil, meaning every
+// field, when the value is empty.
+func ParseFields(param string) ([]string, error) {
+	if strings.TrimSpace(param) == "" {
+		return nil, nil
+	}
+
+	var fields []string
+	for _, field := range strings.Split(param, ",") {
+		field = strings.TrimSpace(field)
+		if !isProjectable(field) {
+			return nil, fmt.Errorf("unknown field %q; valid fields are %s", field, strings.Join(ProjectableFields, ", "))
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// SelectFields restricts a files query to the columns behind fields. The ID is always
+// selected so results can still be matched to the IDs that were asked for.
+func SelectFields(fields []string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if fields == nil {
+			return db
+		}
+
+		columns := []string{"files.id"}
+		for _, field := range fields {
+			if field != "id" {
+				columns = append(columns, "files."+field)
+			}
+		}
+		return db.Select(columns)
+	}
+}
+
+// isProjectable reports whether field may be requested with ?fields=.
+func isProjectable(field string) bool {
+	for _, projectable := range ProjectableFields {
+		if projectable == field {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package repositories
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"go-share/internal/testdb"
+	"go-share/models"
+	"gorm.io/gorm"
+)
+
+func TestParseFields(t *testing.T) {
+	tests := []struct {
+		param string
+		want  []string
+		err   bool
+	}{
+		{"", nil, false},
+		{"  ", nil, false},
+		{"id,name,size,created_at", []string{"id", "name", "size", "created_at"}, false},
+		{" name , size ", []string{"name", "size"}, false},
+		{"name,password", nil, true},
+		{"locked_by", nil, true},
+		{"name,", nil, true},
+		{"files.name", nil, true},
+		{"name; DROP TABLE files", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseFields(tt.param)
+		if (err != nil) != tt.err {
+			t.Errorf("ParseFields(%q) error = %v, want error %v", tt.param, err, tt.err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseFields(%q) = %v, want %v", tt.param, got, tt.want)
+		}
+	}
+}
+
+// The error for an unknown field lists every valid one, so a client can correct the request.
+func TestParseFieldsListsValidFields(t *testing.T) {
+	_, err := ParseFields("name,password")
+	if err == nil {
+		t.Fatal("ParseFields accepted password")
+	}
+	if !strings.Contains(err.Error(), `"password"`) || !strings.Contains(err.Error(), strings.Join(ProjectableFields, ", ")) {
+		t.Errorf("error %q doesn't name the field and list the valid ones", err)
+	}
+}
+
+// Every projectable field is a JSON key of the file and a column of the files table.
+func TestProjectableFieldsAreColumns(t *testing.T) {
+	db := testdb.Open(t, models.All...)
+	for _, field := range ProjectableFields {
+		if !db.Migrator().HasColumn(&models.File{}, field) {
+			t.Errorf("%s is not a column of files", field)
+		}
+	}
+}
+
+func TestSelectFields(t *testing.T) {
+	db := testdb.Open(t, models.All...)
+	tests := []struct {
+		fields []string
+		want   string
+	}{
+		{nil, "SELECT * FROM `files`"},
+		{[]string{"name", "size"}, "SELECT files.id,files.name,files.size FROM `files`"},
+		{[]string{"id", "name"}, "SELECT files.id,files.name FROM `files`"},
+	}
+	for _, tt := range tests {
+		got := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			return tx.Model(&models.File{}).Scopes(SelectFields(tt.fields)).Unscoped().Find(&[]models.File{})
+		})
+		if got != tt.want {
+			t.Errorf("SelectFields(%v) = %s, want %s", tt.fields, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package repositories
+
+import (
+	"go-share/models"
+	"gorm.io/gorm"
+)
+
+// VisibilityOptions widens the set of files returned by VisibleTo.
+type VisibilityOptions struct {
+	// IncludeDeleted also returns the viewer's own soft-deleted files. Files granted to the
+	// viewer are never returned once their owner has deleted them.
+	IncludeDeleted bool
+}
+
+// VisibleTo scopes a files query to the rows userID may see. Every read path (listing,
+// single lookups, and anything that resolves a file for a user) goes through it so the
+// visibility rules live in one place. A user sees their own files and files granted to them
+// by an unexpired access grant, excluding soft-deleted ones unless opts.IncludeDeleted is set;
+// files under legal hold stay visible. Granted files disappear while their owner's account is
+// suspended or deleted, and reappear when it is reinstated.
+func VisibleTo(userID uint, opts VisibilityOptions) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		granted := "EXISTS (SELECT 1 FROM file_grants WHERE file_grants.file_id = files.id AND file_grants.grantee_id = ? AND file_grants.expires_at > ?) AND " + models.ActiveOwnerCondition
+		if opts.IncludeDeleted {
+			db = db.Unscoped()
+			granted = "files.deleted_at IS NULL AND " + granted
+		}
+		now := db.NowFunc()
+		return db.Where("files.user_id = ? OR ("+granted+")", userID, userID, now, now)
+	}
+}
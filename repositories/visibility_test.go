@@ -0,0 +1,143 @@
+package repositories
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"go-share/internal/testdb"
+	"go-share/models"
+	"go-share/utils"
+	"gorm.io/gorm"
+)
+
+// visibilityCase is one file state, set up by apply on a file its owner shares with nobody yet.
+type visibilityCase struct {
+	state string
+	apply func(t *testing.T, db *gorm.DB, owner, grantee *models.User, file *models.File)
+	// visible is whether the owner, the grantee and a stranger see the file, in that order.
+	visible [3]bool
+}
+
+// grant gives grantee read access to file until expiresAt.
+func grant(t *testing.T, db *gorm.DB, grantee *models.User, file *models.File, expiresAt time.Time) {
+	t.Helper()
+	err := db.Create(&models.FileGrant{FileID: utils.PublicID(file.ID), GranteeID: grantee.ID, Permission: models.PermissionRead, ExpiresAt: expiresAt, CreatedBy: file.UserID}).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func shared(t *testing.T, db *gorm.DB, owner, grantee *models.User, file *models.File) {
+	grant(t, db, grantee, file, time.Now().Add(time.Hour))
+}
+
+// Files have no public state in this tree: nothing is reachable without an account other than
+// through a download link, which is checked by its signature rather than by VisibleTo.
+var visibilityCases = []visibilityCase{
+	{"private", func(t *testing.T, db *gorm.DB, owner, grantee *models.User, file *models.File) {}, [3]bool{true, false, false}},
+	{"shared", shared, [3]bool{true, true, false}},
+	{"share expired", func(t *testing.T, db *gorm.DB, owner, grantee *models.User, file *models.File) {
+		grant(t, db, grantee, file, time.Now().Add(-time.Minute))
+	}, [3]bool{true, false, false}},
+	{"shared and deleted", func(t *testing.T, db *gorm.DB, owner, grantee *models.User, file *models.File) {
+		shared(t, db, owner, grantee, file)
+		if err := db.Delete(file).Error; err != nil {
+			t.Fatal(err)
+		}
+	}, [3]bool{false, false, false}},
+	{"shared under legal hold", func(t *testing.T, db *gorm.DB, owner, grantee *models.User, file *models.File) {
+		shared(t, db, owner, grantee, file)
+		if err := db.Model(file).Update("legal_hold", true).Error; err != nil {
+			t.Fatal(err)
+		}
+	}, [3]bool{true, true, false}},
+	{"shared by a suspended owner", func(t *testing.T, db *gorm.DB, owner, grantee *models.User, file *models.File) {
+		shared(t, db, owner, grantee, file)
+		if err := owner.Suspend(db, nil, models.AuditLog{ActorID: owner.ID}); err != nil {
+			t.Fatal(err)
+		}
+	}, [3]bool{true, false, false}},
+	{"shared by a deleted owner", func(t *testing.T, db *gorm.DB, owner, grantee *models.User, file *models.File) {
+		shared(t, db, owner, grantee, file)
+		if err := db.Delete(owner).Error; err != nil {
+			t.Fatal(err)
+		}
+	}, [3]bool{true, false, false}},
+}
+
+// Every file state against every viewer, through each read path of FileRepository: they must
+// all agree with each other and with the expected visibility.
+func TestVisibleToMatrix(t *testing.T) {
+	viewers := []string{"owner", "grantee", "stranger"}
+	for _, tt := range visibilityCases {
+		t.Run(tt.state, func(t *testing.T) {
+			db := testdb.Open(t, models.All...)
+			var users []*models.User
+			for _, name := range viewers {
+				user := &models.User{Email: name + "@example.com", Password: "correct horse"}
+				if err := user.CreateUser(db); err != nil {
+					t.Fatal(err)
+				}
+				users = append(users, user)
+			}
+			file := &models.File{Name: "a.txt", Path: "/a.txt", UserID: users[0].ID}
+			if _, err := file.CreateFile(db, models.CreateOptions{}); err != nil {
+				t.Fatal(err)
+			}
+			tt.apply(t, db, users[0], users[1], file)
+
+			repo := NewFileRepository(db)
+			for i, viewer := range users {
+				listed, err := repo.GetFiles(viewer.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				_, getErr := repo.GetFile(viewer.ID, file.ID)
+				batch, err := repo.GetFilesByIDs(viewer.ID, []uint{file.ID})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				got := fmt.Sprintf("list %v, get %v, batch %v", len(listed) == 1, getErr == nil, len(batch) == 1)
+				want := fmt.Sprintf("list %v, get %v, batch %v", tt.visible[i], tt.visible[i], tt.visible[i])
+				if got != want {
+					t.Errorf("%s: %s; want %s", viewers[i], got, want)
+				}
+			}
+		})
+	}
+}
+
+// IncludeDeleted brings back the viewer's own deleted files, never someone else's.
+func TestVisibleToIncludeDeleted(t *testing.T) {
+	db := testdb.Open(t, models.All...)
+	owner := &models.User{Email: "owner@example.com", Password: "correct horse"}
+	grantee := &models.User{Email: "grantee@example.com", Password: "correct horse"}
+	for _, user := range []*models.User{owner, grantee} {
+		if err := user.CreateUser(db); err != nil {
+			t.Fatal(err)
+		}
+	}
+	file := &models.File{Name: "a.txt", Path: "/a.txt", UserID: owner.ID}
+	if _, err := file.CreateFile(db, models.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	shared(t, db, owner, grantee, file)
+	if err := db.Delete(file).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		viewer *models.User
+		want   int
+	}{{owner, 1}, {grantee, 0}} {
+		var files []models.File
+		if err := db.Scopes(VisibleTo(tt.viewer.ID, VisibilityOptions{IncludeDeleted: true})).Find(&files).Error; err != nil {
+			t.Fatal(err)
+		}
+		if len(files) != tt.want {
+			t.Errorf("%s: %d files with IncludeDeleted, want %d", tt.viewer.Email, len(files), tt.want)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"go-share/controllers"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// duplicateRoutesEnv makes the test binary run startup with a route registered twice instead
+// of the tests, so that TestDuplicateRouteStopsStartup can watch it exit.
+const duplicateRoutesEnv = "GO_SHARE_TEST_DUPLICATE_ROUTES"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(duplicateRoutesEnv) == "1" {
+		router := mux.NewRouter()
+		controllers.RegisterFileRoutes(router)
+		controllers.RegisterFileRoutes(router)
+		checkRoutes(router)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// Registering a route twice stops startup with a message naming it, instead of mux silently
+// serving only the first.
+func TestDuplicateRouteStopsStartup(t *testing.T) {
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	cmd.Env = append(os.Environ(), duplicateRoutesEnv+"=1")
+	out, err := cmd.CombinedOutput()
+
+	if exit, ok := err.(*exec.ExitError); !ok || exit.Success() {
+		t.Fatalf("startup with a duplicate route exited with %v, want a failure:\n%s", err, out)
+	}
+	if !strings.Contains(string(out), "Error registering routes: route") || !strings.Contains(string(out), "is registered twice") {
+		t.Errorf("startup failed without naming the duplicate route:\n%s", out)
+	}
+}
+
+// The route table is snapshotted so that a change to what the API exposes, or to the
+// middleware in front of it, shows up in review. Run with -update to accept a change.
+func TestRouteTable(t *testing.T) {
+	setupMain(t)
+
+	routes, err := controllers.ListRoutes(newRouter())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := json.MarshalIndent(routes, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = append(got, '\n')
+
+	path := filepath.Join("testdata", "routes.json")
+	if *update {
+		if err := os.MkdirAll("testdata", 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("the route table changed; if that is intended, run go test . -run TestRouteTable -update\ngot:\n%s", got)
+	}
+}
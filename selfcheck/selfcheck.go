@@ -0,0 +1,109 @@
+// Package selfcheck validates a deployment without serving traffic. Subsystems register
+// independent checks; Run executes all of them and gathers a pass/warn/fail report.
+package selfcheck
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Status is the outcome of a check. A report's status is the worst of its checks.
+type Status string
+
+// Check outcomes, from best to worst.
+const (
+	Pass Status = "pass"
+	Warn Status = "warn"
+	Fail Status = "fail"
+)
+
+// severity orders statuses so the worst can be picked.
+var severity = map[Status]int{Pass: 0, Warn: 1, Fail: 2}
+
+// Check validates one part of the deployment.
+type Check interface {
+	Name() string
+	// Run returns the outcome and a short explanation. It should honor ctx's deadline.
+	Run(ctx context.Context) (Status, string)
+}
+
+// Func adapts a function to the Check interface.
+func Func(name string, run func(ctx context.Context) (Status, string)) Check {
+	return funcCheck{name: name, run: run}
+}
+
+type funcCheck struct {
+	name string
+	run  func(ctx context.Context) (Status, string)
+}
+
+func (c funcCheck) Name() string                             { return c.name }
+func (c funcCheck) Run(ctx context.Context) (Status, string) { return c.run(ctx) }
+
+var (
+	mu     sync.Mutex
+	checks []Check
+)
+
+// Register adds a check to every future Run.
+func Register(check Check) {
+	mu.Lock()
+	defer mu.Unlock()
+	checks = append(checks, check)
+}
+
+// Result is the outcome of one check.
+type Result struct {
+	Name       string `json:"name"`
+	Status     Status `json:"status"`
+	Message    string `json:"message,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// Report gathers the results of a Run.
+type Report struct {
+	Status    Status    `json:"status"`
+	Checks    []Result  `json:"checks"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Run executes every registered check in registration order, giving each at most timeout.
+// A check that panics fails without stopping the others.
+func Run(ctx context.Context, timeout time.Duration) Report {
+	mu.Lock()
+	registered := append([]Check(nil), checks...)
+	mu.Unlock()
+
+	report := Report{Status: Pass, Checks: []Result{}, CheckedAt: time.Now().UTC()}
+	for _, check := range registered {
+		result := run(ctx, check, timeout)
+		if severity[result.Status] > severity[report.Status] {
+			report.Status = result.Status
+		}
+		report.Checks = append(report.Checks, result)
+	}
+	return report
+}
+
+// run executes one check with its own deadline.
+func run(ctx context.Context, check Check, timeout time.Duration) (result Result) {
+	checkCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	result.Name = check.Name()
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			result.Status, result.Message = Fail, fmt.Sprintf("panic: %v", recovered)
+		}
+		result.DurationMs = time.Since(start).Milliseconds()
+	}()
+
+	result.Status, result.Message = check.Run(checkCtx)
+	if _, ok := severity[result.Status]; !ok {
+		result.Status, result.Message = Fail, fmt.Sprintf("unknown status %q: %s", result.Status, result.Message)
+	}
+	return result
+}
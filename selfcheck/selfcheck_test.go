@@ -0,0 +1,122 @@
+package selfcheck
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// withChecks replaces the registered checks for the rest of the test.
+func withChecks(t *testing.T, registered ...Check) {
+	t.Helper()
+	mu.Lock()
+	old := checks
+	checks = nil
+	mu.Unlock()
+	for _, check := range registered {
+		Register(check)
+	}
+	t.Cleanup(func() {
+		mu.Lock()
+		checks = old
+		mu.Unlock()
+	})
+}
+
+// returns is a fake check with a fixed outcome.
+func returns(name string, status Status, message string) Check {
+	return Func(name, func(ctx context.Context) (Status, string) { return status, message })
+}
+
+// statuses returns the name and status of each result.
+func statuses(report Report) [][2]string {
+	var got [][2]string
+	for _, result := range report.Checks {
+		got = append(got, [2]string{result.Name, string(result.Status)})
+	}
+	return got
+}
+
+func TestRunReportsWorstStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		checks []Check
+		want   Status
+	}{
+		{"no checks", nil, Pass},
+		{"all pass", []Check{returns("a", Pass, ""), returns("b", Pass, "")}, Pass},
+		{"a warning", []Check{returns("a", Pass, ""), returns("b", Warn, "weak"), returns("c", Pass, "")}, Warn},
+		{"a failure", []Check{returns("a", Fail, "down"), returns("b", Warn, "weak")}, Fail},
+	}
+	for _, tt := range tests {
+		withChecks(t, tt.checks...)
+		if report := Run(context.Background(), time.Second); report.Status != tt.want {
+			t.Errorf("%s: status %q, want %q", tt.name, report.Status, tt.want)
+		}
+	}
+}
+
+// A broken check fails on its own: the checks after it still run.
+func TestRunIsolatesBrokenChecks(t *testing.T) {
+	withChecks(t,
+		Func("panics", func(ctx context.Context) (Status, string) { panic("storage driver missing") }),
+		Func("hangs", func(ctx context.Context) (Status, string) {
+			<-ctx.Done()
+			return Fail, ctx.Err().Error()
+		}),
+		returns("invents a status", "maybe", "not sure"),
+		returns("passes", Pass, ""),
+	)
+
+	start := time.Now()
+	report := Run(context.Background(), 50*time.Millisecond)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Run took %s; the hanging check wasn't cut off at its deadline", elapsed)
+	}
+
+	want := [][2]string{{"panics", "fail"}, {"hangs", "fail"}, {"invents a status", "fail"}, {"passes", "pass"}}
+	if got := statuses(report); !reflect.DeepEqual(got, want) {
+		t.Errorf("results %v, want %v", got, want)
+	}
+	messages := []string{"panic: storage driver missing", "context deadline exceeded", `unknown status "maybe": not sure`, ""}
+	for i, result := range report.Checks {
+		if result.Message != messages[i] {
+			t.Errorf("%s: message %q, want %q", result.Name, result.Message, messages[i])
+		}
+	}
+	if report.Status != Fail {
+		t.Errorf("status %q, want fail", report.Status)
+	}
+}
+
+func TestReportJSON(t *testing.T) {
+	withChecks(t, returns("database", Fail, "connection refused"), returns("cache", Pass, ""))
+
+	data, err := json.Marshal(Run(context.Background(), time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		Status    string                   `json:"status"`
+		CheckedAt time.Time                `json:"checked_at"`
+		Checks    []map[string]interface{} `json:"checks"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Status != "fail" || decoded.CheckedAt.IsZero() || len(decoded.Checks) != 2 {
+		t.Fatalf("report %s", data)
+	}
+	database, cache := decoded.Checks[0], decoded.Checks[1]
+	if database["name"] != "database" || database["status"] != "fail" || database["message"] != "connection refused" {
+		t.Errorf("database result %v", database)
+	}
+	if _, ok := database["duration_ms"]; !ok {
+		t.Errorf("database result has no duration_ms: %v", database)
+	}
+	if _, ok := cache["message"]; ok {
+		t.Errorf("a passing check without a message still has one: %v", cache)
+	}
+}
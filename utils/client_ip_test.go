@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string // X-Forwarded-For header lines
+		want       string
+	}{
+		{"direct client", "203.0.113.9:1234", nil, "203.0.113.9"},
+		{"remote addr without port", "203.0.113.9", nil, "203.0.113.9"},
+		{"spoofed header from untrusted peer", "203.0.113.9:1234", []string{"198.51.100.1"}, "203.0.113.9"},
+		{"spoofed chain from untrusted peer", "203.0.113.9:1234", []string{"10.0.0.2, 198.51.100.1"}, "203.0.113.9"},
+		{"trusted proxy", "10.0.0.1:1234", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"trusted proxy without header", "10.0.0.1:1234", nil, "10.0.0.1"},
+		{"chained proxies", "10.0.0.1:1234", []string{"198.51.100.1, 10.0.0.3, 10.0.0.2"}, "198.51.100.1"},
+		{"client spoofs left of real address", "10.0.0.1:1234", []string{"1.2.3.4, 198.51.100.1, 10.0.0.2"}, "198.51.100.1"},
+		{"several header lines", "10.0.0.1:1234", []string{"198.51.100.1", "10.0.0.2"}, "198.51.100.1"},
+		{"garbage hop stops the walk", "10.0.0.1:1234", []string{"198.51.100.1, garbage, 10.0.0.2"}, "10.0.0.2"},
+		{"every hop trusted", "10.0.0.1:1234", []string{"10.0.0.3, 10.0.0.2"}, "10.0.0.3"},
+		{"spaces around hops", "10.0.0.1:1234", []string{" 198.51.100.1 ,10.0.0.2 "}, "198.51.100.1"},
+		{"ipv6 client", "[2001:db8::1]:1234", nil, "2001:db8::1"},
+		{"ipv6 client through ipv6 proxy", "[fd00::1]:1234", []string{"2001:db8::1"}, "2001:db8::1"},
+		{"ipv6 proxy chain", "[fd00::1]:1234", []string{"2001:db8::1, fd00::2"}, "2001:db8::1"},
+		{"ipv4 client through ipv6 proxy", "[fd00::1]:1234", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"spoofed header from untrusted ipv6 peer", "[2001:db8::9]:1234", []string{"198.51.100.1"}, "2001:db8::9"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withSetting(t, "server.trusted_proxies", []string{"10.0.0.0/8", "fd00::/8"})
+
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+
+			if got := ClientIP(r); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// Without trusted proxies, as in the default configuration, the header is never believed.
+func TestClientIPWithoutTrustedProxies(t *testing.T) {
+	withSetting(t, "server.trusted_proxies", []string{})
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "127.0.0.1:1234"
+	r.Header.Set("X-Forwarded-For", "198.51.100.1")
+	if got := ClientIP(r); got != "127.0.0.1" {
+		t.Errorf("got %q, want the peer address 127.0.0.1", got)
+	}
+}
@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// ErrInvalidConfirmToken is returned for malformed, tampered or expired confirmation tokens,
+// and for tokens issued for a different request.
+var ErrInvalidConfirmToken = errors.New("invalid confirmation token")
+
+// confirmClaims is the signed content of a confirmation token. RequestHash binds the token to
+// the exact request it confirms.
+type confirmClaims struct {
+	UserID      uint      `json:"uid"`
+	RequestHash string    `json:"req"`
+	ExpiresAt   time.Time `json:"exp"`
+}
+
+// ConfirmRequestHash fingerprints a destructive request: its method, path, query and body.
+// Changing any of them invalidates a confirmation token issued for it.
+func ConfirmRequestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	for _, part := range []string{r.Method, r.URL.Path, r.URL.Query().Encode()} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	h.Write(body)
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// GenerateConfirmToken returns a token that lets userID repeat the request with the given
+// hash within ttl, and the time it expires.
+func GenerateConfirmToken(userID uint, requestHash string, ttl time.Duration) (string, time.Time, error) {
+	claims := confirmClaims{UserID: userID, RequestHash: requestHash, ExpiresAt: time.Now().Add(ttl).Truncate(time.Second)}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return signToken(confirmTokenPurpose, payload), claims.ExpiresAt, nil
+}
+
+// VerifyConfirmToken checks that token was issued to userID for the request with the given
+// hash and has not expired.
+func VerifyConfirmToken(token string, userID uint, requestHash string) error {
+	payload, err := verifyToken(confirmTokenPurpose, token)
+	if err != nil {
+		return ErrInvalidConfirmToken
+	}
+	var claims confirmClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return ErrInvalidConfirmToken
+	}
+	if claims.UserID != userID || !hmac.Equal([]byte(claims.RequestHash), []byte(requestHash)) {
+		return ErrInvalidConfirmToken
+	}
+	if !time.Now().Before(claims.ExpiresAt) {
+		return ErrInvalidConfirmToken
+	}
+	return nil
+}
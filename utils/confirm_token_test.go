@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestConfirmRequestHash(t *testing.T) {
+	base := ConfirmRequestHash(httptest.NewRequest("POST", "/files/bulk-delete?permanent=true", nil), []byte(`{"ids":["a"]}`))
+
+	tests := []struct {
+		name, method, target, body string
+	}{
+		{"method", "DELETE", "/files/bulk-delete?permanent=true", `{"ids":["a"]}`},
+		{"path", "POST", "/files/bulk-remove?permanent=true", `{"ids":["a"]}`},
+		{"query", "POST", "/files/bulk-delete", `{"ids":["a"]}`},
+		{"body", "POST", "/files/bulk-delete?permanent=true", `{"ids":["a","b"]}`},
+	}
+	for _, tt := range tests {
+		if got := ConfirmRequestHash(httptest.NewRequest(tt.method, tt.target, nil), []byte(tt.body)); got == base {
+			t.Errorf("changing the %s kept the hash", tt.name)
+		}
+	}
+	if got := ConfirmRequestHash(httptest.NewRequest("POST", "/files/bulk-delete?permanent=true", nil), []byte(`{"ids":["a"]}`)); got != base {
+		t.Error("the same request hashed differently")
+	}
+}
+
+func TestConfirmToken(t *testing.T) {
+	withKeys(t, "", "signing key")
+
+	token, expiresAt, err := GenerateConfirmToken(1, "hash", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if until := time.Until(expiresAt); until <= 0 || until > time.Minute {
+		t.Errorf("token expires in %s, want within a minute", until)
+	}
+	expired, _, err := GenerateConfirmToken(1, "hash", -time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		userID uint
+		hash   string
+		valid  bool
+	}{
+		{"valid", token, 1, "hash", true},
+		{"other user", token, 2, "hash", false},
+		{"other request", token, 1, "other hash", false},
+		{"expired", expired, 1, "hash", false},
+		{"tampered", token[:len(token)-2] + "xx", 1, "hash", false},
+		{"upload grant", signToken(uploadGrantPurpose, []byte(`{"uid":1,"req":"hash","exp":"2999-01-01T00:00:00Z"}`)), 1, "hash", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyConfirmToken(tt.token, tt.userID, tt.hash)
+			if tt.valid && err != nil {
+				t.Errorf("VerifyConfirmToken = %v, want success", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidConfirmToken) {
+				t.Errorf("VerifyConfirmToken = %v, want ErrInvalidConfirmToken", err)
+			}
+		})
+	}
+}
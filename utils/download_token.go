@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidDownloadToken is returned for malformed, tampered, expired, or mismatched download tokens.
+var ErrInvalidDownloadToken = errors.New("invalid download token")
+
+// GenerateDownloadToken creates a short-lived token that authorizes downloading one file
+// without an Authorization header. The token is an HMAC over the file ID, user ID and
+// expiry, so nothing needs to be stored server-side.
+func GenerateDownloadToken(fileID, userID uint, ttl time.Duration) (string, time.Time) {
+	expiresAt := time.Now().Add(ttl).Truncate(time.Second)
+	payload := fmt.Sprintf("%d.%d.%d", fileID, userID, expiresAt.Unix())
+	return signToken(downloadTokenPurpose, []byte(payload)), expiresAt
+}
+
+// VerifyDownloadToken checks a download token against the requested file ID and returns
+// the user ID it was issued to.
+func VerifyDownloadToken(token string, fileID uint) (uint, error) {
+	payload, err := verifyToken(downloadTokenPurpose, token)
+	if err != nil {
+		return 0, ErrInvalidDownloadToken
+	}
+
+	var tokenFileID, userID uint
+	var expiresAt int64
+	if _, err := fmt.Sscanf(string(payload), "%d.%d.%d", &tokenFileID, &userID, &expiresAt); err != nil {
+		return 0, ErrInvalidDownloadToken
+	}
+
+	if tokenFileID != fileID || time.Now().Unix() >= expiresAt {
+		return 0, ErrInvalidDownloadToken
+	}
+
+	return userID, nil
+}
@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDownloadToken(t *testing.T) {
+	withKeys(t, "", "signing key")
+
+	token, expiresAt := GenerateDownloadToken(7, 3, time.Minute)
+	if until := time.Until(expiresAt); until <= 0 || until > time.Minute {
+		t.Errorf("token expires in %s, want within a minute", until)
+	}
+	expired, _ := GenerateDownloadToken(7, 3, -time.Second)
+	encoded, signature, _ := strings.Cut(token, ".")
+	otherFile, _ := GenerateDownloadToken(8, 3, time.Minute)
+	otherEncoded, _, _ := strings.Cut(otherFile, ".")
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{"valid", token, true},
+		{"expired", expired, false},
+		{"issued for another file", otherFile, false},
+		{"payload of another file", otherEncoded + "." + signature, false},
+		{"tampered signature", encoded + "." + strings.Repeat("A", len(signature)), false},
+		{"truncated signature", encoded + "." + signature[:len(signature)-1], false},
+		{"garbage", "not-a-token", false},
+		{"empty", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID, err := VerifyDownloadToken(tt.token, 7)
+			if tt.valid {
+				if err != nil || userID != 3 {
+					t.Errorf("got user %d, %v; want user 3", userID, err)
+				}
+				return
+			}
+			if err != ErrInvalidDownloadToken {
+				t.Errorf("got user %d, %v; want ErrInvalidDownloadToken", userID, err)
+			}
+		})
+	}
+}
+
+// Tokens are only as good as the signing key: rotating it invalidates every outstanding link.
+func TestDownloadTokenKeyRotation(t *testing.T) {
+	withKeys(t, "", "signing key")
+	token, _ := GenerateDownloadToken(7, 3, time.Minute)
+
+	JWTKey = []byte("rotated signing key")
+	if _, err := VerifyDownloadToken(token, 7); err != ErrInvalidDownloadToken {
+		t.Errorf("got %v, want ErrInvalidDownloadToken", err)
+	}
+}
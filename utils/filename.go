@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxFileNameBytes is the longest file name SanitizeFileName returns, in bytes.
+const MaxFileNameBytes = 255
+
+// windowsReservedNames are device names that cannot be used as file names on Windows,
+// with or without an extension.
+var windowsReservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// SanitizeFileName turns a client-supplied name into one that is safe to store and to
+// download on any common filesystem. The policy is:
+//   - invalid UTF-8 is replaced with U+FFFD;
+//   - control characters and bidirectional formatting characters (e.g. right-to-left
+//     override) are removed;
+//   - path separators and characters Windows rejects (<>:"|?*) become "_";
+//   - runs of whitespace collapse to a single space, and leading/trailing spaces and
+//     trailing dots are trimmed;
+//   - Windows device names such as CON or nul.txt are prefixed with "_";
+//   - the result is truncated to MaxFileNameBytes, keeping the extension when possible;
+//   - if nothing survives (including "." and ".."), a random "file-<hex>" name is used.
+func SanitizeFileName(name string) string {
+	name = strings.ToValidUTF8(name, "\uFFFD")
+
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsControl(r), isBidiControl(r):
+			return -1
+		case strings.ContainsRune(`/\<>:"|?*`, r):
+			return '_'
+		case unicode.IsSpace(r):
+			return ' '
+		}
+		return r
+	}, name)
+
+	name = strings.Join(strings.Fields(name), " ")
+	name = strings.TrimRight(name, ". ")
+
+	if name == "" {
+		return generatedFileName()
+	}
+
+	base := name
+	if dot := strings.IndexByte(base, '.'); dot > 0 {
+		base = base[:dot]
+	}
+	if windowsReservedNames[strings.ToUpper(strings.TrimSpace(base))] {
+		name = "_" + name
+	}
+
+	return truncateFileName(name, MaxFileNameBytes)
+}
+
+// isBidiControl reports whether r is a bidirectional formatting character, which can be
+// used to disguise a file's real extension.
+func isBidiControl(r rune) bool {
+	switch {
+	case r >= '\u202A' && r <= '\u202E', // LRE, RLE, PDF, LRO, RLO
+		r >= '\u2066' && r <= '\u2069',              // LRI, RLI, FSI, PDI
+		r == '\u200E', r == '\u200F', r == '\u061C': // LRM, RLM, ALM
+		return true
+	}
+	return false
+}
+
+// truncateFileName shortens name to at most maxBytes without splitting a UTF-8 sequence,
+// preserving a short extension.
+func truncateFileName(name string, maxBytes int) string {
+	if len(name) <= maxBytes {
+		return name
+	}
+
+	ext := ""
+	if dot := strings.LastIndexByte(name, '.'); dot > 0 && len(name)-dot <= 16 {
+		ext = name[dot:]
+		name = name[:dot]
+	}
+
+	limit := maxBytes - len(ext)
+	for limit > 0 && !utf8.RuneStart(name[limit]) {
+		limit--
+	}
+	return strings.TrimRight(name[:limit], ". ") + ext
+}
+
+// generatedFileName returns a random fallback name for inputs where nothing usable survives.
+func generatedFileName() string {
+	suffix := make([]byte, 6)
+	rand.Read(suffix)
+	return "file-" + hex.EncodeToString(suffix)
+}
@@ -0,0 +1,189 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"unicode"
+	"unicode/utf8"
+)
+
+// generatedName matches the fallback names returned when nothing of the input survives.
+var generatedName = regexp.MustCompile(`^file-[0-9a-f]{12}$`)
+
+func TestSanitizeFileName(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain name", "report.pdf", "report.pdf"},
+		{"unicode name", "résumé 履歴書.docx", "résumé 履歴書.docx"},
+		{"emoji", "party 🎉.png", "party 🎉.png"},
+		{"leading dot kept", ".bashrc", ".bashrc"},
+
+		{"NUL byte", "evil\x00.txt", "evil.txt"},
+		{"C0 controls", "a\x01b\x07c\x1bd.txt", "abcd.txt"},
+		{"DEL and C1 controls", "a\x7fb\u0085c\u009bd.txt", "abcd.txt"},
+		{"tab and newline are controls", "line\tone\ntwo.txt", "lineonetwo.txt"},
+		{"right-to-left override", "invoice\u202Efdp.exe", "invoicefdp.exe"},
+		{"all bidi embeddings", "\u202Aa\u202Bb\u202Cc\u202Dd\u202Ee.txt", "abcde.txt"},
+		{"bidi isolates", "\u2066a\u2067b\u2068c\u2069.txt", "abc.txt"},
+		{"bidi marks", "a\u200Eb\u200Fc\u061C.txt", "abc.txt"},
+
+		{"path traversal", "../../etc/passwd", ".._.._etc_passwd"},
+		{"windows path", `C:\Windows\system32.dll`, "C__Windows_system32.dll"},
+		{"windows-invalid characters", `a<b>c:d"e|f?g*h.txt`, "a_b_c_d_e_f_g_h.txt"},
+
+		{"whitespace runs", "my   \u00a0 report\u3000.pdf", "my report .pdf"},
+		{"leading and trailing spaces", "   notes.txt   ", "notes.txt"},
+		{"trailing dots", "report...", "report"},
+		{"trailing dots and spaces", "report. . .", "report"},
+
+		{"reserved name", "CON", "_CON"},
+		{"reserved name lower case", "nul", "_nul"},
+		{"reserved name with extension", "nul.txt", "_nul.txt"},
+		{"reserved name with double extension", "com1.tar.gz", "_com1.tar.gz"},
+		{"reserved name with trailing dot", "AUX.", "_AUX"},
+		{"reserved name with trailing space", "lpt9 ", "_lpt9"},
+		{"reserved prefix is fine", "CONSOLE.txt", "CONSOLE.txt"},
+		{"reserved name as extension is fine", "file.con", "file.con"},
+		{"COM0 is not reserved", "COM0.txt", "COM0.txt"},
+
+		{"invalid UTF-8", "a\xffb.txt", "a\uFFFDb.txt"},
+		{"truncated UTF-8 sequence", "caf\xc3.txt", "caf\uFFFD.txt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SanitizeFileName(tt.in); got != tt.want {
+				t.Errorf("SanitizeFileName(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeFileNameFallback(t *testing.T) {
+	inputs := []string{
+		"",
+		".",
+		"..",
+		"...",
+		" ",
+		"\t\n ",
+		"\u3000\u00a0",
+		"\x00\x00",
+		"\u202E",
+		"\u202E\u200F. .",
+	}
+
+	seen := map[string]bool{}
+	for _, in := range inputs {
+		got := SanitizeFileName(in)
+		if !generatedName.MatchString(got) {
+			t.Errorf("SanitizeFileName(%q) = %q, want a generated name", in, got)
+		}
+		if seen[got] {
+			t.Errorf("SanitizeFileName(%q) = %q, which was already generated", in, got)
+		}
+		seen[got] = true
+	}
+}
+
+func TestSanitizeFileNameTruncation(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		wantExt string
+	}{
+		{"ASCII", strings.Repeat("a", 300) + ".txt", ".txt"},
+		{"exactly the limit", strings.Repeat("a", MaxFileNameBytes-4) + ".txt", ".txt"},
+		{"two-byte runes", strings.Repeat("é", 200) + ".txt", ".txt"},
+		{"two-byte runes off by one", "a" + strings.Repeat("é", 200) + ".txt", ".txt"},
+		{"three-byte runes", strings.Repeat("履", 100) + ".pdf", ".pdf"},
+		{"three-byte runes off by one", "ab" + strings.Repeat("履", 100) + ".pdf", ".pdf"},
+		{"four-byte runes", strings.Repeat("🎉", 80) + ".png", ".png"},
+		{"four-byte runes off by two", "ab" + strings.Repeat("🎉", 80) + ".png", ".png"},
+		{"multibyte extension", strings.Repeat("a", 300) + ".文書", ".文書"},
+		{"long extension is not kept", strings.Repeat("a", 250) + "." + strings.Repeat("b", 20), ""},
+		{"no extension", strings.Repeat("履", 100), ""},
+		{"dots before the cut", strings.Repeat("a", 240) + strings.Repeat(".", 20) + "b.txt", ".txt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SanitizeFileName(tt.in)
+			if len(got) > MaxFileNameBytes {
+				t.Errorf("got %d bytes, want at most %d", len(got), MaxFileNameBytes)
+			}
+			if len(tt.in) <= MaxFileNameBytes && got != tt.in {
+				t.Errorf("a name within the limit was changed to %q", got)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("got invalid UTF-8 %q", got)
+			}
+			if strings.ContainsRune(got, utf8.RuneError) {
+				t.Errorf("got a replacement character in %q; a rune was split", got)
+			}
+			if tt.wantExt != "" && !strings.HasSuffix(got, tt.wantExt) {
+				t.Errorf("got %q, want extension %q kept", got, tt.wantExt)
+			}
+			if strings.HasSuffix(got, ".") || strings.HasSuffix(got, " ") {
+				t.Errorf("got %q, which ends in a dot or space", got)
+			}
+		})
+	}
+}
+
+func TestSanitizeFileNameIdempotent(t *testing.T) {
+	inputs := []string{
+		"report.pdf",
+		"  a  b  .txt",
+		"nul.txt",
+		"CON",
+		"_CON",
+		"invoice\u202Efdp.exe",
+		"../../etc/passwd",
+		"a\xffb.txt",
+		strings.Repeat("é", 200) + ".txt",
+		strings.Repeat("🎉", 80) + ".png",
+		strings.Repeat(".", 300) + "a",
+		"",
+		"..",
+	}
+
+	for _, in := range inputs {
+		once := SanitizeFileName(in)
+		if twice := SanitizeFileName(once); twice != once {
+			t.Errorf("SanitizeFileName(%q) = %q, but sanitizing that again gives %q", in, once, twice)
+		}
+	}
+}
+
+// FuzzSanitizeFileName checks the policy's invariants on arbitrary input.
+func FuzzSanitizeFileName(f *testing.F) {
+	for _, seed := range []string{"report.pdf", "", "..", "CON.txt", "a\x00b", "x\u202Eexe.txt", strings.Repeat("é", 200)} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, in string) {
+		got := SanitizeFileName(in)
+		if got == "" || got == "." || got == ".." {
+			t.Fatalf("SanitizeFileName(%q) = %q", in, got)
+		}
+		if len(got) > MaxFileNameBytes {
+			t.Fatalf("SanitizeFileName(%q) is %d bytes", in, len(got))
+		}
+		if !utf8.ValidString(got) {
+			t.Fatalf("SanitizeFileName(%q) = %q is not valid UTF-8", in, got)
+		}
+		for _, r := range got {
+			if unicode.IsControl(r) || isBidiControl(r) || strings.ContainsRune(`/\<>:"|?*`, r) {
+				t.Fatalf("SanitizeFileName(%q) = %q contains %U", in, got, r)
+			}
+		}
+		if again := SanitizeFileName(got); again != got {
+			t.Fatalf("SanitizeFileName(%q) = %q is not stable: %q", in, got, again)
+		}
+	})
+}
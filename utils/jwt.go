@@ -2,9 +2,12 @@ package utils
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -17,20 +20,38 @@ import (
 // and stored securely (e.g., environment variable, secret management service).
 var JWTKey = []byte("secret_key") 
 
+// TokenTTL is the lifetime of tokens issued at login.
+const TokenTTL = 30 * time.Minute
+
 // Claims represents the claims embedded in a JWT token.
 type Claims struct {
 	UserID uint `json:"user_id"`
+	// Impersonator is the ID of the admin acting as UserID, or zero for normal tokens.
+	Impersonator uint `json:"impersonator,omitempty"`
 	jwt.RegisteredClaims
 }
 
 // GenerateToken generates a JWT token for a given user ID.
+// Each token carries a random ID that identifies the login session.
 func GenerateToken(userID uint) (string, error) {
-	expirationTime := time.Now().Add(30 * time.Minute)
-	claims := &Claims{
-		UserID: userID,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-		},
+	return signClaims(&Claims{UserID: userID}, TokenTTL)
+}
+
+// GenerateImpersonationToken generates a short-lived token that lets an admin act as userID.
+func GenerateImpersonationToken(userID, impersonatorID uint, ttl time.Duration) (string, error) {
+	return signClaims(&Claims{UserID: userID, Impersonator: impersonatorID}, ttl)
+}
+
+// signClaims fills in the session ID and expiry and signs the claims.
+func signClaims(claims *Claims, ttl time.Duration) (string, error) {
+	sessionID := make([]byte, 16)
+	if _, err := rand.Read(sessionID); err != nil {
+		return "", fmt.Errorf("error generating session ID: %w", err)
+	}
+
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        hex.EncodeToString(sessionID),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -81,12 +102,25 @@ func ComparePassword(hashedPassword, password string) error {
 func AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
+		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+
+		// Browser clients may authenticate with the session cookie instead. Such requests
+		// are exposed to CSRF, so state-changing methods must carry the double-submit token.
+		if authHeader == "" && CookieAuthEnabled() {
+			if cookie, err := r.Cookie(SessionCookieName); err == nil {
+				if !validCSRF(r) {
+					ErrorCodeJsonResponse(w, "csrf_token_invalid", "Missing or invalid CSRF token", http.StatusForbidden)
+					return
+				}
+				tokenString = cookie.Value
+			}
+		}
+
+		if tokenString == "" {
 			ErrorJsonResponse(w, "Authorization header missing", http.StatusUnauthorized)
 			return
 		}
 
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 		claims, err := VerifyToken(tokenString)
 		if err != nil {
 			ErrorJsonResponse(w, "Invalid token", http.StatusUnauthorized)
@@ -95,9 +129,32 @@ func AuthMiddleware(next http.Handler) http.Handler {
 
 		// Set the user ID in the request context for use in controllers
 		ctx := context.WithValue(r.Context(), "user_id", claims.UserID)
+		ctx = context.WithValue(ctx, "session_id", claims.ID)
+		if claims.Impersonator != 0 {
+			ctx = context.WithValue(ctx, "impersonator_id", claims.Impersonator)
+			w.Header().Set("X-Impersonated-By", strconv.FormatUint(uint64(claims.Impersonator), 10))
+		}
 		r = r.WithContext(ctx)
 
 		// Call the next handler in the chain
 		next.ServeHTTP(w, r)
 	})
-}
\ No newline at end of file
+}
+
+// GetUserID returns the authenticated user's ID placed in the request context by AuthMiddleware.
+func GetUserID(r *http.Request) (uint, bool) {
+	userID, ok := r.Context().Value("user_id").(uint)
+	return userID, ok
+}
+
+// GetSessionID returns the ID of the login session that issued the request's token.
+func GetSessionID(r *http.Request) string {
+	sessionID, _ := r.Context().Value("session_id").(string)
+	return sessionID
+}
+
+// GetImpersonatorID returns the ID of the admin impersonating the authenticated user, if any.
+func GetImpersonatorID(r *http.Request) (uint, bool) {
+	impersonatorID, ok := r.Context().Value("impersonator_id").(uint)
+	return impersonatorID, ok
+}
@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+
+	"go-share/selfcheck"
+)
+
+// minJWTKeyLength is the shortest signing key considered strong: 256 bits, the HMAC-SHA256
+// block the tokens are signed with.
+const minJWTKeyLength = 32
+
+func init() {
+	selfcheck.Register(selfcheck.Func("jwt_secret", checkJWTKey))
+	selfcheck.Register(selfcheck.Func("public_id_key", checkPublicIDKey))
+}
+
+// checkJWTKey reports a missing or weak token signing key. The key also signs download
+// tokens, upload grants and confirmation tokens, so a guessable key compromises all of them.
+func checkJWTKey(ctx context.Context) (selfcheck.Status, string) {
+	switch {
+	case len(JWTKey) == 0:
+		return selfcheck.Fail, "no signing key"
+	case string(JWTKey) == "secret_key":
+		return selfcheck.Warn, "the signing key is the built-in development key"
+	case len(JWTKey) < minJWTKeyLength:
+		return selfcheck.Warn, fmt.Sprintf("the signing key is %d bytes; use at least %d", len(JWTKey), minJWTKeyLength)
+	}
+	return selfcheck.Pass, ""
+}
+
+// checkPublicIDKey fails on a public ID key the server would refuse to start with, and warns
+// on one shorter than a strong signing key.
+func checkPublicIDKey(ctx context.Context) (selfcheck.Status, string) {
+	if err := ValidatePublicIDKey(PublicIDKey); err != nil {
+		return selfcheck.Fail, err.Error()
+	}
+	if len(PublicIDKey) < minJWTKeyLength {
+		return selfcheck.Warn, fmt.Sprintf("api.public_id_key is %d bytes; use at least %d", len(PublicIDKey), minJWTKeyLength)
+	}
+	return selfcheck.Pass, ""
+}
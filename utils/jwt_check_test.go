@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go-share/selfcheck"
+)
+
+func TestCheckJWTKey(t *testing.T) {
+	tests := []struct {
+		key     string
+		status  selfcheck.Status
+		message string
+	}{
+		{"", selfcheck.Fail, "no signing key"},
+		{"secret_key", selfcheck.Warn, "development key"},
+		{"short key", selfcheck.Warn, "9 bytes; use at least 32"},
+		{strings.Repeat("k", minJWTKeyLength), selfcheck.Pass, ""},
+	}
+	for _, tt := range tests {
+		withKeys(t, "", tt.key)
+		status, message := checkJWTKey(context.Background())
+		if status != tt.status || !strings.Contains(message, tt.message) {
+			t.Errorf("key %q: got %s %q, want %s %q", tt.key, status, message, tt.status, tt.message)
+		}
+	}
+}
+
+func TestCheckPublicIDKey(t *testing.T) {
+	tests := []struct {
+		key     string
+		status  selfcheck.Status
+		message string
+	}{
+		{"", selfcheck.Fail, "api.public_id_key is not set"},
+		{"short key", selfcheck.Fail, "9 bytes; use at least 16"},
+		{strings.Repeat("k", MinPublicIDKeyLength), selfcheck.Warn, "16 bytes; use at least 32"},
+		{strings.Repeat("k", minJWTKeyLength), selfcheck.Pass, ""},
+	}
+	for _, tt := range tests {
+		withKeys(t, tt.key, strings.Repeat("s", minJWTKeyLength))
+		status, message := checkPublicIDKey(context.Background())
+		if status != tt.status || !strings.Contains(message, tt.message) {
+			t.Errorf("key %q: got %s %q, want %s %q", tt.key, status, message, tt.status, tt.message)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/viper"
+)
+
+// ErrInvalidPublicID is returned for identifiers that don't decode to a file ID.
+var ErrInvalidPublicID = errors.New("invalid ID")
+
+// PublicIDKey is the secret public IDs are encrypted with, set from api.public_id_key. It is
+// separate from JWTKey so that rotating the signing key doesn't change every file's ID.
+var PublicIDKey []byte
+
+// MinPublicIDKeyLength is the shortest public ID key accepted: the 128 bits of the AES key
+// derived from it.
+const MinPublicIDKeyLength = 16
+
+// ValidatePublicIDKey returns an error unless key is long enough to keep public IDs opaque.
+// The server refuses to start without one: with a known key, anyone could decode public IDs
+// and forge new ones.
+func ValidatePublicIDKey(key []byte) error {
+	switch {
+	case len(key) == 0:
+		return errors.New("api.public_id_key is not set")
+	case len(key) < MinPublicIDKeyLength:
+		return fmt.Errorf("api.public_id_key is %d bytes; use at least %d", len(key), MinPublicIDKeyLength)
+	}
+	return nil
+}
+
+// EncodePublicID turns a numeric file ID into the opaque identifier used by the API. The ID
+// is encrypted as a single AES block, so public IDs are not sequential and can't be forged
+// without the server key.
+func EncodePublicID(id uint) string {
+	var block [aes.BlockSize]byte
+	binary.BigEndian.PutUint64(block[:8], uint64(id))
+	publicIDCipher().Encrypt(block[:], block[:])
+	return base64.RawURLEncoding.EncodeToString(block[:])
+}
+
+// DecodePublicID reverses EncodePublicID.
+func DecodePublicID(publicID string) (uint, error) {
+	block, err := base64.RawURLEncoding.DecodeString(publicID)
+	if err != nil || len(block) != aes.BlockSize {
+		return 0, ErrInvalidPublicID
+	}
+	publicIDCipher().Decrypt(block, block)
+
+	// The second half of the block is zero for every ID we issued.
+	if binary.BigEndian.Uint64(block[8:]) != 0 {
+		return 0, ErrInvalidPublicID
+	}
+	return uint(binary.BigEndian.Uint64(block[:8])), nil
+}
+
+// ParsePublicID accepts a public ID, or a plain numeric ID while api.numeric_ids is enabled.
+func ParsePublicID(s string) (uint, error) {
+	if id, err := DecodePublicID(s); err == nil {
+		return id, nil
+	}
+	if viper.GetBool("api.numeric_ids") {
+		if id, err := strconv.ParseUint(s, 10, 64); err == nil {
+			return uint(id), nil
+		}
+	}
+	return 0, ErrInvalidPublicID
+}
+
+// publicIDCipher derives the AES-128 cipher for public IDs from PublicIDKey.
+func publicIDCipher() interface {
+	Encrypt(dst, src []byte)
+	Decrypt(dst, src []byte)
+} {
+	mac := hmac.New(sha256.New, append([]byte("public-id:"), PublicIDKey...))
+	block, _ := aes.NewCipher(mac.Sum(nil)[:16]) // a 16-byte key never fails
+	return block
+}
+
+// PublicID is a file ID that is encoded as an opaque string in JSON.
+type PublicID uint
+
+// MarshalJSON encodes the ID as its public form.
+func (id PublicID) MarshalJSON() ([]byte, error) {
+	return json.Marshal(EncodePublicID(uint(id)))
+}
+
+// UnmarshalJSON accepts the public form, or a number while api.numeric_ids is enabled.
+func (id *PublicID) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		s = string(data)
+	}
+
+	decoded, err := ParsePublicID(s)
+	if err != nil {
+		return err
+	}
+	*id = PublicID(decoded)
+	return nil
+}
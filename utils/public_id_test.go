@@ -0,0 +1,150 @@
+package utils
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// withKeys sets the public ID and signing keys for the rest of the test.
+func withKeys(t *testing.T, publicIDKey, jwtKey string) {
+	t.Helper()
+	oldPublicIDKey, oldJWTKey := PublicIDKey, JWTKey
+	PublicIDKey, JWTKey = []byte(publicIDKey), []byte(jwtKey)
+	t.Cleanup(func() { PublicIDKey, JWTKey = oldPublicIDKey, oldJWTKey })
+}
+
+// withNumericIDs sets api.numeric_ids for the rest of the test.
+func withNumericIDs(t *testing.T, enabled bool) {
+	t.Helper()
+	old := viper.Get("api.numeric_ids")
+	viper.Set("api.numeric_ids", enabled)
+	t.Cleanup(func() { viper.Set("api.numeric_ids", old) })
+}
+
+func TestPublicIDRoundTrip(t *testing.T) {
+	withKeys(t, "public id key", "signing key")
+
+	for _, id := range []uint{1, 2, 42, 1 << 31, 1<<63 - 1} {
+		publicID := EncodePublicID(id)
+		if publicID == strconv.FormatUint(uint64(id), 10) {
+			t.Errorf("EncodePublicID(%d) = %q, which is the numeric ID", id, publicID)
+		}
+		got, err := DecodePublicID(publicID)
+		if err != nil || got != id {
+			t.Errorf("DecodePublicID(EncodePublicID(%d)) = %d, %v", id, got, err)
+		}
+	}
+	if EncodePublicID(1) == EncodePublicID(2) {
+		t.Error("different IDs encode to the same public ID")
+	}
+}
+
+func TestPublicIDKey(t *testing.T) {
+	withKeys(t, "public id key", "signing key")
+	publicID := EncodePublicID(7)
+
+	// Rotating the signing key leaves public IDs alone.
+	JWTKey = []byte("rotated signing key")
+	if got := EncodePublicID(7); got != publicID {
+		t.Errorf("rotating the signing key changed the public ID from %q to %q", publicID, got)
+	}
+
+	// Another public ID key makes the old IDs unreadable.
+	PublicIDKey = []byte("another public id key")
+	if id, err := DecodePublicID(publicID); err == nil {
+		t.Errorf("a public ID from another key decoded to %d", id)
+	}
+}
+
+// Without a key, or with one too short to keep IDs opaque, the server must not start.
+func TestValidatePublicIDKey(t *testing.T) {
+	tests := []struct {
+		key string
+		err string
+	}{
+		{"", "api.public_id_key is not set"},
+		{"short key", "api.public_id_key is 9 bytes; use at least 16"},
+		{strings.Repeat("k", MinPublicIDKeyLength), ""},
+	}
+	for _, tt := range tests {
+		err := ValidatePublicIDKey([]byte(tt.key))
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("key %q: %s, want no error", tt.key, err)
+		case tt.err != "" && (err == nil || err.Error() != tt.err):
+			t.Errorf("key %q: %v, want %q", tt.key, err, tt.err)
+		}
+	}
+}
+
+func TestDecodePublicIDRejectsTampering(t *testing.T) {
+	withKeys(t, "public id key", "signing key")
+	publicID := EncodePublicID(7)
+
+	tampered := []byte(publicID)
+	tampered[3] ^= 1
+	for _, s := range []string{string(tampered), publicID[1:], publicID + "A", "", "7", "not base64!"} {
+		if id, err := DecodePublicID(s); err == nil {
+			t.Errorf("DecodePublicID(%q) = %d, want an error", s, id)
+		}
+	}
+}
+
+func TestParsePublicIDNumericIDs(t *testing.T) {
+	withKeys(t, "public id key", "signing key")
+	publicID := EncodePublicID(7)
+
+	tests := []struct {
+		name    string
+		enabled bool
+		in      string
+		want    uint
+		wantErr bool
+	}{
+		{"public ID, numeric IDs on", true, publicID, 7, false},
+		{"public ID, numeric IDs off", false, publicID, 7, false},
+		{"numeric ID, numeric IDs on", true, "7", 7, false},
+		{"numeric ID, numeric IDs off", false, "7", 0, true},
+		{"negative number", true, "-7", 0, true},
+		{"garbage", true, "seven", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withNumericIDs(t, tt.enabled)
+			got, err := ParsePublicID(tt.in)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("ParsePublicID(%q) = %d, %v; want %d, error %v", tt.in, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPublicIDJSON(t *testing.T) {
+	withKeys(t, "public id key", "signing key")
+
+	data, err := json.Marshal(struct {
+		ID PublicID `json:"id"`
+	}{7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"id":"` + EncodePublicID(7) + `"}`; string(data) != want {
+		t.Errorf("marshaled %s, want %s", data, want)
+	}
+
+	for _, enabled := range []bool{true, false} {
+		withNumericIDs(t, enabled)
+		var id PublicID
+		err := json.Unmarshal([]byte(`7`), &id)
+		if enabled && (err != nil || id != 7) {
+			t.Errorf("numeric IDs on: unmarshaling 7 gave %d, %v", id, err)
+		}
+		if !enabled && err == nil {
+			t.Errorf("numeric IDs off: unmarshaling 7 gave %d, want an error", id)
+		}
+	}
+}
@@ -20,4 +20,9 @@ func JsonResponse(w http.ResponseWriter, statusCode int, data interface{}) {
 // ErrorJsonResponse sends a JSON error response.
 func ErrorJsonResponse(w http.ResponseWriter, message string, statusCode int) {
 	JsonResponse(w, statusCode, map[string]string{"error": message})
-}
\ No newline at end of file
+}
+
+// ErrorCodeJsonResponse sends a JSON error response carrying a machine-readable error code.
+func ErrorCodeJsonResponse(w http.ResponseWriter, code string, message string, statusCode int) {
+	JsonResponse(w, statusCode, map[string]string{"error": message, "code": code})
+}
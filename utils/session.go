@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Cookie and header names used by cookie-based sessions.
+const (
+	SessionCookieName = "goshare_session"
+	CSRFCookieName    = "goshare_csrf"
+	CSRFHeaderName    = "X-CSRF-Token"
+)
+
+// CookieAuthEnabled reports whether browser clients may authenticate with a session cookie.
+func CookieAuthEnabled() bool {
+	return viper.GetBool("auth.cookie.enabled")
+}
+
+// SetSessionCookies stores the JWT in an HttpOnly cookie and issues a double-submit CSRF
+// token in a cookie readable by scripts. The CSRF token is returned so it can also be put
+// in the response body.
+func SetSessionCookies(w http.ResponseWriter, token string, ttl time.Duration) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("error generating CSRF token: %w", err)
+	}
+	csrfToken := hex.EncodeToString(raw)
+	secure := viper.GetBool("auth.cookie.secure")
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   int(ttl.Seconds()),
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     CSRFCookieName,
+		Value:    csrfToken,
+		Path:     "/",
+		MaxAge:   int(ttl.Seconds()),
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return csrfToken, nil
+}
+
+// ClearSessionCookies expires the session and CSRF cookies.
+func ClearSessionCookies(w http.ResponseWriter) {
+	for _, name := range []string{SessionCookieName, CSRFCookieName} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Value:    "",
+			Path:     "/",
+			MaxAge:   -1,
+			HttpOnly: name == SessionCookieName,
+			Secure:   viper.GetBool("auth.cookie.secure"),
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+}
+
+// validCSRF checks the double-submit token: the X-CSRF-Token header must match the CSRF cookie.
+// Safe methods are always allowed.
+func validCSRF(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+
+	cookie, err := r.Cookie(CSRFCookieName)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+	header := r.Header.Get(CSRFHeaderName)
+	return subtle.ConstantTimeCompare([]byte(header), []byte(cookie.Value)) == 1
+}
@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSignedTokenRoundTrip(t *testing.T) {
+	withKeys(t, "", "signing key")
+
+	for _, payload := range [][]byte{[]byte("1.2.3"), []byte(`{"a":"b"}`), {}, {0, 0xff, '.'}} {
+		got, err := verifyToken("test", signToken("test", payload))
+		if err != nil || !bytes.Equal(got, payload) {
+			t.Errorf("verifyToken(signToken(%q)) = %q, %v", payload, got, err)
+		}
+	}
+}
+
+func TestSignedTokenRejects(t *testing.T) {
+	withKeys(t, "", "signing key")
+	token := signToken("test", []byte("payload"))
+	encoded, signature, _ := strings.Cut(token, ".")
+	other := signToken("test", []byte("other payload"))
+	otherEncoded, _, _ := strings.Cut(other, ".")
+
+	tests := []struct {
+		name    string
+		purpose string
+		token   string
+	}{
+		{"other purpose", "other", token},
+		{"payload swapped", "test", otherEncoded + "." + signature},
+		{"signature changed", "test", encoded + "." + strings.ToUpper(signature)},
+		{"signature missing", "test", encoded + "."},
+		{"no separator", "test", encoded + signature},
+		{"payload not base64", "test", "!!." + tokenSignature("test", "!!")},
+		{"empty", "test", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if payload, err := verifyToken(tt.purpose, tt.token); err == nil {
+				t.Errorf("verifyToken(%q, %q) = %q, want an error", tt.purpose, tt.token, payload)
+			}
+		})
+	}
+
+	JWTKey = []byte("rotated signing key")
+	if _, err := verifyToken("test", token); err == nil {
+		t.Error("a token signed with the old key was accepted")
+	}
+}
+
+// Every kind of token is signed for its own purpose, so none can stand in for another.
+func TestSignedTokenPurposesAreDistinct(t *testing.T) {
+	purposes := []string{downloadTokenPurpose, uploadGrantPurpose, confirmTokenPurpose}
+	for _, signed := range purposes {
+		token := signToken(signed, []byte("payload"))
+		for _, verified := range purposes {
+			if _, err := verifyToken(verified, token); (err == nil) != (signed == verified) {
+				t.Errorf("token signed for %s, verified for %s: err = %v", signed, verified, err)
+			}
+		}
+	}
+}
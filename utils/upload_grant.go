@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrInvalidUploadGrant is returned for malformed, tampered or expired upload grants.
+var ErrInvalidUploadGrant = errors.New("invalid upload grant")
+
+// UploadGrant authorizes a single upload on behalf of a user, for frontends that must not
+// hold the user's JWT. It is signed rather than stored; the ID is recorded when the grant is
+// used so that it works only once.
+type UploadGrant struct {
+	ID           string    `json:"jti"`
+	UserID       uint      `json:"uid"`
+	MaxSize      int64     `json:"max_size"`
+	ContentTypes []string  `json:"content_types,omitempty"`
+	ExpiresAt    time.Time `json:"exp"`
+}
+
+// AllowsContentType reports whether the grant permits a file of the given content type.
+// A grant without content types permits any.
+func (g *UploadGrant) AllowsContentType(contentType string) bool {
+	if len(g.ContentTypes) == 0 {
+		return true
+	}
+	for _, allowed := range g.ContentTypes {
+		if strings.EqualFold(allowed, contentType) {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateUploadGrant fills in the grant's ID and expiry and returns its signed token.
+func GenerateUploadGrant(grant *UploadGrant, ttl time.Duration) (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	grant.ID = base64.RawURLEncoding.EncodeToString(id)
+	grant.ExpiresAt = time.Now().Add(ttl).Truncate(time.Second)
+
+	payload, err := json.Marshal(grant)
+	if err != nil {
+		return "", err
+	}
+	return signToken(uploadGrantPurpose, payload), nil
+}
+
+// VerifyUploadGrant checks an upload grant token and returns the grant it carries. It does
+// not check whether the grant was already used.
+func VerifyUploadGrant(token string) (*UploadGrant, error) {
+	payload, err := verifyToken(uploadGrantPurpose, token)
+	if err != nil {
+		return nil, ErrInvalidUploadGrant
+	}
+	var grant UploadGrant
+	if err := json.Unmarshal(payload, &grant); err != nil || grant.ID == "" {
+		return nil, ErrInvalidUploadGrant
+	}
+	if !time.Now().Before(grant.ExpiresAt) {
+		return nil, ErrInvalidUploadGrant
+	}
+	return &grant, nil
+}
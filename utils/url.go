@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// AbsoluteURL turns a server-relative path into an absolute URL for links handed to
+// clients. It uses server.external_url when configured. Otherwise it falls back to the
+// request's scheme and Host, honouring X-Forwarded-Proto and X-Forwarded-Host only when
+// the request comes from a trusted proxy. Without external_url the Host header is still
+// client-controlled, so deployments that email links should always configure it.
+func AbsoluteURL(r *http.Request, path string) string {
+	if base := viper.GetString("server.external_url"); base != "" {
+		return strings.TrimRight(base, "/") + path
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	host := r.Host
+
+	if IsTrustedProxy(r) {
+		if proto := firstHeaderValue(r, "X-Forwarded-Proto"); proto == "http" || proto == "https" {
+			scheme = proto
+		}
+		if forwardedHost := firstHeaderValue(r, "X-Forwarded-Host"); forwardedHost != "" {
+			host = forwardedHost
+		}
+	}
+
+	return scheme + "://" + host + path
+}
+
+// IsTrustedProxy reports whether the request's direct peer is in server.trusted_proxies.
+func IsTrustedProxy(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return isTrustedIP(net.ParseIP(host))
+}
+
+// isTrustedIP reports whether ip falls in one of the server.trusted_proxies CIDRs.
+// Bare IP addresses are accepted as single-host ranges.
+func isTrustedIP(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+
+	for _, entry := range viper.GetStringSlice("server.trusted_proxies") {
+		if !strings.Contains(entry, "/") {
+			if trusted := net.ParseIP(entry); trusted != nil && trusted.Equal(ip) {
+				return true
+			}
+			continue
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// firstHeaderValue returns the first comma-separated value of a header, trimmed.
+func firstHeaderValue(r *http.Request, name string) string {
+	value, _, _ := strings.Cut(r.Header.Get(name), ",")
+	return strings.TrimSpace(value)
+}
+
+// ClientIP returns the address of the client that made the request. X-Forwarded-For is
+// only consulted when the direct peer is a trusted proxy; it is then walked from the
+// right, skipping trusted hops, and the first untrusted address is returned. Forwarded
+// headers from untrusted peers are ignored entirely, so clients cannot spoof their IP.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	peer := net.ParseIP(host)
+	if !isTrustedIP(peer) {
+		return host
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		ip := net.ParseIP(hop)
+		if ip == nil {
+			// Unparseable entries can't be trusted, and anything to their left may be forged.
+			break
+		}
+		if !isTrustedIP(ip) {
+			return ip.String()
+		}
+		peer = ip
+	}
+
+	// Every hop was a trusted proxy; the left-most trusted address is the best we know.
+	return peer.String()
+}
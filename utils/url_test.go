@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"crypto/tls"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// withSetting sets a configuration value for the rest of the test.
+func withSetting(t *testing.T, key string, value interface{}) {
+	t.Helper()
+	old := viper.Get(key)
+	viper.Set(key, value)
+	t.Cleanup(func() { viper.Set(key, old) })
+}
+
+// Links in reset emails are built from these headers when external_url is unset, so an
+// attacker who can make the server believe a forged host gets links to their own site.
+func TestAbsoluteURL(t *testing.T) {
+	tests := []struct {
+		name        string
+		externalURL string
+		remoteAddr  string
+		tls         bool
+		headers     map[string]string
+		want        string
+	}{
+		{"external url", "https://share.example.com", "203.0.113.9:1234", false, nil, "https://share.example.com/files/a"},
+		{"external url with trailing slash", "https://share.example.com/", "203.0.113.9:1234", false, nil, "https://share.example.com/files/a"},
+		{"external url beats forwarded headers", "https://share.example.com", "10.0.0.1:1234", false,
+			map[string]string{"X-Forwarded-Host": "evil.example", "X-Forwarded-Proto": "http"}, "https://share.example.com/files/a"},
+		{"request host", "", "203.0.113.9:1234", false, nil, "http://api.internal/files/a"},
+		{"request over tls", "", "203.0.113.9:1234", true, nil, "https://api.internal/files/a"},
+		{"forwarded by trusted proxy", "", "10.0.0.1:1234", false,
+			map[string]string{"X-Forwarded-Host": "share.example.com", "X-Forwarded-Proto": "https"}, "https://share.example.com/files/a"},
+		{"first of several forwarded values", "", "10.0.0.1:1234", false,
+			map[string]string{"X-Forwarded-Host": "share.example.com, proxy.internal", "X-Forwarded-Proto": "https, http"}, "https://share.example.com/files/a"},
+		{"forwarded by untrusted peer", "", "203.0.113.9:1234", false,
+			map[string]string{"X-Forwarded-Host": "evil.example", "X-Forwarded-Proto": "https"}, "http://api.internal/files/a"},
+		{"unknown forwarded scheme", "", "10.0.0.1:1234", false,
+			map[string]string{"X-Forwarded-Proto": "javascript"}, "http://api.internal/files/a"},
+		{"trusted single address", "", "192.0.2.7:1234", false,
+			map[string]string{"X-Forwarded-Host": "share.example.com"}, "http://share.example.com/files/a"},
+		{"trusted ipv6 proxy", "", "[fd00::1]:1234", false,
+			map[string]string{"X-Forwarded-Host": "share.example.com"}, "http://share.example.com/files/a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withSetting(t, "server.external_url", tt.externalURL)
+			withSetting(t, "server.trusted_proxies", []string{"10.0.0.0/8", "192.0.2.7", "fd00::/8"})
+
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Host = "api.internal"
+			r.RemoteAddr = tt.remoteAddr
+			if tt.tls {
+				r.TLS = &tls.ConnectionState{}
+			}
+			for name, value := range tt.headers {
+				r.Header.Set(name, value)
+			}
+
+			if got := AbsoluteURL(r, "/files/a"); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsTrustedProxy(t *testing.T) {
+	withSetting(t, "server.trusted_proxies", []string{"10.0.0.0/8", "192.0.2.7", "not an address", "fd00::/8"})
+
+	tests := []struct {
+		remoteAddr string
+		trusted    bool
+	}{
+		{"10.1.2.3:80", true},
+		{"11.0.0.1:80", false},
+		{"192.0.2.7:80", true},
+		{"192.0.2.8:80", false},
+		{"[fd00::1]:80", true},
+		{"[fe80::1]:80", false},
+		{"10.1.2.3", true},
+		{"garbage", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tt.remoteAddr
+		if got := IsTrustedProxy(r); got != tt.trusted {
+			t.Errorf("IsTrustedProxy(%q) = %v, want %v", tt.remoteAddr, got, tt.trusted)
+		}
+	}
+}
+
+func TestNoTrustedProxies(t *testing.T) {
+	withSetting(t, "server.trusted_proxies", []string{})
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "127.0.0.1:80"
+	if IsTrustedProxy(r) {
+		t.Error("a peer was trusted with no trusted proxies configured")
+	}
+}
@@ -1,9 +1,51 @@
 package utils
 
-import "github.com/go-playground/validator/v10"
+import (
+	"errors"
+	"mime"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// validate is shared by all callers; validator instances cache struct metadata and are
+// safe for concurrent use, so building one per call only wastes allocations.
+var validate = newValidator()
+
+// newValidator creates the validator and registers the custom rules:
+//   - filename: the value is already a safe file name (see SanitizeFileName);
+//   - contenttype: the value is a well-formed MIME type such as "image/png".
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterValidation("filename", func(fl validator.FieldLevel) bool {
+		name := fl.Field().String()
+		return name != "" && SanitizeFileName(name) == name
+	})
+	v.RegisterValidation("contenttype", func(fl validator.FieldLevel) bool {
+		mediaType, _, err := mime.ParseMediaType(fl.Field().String())
+		return err == nil && strings.Count(mediaType, "/") == 1 && !strings.HasSuffix(mediaType, "/")
+	})
+	return v
+}
 
 // ValidateStruct validates a struct based on the `validate` tags.
 func ValidateStruct(s interface{}) error {
-    validate := validator.New()
-    return validate.Struct(s)
-}
\ No newline at end of file
+	return validate.Struct(s)
+}
+
+// ValidateFields validates only the named fields of a struct, for updates that must not fail
+// on stored values they leave alone.
+func ValidateFields(s interface{}, fields ...string) error {
+	return validate.StructPartial(s, fields...)
+}
+
+// ValidateVar validates a single value against a tag such as "max=64".
+func ValidateVar(value interface{}, tag string) error {
+	return validate.Var(value, tag)
+}
+
+// IsValidationError reports whether err came from a failed validation.
+func IsValidationError(err error) bool {
+	var validationErrors validator.ValidationErrors
+	return errors.As(err, &validationErrors)
+}